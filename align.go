@@ -0,0 +1,184 @@
+package mapast
+
+import (
+	"strings"
+	"text/tabwriter"
+)
+
+// Kinds of the blocks whose lines CodeStyled aligns in columns.
+const (
+	alignNone   = 0
+	alignStruct = 1
+	alignValue  = 2
+)
+
+// alignkind returns the kind of the block opened at the end of the line.
+func alignkind(line string) byte {
+	switch {
+	case strings.HasSuffix(line, "struct{") || strings.HasSuffix(line, "struct {"):
+		return alignStruct
+
+	case strings.HasPrefix(line, "var (") || strings.HasPrefix(line, "const ("):
+		return alignValue
+	}
+	return alignNone
+}
+
+// alignline is a line of a block being aligned, split into its cells.
+type alignline struct {
+	cells []string
+}
+
+// namesend returns the position of the space ending the names of a field or
+// of a value spec, or -1 if the line is a single name or an embedded field.
+func namesend(line string, class []byte) int {
+	var depth = 0
+	for i := 0; i < len(line); i++ {
+		if class[i] != styleCode {
+			continue
+		}
+		switch {
+		case isopen(line[i]):
+			depth++
+
+		case isclose(line[i]):
+			depth--
+
+		case line[i] == ' ' && depth == 0 && i > 0 && line[i-1] != ',':
+			return i
+		}
+	}
+	return -1
+}
+
+// assignment returns the position of the = sign of a value spec, or -1.
+func assignment(line string, class []byte) int {
+	var depth = 0
+	for i := 0; i < len(line); i++ {
+		if class[i] != styleCode {
+			continue
+		}
+		switch {
+		case isopen(line[i]):
+			depth++
+
+		case isclose(line[i]):
+			depth--
+
+		case line[i] == '=' && depth == 0 && i > 0 && line[i-1] == ' ' && i+1 < len(line) && line[i+1] == ' ':
+			return i
+		}
+	}
+	return -1
+}
+
+// aligncells splits the line of a block of the kind into the cells aligned in
+// columns: the names, the type and the tag of a field, the names, the type and
+// the values of a value spec.
+func aligncells(kind byte, line string, class []byte) (a alignline) {
+	var code = strings.TrimRight(line, " ")
+	class = class[:len(code)]
+	var names = namesend(code, class)
+	switch {
+	case code == "":
+		a.cells = []string{code}
+
+	case kind == alignStruct:
+		if names < 0 {
+			a.cells = []string{code}
+			break
+		}
+		var typ = strings.TrimLeft(code[names:], " ")
+		var tag = ""
+		if last := len(code) - 1; class[last] == styleString && (code[last] == '`' || code[last] == '"') {
+			var start = last - 1
+			for start > names && !(class[start] == styleString && code[start] == code[last]) {
+				start--
+			}
+			if start > names && code[start-1] == ' ' {
+				typ, tag = strings.TrimSpace(code[names:start]), code[start:]
+			}
+		}
+		a.cells = []string{code[:names], typ}
+		if tag != "" {
+			a.cells = append(a.cells, tag)
+		}
+
+	case kind == alignValue:
+		var eq = assignment(code, class)
+		switch {
+		case names < 0:
+			a.cells = []string{code}
+
+		case eq < 0:
+			a.cells = []string{code[:names], strings.TrimLeft(code[names:], " ")}
+
+		case eq == names+1:
+			a.cells = []string{code[:names], "", code[eq:]}
+
+		default:
+			a.cells = []string{code[:names], strings.TrimSpace(code[names:eq]), code[eq:]}
+		}
+	}
+	return a
+}
+
+// dropempty removes the column at the index from the lines if it is empty in
+// all of them but the ones ending before it, such as the type column of a
+// const block having no types.
+func dropempty(lines []alignline, index int) {
+	for _, a := range lines {
+		if len(a.cells) > index+1 && a.cells[index] != "" {
+			return
+		}
+	}
+	for i := range lines {
+		if len(lines[i].cells) > index+1 {
+			lines[i].cells = append(lines[i].cells[:index], lines[i].cells[index+1:]...)
+		}
+	}
+}
+
+// section adds the line to the section of the block being aligned.
+func (s *styler) section(kind byte, depth int, text string, class []byte) {
+	s.aligned = append(s.aligned, aligncells(kind, text, class))
+	s.aligndepth = depth
+}
+
+// flushsection prints the lines of the section aligned in columns, as
+// text/tabwriter does it for gofmt.
+func (s *styler) flushsection() {
+	if len(s.aligned) == 0 {
+		return
+	}
+	var tabwidth = s.style.TabWidth
+	if tabwidth <= 0 {
+		tabwidth = 8
+	}
+	dropempty(s.aligned, 1)
+	var b strings.Builder
+	var w = tabwriter.NewWriter(&b, 0, tabwidth, 1, ' ', tabwriter.DiscardEmptyColumns|tabwriter.StripEscape)
+	for _, a := range s.aligned {
+		for i, cell := range a.cells {
+			if i > 0 {
+				w.Write([]byte{'\t'})
+			}
+			w.Write([]byte("\xff" + cell + "\xff"))
+		}
+		w.Write([]byte{'\n'})
+	}
+	w.Flush()
+	s.aligned = s.aligned[:0]
+	var lines = strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for _, line := range lines {
+		var indent = strings.Repeat(s.style.Indent, s.aligndepth)
+		line = strings.TrimRight(line, " ")
+		if s.style.ExpandTabs {
+			var indentwidth = width(indent, tabwidth)
+			var class, _, _ = scanline(line, false, false)
+			indent = strings.Repeat(" ", indentwidth)
+			line = expandtabs(line, class, indentwidth, tabwidth)
+		}
+		s.emit(indent + line)
+	}
+}
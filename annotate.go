@@ -0,0 +1,36 @@
+package mapast
+
+// AnnotatedCode generates go source code like Code does, but every line of
+// the output is preceded by a comment line naming the kind of the node that
+// starts the line, such as // <BlocOfCodeIf>. The output is meant for learning
+// the tree model and for debugging conversions.
+func AnnotatedCode(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64) {
+	var atstart = true
+	var pending string
+	code(func(s string) {
+		if len(s) == 0 {
+			atstart = true
+			pending = ""
+			print(s)
+			return
+		}
+		if atstart && pending != "" {
+			print("// <" + pending + ">")
+			print("")
+		}
+		atstart = false
+		pending = ""
+		print(s)
+	}, func(key uint64) bool {
+		if atstart && (pending == "" || pending == "RootMatter" || pending == "FileMatter") {
+			pending = nodename(ast[key])
+		}
+		return false
+	}, ast, iterator, parent)
+}
+
+// PrintAnnotatedCode prints annotated go source code from an abstract syntax
+// tree to a standard error.
+func PrintAnnotatedCode(ast map[uint64][]byte, iterator uint64, parent uint64) {
+	AnnotatedCode(Printer, ast, iterator, parent)
+}
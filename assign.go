@@ -0,0 +1,150 @@
+package mapast
+
+import "errors"
+
+// assignops maps the assignment operators to the AssignStmt kinds used when
+// both sides have the same number of elements.
+var assignops = map[string]byte{
+	"=":   AssignStmtEqual,
+	":=":  AssignStmtColonEq,
+	"&^=": AssignStmtAndNot,
+	"+=":  AssignStmtAdd,
+	"-=":  AssignStmtSub,
+	"*=":  AssignStmtMul,
+	"/=":  AssignStmtQuo,
+	"%=":  AssignStmtRem,
+	"&=":  AssignStmtAnd,
+	"|=":  AssignStmtOr,
+	"^=":  AssignStmtXor,
+	"<<=": AssignStmtShl,
+	">>=": AssignStmtShr,
+}
+
+// BuildAssignStmt builds an AssignStmt and returns it as an ast having the node
+// at key zero. Lhs and rhs are the left and right hand side elements and typ
+// is the optional type of a variable declaration; each of them is an ast
+// having its node at key zero. The operator is one of the assignment
+// operators such as = or +=, = range or := range for the range clause of a for
+// loop, or an empty string for a declaration without values. The kind and the
+// element count of the node are chosen from the operator and the number of
+// elements.
+func BuildAssignStmt(lhs []map[uint64][]byte, operator string, typ map[uint64][]byte, rhs ...map[uint64][]byte) (map[uint64][]byte, error) {
+	if len(lhs) == 0 {
+		return nil, errors.New("mapast: assignment without left hand side")
+	}
+	var kind byte
+	switch op, ok := assignops[operator]; {
+	case operator == "":
+		if len(rhs) > 0 {
+			return nil, errors.New("mapast: values need an assignment operator")
+		}
+		kind = AssignStmtIotaIsLast
+		if typ != nil {
+			kind = AssignStmtTypeIsLast
+		}
+
+	case operator == "= range" || operator == ":= range":
+		if typ != nil || len(rhs) != 1 || len(lhs) > 2 {
+			return nil, errors.New("mapast: range clause needs one or two variables and one expression")
+		}
+		kind = AssignStmtMoreEqualRange
+		if operator == ":= range" {
+			kind = AssignStmtMoreColonEqRange
+		}
+
+	case !ok:
+		return nil, errors.New("mapast: unknown assignment operator " + operator)
+
+	case typ != nil && op != AssignStmtEqual:
+		return nil, errors.New("mapast: only = declarations can have a type")
+
+	case len(rhs) == len(lhs):
+		if op != AssignStmtEqual && op != AssignStmtColonEq && len(lhs) != 1 {
+			return nil, errors.New("mapast: operator " + operator + " needs a single operand on each side")
+		}
+		kind = op
+
+	case len(rhs) == 1 && (op == AssignStmtEqual || op == AssignStmtColonEq):
+		kind = op + AssignStmtMoreEqual
+
+	default:
+		return nil, errors.New("mapast: assignment count mismatch")
+	}
+	var s = subtree{}
+	var elems = append(append([]map[uint64][]byte{}, lhs...), rhs...)
+	for _, elem := range elems {
+		if !Poke(elem, 0) {
+			return nil, errors.New("mapast: assignment element without node at key zero")
+		}
+		s.children = append(s.children, clone(elem, 0))
+	}
+	if typ != nil {
+		var t = clone(typ, 0)
+		if !Is(t.node, RootOfType) {
+			t = subtree{node: RootOfType, children: []subtree{t}}
+		}
+		s.children = append(s.children[:len(lhs)], append([]subtree{t}, s.children[len(lhs):]...)...)
+	}
+	s.node = AssignStmtNode(kind, uint64(len(s.children)))
+	var ast = make(map[uint64][]byte)
+	paste(ast, 0, s)
+	return ast, nil
+}
+
+// identexp builds an ExpressionIdentifier holding the name.
+func identexp(name string) (map[uint64][]byte, error) {
+	if err := ValidIdent(name); err != nil {
+		return nil, err
+	}
+	return map[uint64][]byte{0: ExpressionNode(ExpressionIdentifier, 1), O(0): []byte(name)}, nil
+}
+
+// BuildMultiAssign builds an AssignStmt of several variables from a single
+// expression yielding several values, such as v, err := f(). The names are the
+// left hand side variables, the operator is = or :=. The expression is an ast
+// having its node at key zero.
+func BuildMultiAssign(names []string, operator string, expr map[uint64][]byte) (map[uint64][]byte, error) {
+	if operator != "=" && operator != ":=" {
+		return nil, errors.New("mapast: operator " + operator + " cannot assign several values")
+	}
+	if len(names) < 2 {
+		return nil, errors.New("mapast: several values need several variables")
+	}
+	var lhs []map[uint64][]byte
+	for _, name := range names {
+		var ident, err = identexp(name)
+		if err != nil {
+			return nil, err
+		}
+		lhs = append(lhs, ident)
+	}
+	return BuildAssignStmt(lhs, operator, nil, expr)
+}
+
+// BuildCommaOk builds the comma-ok form of an index expression m[k], a type
+// assertion x.(T) or a receive <-ch, such as v, ok := m[k]. The operator is =
+// or :=. The expression is an ast having an ExpressionIndex, an ExpressionType
+// with a type or a receiving ExpressionArrow node, with one child, at key zero.
+func BuildCommaOk(value string, ok string, operator string, expr map[uint64][]byte) (map[uint64][]byte, error) {
+	var node = expr[0]
+	if !Is(node, Expression) {
+		return nil, errors.New("mapast: comma-ok needs an Expression")
+	}
+	switch Variant(node) {
+	case ExpressionIndex:
+
+	case ExpressionArrow:
+		if Count(node) != 1 {
+			return nil, errors.New("mapast: comma-ok needs a receive, not a send")
+		}
+
+	case ExpressionType:
+		if Count(node) != 2 {
+			return nil, errors.New("mapast: comma-ok needs a type assertion with a type")
+		}
+
+	default:
+		return nil, errors.New("mapast: comma-ok needs an index, a type assertion or a receive")
+	}
+	return BuildMultiAssign([]string{value, ok}, operator, expr)
+}
@@ -0,0 +1,144 @@
+package mapast
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// BinaryVersion is the version of the binary format written by Encode. Decode
+// refuses streams of any other version.
+const BinaryVersion byte = 2
+
+var binaryMagic = []byte("MAST")
+
+// Encode writes the subtree at the iterator position to w using a compact
+// length-prefixed binary format. The stream starts with a magic string, the
+// format version byte and the iterator key, followed by the nodes in depth
+// first order. Every node is a tag (0 for nil, 1 for a string, 2 plus kind
+// number for a node), a length and bytes for strings or the variant and count
+// for nodes, then the number of children and the children themselves.
+func Encode(w io.Writer, ast map[uint64][]byte, iterator uint64) error {
+	if !Poke(ast, iterator) {
+		return errors.New("mapast: no node at the iterator position")
+	}
+	var bw = bufio.NewWriter(w)
+	bw.Write(binaryMagic)
+	bw.WriteByte(BinaryVersion)
+	var buf [binary.MaxVarintLen64]byte
+	var uvarint = func(n uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf[:], n)])
+	}
+	uvarint(iterator)
+	var encode func(uint64)
+	encode = func(iterator uint64) {
+		var node = ast[iterator]
+		if node == nil {
+			uvarint(0)
+		} else if kind := kindof(node); kind < 0 {
+			uvarint(1)
+			uvarint(uint64(len(node)))
+			bw.Write(node)
+		} else {
+			var variant, count = params(node)
+			uvarint(2 + uint64(kind))
+			uvarint(variant)
+			uvarint(count)
+		}
+		var n uint64
+		for Poke(ast, O(iterator)+n) {
+			n++
+		}
+		uvarint(n)
+		for i := uint64(0); i < n; i++ {
+			encode(O(iterator) + i)
+		}
+	}
+	encode(iterator)
+	return bw.Flush()
+}
+
+// Decode reads a stream written by Encode and returns a new ast holding the
+// subtree at the same key it was encoded from.
+func Decode(r io.Reader) (map[uint64][]byte, error) {
+	var br = bufio.NewReader(r)
+	var head = make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, err
+	}
+	if string(head[:len(binaryMagic)]) != string(binaryMagic) {
+		return nil, errors.New("mapast: not a binary tree stream")
+	}
+	if head[len(binaryMagic)] != BinaryVersion {
+		return nil, errors.New("mapast: unsupported binary format version")
+	}
+	var ast = make(map[uint64][]byte)
+	iterator, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	var decode func(uint64) error
+	decode = func(iterator uint64) error {
+		tag, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case 0:
+			ast[iterator] = nil
+
+		case 1:
+			n, err := binary.ReadUvarint(br)
+			if err != nil {
+				return err
+			}
+			if n > MaxSubnodes {
+				return errors.New("mapast: string too long")
+			}
+			var s = make([]byte, n)
+			if _, err := io.ReadFull(br, s); err != nil {
+				return err
+			}
+			if Which(s) != nil {
+				return errors.New("mapast: string starts with NodeTag")
+			}
+			ast[iterator] = s
+
+		default:
+			variant, err := binary.ReadUvarint(br)
+			if err != nil {
+				return err
+			}
+			count, err := binary.ReadUvarint(br)
+			if err != nil {
+				return err
+			}
+			var node []byte
+			if tag-2 < uint64(len(kinds)) {
+				node = makenode(int(tag-2), variant, count)
+			}
+			if node == nil {
+				return errors.New("mapast: invalid node in binary stream")
+			}
+			ast[iterator] = node
+		}
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := decode(O(iterator) + i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := decode(iterator); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return ast, nil
+}
@@ -0,0 +1,369 @@
+// Package build assembles mapast trees from fluent constructors.
+//
+// A file is built by chaining calls, such as
+//
+//	build.File("main").Import("fmt").Func("main").Body(build.Call("fmt.Println", build.Str("hi")))
+//
+// and the resulting tree is returned by Tree. Expressions and statements are
+// Nodes. Errors, such as invalid identifiers, are carried along by the Nodes
+// and the builders, and the first one is returned by Tree.
+package build
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+	"strconv"
+	"strings"
+)
+
+// Node is an expression or a statement being built.
+type Node struct {
+	tree map[uint64][]byte
+	err  error
+}
+
+// Tree returns the node as an ast having the node at key zero, or the first
+// error met while building it.
+func (n Node) Tree() (map[uint64][]byte, error) {
+	if n.err != nil {
+		return nil, n.err
+	}
+	return n.tree, nil
+}
+
+// fail returns a Node carrying the error.
+func fail(err error) Node {
+	return Node{err: err}
+}
+
+// node returns a Node made of the node with the children, or a Node carrying
+// the first error of the children.
+func node(n []byte, children ...Node) Node {
+	var tree = map[uint64][]byte{0: n}
+	for i, child := range children {
+		if child.err != nil {
+			return child
+		}
+		mapast.CopySubtree(child.tree, 0, tree, mapast.O(0)+uint64(i))
+	}
+	return Node{tree: tree}
+}
+
+// leaf returns a Node made of the string.
+func leaf(s string) Node {
+	return Node{tree: map[uint64][]byte{0: []byte(s)}}
+}
+
+// name returns the possibly dot separated name as a string, or as a chain of
+// ExpressionDot nodes.
+func name(s string) Node {
+	var parts = strings.Split(s, ".")
+	if len(parts) == 1 {
+		if err := mapast.ValidIdent(s); err != nil {
+			return fail(err)
+		}
+		return leaf(s)
+	}
+	var tree = make(map[uint64][]byte)
+	if err := mapast.BuildSelector(tree, 0, parts...); err != nil {
+		return fail(err)
+	}
+	return Node{tree: tree}
+}
+
+// Ident returns the identifier, or the selector expression if the name is dot
+// separated, such as os.Args.
+func Ident(s string) Node {
+	var tree = make(map[uint64][]byte)
+	if err := mapast.BuildSelector(tree, 0, strings.Split(s, ".")...); err != nil {
+		return fail(err)
+	}
+	return Node{tree: tree}
+}
+
+// Str returns the interpreted string literal of the string.
+func Str(s string) Node {
+	return node(mapast.ExpressionNode(mapast.ExpressionIdentifier, 1), Node{tree: map[uint64][]byte{0: mapast.QuoteString(s)}})
+}
+
+// Int returns the decimal integer literal of the number.
+func Int(n int) Node {
+	return node(mapast.ExpressionNode(mapast.ExpressionIdentifier, 1), leaf(strconv.Itoa(n)))
+}
+
+// Type returns the type expression of the type name. The name can be dot
+// separated and prefixed by any number of * and [] for pointers and slices,
+// such as []*os.File.
+func Type(s string) Node {
+	switch {
+	case strings.HasPrefix(s, "*"):
+		return node(mapast.ExpressionNode(mapast.ExpressionMul, 1), Type(s[1:]))
+
+	case strings.HasPrefix(s, "[]"):
+		return node(mapast.ExpressionNode(mapast.ExpressionSliceType, 1), Type(s[2:]))
+	}
+	return name(s)
+}
+
+// Call returns the call of the possibly dot separated function name with the
+// arguments.
+func Call(fun string, args ...Node) Node {
+	return node(mapast.ExpressionNode(mapast.ExpressionCall, uint64(len(args)+1)), append([]Node{name(fun)}, args...)...)
+}
+
+// binaryops maps the binary operators to the Expression kinds.
+var binaryops = map[string]byte{
+	"||": mapast.ExpressionOrOr,
+	"&&": mapast.ExpressionAndAnd,
+	"==": mapast.ExpressionEqual,
+	"!=": mapast.ExpressionNotEq,
+	"<":  mapast.ExpressionLessThan,
+	"<=": mapast.ExpressionLessEq,
+	">=": mapast.ExpressionGrtEq,
+	">":  mapast.ExpressionGrtThan,
+	"+":  mapast.ExpressionPlus,
+	"-":  mapast.ExpressionMinus,
+	"|":  mapast.ExpressionOr,
+	"^":  mapast.ExpressionXor,
+	"*":  mapast.ExpressionMul,
+	"/":  mapast.ExpressionDiv,
+	"%":  mapast.ExpressionMod,
+	"&":  mapast.ExpressionAnd,
+	"&^": mapast.ExpressionAndNot,
+	"<<": mapast.ExpressionLSh,
+	">>": mapast.ExpressionRSh,
+}
+
+// precedence returns the precedence of the binary operation of the node, from
+// one for || to five for the multiplicative operators, or zero if it is not
+// a binary operation.
+func precedence(root []byte) int {
+	if !mapast.Is(root, mapast.Expression) || mapast.Count(root) != 2 {
+		return 0
+	}
+	switch mapast.Variant(root) {
+	case mapast.ExpressionOrOr:
+		return 1
+
+	case mapast.ExpressionAndAnd:
+		return 2
+
+	case mapast.ExpressionEqual, mapast.ExpressionNotEq, mapast.ExpressionLessThan, mapast.ExpressionLessEq,
+		mapast.ExpressionGrtEq, mapast.ExpressionGrtThan:
+		return 3
+
+	case mapast.ExpressionPlus, mapast.ExpressionMinus, mapast.ExpressionOr, mapast.ExpressionXor:
+		return 4
+
+	case mapast.ExpressionMul, mapast.ExpressionDiv, mapast.ExpressionMod, mapast.ExpressionAnd,
+		mapast.ExpressionAndNot, mapast.ExpressionLSh, mapast.ExpressionRSh:
+		return 5
+	}
+	return 0
+}
+
+// Binary returns the binary expression of the operands, such as x + y for the
+// operator +. An operand binding less tightly than the operator, or as tightly
+// on the right, is wrapped in round brackets, as mapast.Parenthesize would, so
+// the code printed has the meaning of the tree.
+func Binary(x Node, op string, y Node) Node {
+	var kind, ok = binaryops[op]
+	if !ok {
+		return fail(errors.New("build: unknown binary operator " + op))
+	}
+	var outer = precedence(mapast.ExpressionNode(kind, 2))
+	if inner := precedence(x.tree[0]); inner > 0 && inner < outer {
+		x = node(mapast.ExpressionNode(mapast.ExpressionBrackets, 1), x)
+	}
+	if inner := precedence(y.tree[0]); inner > 0 && inner <= outer {
+		y = node(mapast.ExpressionNode(mapast.ExpressionBrackets, 1), y)
+	}
+	return node(mapast.ExpressionNode(kind, 2), x, y)
+}
+
+// assign returns the AssignStmt built by mapast.BuildAssignStmt.
+func assign(lhs Node, op string, typ map[uint64][]byte, rhs ...Node) Node {
+	var trees []map[uint64][]byte
+	for _, n := range append([]Node{lhs}, rhs...) {
+		if n.err != nil {
+			return n
+		}
+		trees = append(trees, n.tree)
+	}
+	var tree, err = mapast.BuildAssignStmt(trees[:1], op, typ, trees[1:]...)
+	if err != nil {
+		return fail(err)
+	}
+	return Node{tree: tree}
+}
+
+// Assign returns the assignment statement of the value to the left hand side,
+// using the assignment operator such as = or +=.
+func Assign(lhs Node, op string, value Node) Node {
+	return assign(lhs, op, nil, value)
+}
+
+// Define returns the short variable declaration of the name, such as x := 1.
+func Define(s string, value Node) Node {
+	return assign(Ident(s), ":=", nil, value)
+}
+
+// Var returns the variable declaration of the name. The type name is optional
+// if the value is given, and the value is optional if the type name is given.
+func Var(s string, typ string, value *Node) Node {
+	var t map[uint64][]byte
+	if typ != "" {
+		var n = Type(typ)
+		if n.err != nil {
+			return n
+		}
+		t = n.tree
+	}
+	var stmt Node
+	if value == nil {
+		stmt = assign(name(s), "", t)
+	} else {
+		stmt = assign(name(s), "=", t, *value)
+	}
+	return node(mapast.VarDefStmtNode(mapast.VarDefStmtVar), stmt)
+}
+
+// Return returns the return statement of the values.
+func Return(values ...Node) Node {
+	return node(mapast.ReturnStmt, values...)
+}
+
+// If returns the if statement executing the body if the condition holds.
+func If(cond Node, body ...Node) Node {
+	var brackets = node(mapast.ExpressionNode(mapast.ExpressionBrackets, 1), cond)
+	return node(mapast.BlocOfCodeNode(mapast.BlocOfCodeIf, 1), append([]Node{brackets}, body...)...)
+}
+
+// FileBuilder builds a file.
+type FileBuilder struct {
+	pkg     string
+	imports []string
+	decls   []*FuncBuilder
+	err     error
+}
+
+// File starts building a file of the package.
+func File(pkg string) *FileBuilder {
+	var f = &FileBuilder{pkg: pkg}
+	f.err = mapast.ValidIdent(pkg)
+	return f
+}
+
+// Import adds an import of the unquoted path to the file.
+func (f *FileBuilder) Import(path string) *FileBuilder {
+	f.imports = append(f.imports, path)
+	return f
+}
+
+// Decl appends the top level declaration to the file, such as the one returned
+// by Var.
+func (f *FileBuilder) Decl(decl Node) *FileBuilder {
+	f.decls = append(f.decls, &FuncBuilder{file: f, decl: &decl})
+	return f
+}
+
+// Var appends the variable declaration of the name with the value to the file.
+func (f *FileBuilder) Var(s string, value Node) *FileBuilder {
+	return f.Decl(Var(s, "", &value))
+}
+
+// Func appends the function of the name to the file. The function is
+// completed by calling the methods of the returned FuncBuilder.
+func (f *FileBuilder) Func(s string) *FuncBuilder {
+	var fn = &FuncBuilder{file: f, name: s}
+	f.decls = append(f.decls, fn)
+	return fn
+}
+
+// Tree returns the file as the first FileMatter of a RootMatter tree, or the
+// first error met while building it.
+func (f *FileBuilder) Tree() (map[uint64][]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var tree = map[uint64][]byte{0: mapast.RootMatter}
+	var file = mapast.O(0)
+	tree[file] = mapast.FileMatterNode(1)
+	tree[mapast.O(file)] = mapast.PackageDefNode(mapast.PackageDefNormal)
+	tree[mapast.O(mapast.O(file))] = []byte(f.pkg)
+	for i, decl := range f.decls {
+		var n = decl.build()
+		if n.err != nil {
+			return nil, n.err
+		}
+		mapast.CopySubtree(n.tree, 0, tree, mapast.O(file)+uint64(i)+1)
+	}
+	for _, path := range f.imports {
+		if err := mapast.AddImport(tree, file, path, ""); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// FuncBuilder builds a function of a file.
+type FuncBuilder struct {
+	file    *FileBuilder
+	decl    *Node
+	name    string
+	recv    []Node
+	params  []Node
+	results []Node
+	body    []Node
+}
+
+// field returns the TypedIdent of the name and the type name. The name can be
+// empty.
+func field(s string, typ string) Node {
+	var t = node(mapast.RootOfType, Type(typ))
+	if s == "" {
+		return node(mapast.TypedIdentNode(mapast.TypedIdentNormal), t)
+	}
+	return node(mapast.TypedIdentNode(mapast.TypedIdentNormal), name(s), t)
+}
+
+// Recv makes the function a method of the receiver of the name and the type
+// name, such as r and *T.
+func (fn *FuncBuilder) Recv(s string, typ string) *FuncBuilder {
+	fn.recv = []Node{field(s, typ)}
+	return fn
+}
+
+// Param appends the parameter of the name and the type name to the function.
+func (fn *FuncBuilder) Param(s string, typ string) *FuncBuilder {
+	fn.params = append(fn.params, field(s, typ))
+	return fn
+}
+
+// Returns appends unnamed results of the type names to the function.
+func (fn *FuncBuilder) Returns(types ...string) *FuncBuilder {
+	for _, typ := range types {
+		fn.results = append(fn.results, field("", typ))
+	}
+	return fn
+}
+
+// Body appends the statements to the body of the function, and returns the
+// FileBuilder of the file to continue with.
+func (fn *FuncBuilder) Body(stmts ...Node) *FileBuilder {
+	fn.body = append(fn.body, stmts...)
+	return fn.file
+}
+
+// build returns the ToplevFunc of the function, or the declaration.
+func (fn *FuncBuilder) build() Node {
+	if fn.decl != nil {
+		return *fn.decl
+	}
+	var children = []Node{name(fn.name)}
+	children = append(children, fn.recv...)
+	children = append(children, fn.params...)
+	children = append(children, fn.results...)
+	children = append(children, node(mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0), fn.body...))
+	return node(mapast.ToplevFuncNode(fn.recv != nil, uint64(len(fn.params))), children...)
+}
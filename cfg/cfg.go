@@ -0,0 +1,494 @@
+// Package cfg builds the control flow graph of a function of a mapast tree.
+//
+// The graph is made of basic blocks, sequences of statements executed one
+// after another, connected by the edges control can take between them. It is
+// built from the structure of the BlocOfCode, BranchStmt, LblGotoCnt and
+// ReturnStmt nodes alone, so every condition is assumed to be able to go
+// either way. The bodies of function literals are not a part of the graph of
+// the function containing them; build their own graphs from their ClosureExp
+// keys.
+package cfg
+
+import (
+	"github.com/go-li/mapast"
+	"strconv"
+	"strings"
+)
+
+// Block is a basic block.
+type Block struct {
+	// Index is the index of the block in the Blocks of its graph.
+	Index int
+	// Nodes are the keys of the statements of the block and of the header
+	// entries of the compound statements evaluated in it, such as the
+	// condition of an if or the post statement of a for, in the order of
+	// execution.
+	Nodes []uint64
+	// Succs are the blocks control can go to at the end of the block. A block
+	// ending with a return or with a call to panic has none.
+	Succs []*Block
+	// Live is set if the block is reachable from the entry block.
+	Live bool
+}
+
+// Graph is the control flow graph of a function.
+type Graph struct {
+	// Blocks are the blocks of the graph, the entry block first.
+	Blocks []*Block
+	// End is the block reached when the execution runs off the end of the
+	// body, a separate exit block added last. It has no nodes, and it is not
+	// live if every path ends with a return, a call to panic or a loop never
+	// left.
+	End *Block
+	// In maps the keys of the nodes of the blocks to the blocks.
+	In map[uint64]*Block
+}
+
+// Returns reports whether the function can only be left by a return
+// statement or a panic, which the compiler requires from a function having
+// results.
+func (g *Graph) Returns() bool {
+	return !g.End.Live
+}
+
+// Format returns a listing of the blocks of the graph with their statements
+// and their successors, for debugging.
+func (g *Graph) Format(ast map[uint64][]byte) string {
+	var b strings.Builder
+	for _, block := range g.Blocks {
+		b.WriteString("block " + strconv.Itoa(block.Index))
+		if !block.Live {
+			b.WriteString(" (dead)")
+		}
+		b.WriteString("\n")
+		for _, key := range block.Nodes {
+			var s = string(ast[key])
+			if mapast.Which(ast[key]) != nil {
+				s = strings.Replace(mapast.SprintNode(ast, key), "\n", "; ", -1)
+			}
+			b.WriteString("\t" + s + "\n")
+		}
+		if len(block.Succs) > 0 {
+			b.WriteString("\tsuccs:")
+			for _, succ := range block.Succs {
+				b.WriteString(" " + strconv.Itoa(succ.Index))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// targets are the blocks the branch statements of a compound statement go
+// to, linked to the targets of the enclosing one.
+type targets struct {
+	tail  *targets
+	label string
+	// brk, cont and fall are the targets of break, continue and fallthrough,
+	// nil if the statement has none.
+	brk, cont, fall *Block
+}
+
+// builder builds a graph.
+type builder struct {
+	ast     map[uint64][]byte
+	g       *Graph
+	current *Block
+	targets *targets
+	// labels maps the label names to the blocks starting at them, and label
+	// is the name of the label of the statement being built.
+	labels map[string]*Block
+	label  string
+}
+
+// block creates a new block.
+func (b *builder) block() *Block {
+	var block = &Block{Index: len(b.g.Blocks)}
+	b.g.Blocks = append(b.g.Blocks, block)
+	return block
+}
+
+// add adds the node at the key to the current block.
+func (b *builder) add(key uint64) {
+	b.current.Nodes = append(b.current.Nodes, key)
+	b.g.In[key] = b.current
+}
+
+// jump adds an edge from the current block to the target, and continues in
+// a new unreachable block.
+func (b *builder) jump(target *Block) {
+	b.current.Succs = append(b.current.Succs, target)
+	b.current = b.block()
+}
+
+// labeled returns the block starting at the label of the name.
+func (b *builder) labeled(name string) *Block {
+	if block, ok := b.labels[name]; ok {
+		return block
+	}
+	var block = b.block()
+	b.labels[name] = block
+	return block
+}
+
+// push opens the targets of a compound statement, taking over the label of
+// the statement.
+func (b *builder) push(brk, cont, fall *Block) {
+	b.targets = &targets{tail: b.targets, label: b.label, brk: brk, cont: cont, fall: fall}
+	b.label = ""
+}
+
+// pop closes the targets of a compound statement.
+func (b *builder) pop() {
+	b.targets = b.targets.tail
+}
+
+// branch returns the target of a break, continue or fallthrough statement,
+// selected by the function, with the label or without one.
+func (b *builder) branch(label string, target func(t *targets) *Block) *Block {
+	for t := b.targets; t != nil; t = t.tail {
+		if block := target(t); block != nil && (label == "" || t.label == label) {
+			return block
+		}
+	}
+	return nil
+}
+
+// segments splits the header entries of the BlocOfCode at the key into the
+// parts separated by semicolons, such as the initialization, condition and
+// post statement of a for loop.
+func segments(ast map[uint64][]byte, key uint64) [][]uint64 {
+	var parts = [][]uint64{nil}
+	var children = mapast.ChildKeys(ast, key)
+	for _, child := range children[:mapast.Count(ast[key])] {
+		if mapast.Is(ast[child], mapast.BranchStmt) && mapast.Variant(ast[child]) == mapast.BranchStmtSemi {
+			parts = append(parts, nil)
+		} else {
+			parts[len(parts)-1] = append(parts[len(parts)-1], child)
+		}
+	}
+	return parts
+}
+
+// isrange reports whether the header of the for loop at the key ranges over
+// a value.
+func isrange(ast map[uint64][]byte, key uint64) bool {
+	if mapast.Variant(ast[key]) == mapast.BlocOfCodeForRange {
+		return true
+	}
+	if mapast.Count(ast[key]) != 1 || !mapast.Is(ast[mapast.O(key)], mapast.AssignStmt) {
+		return false
+	}
+	var v = mapast.Variant(ast[mapast.O(key)])
+	return v == mapast.AssignStmtMoreEqualRange || v == mapast.AssignStmtMoreColonEqRange
+}
+
+// ispanic reports whether the statement at the key is a call to panic.
+func ispanic(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	if !mapast.Is(node, mapast.Expression) || mapast.Variant(node) != mapast.ExpressionCall {
+		return false
+	}
+	var fun = mapast.O(key)
+	if mapast.Is(ast[fun], mapast.Expression) && mapast.Variant(ast[fun]) == mapast.ExpressionIdentifier {
+		fun = mapast.O(fun)
+	}
+	return string(ast[fun]) == "panic"
+}
+
+// stmts builds the statements of the BlocOfCode at the key, following its
+// header entries.
+func (b *builder) stmts(key uint64) {
+	var children = mapast.ChildKeys(b.ast, key)
+	for i := int(mapast.Count(b.ast[key])); i < len(children); i++ {
+		var child = children[i]
+		var node = b.ast[child]
+		if mapast.Is(node, mapast.BlocOfCode) && mapast.Variant(node) == mapast.BlocOfCodeIfElse {
+			b.label = ""
+			i += b.ifstmt(children[i:]) - 1
+			continue
+		}
+		b.stmt(child)
+	}
+}
+
+// stmt builds the statement at the key.
+func (b *builder) stmt(key uint64) {
+	var node = b.ast[key]
+	var label = b.label
+	b.label = ""
+	switch {
+	case mapast.Is(node, mapast.CommentRow):
+		b.label = label
+
+	case mapast.Is(node, mapast.BranchStmt):
+		var target *Block
+		switch mapast.Variant(node) {
+		case mapast.BranchStmtSemi:
+			b.label = label
+			return
+
+		case mapast.BranchStmtBreak:
+			target = b.branch("", func(t *targets) *Block { return t.brk })
+
+		case mapast.BranchStmtContinue:
+			target = b.branch("", func(t *targets) *Block { return t.cont })
+
+		case mapast.BranchStmtFallthrough:
+			target = b.branch("", func(t *targets) *Block { return t.fall })
+		}
+		b.add(key)
+		if target != nil {
+			b.jump(target)
+		} else {
+			b.current = b.block()
+		}
+
+	case mapast.Is(node, mapast.LblGotoCnt):
+		var name = string(b.ast[mapast.O(key)])
+		var target *Block
+		switch mapast.Variant(node) {
+		case mapast.LblGotoCntLabel:
+			var block = b.labeled(name)
+			b.current.Succs = append(b.current.Succs, block)
+			b.current = block
+			b.label = name
+			return
+
+		case mapast.LblGotoCntGoto:
+			target = b.labeled(name)
+
+		case mapast.LblGotoCntBreak:
+			target = b.branch(name, func(t *targets) *Block { return t.brk })
+
+		case mapast.LblGotoCntContinue:
+			target = b.branch(name, func(t *targets) *Block { return t.cont })
+		}
+		b.add(key)
+		if target != nil {
+			b.jump(target)
+		} else {
+			b.current = b.block()
+		}
+
+	case mapast.Is(node, mapast.ReturnStmt), ispanic(b.ast, key):
+		b.add(key)
+		b.current = b.block()
+
+	case mapast.Is(node, mapast.BlocOfCode):
+		b.label = label
+		switch mapast.Variant(node) {
+		case mapast.BlocOfCodeIf, mapast.BlocOfCodeIfElse:
+			b.label = ""
+			b.ifstmt([]uint64{key})
+
+		case mapast.BlocOfCodeFor, mapast.BlocOfCodeForRange:
+			b.forstmt(key)
+
+		case mapast.BlocOfCodeSwitch, mapast.BlocOfCodeTypeSwitch:
+			b.switchstmt(key)
+
+		case mapast.BlocOfCodeSelect:
+			b.selectstmt(key)
+
+		default:
+			b.label = ""
+			b.stmts(key)
+		}
+
+	default:
+		b.add(key)
+	}
+}
+
+// ifstmt builds the if statement at the first key of the chain, and its else
+// branches following it, the siblings of the if. It returns the number of
+// keys of the chain it built.
+func (b *builder) ifstmt(chain []uint64) int {
+	var key = chain[0]
+	for _, part := range segments(b.ast, key) {
+		for _, entry := range part {
+			b.add(entry)
+		}
+	}
+	var then, done = b.block(), b.block()
+	var otherwise = done
+	var n = 1
+	if mapast.Variant(b.ast[key]) == mapast.BlocOfCodeIfElse && len(chain) > 1 {
+		otherwise = b.block()
+	}
+	b.current.Succs = append(b.current.Succs, then, otherwise)
+	b.current = then
+	b.stmts(key)
+	b.current.Succs = append(b.current.Succs, done)
+	if otherwise != done {
+		b.current = otherwise
+		if v := mapast.Variant(b.ast[chain[1]]); v == mapast.BlocOfCodeIf || v == mapast.BlocOfCodeIfElse {
+			n += b.ifstmt(chain[1:])
+		} else {
+			b.stmts(chain[1])
+			n++
+		}
+		b.current.Succs = append(b.current.Succs, done)
+	}
+	b.current = done
+	return n
+}
+
+// forstmt builds the for loop at the key.
+func (b *builder) forstmt(key uint64) {
+	var init, cond, post []uint64
+	switch parts := segments(b.ast, key); {
+	case isrange(b.ast, key), len(parts) == 1:
+		cond = parts[0]
+
+	case len(parts) == 3:
+		init, cond, post = parts[0], parts[1], parts[2]
+	}
+	for _, entry := range init {
+		b.add(entry)
+	}
+	var loop, body, done = b.block(), b.block(), b.block()
+	var cont = loop
+	if len(post) > 0 {
+		cont = b.block()
+	}
+	b.current.Succs = append(b.current.Succs, loop)
+	b.current = loop
+	for _, entry := range cond {
+		b.add(entry)
+	}
+	loop.Succs = append(loop.Succs, body)
+	if len(cond) > 0 {
+		loop.Succs = append(loop.Succs, done)
+	}
+	b.current = body
+	b.push(done, cont, nil)
+	b.stmts(key)
+	b.pop()
+	b.current.Succs = append(b.current.Succs, cont)
+	if cont != loop {
+		b.current = cont
+		for _, entry := range post {
+			b.add(entry)
+		}
+		b.current.Succs = append(b.current.Succs, loop)
+	}
+	b.current = done
+}
+
+// clauses returns the keys of the clauses of the switch or select statement
+// at the key, and the index of its default clause, or -1 if it has none.
+func clauses(ast map[uint64][]byte, key uint64) ([]uint64, int) {
+	var keys []uint64
+	var def = -1
+	for _, child := range mapast.ChildKeys(ast, key)[mapast.Count(ast[key]):] {
+		var node = ast[child]
+		if !mapast.Is(node, mapast.BlocOfCode) {
+			continue
+		}
+		if v := mapast.Variant(node); v == mapast.BlocOfCodeDefault || v == mapast.BlocOfCodeCommunicateDefault {
+			def = len(keys)
+		}
+		keys = append(keys, child)
+	}
+	return keys, def
+}
+
+// switchstmt builds the switch or type switch statement at the key. The case
+// clauses are tested in order, the default one last.
+func (b *builder) switchstmt(key uint64) {
+	for _, part := range segments(b.ast, key) {
+		for _, entry := range part {
+			b.add(entry)
+		}
+	}
+	var keys, def = clauses(b.ast, key)
+	var bodies = make([]*Block, len(keys))
+	for i := range keys {
+		bodies[i] = b.block()
+	}
+	var done = b.block()
+	for i, clause := range keys {
+		if i == def {
+			continue
+		}
+		var test = b.block()
+		b.current.Succs = append(b.current.Succs, test)
+		b.current = test
+		for _, entry := range mapast.ChildKeys(b.ast, clause)[:mapast.Count(b.ast[clause])] {
+			b.add(entry)
+		}
+		b.current.Succs = append(b.current.Succs, bodies[i])
+	}
+	if def >= 0 {
+		b.current.Succs = append(b.current.Succs, bodies[def])
+	} else {
+		b.current.Succs = append(b.current.Succs, done)
+	}
+	var label = b.label
+	for i, clause := range keys {
+		var fall *Block
+		if i+1 < len(keys) {
+			fall = bodies[i+1]
+		}
+		b.current = bodies[i]
+		b.label = label
+		b.push(done, nil, fall)
+		b.stmts(clause)
+		b.pop()
+		b.current.Succs = append(b.current.Succs, done)
+	}
+	b.current = done
+	b.label = ""
+}
+
+// selectstmt builds the select statement at the key. A select without
+// clauses blocks forever.
+func (b *builder) selectstmt(key uint64) {
+	var keys, _ = clauses(b.ast, key)
+	var done = b.block()
+	var from = b.current
+	var label = b.label
+	for _, clause := range keys {
+		var body = b.block()
+		from.Succs = append(from.Succs, body)
+		b.current = body
+		for _, entry := range mapast.ChildKeys(b.ast, clause)[:mapast.Count(b.ast[clause])] {
+			b.add(entry)
+		}
+		b.label = label
+		b.push(done, nil, nil)
+		b.stmts(clause)
+		b.pop()
+		b.current.Succs = append(b.current.Succs, done)
+	}
+	b.current = done
+	b.label = ""
+}
+
+// Build builds the control flow graph of the function at the key, either
+// a ToplevFunc or a ClosureExp. The graph of a function without a body has
+// an entry block only, leading to the end.
+func Build(ast map[uint64][]byte, key uint64) *Graph {
+	var b = &builder{ast: ast, g: &Graph{In: make(map[uint64]*Block)}, labels: make(map[string]*Block)}
+	b.current = b.block()
+	var children = mapast.ChildKeys(ast, key)
+	if n := len(children); n > 0 && mapast.Is(ast[children[n-1]], mapast.BlocOfCode) {
+		b.stmts(children[n-1])
+	}
+	b.g.End = b.block()
+	b.current.Succs = append(b.current.Succs, b.g.End)
+	var mark func(block *Block)
+	mark = func(block *Block) {
+		if block.Live {
+			return
+		}
+		block.Live = true
+		for _, succ := range block.Succs {
+			mark(succ)
+		}
+	}
+	mark(b.g.Blocks[0])
+	return b.g
+}
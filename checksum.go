@@ -0,0 +1,121 @@
+package mapast
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ChecksumPrefix starts the trailer comment written by CodeChecksum. The rest
+// of the trailer line is the hexadecimal SHA-256 hash of the canonical
+// encoding of the tree printed above it.
+const ChecksumPrefix = "// mapast:checksum sha256:"
+
+// checksum returns the hexadecimal hash of the canonical encoding of the
+// subtree at the key. The encoding is the one of Hash, except that round
+// brackets are left out, being implied by the shape of the tree, and so are
+// the number of newlines ending a file and the comments, which the printer
+// does not always put back where the converter found them. So a tree and the
+// tree converted from its printed code encode the same. The directives and
+// the // +build lines are hashed after the tree, in the order they are found,
+// since they change the build.
+func checksum(ast map[uint64][]byte, key uint64) string {
+	var h = sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	var directives []string
+	var walk func(uint64)
+	walk = func(key uint64) {
+		var node = ast[key]
+		for Is(node, Expression) && Variant(node) == ExpressionBrackets && Count(node) == 1 {
+			key = O(key)
+			node = ast[key]
+		}
+		if Is(node, FileMatter) {
+			node = FileMatterNode(0)
+		}
+		if node == nil {
+			h.Write([]byte{0})
+		} else {
+			h.Write([]byte{1})
+			h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(node)))])
+			h.Write(node)
+		}
+		var children = ChildKeys(ast, key)
+		var n int
+		for _, child := range children {
+			if !Is(ast[child], CommentRow) {
+				n++
+			}
+		}
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(n))])
+		for _, child := range children {
+			if !Is(ast[child], CommentRow) {
+				walk(child)
+			} else if text := strings.TrimSpace(string(ast[O(child)])); IsDirective(text) ||
+				strings.HasPrefix(text, "// +build") {
+				directives = append(directives, text)
+			}
+		}
+	}
+	walk(key)
+	h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(directives)))])
+	for _, text := range directives {
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(text)))])
+		h.Write([]byte(text))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CodeChecksum generates go source code from an abstract syntax tree like Code
+// does, making sure the code ends with a newline, and appends a trailer
+// comment line holding the hash of the canonical encoding of the tree at the
+// iterator. The trailer lets pipelines detect manual edits of generated files
+// using VerifyChecksum. Only the tree and its directives are hashed, without
+// the other comments, so changes of the formatting or of the comments alone
+// are not reported. The tree is expected to be shaped like the trees of
+// convert.Parse, so the tree converted from the code encodes the same.
+func CodeChecksum(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64) {
+	var code = sprint(ast, iterator, parent)
+	printlines(print, code)
+	if len(code) > 0 && code[len(code)-1] != '\n' {
+		print("")
+	}
+	print(ChecksumPrefix + checksum(ast, iterator))
+	print("")
+}
+
+// trailer returns the key of the trailer comment of CodeChecksum in the tree
+// at the iterator, being the last child of its last file.
+func trailer(ast map[uint64][]byte, iterator uint64) (key uint64, ok bool) {
+	for key = iterator; Is(ast[key], RootMatter) || Is(ast[key], FileMatter); {
+		var children = ChildKeys(ast, key)
+		if len(children) == 0 {
+			return 0, false
+		}
+		key = children[len(children)-1]
+	}
+	if !Is(ast[key], CommentRow) || !strings.HasPrefix(string(ast[O(key)]), ChecksumPrefix) {
+		return 0, false
+	}
+	return key, true
+}
+
+// VerifyChecksum checks that the tree at the iterator, converted from a file
+// written by CodeChecksum, still matches the hash of its trailer comment. It
+// is given the same kind of node CodeChecksum was, such as the RootMatter or
+// the FileMatter of convert.Parse. It returns an error if the trailer is
+// missing or if the code was modified since it was generated. The file itself
+// is verified by convert.VerifyChecksum.
+func VerifyChecksum(ast map[uint64][]byte, iterator uint64) error {
+	var key, ok = trailer(ast, iterator)
+	if !ok {
+		return errors.New("mapast: checksum trailer not found")
+	}
+	var want = strings.TrimSpace(strings.TrimPrefix(string(ast[O(key)]), ChecksumPrefix))
+	if checksum(ast, iterator) != want {
+		return errors.New("mapast: checksum mismatch, the file was modified")
+	}
+	return nil
+}
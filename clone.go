@@ -0,0 +1,93 @@
+package mapast
+
+import (
+	"crypto/sha256"
+	"sort"
+)
+
+// CloneGroup is a group of identical subtrees found by FindClones.
+type CloneGroup struct {
+	// Size is the number of nodes and strings of each subtree.
+	Size int
+	// Keys are the keys of the subtrees, in depth first order.
+	Keys []uint64
+}
+
+// FindClones reports the groups of identical subtrees under the root having
+// at least minSize nodes and strings each. The root can be a RootMatter
+// holding several files, so clones are found across files. If ignoreIdents is
+// set, subtrees that differ only in their identifiers are identical too. A
+// group is not reported if its subtrees are the children of the subtrees of
+// another group. The groups are ordered by decreasing size.
+func FindClones(ast map[uint64][]byte, root uint64, minSize int, ignoreIdents bool) []CloneGroup {
+	type info struct {
+		sum  [sha256.Size]byte
+		size int
+	}
+	var infos = make(map[uint64]info)
+	var order []uint64
+	var parents = make(map[uint64]uint64)
+	WalkPrePost(ast, root, func(key, parent uint64, node []byte) bool {
+		order = append(order, key)
+		parents[key] = parent
+		return true
+	}, func(key, parent uint64, node []byte) {
+		var h = sha256.New()
+		var size = 1
+		switch {
+		case Which(node) != nil:
+			h.Write([]byte{1})
+			h.Write(node)
+
+		case ignoreIdents && node != nil && ValidIdent(string(node)) == nil:
+			h.Write([]byte{2})
+
+		default:
+			h.Write([]byte{3})
+			h.Write(node)
+		}
+		for _, child := range ChildKeys(ast, key) {
+			var sum = infos[child].sum
+			h.Write(sum[:])
+			size += infos[child].size
+		}
+		var i = info{size: size}
+		copy(i.sum[:], h.Sum(nil))
+		infos[key] = i
+	})
+	var groups = make(map[[sha256.Size]byte][]uint64)
+	var sums [][sha256.Size]byte
+	for _, key := range order {
+		var i = infos[key]
+		if i.size < minSize {
+			continue
+		}
+		if len(groups[i.sum]) == 0 {
+			sums = append(sums, i.sum)
+		}
+		groups[i.sum] = append(groups[i.sum], key)
+	}
+	var clones []CloneGroup
+	for _, sum := range sums {
+		var keys = groups[sum]
+		if len(keys) < 2 {
+			continue
+		}
+		var nested = true
+		var parentsum = infos[parents[keys[0]]].sum
+		for _, key := range keys {
+			var parent = parents[key]
+			if parent == key || infos[parent].sum != parentsum || len(groups[parentsum]) < 2 {
+				nested = false
+				break
+			}
+		}
+		if !nested {
+			clones = append(clones, CloneGroup{Size: infos[keys[0]].size, Keys: keys})
+		}
+	}
+	sort.SliceStable(clones, func(a, b int) bool {
+		return clones[a].Size > clones[b].Size
+	})
+	return clones
+}
@@ -0,0 +1,205 @@
+// Toyconform program measures how much of the go language mapast supports. It
+// extracts the code examples from the go specification and reads the golden
+// snippets of a testdata directory, then converts every snippet, prints it and
+// compares the gofmt formatted result with the gofmt formatted snippet. It
+// reports the snippets that do not survive the round trip and the percentage
+// of those that do. By default the comparison ignores comments, semicolons and
+// parentheses, which the printer is free to place differently; the -exact flag
+// compares the formatted code instead.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// snippet is a single code example.
+type snippet struct {
+	name string
+	code string
+}
+
+// pre matches the code examples of the specification. Grammar productions are
+// marked with the ebnf class and are not matched.
+var pre = regexp.MustCompile(`(?s)<pre>(.*?)</pre>`)
+
+// tag matches the html tags within the code examples.
+var tag = regexp.MustCompile(`<[^>]*>`)
+
+// spec extracts the code examples of the go specification.
+func spec(filename string) ([]snippet, error) {
+	var data, err = os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var snippets []snippet
+	for i, m := range pre.FindAllStringSubmatch(string(data), -1) {
+		var code = html.UnescapeString(tag.ReplaceAllString(m[1], ""))
+		snippets = append(snippets, snippet{name: fmt.Sprintf("spec#%d", i+1), code: code})
+	}
+	return snippets, nil
+}
+
+// golden reads the golden snippets, the go files of the directory.
+func golden(dir string) ([]snippet, error) {
+	var names, err = filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	var snippets []snippet
+	for _, name := range names {
+		var data, err = os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, snippet{name: name, code: string(data)})
+	}
+	return snippets, nil
+}
+
+// wrap turns the snippet into a go source file. A snippet is either a whole
+// file, top level declarations, or statements. It returns false if the snippet
+// is neither, such as a lone expression or a deliberately invalid example.
+func wrap(code string) (string, bool) {
+	for _, src := range []string{
+		code,
+		"package p\n\n" + code,
+		"package p\n\nfunc _() {\n" + code + "\n}\n",
+	} {
+		if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments); err == nil {
+			return src, true
+		}
+	}
+	return "", false
+}
+
+// tokens returns the tokens of the source code, without the comments,
+// semicolons and parentheses.
+func tokens(src string) []string {
+	var fset = token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), []byte(src), nil, scanner.ScanComments)
+	var list []string
+	for {
+		var _, tok, lit = s.Scan()
+		switch tok {
+		case token.EOF:
+			return list
+
+		case token.COMMENT, token.SEMICOLON, token.LPAREN, token.RPAREN:
+
+		default:
+			list = append(list, tok.String()+" "+lit)
+		}
+	}
+}
+
+// same reports whether the source codes have the same tokens.
+func same(a string, b string) bool {
+	var x, y = tokens(a), tokens(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// roundtrip converts and prints the source file, and compares the formatted
+// results.
+func roundtrip(src string, exact bool) (printed string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	ast, err := convert.Parse([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	mapast.Code(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, ast, 0, 0)
+	want, err := format.Source([]byte(src))
+	if err != nil {
+		return out.String(), err
+	}
+	got, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return out.String(), fmt.Errorf("printed code does not parse: %v", err)
+	}
+	if exact && string(got) != string(want) || !exact && !same(string(got), string(want)) {
+		return out.String(), fmt.Errorf("printed code differs")
+	}
+	return out.String(), nil
+}
+
+func main() {
+	var specfile, testdata string
+	var verbose, exact bool
+	flag.StringVar(&specfile, "spec", filepath.Join(runtime.GOROOT(), "doc", "go_spec.html"),
+		"go specification html file, or empty to skip it")
+	flag.StringVar(&testdata, "testdata", "testdata/conformance", "directory of golden snippets")
+	flag.BoolVar(&exact, "exact", false, "compare the formatted code, including comments and parentheses")
+	flag.BoolVar(&verbose, "v", false, "print the snippets that fail and their printed code")
+	flag.Parse()
+	var snippets []snippet
+	if specfile != "" {
+		var found, err = spec(specfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		snippets = append(snippets, found...)
+	}
+	if found, err := golden(testdata); err == nil {
+		snippets = append(snippets, found...)
+	}
+	var checked, passed int
+	for _, s := range snippets {
+		var src, ok = wrap(s.code)
+		if !ok {
+			continue
+		}
+		checked++
+		printed, err := roundtrip(src, exact)
+		if err == nil {
+			passed++
+			continue
+		}
+		fmt.Printf("%s: %v\n", s.name, err)
+		if verbose {
+			fmt.Printf("--- snippet\n%s\n--- printed\n%s\n", s.code, printed)
+		}
+	}
+	if checked == 0 {
+		fmt.Println("no snippets")
+		os.Exit(2)
+	}
+	fmt.Printf("%d of %d snippets conform, %.1f%%\n", passed, checked, 100*float64(passed)/float64(checked))
+	if passed < checked {
+		os.Exit(1)
+	}
+}
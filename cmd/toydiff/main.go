@@ -0,0 +1,261 @@
+// Toydiff program prints the differences between two go files by comparing
+// their trees rather than their lines, so changes of formatting alone are not
+// reported. A file is given by its name or, if no such file exists, as
+// revision:path to be read from git, such as
+//
+//	toydiff HEAD~1:patch.go patch.go
+//
+// The differences are reported by whole statements and declarations, grouped
+// by the declaration holding them. Removed lines are prefixed by -, added
+// ones by +, and the compound statements and the functions whose header
+// changed are shown by their header alone. The exit status is 0 if the files
+// do not differ, 1 if they do and 2 on error.
+package main
+
+import (
+	"fmt"
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// side is one of the files compared.
+type side struct {
+	name      string
+	src       []byte
+	lines     []string
+	ast       map[uint64][]byte
+	positions map[uint64]token.Pos
+	parents   map[uint64]uint64
+	fset      *token.FileSet
+}
+
+// load reads and converts the file of the argument, from git if it is not
+// a file but contains a colon.
+func load(arg string) (*side, error) {
+	var src []byte
+	var err error
+	if _, serr := os.Stat(arg); serr != nil && strings.Contains(arg, ":") {
+		src, err = exec.Command("git", "show", arg).Output()
+		if exit, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("git show %s: %s", arg, strings.TrimSpace(string(exit.Stderr)))
+		}
+	} else {
+		src, err = os.ReadFile(arg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s = &side{name: arg, src: src, lines: strings.Split(string(src), "\n"), fset: token.NewFileSet()}
+	s.ast, s.positions, err = convert.ParsePositions(s.fset, arg, src)
+	if err != nil {
+		return nil, err
+	}
+	s.parents = mapast.BuildParentIndex(s.ast, 0)
+	return s, nil
+}
+
+// unit tells whether the node at the key is a statement, being a child of
+// a block past its header, or a declaration, being a child of the file.
+func (s *side) unit(key uint64) bool {
+	var parent, ok = s.parents[key]
+	if !ok {
+		return false
+	}
+	var node = s.ast[parent]
+	return mapast.Is(node, mapast.FileMatter) ||
+		mapast.Is(node, mapast.BlocOfCode) && key-mapast.O(parent) >= mapast.Count(node)
+}
+
+// lift returns the key of the statement or declaration holding the node at
+// the key, the node itself if it is one.
+func (s *side) lift(key uint64) uint64 {
+	for at := key; ; {
+		if s.unit(at) {
+			return at
+		}
+		var parent, ok = s.parents[at]
+		if !ok {
+			return key
+		}
+		at = parent
+	}
+}
+
+// decl returns the key of the declaration holding the node at the key.
+func (s *side) decl(key uint64) uint64 {
+	for {
+		var parent, ok = s.parents[key]
+		if !ok || mapast.Is(s.ast[parent], mapast.FileMatter) {
+			return key
+		}
+		key = parent
+	}
+}
+
+// line returns the line of the node at the key, or 0 if it has no position.
+func (s *side) line(key uint64) int {
+	if pos, ok := s.positions[key]; ok && pos.IsValid() {
+		return s.fset.Position(pos).Line
+	}
+	return 0
+}
+
+// closing returns the line of the last bracket closing the ones opened from
+// the start position of a node up to the end position of its last string,
+// since the closing brackets are not part of the tree.
+func (s *side) closing(start, end token.Pos) int {
+	var file = s.fset.File(start)
+	var sc scanner.Scanner
+	sc.Init(file, s.src, nil, 0)
+	var depth int
+	var line = file.Line(end)
+	for {
+		var pos, tok, _ = sc.Scan()
+		switch {
+		case tok == token.EOF:
+			return line
+
+		case pos >= end && depth <= 0:
+			return line
+
+		case pos < start:
+			continue
+
+		case tok == token.LBRACE, tok == token.LPAREN, tok == token.LBRACK:
+			depth++
+
+		case tok == token.RBRACE, tok == token.RPAREN, tok == token.RBRACK:
+			depth--
+			if pos >= end {
+				line = file.Line(pos)
+			}
+		}
+	}
+}
+
+// span returns the first and the last line of the node at the key. With
+// header set, the lines of the statements of its body are left out.
+func (s *side) span(key uint64, header bool) (first, last int) {
+	first = s.line(key)
+	if first == 0 {
+		return 0, 0
+	}
+	var body uint64
+	var hasbody bool
+	var end token.Pos
+	mapast.Walk(s.ast, key, func(at, parent uint64, node []byte) bool {
+		if header && !hasbody && at != key && s.unit(at) {
+			body, hasbody = at, true
+		}
+		if pos, ok := s.positions[at]; ok && pos.IsValid() {
+			if mapast.Which(node) == nil {
+				pos += token.Pos(len(node))
+			}
+			if pos > end {
+				end = pos
+			}
+		}
+		return true
+	})
+	if hasbody && s.line(body) > first {
+		return first, s.line(body) - 1
+	}
+	last = s.closing(s.positions[key], end)
+	if last < first {
+		last = first
+	}
+	return first, last
+}
+
+// print prints the lines of the node at the key prefixed by the mark.
+func (s *side) print(mark string, key uint64, header bool) {
+	var first, last = s.span(key, header)
+	if first == 0 {
+		fmt.Printf("%s %s: %s\n", mark, s.name, strings.TrimSpace(mapast.SprintNode(s.ast, key)))
+		return
+	}
+	for line := first; line <= last && line <= len(s.lines); line++ {
+		fmt.Printf("%s %s:%d: %s\n", mark, s.name, line, strings.TrimRight(s.lines[line-1], " \t\r"))
+	}
+}
+
+// hunk is a difference between the statements or declarations of the files,
+// at the key before in the first file, the key after in the second one, or
+// both if the node changed.
+type hunk struct {
+	before, after       uint64
+	hasbefore, hasafter bool
+	// header is set if the change is within the statements or declarations,
+	// rather than replacing them, so the ones holding a body are shown by
+	// their header alone.
+	header bool
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: toydiff old.go new.go")
+		os.Exit(2)
+	}
+	var a, b *side
+	var err error
+	if a, err = load(os.Args[1]); err == nil {
+		b, err = load(os.Args[2])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// The changes come in the order of the trees, so the hunks need no
+	// sorting.
+	var hunks []hunk
+	var seen = make(map[hunk]bool)
+	var add = func(h hunk) {
+		if !seen[h] {
+			seen[h] = true
+			hunks = append(hunks, h)
+		}
+	}
+	for _, change := range mapast.Diff(a.ast, 0, b.ast, 0) {
+		switch {
+		case change.Op == mapast.EditRemove && a.unit(change.Before):
+			add(hunk{before: change.Before, hasbefore: true})
+
+		case change.Op == mapast.EditInsert && b.unit(change.After):
+			add(hunk{after: change.After, hasafter: true})
+
+		default:
+			var before, after = a.lift(change.Before), b.lift(change.After)
+			add(hunk{before: before, after: after, hasbefore: true, hasafter: true,
+				header: change.Op != mapast.EditReplace || before != change.Before || after != change.After})
+		}
+	}
+	if len(hunks) == 0 {
+		return
+	}
+	var group string
+	for _, h := range hunks {
+		var s, key = a, h.before
+		if !h.hasbefore {
+			s, key = b, h.after
+		}
+		var decl = s.decl(key)
+		if decl != key {
+			if first := s.line(decl); first > 0 && strings.TrimSpace(s.lines[first-1]) != group {
+				group = strings.TrimSpace(s.lines[first-1])
+				fmt.Println("@@ " + group)
+			}
+		}
+		if h.hasbefore {
+			a.print("-", h.before, h.header)
+		}
+		if h.hasafter {
+			b.print("+", h.after, h.header)
+		}
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around the changes.
+const context = 3
+
+// line is a line of a diff, marked by a space if it is in both texts, - if it
+// is only in the old one and + if it is only in the new one.
+type line struct {
+	mark byte
+	text string
+	// eof is set if the line ends the text without a newline.
+	eof bool
+}
+
+// lines splits the text into lines, marking the last one if it lacks the
+// newline.
+func lines(text string) (split []string, eof bool) {
+	if text == "" {
+		return nil, false
+	}
+	split = strings.Split(text, "\n")
+	if split[len(split)-1] == "" {
+		return split[:len(split)-1], false
+	}
+	return split, true
+}
+
+// edits returns the shortest edit script turning a into b, found by the
+// algorithm of Myers. Trace holds the furthest reaching paths of every round,
+// each one only as wide as the round, to walk the script back from its end.
+func edits(a, b []string, aeof, beof bool) []line {
+	var n, m = len(a), len(b)
+	var max = n + m
+	var v = make([]int, 2*max+2)
+	var trace [][]int
+	var at = func(v []int, d, k int) int {
+		return v[k+d]
+	}
+	// The last lines are equal only if both end the text the same way.
+	var equal = func(x, y int) bool {
+		return a[x] == b[y] && (aeof && x == n-1) == (beof && y == m-1)
+	}
+	var d int
+search:
+	for d = 0; d <= max; d++ {
+		var round = make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			var y = x - k
+			for x < n && y < m && equal(x, y) {
+				x, y = x+1, y+1
+			}
+			v[max+k] = x
+			round[k+d] = x
+			if x >= n && y >= m {
+				trace = append(trace, round)
+				break search
+			}
+		}
+		trace = append(trace, round)
+	}
+	var script []line
+	var x, y = n, m
+	for ; d > 0; d-- {
+		var k = x - y
+		var prev int
+		if k == -d || k != d && at(trace[d-1], d-1, k-1) < at(trace[d-1], d-1, k+1) {
+			prev = k + 1
+		} else {
+			prev = k - 1
+		}
+		var px = at(trace[d-1], d-1, prev)
+		var py = px - prev
+		for x > px && y > py {
+			script = append(script, line{mark: ' ', text: a[x-1], eof: aeof && x == n})
+			x, y = x-1, y-1
+		}
+		if x == px {
+			script = append(script, line{mark: '+', text: b[y-1], eof: beof && y == m})
+		} else {
+			script = append(script, line{mark: '-', text: a[x-1], eof: aeof && x == n})
+		}
+		x, y = px, py
+	}
+	for x > 0 && y > 0 {
+		script = append(script, line{mark: ' ', text: a[x-1], eof: aeof && x == n})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
+
+// unified returns the unified diff of the old and the new text, with the old
+// and the new name in its header, or an empty string if they are equal.
+func unified(oldname, newname, old, new string) string {
+	if old == new {
+		return ""
+	}
+	var a, aeof = lines(old)
+	var b, beof = lines(new)
+	var script = edits(a, b, aeof, beof)
+	var out strings.Builder
+	fmt.Fprintf(&out, "diff %s %s\n--- %s\n+++ %s\n", oldname, newname, oldname, newname)
+	// The lines of the script are grouped into hunks of changes less than
+	// twice the context apart.
+	var ai, bi = make([]int, len(script)+1), make([]int, len(script)+1)
+	for i, l := range script {
+		ai[i+1], bi[i+1] = ai[i], bi[i]
+		if l.mark != '+' {
+			ai[i+1]++
+		}
+		if l.mark != '-' {
+			bi[i+1]++
+		}
+	}
+	for i := 0; i < len(script); {
+		if script[i].mark == ' ' {
+			i++
+			continue
+		}
+		var start = i - context
+		if start < 0 {
+			start = 0
+		}
+		var end = i
+		for end < len(script) {
+			var next = end
+			for next < len(script) && script[next].mark == ' ' {
+				next++
+			}
+			if next == len(script) || next-end > 2*context {
+				break
+			}
+			for next < len(script) && script[next].mark != ' ' {
+				next++
+			}
+			end = next
+		}
+		var stop = end + context
+		if stop > len(script) {
+			stop = len(script)
+		}
+		var astart, bstart = ai[start] + 1, bi[start] + 1
+		var alen, blen = ai[stop] - ai[start], bi[stop] - bi[start]
+		if alen == 0 {
+			astart--
+		}
+		if blen == 0 {
+			bstart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", astart, alen, bstart, blen)
+		for _, l := range script[start:stop] {
+			out.WriteByte(l.mark)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+			if l.eof {
+				out.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return out.String()
+}
@@ -1,16 +1,33 @@
-// Toyfmt program demonstrates abstract syntax tree conversion and printing
+// Toyfmt program demonstrates abstract syntax tree conversion and printing.
+// Like gofmt, it formats the files given, the go files of the directories
+// given, recursively, or the standard input if none are, and prints them
+// unless given -l, -w or -d: -l lists the files whose formatting differs, -w
+// rewrites them in place and -d prints the unified diffs of the files and
+// their formatting. The files are formatted concurrently, and an error met in
+// a file is reported without stopping the others.
+//
+// Errors are reported on the standard error as file:line:col: message when
+// their position is known. The exit status is 0 on success, 1 if -l listed
+// files whose formatting differs, 2 on wrong usage and 3 if a file could not
+// be read, parsed or written. A file having constructs mapast cannot represent,
+// such as type parameters, is reported as a parse error and left unchanged.
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/go-li/mapast"
 	"github.com/go-li/mapast/convert"
-	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 func Printer(s string) {
@@ -21,25 +38,286 @@ func Printer(s string) {
 	}
 }
 
-func main() {
-	var filename string
-	flag.StringVar(&filename, "I", "", "go source code file to translate")
-	flag.Parse()
-	content, err := ioutil.ReadFile(filename)
+// options holds the flags applying to every file.
+type options struct {
+	in, emit          string
+	policy            mapast.EOFPolicy
+	list, write, diff bool
+}
+
+// gofiles returns the files of the path: the path itself if it is a file or
+// -, else the go files of the directory and of its subdirectories. A path
+// ending with /... is the directory before it.
+func gofiles(path string) ([]string, error) {
+	if path == "-" {
+		return []string{path}, nil
+	}
+	if strings.HasSuffix(path, "/...") {
+		path = strings.TrimSuffix(path, "/...")
+		if path == "" {
+			path = "/"
+		}
+	}
+	var info, err = os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && name != path && (strings.HasPrefix(info.Name(), ".") || info.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(name, ".go") {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// stdin is the name of the standard input in the errors.
+const stdin = "<standard input>"
+
+// process formats the file, or the standard input if the file name is -, and
+// returns what is to be printed to the standard output for it, and whether the
+// formatting differs from the file. The errors of parsing go code are
+// scanner.ErrorList values, holding the position of every error.
+func process(filename string, opts options) ([]byte, bool, error) {
+	var name = filename
+	if filename == "-" {
+		name = stdin
+	}
+	if opts.write && filename == "-" {
+		return nil, false, errors.New("cannot write the standard input")
+	}
+	var content []byte
+	var err error
+	if filename == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filename)
+	}
 	if err != nil {
-		panic(err)
+		return nil, false, err
+	}
+	var asttree map[uint64][]byte
+	switch opts.in {
+	case "go":
+		asttree, _, err = convert.ParsePositions(token.NewFileSet(), name, content)
+
+	case "json":
+		asttree = make(map[uint64][]byte)
+		err = mapast.UnmarshalJSON(asttree, 0, content)
+
+	case "binary":
+		asttree, err = mapast.Decode(bytes.NewReader(content))
+	}
+	if _, ok := err.(scanner.ErrorList); ok {
+		return nil, false, err
 	}
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "demo", content, parser.ParseComments)
 	if err != nil {
-		fmt.Printf("Error parsing: %v\n", err)
-		os.Exit(4)
+		return nil, false, fmt.Errorf("%s: %v", name, err)
 	}
-	asttree := make(map[uint64][]byte)
-	ast.Walk(convert.NewConversion(asttree, 0, content), file)
 	if false {
 		mapast.Dump(Printer, asttree, 0, 0)
 		fmt.Println("---------------------------------------------------------")
 	}
-	mapast.Code(Printer, asttree, 0, 0)
+	var out bytes.Buffer
+	switch opts.emit {
+	case "json":
+		data, err := mapast.MarshalJSON(asttree, 0)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %v", name, err)
+		}
+		return append(data, '\n'), false, nil
+
+	case "binary":
+		if err := mapast.Encode(&out, asttree, 0); err != nil {
+			return nil, false, fmt.Errorf("%s: %v", name, err)
+		}
+		return out.Bytes(), false, nil
+	}
+	mapast.CodeEOF(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, asttree, 0, 0, opts.policy)
+	if !opts.list && !opts.write && !opts.diff {
+		return out.Bytes(), false, nil
+	}
+	if bytes.Equal(out.Bytes(), content) {
+		return nil, false, nil
+	}
+	var report []byte
+	if opts.list {
+		report = append(report, filename+"\n"...)
+	}
+	if opts.write {
+		if err := replace(filename, out.Bytes()); err != nil {
+			return report, true, err
+		}
+	}
+	if opts.diff {
+		report = append(report, unified(filename+".orig", filename, string(content), out.String())...)
+	}
+	return report, true, nil
+}
+
+// result is what processing a file printed, whether its formatting differs,
+// and the error it met.
+type result struct {
+	out     []byte
+	differs bool
+	err     error
+}
+
+// usage reports the wrong usage and exits.
+func usage(msg string) {
+	fmt.Fprintln(os.Stderr, "toyfmt: "+msg)
+	os.Exit(2)
+}
+
+func main() {
+	var filename string
+	var eof string
+	var opts options
+	var workers int
+	flag.StringVar(&filename, "I", "", "go source code file to translate, or - for standard input")
+	flag.StringVar(&eof, "eof", "single", "end of file newlines: single, none or preserve")
+	flag.StringVar(&opts.in, "in", "go", "input format: go, json or binary")
+	flag.StringVar(&opts.emit, "emit", "code", "output format: code, json or binary")
+	flag.BoolVar(&opts.list, "l", false, "list the files whose formatting differs")
+	flag.BoolVar(&opts.write, "w", false, "write the formatting to the files instead of standard output")
+	flag.BoolVar(&opts.diff, "d", false, "print the diffs of the files and their formatting")
+	flag.IntVar(&workers, "j", runtime.NumCPU(), "number of files formatted at once")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: toyfmt [flags] [path ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch opts.in {
+	case "go", "json", "binary":
+
+	default:
+		usage("unknown input format " + opts.in)
+	}
+	switch opts.emit {
+	case "code", "json", "binary":
+
+	default:
+		usage("unknown output format " + opts.emit)
+	}
+	if (opts.list || opts.write || opts.diff) && (opts.in != "go" || opts.emit != "code") {
+		usage("the -l, -w and -d flags need go input and code output")
+	}
+	switch eof {
+	case "single":
+		opts.policy = mapast.EOFSingleNewline
+
+	case "none":
+		opts.policy = mapast.EOFNoNewline
+
+	case "preserve":
+		opts.policy = mapast.EOFPreserve
+
+	default:
+		usage("unknown end of file policy " + eof)
+	}
+	var paths = flag.Args()
+	if filename != "" {
+		paths = append([]string{filename}, paths...)
+	}
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	var failed bool
+	var files []string
+	for _, path := range paths {
+		var found, err = gofiles(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+		files = append(files, found...)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	// The files are formatted by the workers in any order, but their results
+	// are printed in the order of the files.
+	var results = make([]chan result, len(files))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	var jobs = make(chan int)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				var out, differs, err = process(files[i], opts)
+				results[i] <- result{out: out, differs: differs, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	var differs bool
+	for i := range files {
+		var r = <-results[i]
+		os.Stdout.Write(r.out)
+		differs = differs || r.differs
+		if r.err != nil {
+			scanner.PrintError(os.Stderr, r.err)
+			failed = true
+		}
+	}
+	switch {
+	case failed:
+		os.Exit(3)
+
+	case differs && opts.list:
+		os.Exit(1)
+	}
+}
+
+// replace replaces the content of the file atomically, by writing a temporary
+// file in the same directory and renaming it over the file. The file keeps its
+// permissions. The file is left unchanged if the content does not parse, so
+// a printing bug cannot destroy the code.
+func replace(filename string, content []byte) error {
+	if _, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.ParseComments); err != nil {
+		return fmt.Errorf("%s: formatted code does not parse, file left unchanged: %v", filename, err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".toyfmt")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(content); err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
 }
@@ -0,0 +1,150 @@
+// Toygrep program searches go source code structurally. The pattern is a go
+// expression, statement or declaration whose $name variables match any
+// subtree, such as
+//
+//	toygrep 'if $err != nil { return $err }' ./...
+//
+// A variable used twice must match identical code, and $_ matches anything.
+// With the -q flag the pattern is a query selector instead, such as
+// 'Func > BlocOfCode Expression[kind=Call]'. The paths are go files or
+// directories, searched recursively if they end with /... Every match is
+// printed as file:line: followed by the line of the source where it starts. The
+// exit status is 0 if there were matches, 1 if there were none and 2 on error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"github.com/go-li/mapast/query"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gofiles returns the go files of the path, a file, a directory, or
+// a directory followed by /... to be searched recursively.
+func gofiles(path string) ([]string, error) {
+	var recursive = strings.HasSuffix(path, "/...")
+	if recursive {
+		path = strings.TrimSuffix(path, "/...")
+		if path == "" {
+			path = "/"
+		}
+	}
+	var info, err = os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	if !recursive {
+		return filepath.Glob(filepath.Join(path, "*.go"))
+	}
+	var files []string
+	err = filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && name != path && (strings.HasPrefix(info.Name(), ".") || info.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(name, ".go") {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func main() {
+	var selector bool
+	var list bool
+	flag.BoolVar(&selector, "q", false, "the pattern is a query selector rather than go code")
+	flag.BoolVar(&list, "l", false, "print only the names of the files having matches")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: toygrep [-q] [-l] pattern path...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var template map[uint64][]byte
+	var matcher *query.Matcher
+	var err error
+	if selector {
+		matcher, err = query.Compile(flag.Arg(0))
+	} else {
+		template, err = convert.ParsePattern(flag.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var files []string
+	for _, path := range flag.Args()[1:] {
+		var found, err = gofiles(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		files = append(files, found...)
+	}
+	var failed bool
+	var matched bool
+	for _, name := range files {
+		var src, err = os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		var fset = token.NewFileSet()
+		ast, positions, err := convert.ParsePositions(fset, name, src)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		var keys []uint64
+		if matcher != nil {
+			keys = matcher.Match(ast, 0)
+		} else {
+			mapast.Walk(ast, 0, func(key, parent uint64, node []byte) bool {
+				if _, ok := mapast.Match(template, 0, ast, key); ok {
+					keys = append(keys, key)
+				}
+				return true
+			})
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		matched = true
+		if list {
+			fmt.Println(name)
+			continue
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			return positions[keys[i]] < positions[keys[j]]
+		})
+		var lines = strings.Split(string(src), "\n")
+		for _, key := range keys {
+			var line = fset.Position(positions[key]).Line
+			fmt.Printf("%s:%d: %s\n", name, line, strings.TrimSpace(lines[line-1]))
+		}
+	}
+	switch {
+	case failed:
+		os.Exit(2)
+
+	case !matched:
+		os.Exit(1)
+	}
+}
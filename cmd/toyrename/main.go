@@ -0,0 +1,287 @@
+// Toyrename program renames a top level identifier of a package across all of
+// its files, and its uses by the other packages given, such as
+//
+//	toyrename -from github.com/go-li/mapast.Which -to KindOfNode ./...
+//
+// The identifier is given as the import path or the name of its package,
+// a dot, and its name. The packages are given as patterns of the go command,
+// and loaded with their test files by convert.Load, which asks go list like
+// go/packages does. The files are converted to mapast trees, renamed by
+// scope.Rename, which refuses renames that would change the meaning of the
+// code, and the renamed identifiers are replaced in the source at the
+// positions of their strings, so the formatting of the files is kept.
+// Identifiers are resolved without type information, so fields and methods
+// cannot be renamed, and the files excluded by build constraints are not
+// updated. Packages having constructs mapast cannot represent, such as type
+// parameters, are refused.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"github.com/go-li/mapast/scope"
+	"go/ast"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// file is a file of a package tree.
+type file struct {
+	name  string
+	src   []byte
+	key   uint64
+	tfile *token.File
+}
+
+// pkg is a package converted to a single tree, having the RootMatter at key
+// zero and one FileMatter per file.
+type pkg struct {
+	path      string
+	name      string
+	ast       map[uint64][]byte
+	positions map[uint64]token.Pos
+	fset      *token.FileSet
+	files     []*file
+}
+
+// load converts the files of the loaded package into a single tree,
+// recording the positions of its nodes. It refuses a package having
+// constructs mapast cannot represent, reporting every one by its file and
+// position, since renaming in a lossy tree could change the meaning of the
+// code.
+func load(l *convert.Loaded) (*pkg, error) {
+	var p = &pkg{path: l.Path, name: l.Name, ast: map[uint64][]byte{0: mapast.RootMatter},
+		positions: make(map[uint64]token.Pos), fset: l.Fset}
+	var lossy []string
+	for i, parsed := range l.Syntax {
+		var c = convert.NewConversion(p.ast, uint64(i), l.Srcs[i])
+		c.Positions = p.positions
+		ast.Walk(c, parsed)
+		for _, err := range c.Errors() {
+			var where = l.Files[i]
+			if unsupported, ok := err.(*convert.UnsupportedNode); ok {
+				where = p.fset.Position(unsupported.Pos).String()
+			}
+			lossy = append(lossy, "toyrename: "+where+": "+err.Error())
+		}
+		p.files = append(p.files, &file{name: l.Files[i], src: l.Srcs[i], key: c.MyFile,
+			tfile: p.fset.File(parsed.Pos())})
+	}
+	if len(lossy) > 0 {
+		return nil, errors.New(strings.Join(lossy, "\n"))
+	}
+	return p, nil
+}
+
+// named returns the keys of the strings of the tree equal to the name.
+func named(p *pkg, name string) map[uint64]bool {
+	var keys = make(map[uint64]bool)
+	mapast.Walk(p.ast, 0, func(key, parent uint64, node []byte) bool {
+		if mapast.Which(node) == nil && string(node) == name {
+			keys[key] = true
+		}
+		return true
+	})
+	return keys
+}
+
+// selectors renames the selectors of the identifier of the imported package
+// in the package, returning how many were renamed.
+func selectors(p *pkg, path string, from string, to string) int {
+	var info = scope.Build(p.ast, 0)
+	var parents = mapast.BuildParentIndex(p.ast, 0)
+	var n int
+	mapast.Walk(p.ast, 0, func(key, parent uint64, node []byte) bool {
+		if !mapast.Is(node, mapast.Expression) || mapast.Variant(node) != mapast.ExpressionDot ||
+			mapast.Count(node) != 2 || string(p.ast[mapast.O(key)+1]) != from {
+			return true
+		}
+		var def, ok = info.Uses[mapast.O(key)]
+		if !ok || def == 0 {
+			return true
+		}
+		var stmt = parents[def]
+		if paths := mapast.ImportPaths(p.ast, stmt); !mapast.Is(p.ast[stmt], mapast.ImportStmt) ||
+			len(paths) != 1 || paths[0] != path {
+			return true
+		}
+		p.ast[mapast.O(key)+1] = []byte(to)
+		n++
+		return true
+	})
+	return n
+}
+
+// splice returns the source of the file with the identifiers renamed, at the
+// positions of the strings of the old name.
+func splice(p *pkg, f *file, keys map[uint64]bool, old string) ([]byte, bool, error) {
+	var offsets []int
+	var names = make(map[int]string)
+	var err error
+	mapast.Walk(p.ast, f.key, func(key, parent uint64, node []byte) bool {
+		if err != nil || !keys[key] || string(node) == old {
+			return err == nil
+		}
+		var offset = f.tfile.Offset(p.positions[key])
+		if !bytes.HasPrefix(f.src[offset:], []byte(old)) {
+			err = errors.New("toyrename: " + f.name + ": cannot find the identifier " + old +
+				" of the tree in the source")
+			return false
+		}
+		offsets = append(offsets, offset)
+		names[offset] = string(node)
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(offsets) == 0 {
+		return f.src, false, nil
+	}
+	sort.Ints(offsets)
+	var out []byte
+	var last int
+	for _, offset := range offsets {
+		if offset < last {
+			return nil, false, errors.New("toyrename: " + f.name + ": overlapping identifiers " + old)
+		}
+		out = append(append(out, f.src[last:offset]...), names[offset]...)
+		last = offset + len(old)
+	}
+	return append(out, f.src[last:]...), true, nil
+}
+
+// write writes the files of the package having identifiers renamed, unless
+// dry is set, and returns their names. Keys holds the keys of the strings of
+// the old name before renaming.
+func write(p *pkg, keys map[uint64]bool, old string, dry bool) ([]string, error) {
+	var written []string
+	for _, f := range p.files {
+		var out, changed, err = splice(p, f, keys, old)
+		if err != nil {
+			return written, err
+		}
+		if !changed {
+			continue
+		}
+		written = append(written, f.name)
+		if dry {
+			continue
+		}
+		info, err := os.Stat(f.name)
+		if err != nil {
+			return written, err
+		}
+		var tmp = f.name + ".toyrename"
+		if err := os.WriteFile(tmp, out, info.Mode().Perm()); err != nil {
+			return written, err
+		}
+		if err := os.Rename(tmp, f.name); err != nil {
+			os.Remove(tmp)
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// rename renames the identifier in the packages of the patterns.
+func rename(from string, to string, paths []string, dry bool) error {
+	var dot = strings.LastIndex(from, ".")
+	if dot <= 0 || dot == len(from)-1 {
+		return errors.New("toyrename: -from must be package.Name")
+	}
+	var target, old = from[:dot], from[dot+1:]
+	if err := mapast.ValidIdent(to); err != nil {
+		return err
+	}
+	var loaded, err = convert.Load(true, paths...)
+	if err != nil {
+		return err
+	}
+	var found, others []*pkg
+	for _, l := range loaded {
+		var p, err = load(l)
+		if err != nil {
+			return err
+		}
+		if p.path == target || p.name == target {
+			found = append(found, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return errors.New("toyrename: no package " + target + " found")
+
+	case len(found) > 1:
+		return errors.New("toyrename: package " + target + " is ambiguous")
+	}
+	var p = found[0]
+	var keys = make(map[*pkg]map[uint64]bool)
+	for _, q := range append(others, p) {
+		keys[q] = named(q, old)
+	}
+	var info = scope.Build(p.ast, 0)
+	var def, ok = info.Scopes[0].Names[old]
+	if !ok {
+		return errors.New("toyrename: " + old + " is not declared at the top level of package " + target)
+	}
+	if err := scope.Rename(p.ast, 0, def, to); err != nil {
+		return err
+	}
+	var n = len(info.References(def)) + 1
+	var packages = []*pkg{p}
+	if p.path != "." && !strings.HasPrefix(p.path, "_") && token.IsExported(old) {
+		for _, other := range others {
+			if m := selectors(other, p.path, old, to); m > 0 {
+				n += m
+				packages = append(packages, other)
+			}
+		}
+	}
+	var files []string
+	for _, p := range packages {
+		var written, err = write(p, keys[p], old, dry)
+		files = append(files, written...)
+		if err != nil {
+			return err
+		}
+	}
+	if dry {
+		for _, name := range files {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	fmt.Println("renamed " + strconv.Itoa(n) + " occurrences in " + strconv.Itoa(len(files)) + " files")
+	return nil
+}
+
+func main() {
+	var from, to string
+	var dry bool
+	flag.StringVar(&from, "from", "", "identifier to rename, as package.Name")
+	flag.StringVar(&to, "to", "", "new name of the identifier")
+	flag.BoolVar(&dry, "n", false, "print the names of the files to change instead of writing them")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: toyrename [-n] -from package.Name -to NewName packages...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if from == "" || to == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := rename(from, to, flag.Args(), dry); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
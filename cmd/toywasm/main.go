@@ -0,0 +1,35 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Toywasm program exposes the web facade to javascript. Once the module is
+// running, the global functions mapastParseToJSON, mapastPrintFromJSON,
+// mapastFormat and mapastDumpTree take a string and return an object holding
+// either the result or the error.
+package main
+
+import (
+	"github.com/go-li/mapast/web"
+	"syscall/js"
+)
+
+// wrap turns a facade function into a javascript function.
+func wrap(f func(string) (string, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 || args[0].Type() != js.TypeString {
+			return map[string]interface{}{"error": "expected a single string argument"}
+		}
+		result, err := f(args[0].String())
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return map[string]interface{}{"result": result}
+	})
+}
+
+func main() {
+	js.Global().Set("mapastParseToJSON", wrap(web.ParseToJSON))
+	js.Global().Set("mapastPrintFromJSON", wrap(web.PrintFromJSON))
+	js.Global().Set("mapastFormat", wrap(web.Format))
+	js.Global().Set("mapastDumpTree", wrap(web.DumpTree))
+	select {}
+}
@@ -0,0 +1,41 @@
+package mapast_test
+
+import (
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkCode measures the speed of the printer on the files of net/http of
+// the go installation that convert.
+func BenchmarkCode(b *testing.B) {
+	var files, _ = filepath.Glob(filepath.Join(runtime.GOROOT(), "src", "net", "http", "*.go"))
+	var trees []map[uint64][]byte
+	var size int64
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		tree, err := convert.Parse(src)
+		if err != nil {
+			continue
+		}
+		trees = append(trees, tree)
+		size += int64(len(src))
+	}
+	if len(trees) == 0 {
+		b.Skip("no files of net/http convert")
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tree := range trees {
+			mapast.Code(func(string) {}, tree, 0, 0)
+		}
+	}
+}
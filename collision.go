@@ -0,0 +1,76 @@
+package mapast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// KeyCollision is the error reporting two positions of a tree that O maps to
+// the same key, so one of them overwrote the other.
+type KeyCollision struct {
+	Key uint64
+	// First and Second are the child indexes leading from the root to the
+	// key, along the first path visited and along the colliding one.
+	First, Second []uint64
+}
+
+// pathstring returns the path as slash separated child indexes.
+func pathstring(path []uint64) string {
+	var parts = []string{""}
+	for _, index := range path {
+		parts = append(parts, strconv.FormatUint(index, 10))
+	}
+	return strings.Join(parts, "/")
+}
+
+// Error returns the description of the error.
+func (e *KeyCollision) Error() string {
+	return "mapast: key " + strconv.FormatUint(e.Key, 10) + " collides between paths " +
+		pathstring(e.First) + " and " + pathstring(e.Second)
+}
+
+// CheckKeys walks the subtree at the root and returns a *KeyCollision if a key
+// is reached along two different paths, which happens when O maps two
+// positions of a deep or wide tree to the same key. It returns nil if the keys
+// are all distinct.
+func CheckKeys(ast map[uint64][]byte, root uint64) error {
+	type step struct {
+		parent uint64
+		index  uint64
+	}
+	var seen = map[uint64]step{root: {root, 0}}
+	var path []uint64
+	var pathto = func(key uint64) []uint64 {
+		var reversed []uint64
+		for key != root {
+			reversed = append(reversed, seen[key].index)
+			key = seen[key].parent
+		}
+		var p = make([]uint64, len(reversed))
+		for i := range reversed {
+			p[i] = reversed[len(reversed)-1-i]
+		}
+		return p
+	}
+	var check func(key uint64) error
+	check = func(key uint64) error {
+		for i := uint64(0); Poke(ast, O(key)+i); i++ {
+			var child = O(key) + i
+			path = append(path, i)
+			if _, ok := seen[child]; ok {
+				var second = append([]uint64{}, path...)
+				return &KeyCollision{Key: child, First: pathto(child), Second: second}
+			}
+			seen[child] = step{key, i}
+			if err := check(child); err != nil {
+				return err
+			}
+			path = path[:len(path)-1]
+		}
+		return nil
+	}
+	if !Poke(ast, root) {
+		return nil
+	}
+	return check(root)
+}
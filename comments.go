@@ -1,13 +1,33 @@
 package mapast
 
+// The states of the LookupComments scanner.
+const (
+	scanCode = iota
+	scanLineComment
+	scanBlockComment
+	scanString
+	scanRawString
+	scanRune
+)
+
 // LookupComments fills EnderSepar with comment location information from file.
 // This information is necessary to recognize comments of various types, like
 // comments that span end of line only, or comments that follow an empty lines.
+// The file is scanned for string, raw string and rune literals and for the
+// comments themselves, so that // and /* inside of them, such as in
+// "http://x", are not taken for comments. Block comments can span several
+// lines. A leading byte order mark and the carriage returns of CRLF line
+// endings count as white space.
 func LookupComments(file []byte, EnderSepar [2]map[int]struct{}) {
-	var whitespace bool
+	var whitespace = true
 	var cleanline bool
 	var sawender bool
-	for i := 0; i+1 < len(file); i++ {
+	var state = scanCode
+	var start = 0
+	if len(file) >= 3 && file[0] == 0xef && file[1] == 0xbb && file[2] == 0xbf {
+		start = 3
+	}
+	for i := start; i+1 < len(file); i++ {
 		var c, d = file[i], file[i+1]
 		if c == '\n' {
 			if whitespace {
@@ -15,27 +35,70 @@ func LookupComments(file []byte, EnderSepar [2]map[int]struct{}) {
 			}
 			whitespace = true
 			sawender = false
+			if state != scanBlockComment && state != scanRawString {
+				state = scanCode
+			}
 			continue
 		}
-		if c == '/' && d == '/' && !whitespace && !sawender {
-			EnderSepar[0][(i+1)/2] = struct{}{}
-			sawender = true
-		}
-		if c == '/' && d == '*' && !whitespace && !sawender {
-			EnderSepar[0][(i+1)/2] = struct{}{}
-			sawender = true
-		}
-		if c == '/' && d == '/' && cleanline {
-			EnderSepar[1][(i+1)/2] = struct{}{}
-			cleanline = false
-		}
-		if c == '/' && d == '*' && cleanline {
-			EnderSepar[1][(i+1)/2] = struct{}{}
-			cleanline = false
-		}
-		if c == 'p' && d == 'a' && cleanline {
-			EnderSepar[1][(i+1)/2] = struct{}{}
-			cleanline = false
+		switch state {
+		case scanBlockComment:
+			if c == '*' && d == '/' {
+				state = scanCode
+				i++
+			}
+
+		case scanString, scanRune:
+			if c == '\\' {
+				i++
+			} else if c == '"' && state == scanString || c == '\'' && state == scanRune {
+				state = scanCode
+			}
+
+		case scanRawString:
+			if c == '`' {
+				state = scanCode
+			}
+
+		case scanCode:
+			if c == '/' && d == '/' && !whitespace && !sawender {
+				EnderSepar[0][(i+1)/2] = struct{}{}
+				sawender = true
+			}
+			if c == '/' && d == '*' && !whitespace && !sawender {
+				EnderSepar[0][(i+1)/2] = struct{}{}
+				sawender = true
+			}
+			if c == '/' && d == '/' && cleanline {
+				EnderSepar[1][(i+1)/2] = struct{}{}
+				cleanline = false
+			}
+			if c == '/' && d == '*' && cleanline {
+				EnderSepar[1][(i+1)/2] = struct{}{}
+				cleanline = false
+			}
+			if c == 'p' && d == 'a' && cleanline {
+				EnderSepar[1][(i+1)/2] = struct{}{}
+				cleanline = false
+			}
+			switch {
+			case c == '/' && d == '/':
+				state = scanLineComment
+
+			case c == '/' && d == '*':
+				state = scanBlockComment
+				whitespace, cleanline = false, false
+				i++
+				continue
+
+			case c == '"':
+				state = scanString
+
+			case c == '`':
+				state = scanRawString
+
+			case c == '\'':
+				state = scanRune
+			}
 		}
 		if c > ' ' {
 			whitespace = false
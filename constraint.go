@@ -0,0 +1,99 @@
+package mapast
+
+import (
+	"errors"
+	"go/build/constraint"
+)
+
+// buildlines returns the positions of the //go:build and // +build CommentRow
+// children of the file, which precede its PackageDef.
+func buildlines(ast map[uint64][]byte, file uint64) (lines []uint64) {
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		var node = ast[O(file)+i]
+		if !Is(node, CommentRow) {
+			break
+		}
+		if constraint.IsGoBuild(string(ast[O(O(file)+i)])) || constraint.IsPlusBuild(string(ast[O(O(file)+i)])) {
+			lines = append(lines, i)
+		}
+	}
+	return lines
+}
+
+// BuildConstraint returns the parsed build constraint of the file. The
+// //go:build line is preferred over the legacy // +build lines. It returns nil
+// if the file has no build constraint.
+func BuildConstraint(ast map[uint64][]byte, file uint64) (constraint.Expr, error) {
+	var plus constraint.Expr
+	for _, i := range buildlines(ast, file) {
+		var line = string(ast[O(O(file)+i)])
+		var expr, err = constraint.Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		if constraint.IsGoBuild(line) {
+			return expr, nil
+		}
+		if plus == nil {
+			plus = expr
+		} else {
+			plus = &constraint.AndExpr{X: plus, Y: expr}
+		}
+	}
+	return plus, nil
+}
+
+// separate makes the node at the key start after an empty line, or not, if
+// it is a CommentRow or a PackageDef.
+func separate(ast map[uint64][]byte, key uint64, empty bool) {
+	var node = ast[key]
+	switch {
+	case Is(node, CommentRow):
+		ast[key] = CommentRowNode(CommentRowNormal)
+		if empty {
+			ast[key] = CommentRowNode(CommentRowSeparate)
+		}
+
+	case Is(node, PackageDef):
+		ast[key] = PackageDefNode(PackageDefNormal)
+		if empty {
+			ast[key] = PackageDefNode(PackageDefSeparate)
+		}
+	}
+}
+
+// SetBuildConstraint replaces the build constraint of the file. The //go:build
+// line is put at the top of the file, followed by an empty line, before the
+// package comment and the PackageDef. If the file had legacy // +build lines,
+// they are regenerated from the expression. A nil expression removes the
+// build constraint.
+func SetBuildConstraint(ast map[uint64][]byte, file uint64, expr constraint.Expr) error {
+	if !Is(ast[file], FileMatter) {
+		return errors.New("mapast: not a FileMatter node")
+	}
+	var lines = buildlines(ast, file)
+	var plus bool
+	for j := len(lines) - 1; j >= 0; j-- {
+		plus = plus || constraint.IsPlusBuild(string(ast[O(O(file)+lines[j])]))
+		RemoveChild(ast, file, lines[j])
+	}
+	if expr == nil {
+		separate(ast, O(file), false)
+		return nil
+	}
+	var rows = []string{"//go:build " + expr.String()}
+	if plus {
+		var more, err = constraint.PlusBuildLines(expr)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, more...)
+	}
+	var trees []map[uint64][]byte
+	for _, row := range rows {
+		trees = append(trees, map[uint64][]byte{0: CommentRowNode(CommentRowNormal), O(0): []byte(row)})
+	}
+	InsertChild(ast, file, 0, trees...)
+	separate(ast, O(file)+uint64(len(rows)), true)
+	return nil
+}
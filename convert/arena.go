@@ -0,0 +1,59 @@
+package convert
+
+// arenablock is the size of the blocks an Arena allocates strings from.
+const arenablock = 64 << 10
+
+// Arena allocates the nodes of converted trees from large pooled blocks, so a
+// tree of millions of nodes is made of a few thousand allocations instead of
+// one per node, which the garbage collector scans much faster. The strings are
+// written directly into the blocks, and the headers of the nodes are shared by
+// all the nodes having the same one. Set it to the Arena field of the
+// Conversions; an Arena can be shared by the conversions of many files, but
+// not concurrently. The nodes of a tree built in an arena must not be
+// modified or appended to in place, as they share their blocks.
+type Arena struct {
+	block   []byte
+	headers map[string][]byte
+}
+
+// take returns n bytes of the arena. More than a quarter of a block is
+// allocated alone.
+func (a *Arena) take(n int) []byte {
+	if n > arenablock/4 {
+		return make([]byte, n)
+	}
+	if len(a.block)+n > cap(a.block) {
+		a.block = make([]byte, 0, arenablock)
+	}
+	var start = len(a.block)
+	a.block = a.block[:start+n]
+	return a.block[start : start+n : start+n]
+}
+
+// str returns the string as a node allocated from the arena.
+func (a *Arena) str(s string) []byte {
+	var node = a.take(len(s))
+	copy(node, s)
+	return node
+}
+
+// header returns the node header shared by the nodes equal to it.
+func (a *Arena) header(node []byte) []byte {
+	if shared, ok := a.headers[string(node)]; ok {
+		return shared
+	}
+	if a.headers == nil {
+		a.headers = make(map[string][]byte)
+	}
+	var shared = a.take(len(node))
+	copy(shared, node)
+	a.headers[string(shared)] = shared
+	return shared
+}
+
+// EstimateNodes returns an estimate of the number of keys of the tree
+// converted from the go source code, for pre-sizing the tree map. Go code
+// averages about six bytes of source per key.
+func EstimateNodes(src []byte) int {
+	return len(src)/6 + 16
+}
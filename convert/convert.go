@@ -5,6 +5,7 @@ import (
 	"github.com/go-li/mapast"
 	"go/ast"
 	"go/token"
+	"strings"
 )
 
 func o(n uint64) uint64 {
@@ -24,6 +25,9 @@ func how_many_substmts_labeled_stmt(list *ast.LabeledStmt) int {
 	case *ast.EmptyStmt:
 		n++
 
+	case *ast.BadStmt:
+		n++
+
 	case *ast.BranchStmt:
 		n++
 
@@ -71,6 +75,9 @@ func how_many_substmts_stmt_list(list []ast.Stmt) int {
 		case *ast.EmptyStmt:
 			n++
 
+		case *ast.BadStmt:
+			n++
+
 		case *ast.BranchStmt:
 			n++
 
@@ -228,7 +235,13 @@ func how_many_subblocks_block(x *ast.BlockStmt) int {
 	return how_many_subblocks_stmt_list(x.List)
 }
 
+// coolcomment reports whether the comment is kept even if Comments1 is false,
+// such as directives and build constraints, which stay attached to the
+// declaration following them.
 func coolcomment(n string) bool {
+	if mapast.IsDirective(n) {
+		return true
+	}
 	if len(n) >= 9 && n[0:9] == "// +build" {
 		return true
 	}
@@ -259,18 +272,47 @@ func NewConversion(asttree map[uint64][]byte, whichfile uint64, file []byte) *Co
 	var endersepar = [2]map[int]struct{}{ender, separ}
 	mapast.LookupComments(file, endersepar)
 	asttree[0] = mapast.RootMatter
-	asttree[o(0)+whichfile] = mapast.FileMatter
-	return &Conversion{AstTree: asttree, MyFile: o(0), EnderSepared: endersepar, Comments1: true}
+	var newlines byte
+	for i := len(file) - 1; i >= 0 && newlines < mapast.FileMatterUnknownEOF-1; i-- {
+		if file[i] == '\n' {
+			newlines++
+		} else if file[i] != ' ' && file[i] != '\t' && file[i] != '\r' {
+			break
+		}
+	}
+	asttree[o(0)+whichfile] = mapast.FileMatterNode(newlines)
+	return &Conversion{AstTree: asttree, MyFile: o(0) + whichfile, EnderSepared: endersepar, Comments1: true, src: file}
 }
 
 // Conversion holds the state of translation of a single file. Please put your
 // ast tree map to AstTree field and the key of your file to the MyFile field.
-// Conversion is usually not reused.
+// If Positions is not nil, the position of the go/ast node being translated
+// is recorded there for the key of every mapast node written, and the
+// position of its identifier or literal for a string. If Tolerant is
+// set, the BadExpr, BadStmt and BadDecl nodes of a partially parsed file are
+// translated to Placeholder nodes holding their source code, and their
+// positions are appended to Skipped. If CheckKeys is set, Errors also reports
+// a mapast.KeyCollision found in the translated file. Conversion is usually
+// not reused. If Arena is not nil, the nodes are allocated from it. If Docs is
+// not nil, the number of CommentRows forming the doc comment of every top
+// level declaration, as go/ast binds them, is recorded there for the key of
+// the declaration, see mapast.DocComments.
 type Conversion struct {
 	AstTree            map[uint64][]byte
 	MyFile             uint64
 	EnderSepared       [2]map[int]struct{}
 	Comments1          bool
+	Positions          map[uint64]token.Pos
+	Docs               map[uint64]uint64
+	Tolerant           bool
+	Skipped            []token.Pos
+	CheckKeys          bool
+	Arena              *Arena
+	errors             []error
+	pos                token.Pos
+	leaves             map[*byte]token.Pos
+	src                []byte
+	base               token.Pos
 	importswhere       uint64
 	nestedimports      uint64
 	structfield        [][2]uint64
@@ -295,6 +337,8 @@ type Conversion struct {
 	typedcases         map[*ast.CaseClause]struct{}
 	comments           []string
 	commentpos         []int
+	depth              int
+	lastdecl           token.Pos
 }
 
 func packint(n int, ender bool, separ bool) int {
@@ -317,12 +361,161 @@ func fetchvariant(n int) byte {
 	return mapast.CommentRowNormal
 }
 
+// bytes returns the string as a node, allocated from the arena if there is
+// one.
+func (c *Conversion) bytes(s string) []byte {
+	if c.Arena != nil {
+		return c.Arena.str(s)
+	}
+	return []byte(s)
+}
+
+// leaf returns the string of an identifier or a literal as a node, made by
+// bytes, remembering the position of the identifier or the literal for set.
+func (c *Conversion) leaf(s string, pos token.Pos) []byte {
+	var node = c.bytes(s)
+	if c.Positions != nil && len(node) > 0 {
+		if c.leaves == nil {
+			c.leaves = make(map[*byte]token.Pos)
+		}
+		c.leaves[&node[0]] = pos
+	}
+	return node
+}
+
+// set stores the node at the key, recording the current position, or the
+// position of the identifier or the literal the string was made from by leaf.
+// Strings are stored as they are, being made by bytes, and the headers of the
+// nodes are shared within the arena if there is one.
+func (c *Conversion) set(key uint64, node []byte) {
+	if c.Arena != nil && mapast.Which(node) != nil {
+		node = c.Arena.header(node)
+	}
+	c.AstTree[key] = node
+	if c.Positions != nil {
+		var pos = c.pos
+		if len(node) > 0 && node[0] != mapast.NodeTag {
+			if leaf, ok := c.leaves[&node[0]]; ok {
+				pos = leaf
+				delete(c.leaves, &node[0])
+			}
+		}
+		c.Positions[key] = pos
+	}
+}
+
+// setcomment stores a CommentRow with its text at the key. Packed holds the
+// position and the variant of the comment, as packed by packint.
+func (c *Conversion) setcomment(key uint64, packed int, text string) {
+	var pos = c.pos
+	c.pos = token.Pos(packed & 0xfffffff)
+	c.set(key, mapast.CommentRowNode(fetchvariant(packed)))
+	c.set(o(key), c.bytes(text))
+	c.pos = pos
+}
+
+// trailingcomments stores the comments following the last declaration of the
+// file, once the whole file was visited. The comments left within the last
+// declaration, such as the ones of a function body, are not moved after it.
+func (c *Conversion) trailingcomments() {
+	for len(c.commentpos) > 0 && c.commentpos[0]&0xfffffff != 0xfffffff {
+		if token.Pos(c.commentpos[0]&0xfffffff) >= c.lastdecl {
+			c.setcomment(o(c.MyFile)+c.importswhere, c.commentpos[0], c.comments[0])
+			c.importswhere++
+		}
+		c.commentpos = c.commentpos[1:]
+		c.comments = c.comments[1:]
+	}
+}
+
+// doccomment returns the number of CommentRows of the doc comment once the
+// comment packed by packint is stored, given their number before. The count
+// restarts at a comment not part of the doc comment.
+func (c *Conversion) doccomment(doc *ast.CommentGroup, packed int, n uint64) uint64 {
+	var pos = token.Pos(packed & 0xfffffff)
+	if doc == nil || pos < doc.Pos() || pos >= doc.End() {
+		return 0
+	}
+	return n + 1
+}
+
+// setdoc records the number of CommentRows of the doc comment of the top level
+// declaration stored next.
+func (c *Conversion) setdoc(n uint64) {
+	if c.Docs != nil && n > 0 && c.depth == 2 {
+		c.Docs[o(c.MyFile)+c.importswhere] = n
+	}
+}
+
+// UnsupportedNode is the error reporting a go construct that mapast cannot
+// represent. The tree is lossy at the construct.
+type UnsupportedNode struct {
+	// Pos is the position of the construct.
+	Pos token.Pos
+	// Construct names the construct, such as type parameters.
+	Construct string
+}
+
+// Error returns the description of the error.
+func (e *UnsupportedNode) Error() string {
+	return "convert: unsupported " + e.Construct
+}
+
+// Errors returns the UnsupportedNode errors collected during the translation,
+// in the order the constructs were met, followed by the key collision found if
+// CheckKeys is set.
+func (c *Conversion) Errors() []error {
+	if c.CheckKeys {
+		if err := mapast.CheckKeys(c.AstTree, c.MyFile); err != nil {
+			return append(c.errors[:len(c.errors):len(c.errors)], err)
+		}
+	}
+	return c.errors
+}
+
+// unsupported records an UnsupportedNode error.
+func (c *Conversion) unsupported(pos token.Pos, construct string) {
+	c.errors = append(c.errors, &UnsupportedNode{Pos: pos, Construct: construct})
+}
+
+// placeholder stores a Placeholder at the key, holding the source code between
+// the positions, and records the position as skipped. The parser may extend
+// a bad region over the closing braces of the enclosing blocks, so unmatched
+// trailing braces are left out, as they are printed by the blocks.
+func (c *Conversion) placeholder(key uint64, from token.Pos, to token.Pos) {
+	var start, end = int(from - c.base), int(to - c.base)
+	if start < 0 || end > len(c.src) || start > end {
+		start, end = 0, 0
+	}
+	var text = strings.TrimRight(string(c.src[start:end]), " \t\r\n")
+	for strings.HasSuffix(text, "}") && strings.Count(text, "}") > strings.Count(text, "{") {
+		text = strings.TrimRight(text[:len(text)-1], " \t\r\n")
+	}
+	c.set(key, mapast.Placeholder)
+	c.set(o(key), c.bytes(text))
+	c.Skipped = append(c.Skipped, from)
+}
+
 // Visit is the main function used to translate go/ast to mapast. Visit is not
 // called directly, but instead the Conversion is passed to the ast.Walk call.
 func (c *Conversion) Visit(x ast.Node) ast.Visitor {
+	if x != nil {
+		c.pos = x.Pos()
+		c.depth++
+	} else if c.depth--; c.depth == 0 {
+		c.trailingcomments()
+	}
 	switch x.(type) {
 	case *ast.File:
 		var xx = (x).(*ast.File)
+		c.base = xx.FileStart
+		if c.Positions != nil {
+			c.Positions[0] = xx.Pos()
+			c.Positions[c.MyFile] = xx.Pos()
+		}
+		if len(xx.Decls) > 0 {
+			c.lastdecl = xx.Decls[len(xx.Decls)-1].End()
+		}
 		var imp = int(xx.Package)
 		if len(xx.Imports) > 0 {
 			imp = int(xx.Imports[0].Path.ValuePos)
@@ -345,16 +538,9 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					_, separ2 := c.EnderSepared[1][(sl+1)/2]
 					separ = separ1 || separ2
 				}
-				var variant = mapast.CommentRowNormal
-				if ender {
-					variant = mapast.CommentRowEnder
-				} else if separ {
-					variant = mapast.CommentRowSeparate
-				}
 				if sl > pk {
 					for k := range c.commentpos {
-						c.AstTree[o(c.MyFile)+c.importswhere] = mapast.CommentRow[0 : 1+fetchvariant(c.commentpos[k])]
-						c.AstTree[o(o(c.MyFile)+c.importswhere)] = []byte(c.comments[k])
+						c.setcomment(o(c.MyFile)+c.importswhere, c.commentpos[k], c.comments[k])
 						c.importswhere++
 					}
 					c.commentpos = c.commentpos[0:0]
@@ -369,14 +555,13 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					if separ {
 						variant = mapast.PackageDefSeparate
 					}
-					c.AstTree[o(c.MyFile)+c.importswhere] = mapast.PackageDef[0 : 1+variant]
-					c.AstTree[o(o(c.MyFile)+c.importswhere)] = []byte(n)
+					c.set(o(c.MyFile)+c.importswhere, mapast.PackageDefNode(variant))
+					c.set(o(o(c.MyFile)+c.importswhere), c.leaf(n, xx.Name.Pos()))
 					pk = 0xffffff
 					c.importswhere++
 				}
 				if sl < imp {
-					c.AstTree[o(c.MyFile)+c.importswhere] = mapast.CommentRow[0 : 1+variant]
-					c.AstTree[o(o(c.MyFile)+c.importswhere)] = []byte(ctext)
+					c.setcomment(o(c.MyFile)+c.importswhere, packint(sl, ender, separ), ctext)
 					c.importswhere++
 				} else {
 					c.comments = append(c.comments, ctext)
@@ -395,8 +580,8 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			if separ {
 				variant = mapast.PackageDefSeparate
 			}
-			c.AstTree[o(c.MyFile)+c.importswhere] = mapast.PackageDef[0 : 1+variant]
-			c.AstTree[o(o(c.MyFile)+c.importswhere)] = []byte(((x).(*ast.File)).Name.Name)
+			c.set(o(c.MyFile)+c.importswhere, mapast.PackageDefNode(variant))
+			c.set(o(o(c.MyFile)+c.importswhere), c.leaf(xx.Name.Name, xx.Name.Pos()))
 			c.importswhere++
 		}
 		c.comments = append(c.comments, "")
@@ -408,19 +593,21 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 
 	case *ast.GenDecl:
 		var xx = (x).(*ast.GenDecl)
+		var doc uint64
 		for (c.commentpos[0] & 0xfffffff) < int(xx.TokPos) {
-			c.AstTree[o(c.MyFile)+c.importswhere] = mapast.CommentRow[0 : 1+fetchvariant(c.commentpos[0])]
-			c.AstTree[o(o(c.MyFile)+c.importswhere)] = []byte(c.comments[0])
+			c.setcomment(o(c.MyFile)+c.importswhere, c.commentpos[0], c.comments[0])
 			c.importswhere++
+			doc = c.doccomment(xx.Doc, c.commentpos[0], doc)
 			c.commentpos = c.commentpos[1:]
 			c.comments = c.comments[1:]
 		}
+		c.setdoc(doc)
 		if xx.Tok == token.IMPORT {
 			if xx.Lparen == 0 {
 				c.importswhere++
 				c.nestedimports = 0
 			} else {
-				c.AstTree[o(c.MyFile)+c.importswhere] = mapast.ImportsDef
+				c.set(o(c.MyFile)+c.importswhere, mapast.ImportsDef)
 				c.importswhere++
 				c.nestedimports = 1
 			}
@@ -445,7 +632,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				blk = c.nowblock[len(c.nowblock)-1]
 				c.nowblock[len(c.nowblock)-1]++
 			}
-			c.AstTree[blk] = mapast.VarDefStmtNode(variant)
+			c.set(blk, mapast.VarDefStmtNode(variant))
 			for i := 0; i < len(xx.Specs); i++ {
 				xxx := xx.Specs[i].(*ast.ValueSpec)
 				var names = uint64(len(xxx.Names))
@@ -462,19 +649,19 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				} else if len(xxx.Values) != 1 {
 					panic("multiple values.")
 				}
-				c.AstTree[o(blk)+uint64(i)] = mapast.AssignStmtNode(variant, names+types+uint64(len(xxx.Values)))
+				c.set(o(blk)+uint64(i), mapast.AssignStmtNode(variant, names+types+uint64(len(xxx.Values))))
 				for j := range xxx.Names {
-					c.AstTree[o(o(blk)+uint64(i))+uint64(j)] = []byte(xxx.Names[j].Name)
+					c.set(o(o(blk)+uint64(i))+uint64(j), c.leaf(xxx.Names[j].Name, xxx.Names[j].Pos()))
 				}
 				if xxx.Type != nil {
 					id, ok := xxx.Type.(*ast.Ident)
 					var ident []byte
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
-					c.AstTree[o(o(blk)+uint64(i))+names] = mapast.RootOfType
+					c.set(o(o(blk)+uint64(i))+names, mapast.RootOfType)
 					if ok {
-						c.AstTree[o(o(o(blk)+uint64(i))+names)] = ident
+						c.set(o(o(o(blk)+uint64(i))+names), ident)
 					} else {
 						stack = append([]uint64{o(o(o(blk)+uint64(i)) + names)}, stack...)
 					}
@@ -483,10 +670,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					id, ok := xxx.Values[j].(*ast.Ident)
 					var ident []byte
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[o(o(blk)+uint64(i))+uint64(j)+names+types] = ident
+						c.set(o(o(blk)+uint64(i))+uint64(j)+names+types, ident)
 					} else {
 						stack = append([]uint64{o(o(blk)+uint64(i)) + uint64(j) + names + types}, stack...)
 					}
@@ -503,30 +690,35 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		} else {
 			where = o(c.MyFile) + c.importswhere - 1
 		}
-		c.AstTree[where] = mapast.ImportStmt
+		c.set(where, mapast.ImportStmt)
 		var p []byte
-		p = []byte(((x).(*ast.ImportSpec)).Path.Value)
+		p = c.leaf(((x).(*ast.ImportSpec)).Path.Value, ((x).(*ast.ImportSpec)).Path.Pos())
 		var n []byte
 		if (x).(*ast.ImportSpec).Name != nil {
 			n = p
-			p = []byte(((x).(*ast.ImportSpec)).Name.Name)
+			p = c.leaf(((x).(*ast.ImportSpec)).Name.Name, ((x).(*ast.ImportSpec)).Name.Pos())
 		}
-		c.AstTree[o(where)] = p
+		c.set(o(where), p)
 		if n != nil {
-			c.AstTree[o(where)+1] = n
+			c.set(o(where)+1, n)
 		}
 
 	case *ast.FuncDecl:
 		var xx = (x).(*ast.FuncDecl)
+		if xx.Type.TypeParams != nil {
+			c.unsupported(xx.Type.TypeParams.Pos(), "type parameters")
+		}
+		var doc uint64
 		for (c.commentpos[0] & 0xfffffff) < int(xx.Type.Func) {
 			if coolcomment(c.comments[0]) || c.Comments1 {
-				c.AstTree[o(c.MyFile)+c.importswhere] = mapast.CommentRow[0 : 1+fetchvariant(c.commentpos[0])]
-				c.AstTree[o(o(c.MyFile)+c.importswhere)] = []byte(c.comments[0])
+				c.setcomment(o(c.MyFile)+c.importswhere, c.commentpos[0], c.comments[0])
 				c.importswhere++
+				doc = c.doccomment(xx.Doc, c.commentpos[0], doc)
 			}
 			c.commentpos = c.commentpos[1:]
 			c.comments = c.comments[1:]
 		}
+		c.setdoc(doc)
 		var recv_count = uint64(bool2byte(xx.Recv != nil))
 		var argument_count uint64 = 0
 		var result_count uint64 = 0
@@ -541,8 +733,8 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var where uint64
 		where = o(c.MyFile) + c.importswhere
 		c.importswhere++
-		c.AstTree[where] = mapast.ToplevFuncNode(recv_count > 0, argument_count)
-		c.AstTree[o(where)] = []byte(xx.Name.Name)
+		c.set(where, mapast.ToplevFuncNode(recv_count > 0, argument_count))
+		c.set(o(where), c.leaf(xx.Name.Name, xx.Name.Pos()))
 		c.structfield = append(c.structfield, [2]uint64{o(where) + 1, totalparams})
 		c.deadif = make(map[*ast.IfStmt]struct{})
 		c.deadassignments = make(map[*ast.AssignStmt]struct{})
@@ -551,7 +743,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		c.deadexprs = make(map[*ast.ExprStmt]struct{})
 		c.typedcases = make(map[*ast.CaseClause]struct{})
 		if xx.Body != nil {
-			c.AstTree[o(where)+totalparams+1] = mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0)
+			c.set(o(where)+totalparams+1, mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0))
 			c.typefield = []uint64{}
 			c.blocksstmts[xx.Body] = o(o(where) + totalparams + 1)
 			c.nowblock = []uint64{o(o(where) + totalparams + 1)}
@@ -562,6 +754,9 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 
 	case *ast.TypeSpec:
 		var xx = (x).(*ast.TypeSpec)
+		if xx.TypeParams != nil {
+			c.unsupported(xx.TypeParams.Pos(), "type parameters")
+		}
 		for len(c.substmts) > 0 && len(c.subblocks) > 0 && c.substmts[len(c.substmts)-1] <= 0 && c.subblocks[len(c.subblocks)-1] <= 0 {
 			c.substmts = c.substmts[0 : len(c.substmts)-1]
 			c.subblocks = c.subblocks[0 : len(c.subblocks)-1]
@@ -584,12 +779,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		} else {
 			variant = mapast.TypDefStmtAlias
 		}
-		c.AstTree[where] = mapast.TypDefStmtNode(variant)
-		c.AstTree[o(where)] = []byte(xx.Name.Name)
-		c.AstTree[o(where)+1] = mapast.RootOfType
+		c.set(where, mapast.TypDefStmtNode(variant))
+		c.set(o(where), c.leaf(xx.Name.Name, xx.Name.Pos()))
+		c.set(o(where)+1, mapast.RootOfType)
 		switch xxx := xx.Type.(type) {
 		case *ast.Ident:
-			c.AstTree[o(o(where)+1)] = []byte(xxx.Name)
+			c.set(o(o(where)+1), c.leaf(xxx.Name, xxx.Pos()))
 
 		default:
 			c.typefield = append(c.typefield, o(o(where)+1))
@@ -611,16 +806,16 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		var t = c.structfield[len(c.structfield)-1][0]
 		for i := uint64(0); i < uint64(len(xx.Names)); i++ {
-			c.AstTree[o(t)+i] = []byte(xx.Names[i].Name)
+			c.set(o(t)+i, c.leaf(xx.Names[i].Name, xx.Names[i].Pos()))
 		}
-		c.AstTree[o(t)+uint64(len(xx.Names))] = mapast.RootOfType
+		c.set(o(t)+uint64(len(xx.Names)), mapast.RootOfType)
 		switch yyy := xx.Type.(type) {
 		case *ast.Ellipsis:
 			c.skippedellipsis++
 			variant = mapast.TypedIdentEllipsis
 			switch xxx := yyy.Elt.(type) {
 			case *ast.Ident:
-				c.AstTree[o(o(t)+uint64(len(xx.Names)))] = []byte(xxx.Name)
+				c.set(o(o(t)+uint64(len(xx.Names))), c.leaf(xxx.Name, xxx.Pos()))
 
 			default:
 				c.typefield = append(c.typefield, o(o(t)+uint64(len(xx.Names))))
@@ -628,7 +823,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			}
 
 		case *ast.Ident:
-			c.AstTree[o(o(t)+uint64(len(xx.Names)))] = []byte(yyy.Name)
+			c.set(o(o(t)+uint64(len(xx.Names))), c.leaf(yyy.Name, yyy.Pos()))
 
 		case *ast.FuncType:
 			_, ok := c.deadfunc[yyy]
@@ -642,9 +837,9 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		if xx.Tag != nil {
 			c.skippedbalits[xx.Tag] = struct{}{}
-			c.AstTree[(o(t) + 1 + uint64(len(xx.Names)))] = []byte(xx.Tag.Value)
+			c.set((o(t) + 1 + uint64(len(xx.Names))), c.leaf(xx.Tag.Value, xx.Tag.Pos()))
 		}
-		c.AstTree[t] = mapast.TypedIdent[0 : 1+variant]
+		c.set(t, mapast.TypedIdentNode(variant))
 		c.structfield[len(c.structfield)-1][0]++
 		if c.structfield[len(c.structfield)-1][1] != 0 {
 			c.structfield[len(c.structfield)-1][1]--
@@ -678,11 +873,11 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xxx.X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
@@ -694,12 +889,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xxx.X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)+theadcount] = mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC))
+				c.set(o(t)+theadcount, mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC)))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
@@ -711,23 +906,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id1, ok1 := xxx.Chan.(*ast.Ident)
 				var ident1 []byte
 				if ok1 {
-					ident1 = []byte(id1.Name)
+					ident1 = c.leaf(id1.Name, id1.Pos())
 				}
 				id2, ok2 := xxx.Value.(*ast.Ident)
 				var ident2 []byte
 				if ok2 {
-					ident2 = []byte(id2.Name)
+					ident2 = c.leaf(id2.Name, id2.Pos())
 				}
 				_ = ident1
 				_ = ident2
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 				if ok1 {
-					c.AstTree[o(o(t)+theadcount)] = ident1
+					c.set(o(o(t)+theadcount), ident1)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
 				if ok2 {
-					c.AstTree[o(o(t)+theadcount)+1] = ident2
+					c.set(o(o(t)+theadcount)+1, ident2)
 				} else {
 					stack = append([]uint64{o(o(t)+theadcount) + 1}, stack...)
 				}
@@ -786,10 +981,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					var ident []byte
 					id2, ok2 := xxx.Lhs[i].(*ast.Ident)
 					if ok2 {
-						ident = []byte(id2.Name)
+						ident = c.leaf(id2.Name, id2.Pos())
 					}
 					if ok2 {
-						c.AstTree[o(o(t)+theadcount)+r] = ident
+						c.set(o(o(t)+theadcount)+r, ident)
 					} else {
 						stack = append([]uint64{o(o(t)+theadcount) + r}, stack...)
 					}
@@ -799,37 +994,37 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					var ident []byte
 					id2, ok2 := xxx.Rhs[i].(*ast.Ident)
 					if ok2 {
-						ident = []byte(id2.Name)
+						ident = c.leaf(id2.Name, id2.Pos())
 					}
 					if ok2 {
-						c.AstTree[o(o(t)+theadcount)+r] = ident
+						c.set(o(o(t)+theadcount)+r, ident)
 					} else {
 						stack = append([]uint64{o(o(t)+theadcount) + r}, stack...)
 					}
 					r++
 				}
-				c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, (r))
+				c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, (r)))
 				theadcount++
 				c.skippedassignments++
 
 			}
-			c.AstTree[o(t)+theadcount] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+			c.set(o(t)+theadcount, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 			theadcount++
 		}
 		id, ok := xx.Cond.(*ast.Ident)
 		var ident []byte
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		_ = ident
-		c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+		c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 		if ok {
-			c.AstTree[o(o(t)+theadcount)] = ident
+			c.set(o(o(t)+theadcount), ident)
 		} else {
 			stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 		}
 		theadcount++
-		c.AstTree[t] = mapast.BlocOfCodeNode(variant, (theadcount))
+		c.set(t, mapast.BlocOfCodeNode(variant, (theadcount)))
 		c.ifblocks[xx.Body] = o(t) + theadcount
 		c.nowblock[len(c.nowblock)-1]++
 		for xx.Else != nil {
@@ -852,11 +1047,11 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 						id, ok := xxx.X.(*ast.Ident)
 						var ident []byte
 						if ok {
-							ident = []byte(id.Name)
+							ident = c.leaf(id.Name, id.Pos())
 						}
-						c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+						c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 						if ok {
-							c.AstTree[o(o(t)+theadcount)] = ident
+							c.set(o(o(t)+theadcount), ident)
 						} else {
 							stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 						}
@@ -868,12 +1063,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 						id, ok := xxx.X.(*ast.Ident)
 						var ident []byte
 						if ok {
-							ident = []byte(id.Name)
+							ident = c.leaf(id.Name, id.Pos())
 						}
 						_ = ident
-						c.AstTree[o(t)+theadcount] = mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC))
+						c.set(o(t)+theadcount, mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC)))
 						if ok {
-							c.AstTree[o(o(t)+theadcount)] = ident
+							c.set(o(o(t)+theadcount), ident)
 						} else {
 							stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 						}
@@ -885,23 +1080,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 						id1, ok1 := xxx.Chan.(*ast.Ident)
 						var ident1 []byte
 						if ok1 {
-							ident1 = []byte(id1.Name)
+							ident1 = c.leaf(id1.Name, id1.Pos())
 						}
 						id2, ok2 := xxx.Value.(*ast.Ident)
 						var ident2 []byte
 						if ok2 {
-							ident2 = []byte(id2.Name)
+							ident2 = c.leaf(id2.Name, id2.Pos())
 						}
 						_ = ident1
 						_ = ident2
-						c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+						c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 						if ok1 {
-							c.AstTree[o(o(t)+theadcount)] = ident1
+							c.set(o(o(t)+theadcount), ident1)
 						} else {
 							stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 						}
 						if ok2 {
-							c.AstTree[o(o(t)+theadcount)+1] = ident2
+							c.set(o(o(t)+theadcount)+1, ident2)
 						} else {
 							stack = append([]uint64{o(o(t)+theadcount) + 1}, stack...)
 						}
@@ -960,10 +1155,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 							var ident []byte
 							id2, ok2 := xxx.Lhs[i].(*ast.Ident)
 							if ok2 {
-								ident = []byte(id2.Name)
+								ident = c.leaf(id2.Name, id2.Pos())
 							}
 							if ok2 {
-								c.AstTree[o(o(t)+theadcount)+r] = ident
+								c.set(o(o(t)+theadcount)+r, ident)
 							} else {
 								stack = append([]uint64{o(o(t)+theadcount) + r}, stack...)
 							}
@@ -973,37 +1168,37 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 							var ident []byte
 							id2, ok2 := xxx.Rhs[i].(*ast.Ident)
 							if ok2 {
-								ident = []byte(id2.Name)
+								ident = c.leaf(id2.Name, id2.Pos())
 							}
 							if ok2 {
-								c.AstTree[o(o(t)+theadcount)+r] = ident
+								c.set(o(o(t)+theadcount)+r, ident)
 							} else {
 								stack = append([]uint64{o(o(t)+theadcount) + r}, stack...)
 							}
 							r++
 						}
-						c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, (r))
+						c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, (r)))
 						theadcount++
 						c.deadassignments[xxx] = struct{}{}
 
 					}
-					c.AstTree[o(t)+theadcount] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+					c.set(o(t)+theadcount, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 					theadcount++
 				}
 				id, ok := xx.Cond.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
 				theadcount++
-				c.AstTree[t] = mapast.BlocOfCodeNode(variant, (theadcount))
+				c.set(t, mapast.BlocOfCodeNode(variant, (theadcount)))
 				c.ifblocks[xx.Body] = o(t) + theadcount
 				c.nowblock[len(c.nowblock)-1]++
 				continue
@@ -1013,7 +1208,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				_ = zz
 				var t = c.nowblock[len(c.nowblock)-1]
 				var theadcount uint64 = 0
-				c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0)
+				c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0))
 				c.nowblock[len(c.nowblock)-1]++
 				c.ifblocks[zz] = o(t) + theadcount
 				break
@@ -1036,17 +1231,17 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id, ok := xx.X.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+			c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 			if ok {
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 			}
 			theadcount++
-			c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeForRange, (theadcount))
+			c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeForRange, (theadcount)))
 			c.nowblock[len(c.nowblock)-1]++
 			theadcount++
 			c.blocksstmts[xx.Body] = o(t) + theadcount - 1
@@ -1071,12 +1266,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xx.Key.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(o(t)+theadcount)] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+				c.set(o(o(t)+theadcount), mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 				if ok {
-					c.AstTree[o(o(o(t)+theadcount))] = ident
+					c.set(o(o(o(t)+theadcount)), ident)
 				} else {
 					stack = append([]uint64{(o(o(t) + theadcount))}, stack...)
 				}
@@ -1085,12 +1280,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xx.Value.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(o(t)+theadcount)+1] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+				c.set(o(o(t)+theadcount)+1, mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 				if ok {
-					c.AstTree[o(o(o(t)+theadcount)+1)] = ident
+					c.set(o(o(o(t)+theadcount)+1), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + 1)}, stack...)
 				}
@@ -1101,18 +1296,18 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id, ok := xx.X.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(o(t)+theadcount)+offset] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+			c.set(o(o(t)+theadcount)+offset, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 			if ok {
-				c.AstTree[o(o(o(t)+theadcount)+offset)] = ident
+				c.set(o(o(o(t)+theadcount)+offset), ident)
 			} else {
 				stack = append([]uint64{o(o(o(t)+theadcount) + offset)}, stack...)
 			}
-			c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, (1 + offset))
+			c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, (1+offset)))
 			theadcount++
-			c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeFor, (theadcount))
+			c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeFor, (theadcount)))
 			c.nowblock[len(c.nowblock)-1]++
 			theadcount++
 			c.blocksstmts[xx.Body] = o(t) + theadcount - 1
@@ -1142,12 +1337,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id, ok := xxx.X.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+			c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 			if ok {
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 			}
@@ -1159,12 +1354,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id, ok := xxx.X.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(t)+theadcount] = mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC))
+			c.set(o(t)+theadcount, mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC)))
 			if ok {
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 			}
@@ -1176,23 +1371,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id1, ok1 := xxx.Chan.(*ast.Ident)
 			var ident1 []byte
 			if ok1 {
-				ident1 = []byte(id1.Name)
+				ident1 = c.leaf(id1.Name, id1.Pos())
 			}
 			id2, ok2 := xxx.Value.(*ast.Ident)
 			var ident2 []byte
 			if ok2 {
-				ident2 = []byte(id2.Name)
+				ident2 = c.leaf(id2.Name, id2.Pos())
 			}
 			_ = ident1
 			_ = ident2
-			c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+			c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 			if ok1 {
-				c.AstTree[(o(o(t) + theadcount))] = ident1
+				c.set((o(o(t) + theadcount)), ident1)
 			} else {
 				stack = append([]uint64{(o(o(t) + theadcount))}, stack...)
 			}
 			if ok2 {
-				c.AstTree[(o(o(t)+theadcount) + 1)] = ident2
+				c.set((o(o(t)+theadcount) + 1), ident2)
 			} else {
 				stack = append([]uint64{(o(o(t)+theadcount) + 1)}, stack...)
 			}
@@ -1248,15 +1443,15 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			}
 			_ = variant
 			var l = uint64(len(xxx.Lhs))
-			c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs)))
+			c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs))))
 			for i := range xxx.Lhs {
 				var ident []byte
 				id, ok := xxx.Lhs[i].(*ast.Ident)
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				if ok {
-					c.AstTree[(o(o(t)+theadcount) + uint64(i))] = ident
+					c.set((o(o(t)+theadcount) + uint64(i)), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + uint64(i))}, stack...)
 				}
@@ -1265,10 +1460,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				var ident []byte
 				id, ok := xxx.Rhs[i].(*ast.Ident)
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				if ok {
-					c.AstTree[(o(o(t)+theadcount) + uint64(i) + l)] = ident
+					c.set((o(o(t)+theadcount) + uint64(i) + l), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + uint64(i) + l)}, stack...)
 				}
@@ -1278,26 +1473,26 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 
 		}
 		if !uniform {
-			c.AstTree[o(t)+theadcount] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+			c.set(o(t)+theadcount, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 			theadcount++
 		}
 		if xx.Cond != nil {
 			id, ok := xx.Cond.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+			c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 			if ok {
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 			}
 			theadcount++
 		}
 		if !uniform {
-			c.AstTree[o(t)+theadcount] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+			c.set(o(t)+theadcount, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 			theadcount++
 		}
 		switch xx.Post.(type) {
@@ -1306,12 +1501,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id, ok := xxx.X.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+			c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 			if ok {
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 			}
@@ -1323,12 +1518,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id, ok := xxx.X.(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			_ = ident
-			c.AstTree[o(t)+theadcount] = mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC))
+			c.set(o(t)+theadcount, mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC)))
 			if ok {
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 			}
@@ -1340,23 +1535,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			id1, ok1 := xxx.Chan.(*ast.Ident)
 			var ident1 []byte
 			if ok1 {
-				ident1 = []byte(id1.Name)
+				ident1 = c.leaf(id1.Name, id1.Pos())
 			}
 			id2, ok2 := xxx.Value.(*ast.Ident)
 			var ident2 []byte
 			if ok2 {
-				ident2 = []byte(id2.Name)
+				ident2 = c.leaf(id2.Name, id2.Pos())
 			}
 			_ = ident1
 			_ = ident2
-			c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+			c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 			if ok1 {
-				c.AstTree[(o(o(t) + theadcount))] = ident1
+				c.set((o(o(t) + theadcount)), ident1)
 			} else {
 				stack = append([]uint64{(o(o(t) + theadcount))}, stack...)
 			}
 			if ok2 {
-				c.AstTree[(o(o(t)+theadcount) + 1)] = ident2
+				c.set((o(o(t)+theadcount) + 1), ident2)
 			} else {
 				stack = append([]uint64{(o(o(t)+theadcount) + 1)}, stack...)
 			}
@@ -1411,15 +1606,15 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				variant += mapast.AssignStmtMoreEqual
 			}
 			var l = uint64(len(xxx.Lhs))
-			c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs)))
+			c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs))))
 			for i := range xxx.Lhs {
 				var ident []byte
 				id, ok := xxx.Lhs[i].(*ast.Ident)
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				if ok {
-					c.AstTree[(o(o(t)+theadcount) + uint64(i))] = ident
+					c.set((o(o(t)+theadcount) + uint64(i)), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + uint64(i))}, stack...)
 				}
@@ -1428,10 +1623,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				var ident []byte
 				id, ok := xxx.Rhs[i].(*ast.Ident)
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				if ok {
-					c.AstTree[(o(o(t)+theadcount) + uint64(i) + l)] = ident
+					c.set((o(o(t)+theadcount) + uint64(i) + l), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + uint64(i) + l)}, stack...)
 				}
@@ -1444,7 +1639,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		c.nowblock = append(c.nowblock, o(t)+theadcount)
 		c.subblocks = append(c.subblocks, how_many_subblocks_block(xx.Body))
 		c.substmts = append(c.substmts, how_many_substmts_block(xx.Body))
-		c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeFor, (theadcount))
+		c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeFor, (theadcount)))
 		c.typefield = append(c.typefield, stack...)
 
 	case *ast.SwitchStmt:
@@ -1456,7 +1651,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		c.subblocks[len(c.subblocks)-1]--
 		var t = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeSwitch, uint64(bool2byte(xx.Tag != nil)+2*bool2byte(xx.Init != nil)))
+		c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeSwitch, uint64(bool2byte(xx.Tag != nil)+2*bool2byte(xx.Init != nil))))
 		c.nowblock[len(c.nowblock)-1]++
 		var theadcount uint64
 		var stack []uint64
@@ -1467,12 +1662,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xxx.X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
@@ -1484,12 +1679,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xxx.X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)+theadcount] = mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC))
+				c.set(o(t)+theadcount, mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC)))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
@@ -1501,23 +1696,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id1, ok1 := xxx.Chan.(*ast.Ident)
 				var ident1 []byte
 				if ok1 {
-					ident1 = []byte(id1.Name)
+					ident1 = c.leaf(id1.Name, id1.Pos())
 				}
 				id2, ok2 := xxx.Value.(*ast.Ident)
 				var ident2 []byte
 				if ok2 {
-					ident2 = []byte(id2.Name)
+					ident2 = c.leaf(id2.Name, id2.Pos())
 				}
 				_ = ident1
 				_ = ident2
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 				if ok1 {
-					c.AstTree[(o(o(t) + theadcount))] = ident1
+					c.set((o(o(t) + theadcount)), ident1)
 				} else {
 					stack = append([]uint64{(o(o(t) + theadcount))}, stack...)
 				}
 				if ok2 {
-					c.AstTree[(o(o(t)+theadcount) + 1)] = ident2
+					c.set((o(o(t)+theadcount) + 1), ident2)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + 1)}, stack...)
 				}
@@ -1572,15 +1767,15 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					variant += mapast.AssignStmtMoreEqual
 				}
 				var l = uint64(len(xxx.Lhs))
-				c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs)))
+				c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs))))
 				for i := range xxx.Lhs {
 					var ident []byte
 					id, ok := xxx.Lhs[i].(*ast.Ident)
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[(o(o(t)+theadcount) + uint64(i))] = ident
+						c.set((o(o(t)+theadcount) + uint64(i)), ident)
 					} else {
 						stack = append([]uint64{(o(o(t)+theadcount) + uint64(i))}, stack...)
 					}
@@ -1589,10 +1784,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					var ident []byte
 					id, ok := xxx.Rhs[i].(*ast.Ident)
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[(o(o(t)+theadcount) + uint64(i) + l)] = ident
+						c.set((o(o(t)+theadcount) + uint64(i) + l), ident)
 					} else {
 						stack = append([]uint64{(o(o(t)+theadcount) + uint64(i) + l)}, stack...)
 					}
@@ -1601,25 +1796,25 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				c.skippedassignments++
 
 			}
-			c.AstTree[o(t)+theadcount] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+			c.set(o(t)+theadcount, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 			theadcount++
 		}
 		if xx.Tag != nil {
 			var ident []byte
 			id, ok := xx.Tag.(*ast.Ident)
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			} else {
 				id, ok2 := xx.Tag.(*ast.BasicLit)
 				if ok2 {
-					ident = []byte(id.Value)
+					ident = c.leaf(id.Value, id.Pos())
 					ok = true
 					c.skippedbalits[id] = struct{}{}
 				}
 			}
 			if ok {
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{o(t) + theadcount}, stack...)
 			}
@@ -1640,7 +1835,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		c.subblocks[len(c.subblocks)-1]--
 		var t = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeTypeSwitch, 1+2*uint64(bool2byte(xx.Init != nil)))
+		c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeTypeSwitch, 1+2*uint64(bool2byte(xx.Init != nil))))
 		c.nowblock[len(c.nowblock)-1]++
 		for _, v := range xx.Body.List {
 			if w, ok := v.(*ast.CaseClause); ok {
@@ -1658,12 +1853,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xxx.X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
@@ -1675,12 +1870,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xxx.X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)+theadcount] = mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC))
+				c.set(o(t)+theadcount, mapast.IncDecStmtNode(bool2byte(xxx.Tok != token.INC)))
 				if ok {
-					c.AstTree[o(o(t)+theadcount)] = ident
+					c.set(o(o(t)+theadcount), ident)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
@@ -1692,23 +1887,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id1, ok1 := xxx.Chan.(*ast.Ident)
 				var ident1 []byte
 				if ok1 {
-					ident1 = []byte(id1.Name)
+					ident1 = c.leaf(id1.Name, id1.Pos())
 				}
 				id2, ok2 := xxx.Value.(*ast.Ident)
 				var ident2 []byte
 				if ok2 {
-					ident2 = []byte(id2.Name)
+					ident2 = c.leaf(id2.Name, id2.Pos())
 				}
 				_ = ident1
 				_ = ident2
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 				if ok1 {
-					c.AstTree[(o(o(t) + theadcount))] = ident1
+					c.set((o(o(t) + theadcount)), ident1)
 				} else {
 					stack = append([]uint64{(o(o(t) + theadcount))}, stack...)
 				}
 				if ok2 {
-					c.AstTree[(o(o(t)+theadcount) + 1)] = ident2
+					c.set((o(o(t)+theadcount) + 1), ident2)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + 1)}, stack...)
 				}
@@ -1763,15 +1958,15 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					variant += mapast.AssignStmtMoreEqual
 				}
 				var l = uint64(len(xxx.Lhs))
-				c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs)))
+				c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs))))
 				for i := range xxx.Lhs {
 					var ident []byte
 					id, ok := xxx.Lhs[i].(*ast.Ident)
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[(o(o(t)+theadcount) + uint64(i))] = ident
+						c.set((o(o(t)+theadcount) + uint64(i)), ident)
 					} else {
 						stack = append([]uint64{(o(o(t)+theadcount) + uint64(i))}, stack...)
 					}
@@ -1780,10 +1975,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					var ident []byte
 					id, ok := xxx.Rhs[i].(*ast.Ident)
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[(o(o(t)+theadcount) + uint64(i) + l)] = ident
+						c.set((o(o(t)+theadcount) + uint64(i) + l), ident)
 					} else {
 						stack = append([]uint64{(o(o(t)+theadcount) + uint64(i) + l)}, stack...)
 					}
@@ -1792,17 +1987,17 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				c.skippedassignments++
 
 			}
-			c.AstTree[o(t)+theadcount] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+			c.set(o(t)+theadcount, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 			theadcount++
 		}
 		if _, ok = xx.Assign.(*ast.ExprStmt); ok {
 			id, ok := xx.Assign.(*ast.ExprStmt).X.(*ast.TypeAssertExpr).X.(*ast.Ident)
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			}
 			if ok {
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionType, 1)
-				c.AstTree[o(o(t)+theadcount)] = ident
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionType, 1))
+				c.set(o(o(t)+theadcount), ident)
 			} else {
 				stack = append([]uint64{(o(t) + theadcount)}, stack...)
 			}
@@ -1856,15 +2051,15 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				variant += mapast.AssignStmtMoreEqual
 			}
 			var l = uint64(len(xxx.Lhs))
-			c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs)))
+			c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs))))
 			for i := range xxx.Lhs {
 				var ident []byte
 				id, ok := xxx.Lhs[i].(*ast.Ident)
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				if ok {
-					c.AstTree[(o(o(t)+theadcount) + uint64(i))] = ident
+					c.set((o(o(t)+theadcount) + uint64(i)), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + uint64(i))}, stack...)
 				}
@@ -1873,10 +2068,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				var ident []byte
 				id, ok := xxx.Rhs[i].(*ast.Ident)
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				if ok {
-					c.AstTree[(o(o(t)+theadcount) + uint64(i) + l)] = ident
+					c.set((o(o(t)+theadcount) + uint64(i) + l), ident)
 				} else {
 					stack = append([]uint64{(o(o(t)+theadcount) + uint64(i) + l)}, stack...)
 				}
@@ -1907,27 +2102,27 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var stack []uint64
 		var t = c.nowblock[len(c.nowblock)-1]
 		c.nowblock[len(c.nowblock)-1]++
-		c.AstTree[t] = mapast.BlocOfCodeNode(variant, theadcount)
+		c.set(t, mapast.BlocOfCodeNode(variant, theadcount))
 		for i := uint64(0); i < uint64(len(xx.List)); i++ {
 			id, ok := xx.List[i].(*ast.Ident)
 			var ident []byte
 			if ok {
-				ident = []byte(id.Name)
+				ident = c.leaf(id.Name, id.Pos())
 			} else {
 				id3, ok3 := xx.List[i].(*ast.BasicLit)
 				if ok3 {
 					ok = true
-					ident = []byte(id3.Value)
+					ident = c.leaf(id3.Value, id3.Pos())
 					c.skippedbalits[id3] = struct{}{}
 				}
 			}
 			if _, ok2 := c.typedcases[xx]; ok2 {
-				c.AstTree[o(t)+i] = mapast.RootOfType
+				c.set(o(t)+i, mapast.RootOfType)
 			} else {
-				c.AstTree[o(t)+i] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+				c.set(o(t)+i, mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 			}
 			if ok {
-				c.AstTree[o(o(t)+i)] = ident
+				c.set(o(o(t)+i), ident)
 			} else {
 				stack = append([]uint64{(o(t) + i)}, stack...)
 			}
@@ -1960,7 +2155,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			}
 			c.subblocks[len(c.subblocks)-1]--
 			var t = c.nowblock[len(c.nowblock)-1]
-			c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0)
+			c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0))
 			c.nowblock[len(c.nowblock)-1]++
 			var subs = how_many_subblocks_stmt_list(xx.List)
 			var sus = how_many_substmts_stmt_list(xx.List)
@@ -1979,7 +2174,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		c.substmts[len(c.substmts)-1]--
 		if xx.Implicit == false {
 			var blk = c.nowblock[len(c.nowblock)-1]
-			c.AstTree[blk] = mapast.BranchStmtNode(mapast.BranchStmtSemi)
+			c.set(blk, mapast.BranchStmtNode(mapast.BranchStmtSemi))
 			c.nowblock[len(c.nowblock)-1]++
 		}
 
@@ -2010,14 +2205,14 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var blk = c.nowblock[len(c.nowblock)-1]
 		if variant == 255 {
 			if xx.Label == nil {
-				c.AstTree[blk] = mapast.BranchStmtNode(mapast.BranchStmtGoto)
+				c.set(blk, mapast.BranchStmtNode(mapast.BranchStmtGoto))
 			} else {
-				c.AstTree[blk] = mapast.LblGotoCntNode(mapast.LblGotoCntGoto)
-				c.AstTree[o(blk)] = []byte(xx.Label.Name)
+				c.set(blk, mapast.LblGotoCntNode(mapast.LblGotoCntGoto))
+				c.set(o(blk), c.leaf(xx.Label.Name, xx.Label.Pos()))
 			}
 		} else {
 			if xx.Label == nil {
-				c.AstTree[blk] = mapast.BranchStmtNode(variant)
+				c.set(blk, mapast.BranchStmtNode(variant))
 			} else {
 				switch variant {
 				case mapast.BranchStmtContinue:
@@ -2027,8 +2222,8 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					variant = mapast.LblGotoCntBreak
 
 				}
-				c.AstTree[blk] = mapast.LblGotoCntNode(variant)
-				c.AstTree[o(blk)] = []byte(xx.Label.Name)
+				c.set(blk, mapast.LblGotoCntNode(variant))
+				c.set(o(blk), c.leaf(xx.Label.Name, xx.Label.Pos()))
 			}
 		}
 		c.nowblock[len(c.nowblock)-1]++
@@ -2051,14 +2246,14 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id, ok := xx.X.(*ast.Ident)
 		var ident []byte
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		_ = ident
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+		c.set(blk, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 		if ok {
-			c.AstTree[o(blk)] = ident
+			c.set(o(blk), ident)
 		} else {
 			stack = append([]uint64{(blk)}, stack...)
 		}
@@ -2075,7 +2270,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			break
 		}
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = []byte(xx.Value)
+		c.set(where, c.leaf(xx.Value, xx.Pos()))
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 
 	case *ast.SelectorExpr:
@@ -2086,21 +2281,21 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.X.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
-		var ident2 = []byte(xx.Sel.Name)
+		var ident2 = c.leaf(xx.Sel.Name, xx.Sel.Pos())
 		const ok2 = true
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionDot, 2)
+		c.set(where, mapast.ExpressionNode(mapast.ExpressionDot, 2))
 		if ok1 {
-			c.AstTree[o(where)] = ident1
+			c.set(o(where), ident1)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
 		if ok2 {
-			c.AstTree[o(where)+1] = ident2
+			c.set(o(where)+1, ident2)
 		} else {
 			stack = append([]uint64{o(where) + 1}, stack...)
 		}
@@ -2118,23 +2313,23 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.ReturnStmt
+		c.set(blk, mapast.ReturnStmt)
 		for i := range xx.Results {
 			var ident []byte
 			id2, ok2 := xx.Results[i].(*ast.Ident)
 			if ok2 {
-				ident = []byte(id2.Name)
+				ident = c.leaf(id2.Name, id2.Pos())
 			} else {
 				id3, ok3 := xx.Results[i].(*ast.BasicLit)
 				if ok3 {
 					ok2 = true
-					ident = []byte(id3.Value)
+					ident = c.leaf(id3.Value, id3.Pos())
 					c.skippedbalits[id3] = struct{}{}
 				}
 			}
-			c.AstTree[o(blk)+uint64(i)] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+			c.set(o(blk)+uint64(i), mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 			if ok2 {
-				c.AstTree[o(o(blk)+uint64(i))] = ident
+				c.set(o(o(blk)+uint64(i)), ident)
 			} else {
 				stack = append([]uint64{(o(blk) + uint64(i))}, stack...)
 			}
@@ -2167,17 +2362,20 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		case token.ARROW:
 			variant = mapast.ExpressionArrow
 
+		case token.TILDE:
+			variant = mapast.ExpressionTilde
+
 		}
 		id, ok := xx.X.(*ast.Ident)
 		var ident []byte
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(variant, 1)
+		c.set(where, mapast.ExpressionNode(variant, 1))
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		if ok {
-			c.AstTree[o(where)] = ident
+			c.set(o(where), ident)
 		} else {
 			c.typefield = append(c.typefield, o(where))
 		}
@@ -2188,11 +2386,11 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			break
 		}
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = mapast.Expression[0 : 1+mapast.ExpressionMul : 1+mapast.ExpressionTotalCount]
+		c.set(where, mapast.ExpressionNode(mapast.ExpressionMul, 1))
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		id, ok := xx.X.(*ast.Ident)
 		if ok {
-			c.AstTree[o(where)] = []byte(id.Name)
+			c.set(o(where), c.leaf(id.Name, id.Pos()))
 		} else {
 			c.typefield = append(c.typefield, o(where))
 		}
@@ -2205,14 +2403,14 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id, ok := xx.X.(*ast.Ident)
 		var ident []byte
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+		c.set(where, mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		if ok {
-			c.AstTree[o(where)] = ident
+			c.set(o(where), ident)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
@@ -2286,24 +2484,24 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.X.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Y.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(variant, 2)
+		c.set(where, mapast.ExpressionNode(variant, 2))
 		if ok1 {
-			c.AstTree[o(where)] = ident1
+			c.set(o(where), ident1)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
 		if ok2 {
-			c.AstTree[o(where)+1] = ident2
+			c.set(o(where)+1, ident2)
 		} else {
 			stack = append([]uint64{o(where) + 1}, stack...)
 		}
@@ -2323,25 +2521,25 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id, ok := xx.Fun.(*ast.Ident)
 		var ident []byte
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		if ok {
-			c.AstTree[o(where)] = ident
+			c.set(o(where), ident)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
-		c.AstTree[where] = mapast.ExpressionNode(variant, 1+uint64(len(xx.Args)))
+		c.set(where, mapast.ExpressionNode(variant, 1+uint64(len(xx.Args))))
 		for i := range xx.Args {
 			var ident []byte
 			id2, ok2 := xx.Args[i].(*ast.Ident)
 			if ok2 {
-				ident = []byte(id2.Name)
+				ident = c.leaf(id2.Name, id2.Pos())
 			}
-			c.AstTree[o(where)+uint64(i)+1] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+			c.set(o(where)+uint64(i)+1, mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 			if ok2 {
-				c.AstTree[o(o(where)+uint64(i)+1)] = ident
+				c.set(o(o(where)+uint64(i)+1), ident)
 			} else {
 				stack = append([]uint64{(o(where) + uint64(i) + 1)}, stack...)
 			}
@@ -2369,17 +2567,17 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id, ok := xx.X.(*ast.Ident)
 		var ident []byte
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
 		if xx.Tok == token.INC {
-			c.AstTree[blk] = mapast.IncDecStmtNode(mapast.IncDecStmtPlusPlus)
+			c.set(blk, mapast.IncDecStmtNode(mapast.IncDecStmtPlusPlus))
 		} else {
-			c.AstTree[blk] = mapast.IncDecStmtNode(mapast.IncDecStmtMinusMinus)
+			c.set(blk, mapast.IncDecStmtNode(mapast.IncDecStmtMinusMinus))
 		}
 		if ok {
-			c.AstTree[(o(blk))] = ident
+			c.set((o(blk)), ident)
 		} else {
 			stack = append([]uint64{(o(blk))}, stack...)
 		}
@@ -2397,7 +2595,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.GoDferStmtNode(mapast.GoDferStmtGo)
+		c.set(blk, mapast.GoDferStmtNode(mapast.GoDferStmtGo))
 		stack = append([]uint64{(o(blk))}, stack...)
 		c.nowblock[len(c.nowblock)-1]++
 		c.typefield = append(c.typefield, stack...)
@@ -2411,7 +2609,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		c.substmts[len(c.substmts)-1]--
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.GoDferStmtNode(mapast.GoDferStmtDefer)
+		c.set(blk, mapast.GoDferStmtNode(mapast.GoDferStmtDefer))
 		stack = append([]uint64{(o(blk))}, stack...)
 		c.nowblock[len(c.nowblock)-1]++
 		c.typefield = append(c.typefield, stack...)
@@ -2434,12 +2632,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.Chan.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Value.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		_ = ok1
 		_ = ok2
@@ -2447,14 +2645,14 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		_ = ident2
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+		c.set(blk, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 		if ok1 {
-			c.AstTree[(o(blk))] = ident1
+			c.set((o(blk)), ident1)
 		} else {
 			stack = append([]uint64{(o(blk))}, stack...)
 		}
 		if ok2 {
-			c.AstTree[(o(blk) + 1)] = ident2
+			c.set((o(blk) + 1), ident2)
 		} else {
 			stack = append([]uint64{(o(blk) + 1)}, stack...)
 		}
@@ -2470,8 +2668,8 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		c.substmts[len(c.substmts)-1]--
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.LblGotoCntNode(mapast.LblGotoCntLabel)
-		c.AstTree[o(blk)] = []byte(xx.Label.Name)
+		c.set(blk, mapast.LblGotoCntNode(mapast.LblGotoCntLabel))
+		c.set(o(blk), c.leaf(xx.Label.Name, xx.Label.Pos()))
 		c.nowblock[len(c.nowblock)-1]++
 
 	case *ast.AssignStmt:
@@ -2536,16 +2734,16 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		var stack []uint64
 		var blk = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[blk] = mapast.AssignStmtNode(variant, uint64(len(xx.Lhs)+len(xx.Rhs)))
+		c.set(blk, mapast.AssignStmtNode(variant, uint64(len(xx.Lhs)+len(xx.Rhs))))
 		for i := range xx.Lhs {
 			var ident []byte
 			id2, ok2 := xx.Lhs[i].(*ast.Ident)
 			if ok2 {
-				ident = []byte(id2.Name)
+				ident = c.leaf(id2.Name, id2.Pos())
 			}
-			c.AstTree[o(blk)+uint64(i)] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+			c.set(o(blk)+uint64(i), mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 			if ok2 {
-				c.AstTree[o(o(blk)+uint64(i))] = ident
+				c.set(o(o(blk)+uint64(i)), ident)
 			} else {
 				stack = append([]uint64{(o(blk) + uint64(i))}, stack...)
 			}
@@ -2554,11 +2752,11 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 			var ident []byte
 			id2, ok2 := xx.Rhs[i].(*ast.Ident)
 			if ok2 {
-				ident = []byte(id2.Name)
+				ident = c.leaf(id2.Name, id2.Pos())
 			}
-			c.AstTree[o(blk)+uint64(i+len(xx.Lhs))] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+			c.set(o(blk)+uint64(i+len(xx.Lhs)), mapast.ExpressionNode(mapast.ExpressionIdentifier, 1))
 			if ok2 {
-				c.AstTree[o(o(blk)+uint64(i+len(xx.Lhs)))] = ident
+				c.set(o(o(blk)+uint64(i+len(xx.Lhs))), ident)
 			} else {
 				stack = append([]uint64{(o(blk) + uint64(i+len(xx.Lhs)))}, stack...)
 			}
@@ -2574,12 +2772,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.X.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Index.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		_ = ok1
 		_ = ok2
@@ -2588,14 +2786,14 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionIndex, 2)
+		c.set(where, mapast.ExpressionNode(mapast.ExpressionIndex, 2))
 		if ok1 {
-			c.AstTree[o(where)] = ident1
+			c.set(o(where), ident1)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
 		if ok2 {
-			c.AstTree[o(where)+1] = ident2
+			c.set(o(where)+1, ident2)
 		} else {
 			stack = append([]uint64{o(where) + 1}, stack...)
 		}
@@ -2609,12 +2807,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.X.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Low.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		if xx.Low == nil {
 			ok2 = true
@@ -2622,12 +2820,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id3, ok3 := xx.High.(*ast.Ident)
 		var ident3 []byte
 		if ok3 {
-			ident3 = []byte(id3.Name)
+			ident3 = c.leaf(id3.Name, id3.Pos())
 		}
 		id4, ok4 := xx.Max.(*ast.Ident)
 		var ident4 []byte
 		if ok4 {
-			ident4 = []byte(id4.Name)
+			ident4 = c.leaf(id4.Name, id4.Pos())
 		}
 		_ = ident3
 		_ = ident4
@@ -2635,36 +2833,36 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		if xx.Slice3 {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionSlice, 4)
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionSlice, 4))
 		} else if xx.High == nil {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionSlice, 2)
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionSlice, 2))
 		} else {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionSlice, 3)
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionSlice, 3))
 		}
 		if ok1 {
-			c.AstTree[o(where)] = ident1
+			c.set(o(where), ident1)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
 		if ok2 {
 			if xx.Low == nil {
-				c.AstTree[o(where)+1] = []byte("0")
+				c.set(o(where)+1, c.bytes("0"))
 			} else {
-				c.AstTree[o(where)+1] = ident2
+				c.set(o(where)+1, ident2)
 			}
 		} else {
 			stack = append([]uint64{o(where) + 1}, stack...)
 		}
 		if xx.High != nil {
 			if ok3 {
-				c.AstTree[o(where)+2] = ident3
+				c.set(o(where)+2, ident3)
 			} else {
 				stack = append([]uint64{o(where) + 2}, stack...)
 			}
 		}
 		if xx.Slice3 {
 			if ok4 {
-				c.AstTree[o(where)+3] = ident4
+				c.set(o(where)+3, ident4)
 			} else {
 				stack = append([]uint64{o(where) + 3}, stack...)
 			}
@@ -2688,12 +2886,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.Len.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Elt.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		_ = variant
 		_ = ident2
@@ -2701,16 +2899,16 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(variant, l)
+		c.set(where, mapast.ExpressionNode(variant, l))
 		if xx.Len != nil {
 			if ok1 {
-				c.AstTree[o(where)] = ident1
+				c.set(o(where), ident1)
 			} else {
 				stack = append([]uint64{o(where)}, stack...)
 			}
 		}
 		if ok2 {
-			c.AstTree[o(where)+l-1] = ident2
+			c.set(o(where)+l-1, ident2)
 		} else {
 			stack = append([]uint64{o(where) + l - 1}, stack...)
 		}
@@ -2724,24 +2922,24 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.Key.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Value.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionKeyVal, 2)
+		c.set(where, mapast.ExpressionNode(mapast.ExpressionKeyVal, 2))
 		if ok1 {
-			c.AstTree[o(where)] = ident1
+			c.set(o(where), ident1)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
 		if ok2 {
-			c.AstTree[o(where)+1] = ident2
+			c.set(o(where)+1, ident2)
 		} else {
 			stack = append([]uint64{o(where) + 1}, stack...)
 		}
@@ -2759,31 +2957,31 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.Type.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		if numtypes == 1 {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionComposite, 1+uint64(len(xx.Elts)))
-			c.AstTree[o(where)] = mapast.RootOfType
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionComposite, 1+uint64(len(xx.Elts))))
+			c.set(o(where), mapast.RootOfType)
 			if ok1 {
-				c.AstTree[o(o(where))] = ident1
+				c.set(o(o(where)), ident1)
 			} else {
 				stack = append([]uint64{o(o(where))}, stack...)
 			}
 		} else {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionComposed, 0+uint64(len(xx.Elts)))
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionComposed, 0+uint64(len(xx.Elts))))
 		}
 		for i := range xx.Elts {
 			id2, ok2 := xx.Elts[i].(*ast.Ident)
 			var ident2 []byte
 			if ok2 {
-				ident2 = []byte(id2.Name)
+				ident2 = c.leaf(id2.Name, id2.Pos())
 			}
 			_ = ident2
 			if ok2 {
-				c.AstTree[o(where)+uint64(i)+numtypes] = ident2
+				c.set(o(where)+uint64(i)+numtypes, ident2)
 			} else {
 				stack = append([]uint64{o(where) + uint64(i) + numtypes}, stack...)
 			}
@@ -2798,34 +2996,34 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.X.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		if xx.Type == nil {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionType, 1)
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionType, 1))
 			if ok1 {
-				c.AstTree[o(where)] = ident1
+				c.set(o(where), ident1)
 			} else {
 				stack = append([]uint64{o(where)}, stack...)
 			}
 		} else {
-			c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionType, 2)
+			c.set(where, mapast.ExpressionNode(mapast.ExpressionType, 2))
 			id2, ok2 := xx.Type.(*ast.Ident)
 			var ident2 []byte
 			if ok2 {
-				ident2 = []byte(id2.Name)
+				ident2 = c.leaf(id2.Name, id2.Pos())
 			}
 			_ = ident2
 			if ok1 {
-				c.AstTree[o(where)] = ident1
+				c.set(o(where), ident1)
 			} else {
 				stack = append([]uint64{o(where)}, stack...)
 			}
-			c.AstTree[o(where)+1] = mapast.RootOfType
+			c.set(o(where)+1, mapast.RootOfType)
 			if ok2 {
-				c.AstTree[o(o(where)+1)] = ident2
+				c.set(o(o(where)+1), ident2)
 			} else {
 				stack = append([]uint64{o(o(where) + 1)}, stack...)
 			}
@@ -2839,7 +3037,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		_ = xx
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = mapast.StructType
+		c.set(where, mapast.StructType)
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		var fieldscount = uint64(len(xx.Fields.List))
 		if fieldscount > 0 {
@@ -2854,26 +3052,26 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		id1, ok1 := xx.Key.(*ast.Ident)
 		var ident1 []byte
 		if ok1 {
-			ident1 = []byte(id1.Name)
+			ident1 = c.leaf(id1.Name, id1.Pos())
 		}
 		id2, ok2 := xx.Value.(*ast.Ident)
 		var ident2 []byte
 		if ok2 {
-			ident2 = []byte(id2.Name)
+			ident2 = c.leaf(id2.Name, id2.Pos())
 		}
 		_ = ident2
 		_ = ident1
 		var stack []uint64
 		var where = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(mapast.ExpressionMap, 2)
+		c.set(where, mapast.ExpressionNode(mapast.ExpressionMap, 2))
 		if ok1 {
-			c.AstTree[o(where)] = ident1
+			c.set(o(where), ident1)
 		} else {
 			stack = append([]uint64{o(where)}, stack...)
 		}
 		if ok2 {
-			c.AstTree[o(where)+1] = ident2
+			c.set(o(where)+1, ident2)
 		} else {
 			stack = append([]uint64{o(where) + 1}, stack...)
 		}
@@ -2894,8 +3092,8 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		if dimension > 0 {
 			c.structfield = append(c.structfield, [2]uint64{(o(t)), dimension})
 		}
-		c.AstTree[t] = mapast.ClosureExpNode(uint64(len(xx.Type.Params.List)))
-		c.AstTree[o(t)+dimension] = mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0)
+		c.set(t, mapast.ClosureExpNode(uint64(len(xx.Type.Params.List))))
+		c.set(o(t)+dimension, mapast.BlocOfCodeNode(mapast.BlocOfCodePlain, 0))
 		c.blocksstmts[xx.Body] = o(o(t) + dimension)
 		c.nowblock = append(c.nowblock, o(o(t)+dimension))
 		c.subblocks = append(c.subblocks, how_many_subblocks_block(xx.Body))
@@ -2909,7 +3107,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		_ = xx
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = mapast.IfceTypExp
+		c.set(where, mapast.IfceTypExp)
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		var stack []uint64
 		var structstack [][2]uint64
@@ -2925,22 +3123,20 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				if xx.Methods.List[i].Type.(*ast.FuncType).Results != nil {
 					nrets = len(xx.Methods.List[i].Type.(*ast.FuncType).Results.List)
 				}
-				c.AstTree[o(where)+uint64(i)] = mapast.IfceMethod[0 : npars+1]
+				c.set(o(where)+uint64(i), mapast.IfceMethodNode(uint64(npars)))
 				_ = nrets
 				structstack = append([][2]uint64{{o(o(where) + uint64(i)), uint64(1 + npars + nrets)}}, structstack...)
 
 			case *ast.Ident:
-				c.AstTree[o(where)+uint64(i)] = mapast.RootOfType
-				c.AstTree[o(o(where)+uint64(i))] = []byte(xx.Methods.List[i].Type.(*ast.Ident).Name)
+				c.set(o(where)+uint64(i), mapast.RootOfType)
+				c.set(o(o(where)+uint64(i)), c.leaf(xx.Methods.List[i].Type.(*ast.Ident).Name, xx.Methods.List[i].Type.Pos()))
 				structstack = append([][2]uint64{{0, 0}}, structstack...)
 
-			case *ast.SelectorExpr:
-				c.AstTree[o(where)+uint64(i)] = mapast.RootOfType
+			default:
+				c.set(o(where)+uint64(i), mapast.RootOfType)
 				stack = append([]uint64{o(o(where) + uint64(i))}, stack...)
 				structstack = append([][2]uint64{{0, 0}}, structstack...)
 
-			default:
-
 			}
 		}
 		c.structfield = append(c.structfield, structstack...)
@@ -2955,7 +3151,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		c.subblocks[len(c.subblocks)-1]--
 		var t = c.nowblock[len(c.nowblock)-1]
-		c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeSelect, 0)
+		c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeSelect, 0))
 		c.nowblock[len(c.nowblock)-1]++
 		c.blocksstmts[xx.Body] = o(t)
 		c.nowblock = append(c.nowblock, o(t))
@@ -2975,32 +3171,32 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		var theadcount uint64
 		var stack []uint64
 		if xx.Comm == nil {
-			c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeCommunicateDefault, 0)
+			c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeCommunicateDefault, 0))
 		} else {
-			c.AstTree[t] = mapast.BlocOfCodeNode(mapast.BlocOfCodeCommunicate, 1)
+			c.set(t, mapast.BlocOfCodeNode(mapast.BlocOfCodeCommunicate, 1))
 			switch xx.Comm.(type) {
 			case *ast.SendStmt:
 				xxx := xx.Comm.(*ast.SendStmt)
 				id1, ok1 := xxx.Chan.(*ast.Ident)
 				var ident1 []byte
 				if ok1 {
-					ident1 = []byte(id1.Name)
+					ident1 = c.leaf(id1.Name, id1.Pos())
 				}
 				id2, ok2 := xxx.Value.(*ast.Ident)
 				var ident2 []byte
 				if ok2 {
-					ident2 = []byte(id2.Name)
+					ident2 = c.leaf(id2.Name, id2.Pos())
 				}
 				_ = ident1
 				_ = ident2
-				c.AstTree[o(t)+theadcount] = mapast.ExpressionNode(mapast.ExpressionArrow, 2)
+				c.set(o(t)+theadcount, mapast.ExpressionNode(mapast.ExpressionArrow, 2))
 				if ok1 {
-					c.AstTree[o(o(t)+theadcount)] = ident1
+					c.set(o(o(t)+theadcount), ident1)
 				} else {
 					stack = append([]uint64{o(o(t) + theadcount)}, stack...)
 				}
 				if ok2 {
-					c.AstTree[o(o(t)+theadcount)+1] = ident2
+					c.set(o(o(t)+theadcount)+1, ident2)
 				} else {
 					stack = append([]uint64{o(o(t)+theadcount) + 1}, stack...)
 				}
@@ -3011,12 +3207,12 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 				id, ok := xx.Comm.(*ast.ExprStmt).X.(*ast.Ident)
 				var ident []byte
 				if ok {
-					ident = []byte(id.Name)
+					ident = c.leaf(id.Name, id.Pos())
 				}
 				_ = ident
-				c.AstTree[o(t)] = mapast.ExpressionNode(mapast.ExpressionBrackets, 1)
+				c.set(o(t), mapast.ExpressionNode(mapast.ExpressionBrackets, 1))
 				if ok {
-					c.AstTree[o(o(t))] = ident
+					c.set(o(o(t)), ident)
 				} else {
 					stack = append([]uint64{o(o(t))}, stack...)
 				}
@@ -3071,15 +3267,15 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					variant += mapast.AssignStmtMoreEqual
 				}
 				var l = uint64(len(xxx.Lhs))
-				c.AstTree[o(t)+theadcount] = mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs)))
+				c.set(o(t)+theadcount, mapast.AssignStmtNode(variant, uint64(len(xxx.Lhs)+len(xxx.Rhs))))
 				for i := range xxx.Lhs {
 					var ident []byte
 					id, ok := xxx.Lhs[i].(*ast.Ident)
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[(o(o(t)+theadcount) + uint64(i))] = ident
+						c.set((o(o(t)+theadcount) + uint64(i)), ident)
 					} else {
 						stack = append([]uint64{(o(o(t)+theadcount) + uint64(i))}, stack...)
 					}
@@ -3088,10 +3284,10 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 					var ident []byte
 					id, ok := xxx.Rhs[i].(*ast.Ident)
 					if ok {
-						ident = []byte(id.Name)
+						ident = c.leaf(id.Name, id.Pos())
 					}
 					if ok {
-						c.AstTree[(o(o(t)+theadcount) + uint64(i) + l)] = ident
+						c.set((o(o(t)+theadcount) + uint64(i) + l), ident)
 					} else {
 						stack = append([]uint64{(o(o(t)+theadcount) + uint64(i) + l)}, stack...)
 					}
@@ -3125,13 +3321,13 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 
 		}
 		if ok {
-			ident = []byte(id.Name)
+			ident = c.leaf(id.Name, id.Pos())
 		}
 		var where = c.typefield[len(c.typefield)-1]
-		c.AstTree[where] = mapast.ExpressionNode(variant, 1)
+		c.set(where, mapast.ExpressionNode(variant, 1))
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
 		if ok {
-			c.AstTree[o(where)] = ident
+			c.set(o(where), ident)
 		} else {
 			c.typefield = append(c.typefield, o(where))
 		}
@@ -3155,7 +3351,47 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		if dimension > 0 {
 			c.structfield = append(c.structfield, [2]uint64{(o(t)), dimension})
 		}
-		c.AstTree[t] = mapast.ClosureExpNode(uint64(len(xx.Params.List)))
+		c.set(t, mapast.ClosureExpNode(uint64(len(xx.Params.List))))
+
+	case *ast.IndexListExpr:
+		var xx = (x).(*ast.IndexListExpr)
+		c.unsupported(xx.Lbrack, "type argument list")
+
+	case *ast.BadExpr:
+		var xx = (x).(*ast.BadExpr)
+		if !c.Tolerant || len(c.typefield) == 0 {
+			break
+		}
+		var t = c.typefield[len(c.typefield)-1]
+		c.typefield = c.typefield[0 : len(c.typefield)-1]
+		c.placeholder(t, xx.From, xx.To)
+
+	case *ast.BadStmt:
+		var xx = (x).(*ast.BadStmt)
+		for c.substmts[len(c.substmts)-1] <= 0 && c.subblocks[len(c.subblocks)-1] <= 0 {
+			c.substmts = c.substmts[0 : len(c.substmts)-1]
+			c.subblocks = c.subblocks[0 : len(c.subblocks)-1]
+			c.nowblock = c.nowblock[0 : len(c.nowblock)-1]
+		}
+		c.substmts[len(c.substmts)-1]--
+		if c.Tolerant {
+			c.placeholder(c.nowblock[len(c.nowblock)-1], xx.From, xx.To)
+			c.nowblock[len(c.nowblock)-1]++
+		}
+
+	case *ast.BadDecl:
+		var xx = (x).(*ast.BadDecl)
+		if !c.Tolerant {
+			break
+		}
+		for (c.commentpos[0] & 0xfffffff) < int(xx.From) {
+			c.setcomment(o(c.MyFile)+c.importswhere, c.commentpos[0], c.comments[0])
+			c.importswhere++
+			c.commentpos = c.commentpos[1:]
+			c.comments = c.comments[1:]
+		}
+		c.placeholder(o(c.MyFile)+c.importswhere, xx.From, xx.To)
+		c.importswhere++
 
 	case *ast.Ellipsis:
 		if c.skippedellipsis > 0 {
@@ -3167,7 +3403,7 @@ func (c *Conversion) Visit(x ast.Node) ast.Visitor {
 		}
 		var t = c.typefield[len(c.typefield)-1]
 		c.typefield = c.typefield[0 : len(c.typefield)-1]
-		c.AstTree[t] = []byte("...")
+		c.set(t, c.bytes("..."))
 
 	default:
 
@@ -0,0 +1,116 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Loaded is a package found by Load, with its files parsed.
+type Loaded struct {
+	// Path is the import path of the package. The external test package of
+	// a directory has _test appended to the path of the directory.
+	Path string
+	Name string
+	Dir  string
+	// Files holds the names of the files, Srcs their source code and Syntax
+	// the files parsed with comments into Fset.
+	Files  []string
+	Srcs   [][]byte
+	Syntax []*ast.File
+	Fset   *token.FileSet
+}
+
+// listed is the part of the output of go list read by Load.
+type listed struct {
+	Dir          string
+	ImportPath   string
+	Name         string
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Error        *struct {
+		Err string
+	}
+}
+
+// Load finds the packages of the patterns, such as ./... or an import path,
+// like go/packages does: it asks go list in the current directory, so the
+// packages are resolved by the go command, in module or in GOPATH mode, and
+// the files excluded by build constraints are left out. If tests is set, the
+// test files are loaded with their package, and the external test files of
+// a directory as a package of their own. The files can be converted to one
+// tree per package by Package.
+func Load(tests bool, patterns ...string) ([]*Loaded, error) {
+	var cmd = exec.Command("go", append([]string{"list", "-json", "--"}, patterns...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	var out, err = cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New("convert: go list: " + msg)
+		}
+		return nil, err
+	}
+	var loaded []*Loaded
+	var dec = json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listed
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if p.Error != nil {
+			return nil, errors.New("convert: " + p.ImportPath + ": " + p.Error.Err)
+		}
+		var files = append(append([]string{}, p.GoFiles...), p.CgoFiles...)
+		if tests {
+			files = append(files, p.TestGoFiles...)
+		}
+		l, err := parsefiles(p.ImportPath, p.Dir, files)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, l)
+		if !tests || len(p.XTestGoFiles) == 0 {
+			continue
+		}
+		if l, err = parsefiles(p.ImportPath+"_test", p.Dir, p.XTestGoFiles); err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, l)
+	}
+	return loaded, nil
+}
+
+// parsefiles reads and parses the files of the directory as the package of
+// the path.
+func parsefiles(path string, dir string, names []string) (*Loaded, error) {
+	var l = &Loaded{Path: path, Dir: dir, Fset: token.NewFileSet()}
+	for _, name := range names {
+		name = filepath.Join(dir, name)
+		var src, err = os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(l.Fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		l.Name = file.Name.Name
+		l.Files = append(l.Files, name)
+		l.Srcs = append(l.Srcs, src)
+		l.Syntax = append(l.Syntax, file)
+	}
+	return l, nil
+}
@@ -0,0 +1,302 @@
+package convert
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+)
+
+// Parse parses the go source code of a single file and converts it to a new
+// mapast tree. The tree has the RootMatter at key zero, and the file is its
+// first child. Comments are kept. Parse lives in package convert, because
+// package mapast cannot import the converter. If the file has constructs
+// mapast cannot represent, the lossy tree is returned with a scanner.ErrorList
+// holding an UnsupportedNode error per construct.
+func Parse(src []byte) (map[uint64][]byte, error) {
+	asttree, _, err := ParsePositions(token.NewFileSet(), "", src)
+	return asttree, err
+}
+
+// ParsePositions is like Parse, but it also returns the position side-table
+// mapping the key of every node of the tree to the position of the go/ast node
+// it was translated from. The file is added to the file set under the file
+// name, so the positions can be turned into file, line and column. Like
+// Parse, it returns the lossy tree with the errors of the constructs mapast
+// cannot represent.
+func ParsePositions(fset *token.FileSet, filename string, src []byte) (map[uint64][]byte, map[uint64]token.Pos, error) {
+	positions := make(map[uint64]token.Pos, EstimateNodes(src))
+	asttree, _, err := parsefile(fset, filename, src, func(c *Conversion) {
+		c.Positions = positions
+	})
+	if asttree == nil {
+		return nil, nil, err
+	}
+	return asttree, positions, err
+}
+
+// ParseDocs is like Parse, but it also returns the side-table of the doc
+// comments, holding the number of CommentRows forming the doc comment of every
+// top level declaration having one, for the key of the declaration. The edits
+// of mapast taking the doc comments along with the declarations need it.
+func ParseDocs(src []byte) (map[uint64][]byte, map[uint64]uint64, error) {
+	docs := make(map[uint64]uint64)
+	asttree, _, err := parsefile(token.NewFileSet(), "", src, func(c *Conversion) {
+		c.Docs = docs
+	})
+	if asttree == nil {
+		return nil, nil, err
+	}
+	return asttree, docs, err
+}
+
+// parsefile parses the file and converts it to a new tree, with the
+// conversion set up by the function. It returns the tree, the parsed file and
+// the lossy errors of the conversion, or only the error if the file does not
+// parse.
+func parsefile(fset *token.FileSet, filename string, src []byte, setup func(c *Conversion)) (map[uint64][]byte, *ast.File, error) {
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	asttree := make(map[uint64][]byte, EstimateNodes(src))
+	c := NewConversion(asttree, 0, src)
+	setup(c)
+	ast.Walk(c, file)
+	return asttree, file, lossy(fset, c.Errors())
+}
+
+// lossy returns the errors of the conversion as a scanner.ErrorList, holding
+// the position of every UnsupportedNode, or nil if there are none.
+func lossy(fset *token.FileSet, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	var list scanner.ErrorList
+	for _, err := range errs {
+		var pos token.Position
+		if unsupported, ok := err.(*UnsupportedNode); ok {
+			pos = fset.Position(unsupported.Pos)
+		}
+		list.Add(pos, err.Error())
+	}
+	return list
+}
+
+// ParseSpans is like Parse, but it also returns the spans of the package
+// clause and the top level declarations of the file and of the statements of
+// their blocks, keyed by the key of their node, and the empty spans of the
+// comments. The spans let mapast.CodeLossless print the declarations and the
+// statements that were not modified verbatim. Declarations translated to
+// several nodes, such as grouped type declarations, have no span, nor do the
+// statements sharing their source with their siblings, such as the branches
+// of an else if. Like Parse, it returns the lossy tree with the errors of the
+// constructs mapast cannot represent.
+func ParseSpans(src []byte) (map[uint64][]byte, map[uint64]mapast.Span, error) {
+	fset := token.NewFileSet()
+	positions := make(map[uint64]token.Pos, EstimateNodes(src))
+	asttree, file, err := parsefile(fset, "", src, func(c *Conversion) {
+		c.Positions = positions
+	})
+	if asttree == nil {
+		return nil, nil, err
+	}
+	tfile := fset.File(file.Pos())
+	spans := make(map[uint64]mapast.Span)
+	// owned records the span of the node among the sibling keys whose
+	// position lies within the node, if it is the only one.
+	owned := func(node ast.Node, siblings []uint64) {
+		var owner []uint64
+		for _, key := range siblings {
+			if pos := positions[key]; node.Pos() <= pos && pos < node.End() {
+				owner = append(owner, key)
+			}
+		}
+		if len(owner) == 1 {
+			spans[owner[0]] = mapast.NewSpan(asttree, owner[0], tfile.Offset(node.Pos()), tfile.Offset(node.End()))
+		}
+	}
+	var decls []uint64
+	for i := uint64(0); mapast.Poke(asttree, o(o(0))+i); i++ {
+		if key := o(o(0)) + i; declaration(asttree[key]) {
+			decls = append(decls, key)
+		} else if mapast.Is(asttree[key], mapast.PackageDef) {
+			spans[key] = mapast.NewSpan(asttree, key, tfile.Offset(file.Package), tfile.Offset(file.Name.End()))
+		}
+	}
+	for _, decl := range file.Decls {
+		owned(decl, decls)
+	}
+	// The statements of the blocks are matched by their position with the
+	// statements of the lists of go/ast.
+	stmts := make(map[token.Pos]ast.Stmt)
+	ast.Inspect(file, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+
+		case *ast.CaseClause:
+			list = n.Body
+
+		case *ast.CommClause:
+			list = n.Body
+		}
+		for _, stmt := range list {
+			stmts[stmt.Pos()] = stmt
+		}
+		return true
+	})
+	mapast.Walk(asttree, 0, func(key, parent uint64, node []byte) bool {
+		if !mapast.Is(node, mapast.BlocOfCode) {
+			return true
+		}
+		var siblings []uint64
+		for _, child := range mapast.ChildKeys(asttree, key)[mapast.Count(node):] {
+			if !mapast.Is(asttree[child], mapast.CommentRow) {
+				siblings = append(siblings, child)
+			}
+		}
+		for _, child := range siblings {
+			if stmt, ok := stmts[positions[child]]; ok {
+				owned(stmt, siblings)
+			}
+		}
+		return true
+	})
+	mapast.Walk(asttree, 0, func(key, parent uint64, node []byte) bool {
+		if !mapast.Is(node, mapast.CommentRow) {
+			return true
+		}
+		if pos := positions[key]; pos.IsValid() {
+			offset := tfile.Offset(pos)
+			spans[key] = mapast.NewSpan(asttree, key, offset, offset)
+		}
+		return false
+	})
+	return asttree, spans, err
+}
+
+// declaration reports whether the node is a top level declaration.
+func declaration(node []byte) bool {
+	for _, kind := range [][]byte{mapast.ImportStmt, mapast.ImportsDef, mapast.VarDefStmt,
+		mapast.TypDefStmt, mapast.ToplevFunc} {
+		if mapast.Is(node, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Package converts the files of a package into a single tree. The tree has the
+// RootMatter at key zero and one FileMatter child per file, in the order of
+// the files, so that mapast.Code prints a single file when given the key of
+// its FileMatter. Srcs holds the source code of the files, used to scan for
+// comments info, and fset the file set the files were parsed into. Like
+// Parse, it returns the lossy tree with the errors of the constructs mapast
+// cannot represent, found in any of the files.
+func Package(fset *token.FileSet, files []*ast.File, srcs [][]byte) (map[uint64][]byte, error) {
+	size := 0
+	for i := range srcs {
+		size += EstimateNodes(srcs[i])
+	}
+	asttree := make(map[uint64][]byte, size)
+	asttree[0] = mapast.RootMatter
+	var errs []error
+	for i := range files {
+		c := NewConversion(asttree, uint64(i), srcs[i])
+		ast.Walk(c, files[i])
+		errs = append(errs, c.Errors()...)
+	}
+	return asttree, lossy(fset, errs)
+}
+
+// ParseExprInto parses the go expression and converts it into the tree at the
+// destination key, replacing the subtree there. An expression that converts
+// to a plain string, such as an identifier, is wrapped by
+// ExpressionIdentifier, so it is valid under any parent.
+func ParseExprInto(asttree map[uint64][]byte, dstKey uint64, src string) error {
+	if _, err := parser.ParseExpr(src); err != nil {
+		return err
+	}
+	fragment, err := Parse([]byte("package p\n\nvar _ = " + src + "\n"))
+	if err != nil {
+		return err
+	}
+	expr := o(o(o(o(0))+1)) + 1
+	mapast.CopySubtree(fragment, expr, asttree, dstKey)
+	if mapast.Which(asttree[dstKey]) == nil {
+		asttree[o(dstKey)] = asttree[dstKey]
+		asttree[dstKey] = mapast.ExpressionNode(mapast.ExpressionIdentifier, 1)
+	}
+	return nil
+}
+
+// ParseStmtsInto parses the go statements and converts them into children of
+// the parent node, usually a BlocOfCode, starting at the index. The children
+// previously at the index and above are moved after the inserted ones.
+// If the statements have constructs mapast cannot represent, nothing is
+// inserted and the errors are returned like Parse returns them.
+func ParseStmtsInto(asttree map[uint64][]byte, parent uint64, index uint64, src string) error {
+	// The line directive makes the positions of the errors those of the
+	// statements.
+	wrapped := "package p\n\nfunc _() {\n//line :1:1\n" + src + "\n}\n"
+	fragment, file, err := parsefile(token.NewFileSet(), "", []byte(wrapped), func(*Conversion) {})
+	if err != nil {
+		return err
+	}
+	if len(file.Decls) != 1 {
+		return errors.New("convert: statements must not close the block")
+	}
+	body := o(o(o(0))+1) + 1
+	var stmts []map[uint64][]byte
+	for i := uint64(0); mapast.Poke(fragment, o(body)+i); i++ {
+		stmt := make(map[uint64][]byte)
+		mapast.CopySubtree(fragment, o(body)+i, stmt, 0)
+		stmts = append(stmts, stmt)
+	}
+	mapast.InsertChild(asttree, parent, index, stmts...)
+	return nil
+}
+
+// ParseTolerant is like Parse, but it also converts source code that does not
+// parse completely. The regions the parser could not make sense of become
+// mapast.Placeholder nodes, printed back verbatim, and their positions in the
+// file set are returned. An error is returned only if the parser gives up on
+// the whole file.
+func ParseTolerant(fset *token.FileSet, filename string, src []byte) (map[uint64][]byte, []token.Pos, error) {
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if file == nil {
+		return nil, nil, err
+	}
+	asttree := make(map[uint64][]byte, EstimateNodes(src))
+	c := NewConversion(asttree, 0, src)
+	c.Tolerant = true
+	ast.Walk(c, file)
+	return asttree, c.Skipped, nil
+}
+
+// VerifyChecksum converts the source code of a file written by
+// mapast.CodeChecksum and checks that it still matches the hash of its
+// trailer comment, with mapast.VerifyChecksum. It returns an error if the
+// file does not convert, if the trailer is missing or if the code was modified
+// since it was generated.
+func VerifyChecksum(src []byte) error {
+	asttree, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	return mapast.VerifyChecksum(asttree, 0)
+}
+
+// VerifyChecksumFile is like VerifyChecksum, for the file at the path.
+func VerifyChecksumFile(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return VerifyChecksum(src)
+}
@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// templateprefix replaces the $ of template variables while parsing, so that
+// they become identifiers.
+const templateprefix = "mapast_template_var_"
+
+// templatesource returns the source code with the $ of the template variables
+// replaced, so that they parse as identifiers.
+func templatesource(src string) string {
+	var s scanner.Scanner
+	file := token.NewFileSet().AddFile("", -1, len(src))
+	s.Init(file, []byte(src), func(token.Position, string) {}, 0)
+	var b strings.Builder
+	last, dollar := 0, -1
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		offset := file.Offset(pos)
+		if tok == token.IDENT && dollar >= 0 && offset == dollar+1 {
+			b.WriteString(src[last:dollar])
+			b.WriteString(templateprefix)
+			last = offset
+		}
+		if tok == token.ILLEGAL && lit == "$" {
+			dollar = offset
+		}
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
+
+// templatevars turns the identifiers replaced by templatesource back into
+// mapast.TemplateVar leaves.
+func templatevars(template map[uint64][]byte) {
+	mapast.Walk(template, 0, func(key, parent uint64, node []byte) bool {
+		if mapast.Which(node) == nil && strings.HasPrefix(string(node), templateprefix) {
+			template[key] = mapast.TemplateVar(string(node[len(templateprefix):]))
+		}
+		return true
+	})
+}
+
+// ParseTemplate parses the go source code of a single top level declaration
+// containing template variables, such as func ($recv) String() string { $body },
+// and converts it to a template for mapast.Instantiate. The template has the
+// declaration at key zero and every variable is a mapast.TemplateVar leaf.
+func ParseTemplate(src string) (map[uint64][]byte, error) {
+	fragment, err := Parse([]byte("package p\n\n" + templatesource(src) + "\n"))
+	if err != nil {
+		return nil, err
+	}
+	decl := o(o(0)) + 1
+	if !mapast.Poke(fragment, decl) || mapast.Poke(fragment, decl+1) {
+		return nil, errors.New("convert: template must be a single declaration")
+	}
+	template := make(map[uint64][]byte)
+	mapast.CopySubtree(fragment, decl, template, 0)
+	templatevars(template)
+	return template, nil
+}
+
+// ParsePattern parses the go source code of a single expression, statement or
+// top level declaration containing template variables, such as $x == nil, and
+// converts it to a template for mapast.Match. The template has the node at
+// key zero and every variable is a mapast.TemplateVar leaf.
+func ParsePattern(src string) (map[uint64][]byte, error) {
+	source := templatesource(src)
+	var fragment map[uint64][]byte
+	var at uint64
+	if _, err := parser.ParseExpr(source); err == nil {
+		fragment, err = Parse([]byte("package p\n\nvar _ = " + source + "\n"))
+		if err != nil {
+			return nil, err
+		}
+		at = o(o(o(o(0))+1)) + 1
+	} else if fragment, err = Parse([]byte("package p\n\nfunc _() {\n" + source + "\n}\n")); err == nil {
+		at = o(o(o(0))+1) + 1
+		if !mapast.Poke(fragment, o(at)) || mapast.Poke(fragment, o(at)+1) {
+			return nil, errors.New("convert: pattern must be a single statement")
+		}
+		at = o(at)
+	} else if template, err := ParseTemplate(src); err == nil {
+		return template, nil
+	} else {
+		return nil, errors.New("convert: pattern is neither an expression, a statement nor a declaration")
+	}
+	template := make(map[uint64][]byte)
+	mapast.CopySubtree(fragment, at, template, 0)
+	templatevars(template)
+	return template, nil
+}
@@ -0,0 +1,197 @@
+package mapast_test
+
+import (
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"testing"
+)
+
+// top holds samples that are whole files.
+var top = map[mapast.Kind]string{
+	mapast.KindRootMatter:           "package p\n",
+	mapast.KindFileMatter:           "package p\n",
+	mapast.KindPackageDefNormal:     "package p\n",
+	mapast.KindPackageDefSeparate:   "// Package p.\n\npackage p\n",
+	mapast.KindImportStmt:           "package p\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n",
+	mapast.KindImportsDef:           "package p\n\nimport (\n\t\"fmt\"\n)\n\nvar _ = fmt.Sprint\n",
+	mapast.KindTypedIdentNormal:     "package p\n\nfunc f(a int) {}\n",
+	mapast.KindTypedIdentEllipsis:   "package p\n\nfunc f(a ...int) {}\n",
+	mapast.KindTypedIdentTagged:     "package p\n\ntype T struct {\n\tA int `json:\"a\"`\n}\n",
+	mapast.KindRootOfType:           "package p\n\nvar a int\n",
+	mapast.KindTypDefStmtNormal:     "package p\n\ntype T int\n",
+	mapast.KindTypDefStmtAlias:      "package p\n\ntype T = int\n",
+	mapast.KindStructType:           "package p\n\ntype T struct {\n\tA int\n}\n",
+	mapast.KindVarDefStmtVar:        "package p\n\nvar a = 1\n",
+	mapast.KindVarDefStmtConst:      "package p\n\nconst a = 1\n",
+	mapast.KindIfceTypExp:           "package p\n\ntype I interface {\n\tM()\n}\n",
+	mapast.KindIfceMethod:           "package p\n\ntype I interface {\n\tM(a int) (b int)\n}\n",
+	mapast.KindCommentRowEnder:      "package p\n\nvar a = 1 // a\n\nvar b = 1\n",
+	mapast.KindCommentRowNormal:     "package p\n\nvar a = 1\n// b\nvar b = 1\n",
+	mapast.KindCommentRowSeparate:   "package p\n\nvar a = 1\n\n// b\n\nvar b = 1\n",
+	mapast.KindToplevFunc:           "package p\n\nfunc (r T) f(a int) (b int) { return a }\n\ntype T int\n",
+	mapast.KindExpressionArrayType:  "package p\n\nvar a [4]int\n",
+	mapast.KindExpressionTilde:      "package p\n\ntype N interface {\n\t~int | ~string\n}\n",
+	mapast.KindExpressionSliceType:  "package p\n\nvar a []int\n",
+	mapast.KindExpressionMap:        "package p\n\nvar a map[string]int\n",
+	mapast.KindExpressionChan:       "package p\n\nvar a chan int\n",
+	mapast.KindExpressionInChan:     "package p\n\nvar a chan<- int\n",
+	mapast.KindExpressionOutChan:    "package p\n\nvar a <-chan int\n",
+	mapast.KindExpressionIdentifier: "package p\n\nfunc f(a int) int {\n\treturn a\n}\n",
+	mapast.KindAssignStmtIotaIsLast: "package p\n\nconst (\n\ta = iota\n\tb\n)\n",
+	mapast.KindAssignStmtTypeIsLast: "package p\n\nvar a, b int\n",
+	mapast.KindAssignStmtMoreEqual:  "package p\n\nvar a, b int = f()\n\nfunc f() (int, int) { return 1, 2 }\n",
+}
+
+// body holds samples that are statements of a function body. The function has
+// parameters a and b of type int, s of type []int, m of type map[int]int, c of
+// type chan int, x of type interface{} and f of type func(...int) int.
+var body = map[mapast.Kind]string{
+	mapast.KindBranchStmtSemi:               "for i := 0; i < a; i++ {\n}",
+	mapast.KindBranchStmtBreak:              "for {\n\tbreak\n}",
+	mapast.KindBranchStmtContinue:           "for {\n\tcontinue\n}",
+	mapast.KindBranchStmtFallthrough:        "switch a {\ncase 1:\n\tfallthrough\ndefault:\n}",
+	mapast.KindGoDferStmtGo:                 "go f()",
+	mapast.KindGoDferStmtDefer:              "defer f()",
+	mapast.KindReturnStmt:                   "return",
+	mapast.KindIncDecStmtPlusPlus:           "a++",
+	mapast.KindIncDecStmtMinusMinus:         "a--",
+	mapast.KindLblGotoCntLabel:              "L:\n\tfor {\n\t\tbreak L\n\t}",
+	mapast.KindLblGotoCntGoto:               "goto L\nL:\n\ta++",
+	mapast.KindLblGotoCntContinue:           "L:\n\tfor {\n\t\tcontinue L\n\t}",
+	mapast.KindLblGotoCntBreak:              "L:\n\tfor {\n\t\tbreak L\n\t}",
+	mapast.KindExpressionBrackets:           "a = (a + b) * b",
+	mapast.KindExpressionOrOr:               "_ = a > 0 || b > 0",
+	mapast.KindExpressionAndAnd:             "_ = a > 0 && b > 0",
+	mapast.KindExpressionEqual:              "_ = a == b",
+	mapast.KindExpressionNotEq:              "_ = a != b",
+	mapast.KindExpressionLessThan:           "_ = a < b",
+	mapast.KindExpressionLessEq:             "_ = a <= b",
+	mapast.KindExpressionGrtEq:              "_ = a >= b",
+	mapast.KindExpressionGrtThan:            "_ = a > b",
+	mapast.KindExpressionPlus:               "a = a + b",
+	mapast.KindExpressionMinus:              "a = a - b",
+	mapast.KindExpressionOr:                 "a = a | b",
+	mapast.KindExpressionXor:                "a = a ^ b",
+	mapast.KindExpressionMul:                "a = a * b",
+	mapast.KindExpressionDiv:                "a = a / b",
+	mapast.KindExpressionMod:                "a = a % b",
+	mapast.KindExpressionAnd:                "a = a & b",
+	mapast.KindExpressionAndNot:             "a = a &^ b",
+	mapast.KindExpressionLSh:                "a = a << uint(b)",
+	mapast.KindExpressionRSh:                "a = a >> uint(b)",
+	mapast.KindExpressionNot:                "_ = !(a > b)",
+	mapast.KindExpressionDot:                "_ = x.(interface{ M() }).M",
+	mapast.KindExpressionSlice:              "s = s[a:b]",
+	mapast.KindExpressionComposite:          "s = []int{a, b}",
+	mapast.KindExpressionCall:               "a = f(a, b)",
+	mapast.KindExpressionArrow:              "a = <-c",
+	mapast.KindExpressionKeyVal:             "m = map[int]int{a: b}",
+	mapast.KindExpressionType:               "_ = x.(int)",
+	mapast.KindExpressionCallDotDotDot:      "a = f(s...)",
+	mapast.KindExpressionComposed:           "_ = [][]int{{a}, {b}}",
+	mapast.KindExpressionIndex:              "a = s[b]",
+	mapast.KindExpressionIdentifier:         "return",
+	mapast.KindBlocOfCodePlain:              "{\n\ta++\n}",
+	mapast.KindBlocOfCodeIf:                 "if a > b {\n\ta++\n}",
+	mapast.KindBlocOfCodeIfElse:             "if a > b {\n\ta++\n} else {\n\tb++\n}",
+	mapast.KindBlocOfCodeSwitch:             "switch a {\ncase 1:\n\tb++\n}",
+	mapast.KindBlocOfCodeFor:                "for a < b {\n\ta++\n}",
+	mapast.KindBlocOfCodeForRange:           "for range s {\n\ta++\n}",
+	mapast.KindBlocOfCodeTypeSwitch:         "switch y := x.(type) {\ncase int:\n\ta = y\n}",
+	mapast.KindBlocOfCodeSelect:             "select {\ncase c <- a:\n}",
+	mapast.KindBlocOfCodeCase:               "switch a {\ncase 1, 2:\n\tb++\n}",
+	mapast.KindBlocOfCodeDefault:            "switch a {\ndefault:\n\tb++\n}",
+	mapast.KindBlocOfCodeCommunicate:        "select {\ncase a = <-c:\n\tb++\n}",
+	mapast.KindBlocOfCodeCommunicateDefault: "select {\ndefault:\n\tb++\n}",
+	mapast.KindAssignStmtEqual:              "a = b",
+	mapast.KindAssignStmtColonEq:            "d := a\n_ = d",
+	mapast.KindAssignStmtAndNot:             "a &^= b",
+	mapast.KindAssignStmtAdd:                "a += b",
+	mapast.KindAssignStmtSub:                "a -= b",
+	mapast.KindAssignStmtMul:                "a *= b",
+	mapast.KindAssignStmtQuo:                "a /= b",
+	mapast.KindAssignStmtRem:                "a %= b",
+	mapast.KindAssignStmtAnd:                "a &= b",
+	mapast.KindAssignStmtOr:                 "a |= b",
+	mapast.KindAssignStmtXor:                "a ^= b",
+	mapast.KindAssignStmtShl:                "a <<= uint(b)",
+	mapast.KindAssignStmtShr:                "a >>= uint(b)",
+	mapast.KindAssignStmtMoreColonEq:        "d, e := <-c\n_, _ = d, e",
+	mapast.KindAssignStmtMoreEqualRange:     "for a, b = range s {\n}",
+	mapast.KindAssignStmtMoreColonEqRange:   "for i, v := range s {\n\t_, _ = i, v\n}",
+	mapast.KindClosureExp:                   "g := func(d int) int { return d }\n_ = g",
+}
+
+// reserved lists the kinds that the converter never produces, with the reason.
+var reserved = map[mapast.Kind]string{
+	mapast.KindTypedIdentEquals: "no go syntax uses it",
+	mapast.KindBranchStmtGoto:   "goto always has a label",
+	mapast.KindGenericExp:       "reserved for future use",
+	mapast.KindBlocOfCodeNone:   "unused",
+}
+
+// tolerant holds samples that do not parse, converted by convert.ParseTolerant.
+var tolerant = map[mapast.Kind]string{
+	mapast.KindPlaceholder: "package p\n\nfunc f(a int) {\n\ta = a +\n}\n",
+}
+
+// sample returns the sample source file of the kind.
+func sample(k mapast.Kind) (string, bool) {
+	if src, ok := top[k]; ok {
+		return src, true
+	}
+	if src, ok := body[k]; ok {
+		return "package p\n\nfunc g(a, b int, s []int, m map[int]int, c chan int, x interface{}, " +
+			"f func(...int) int) {\n" + src + "\n}\n", true
+	}
+	return "", false
+}
+
+// contains reports whether the tree holds a node of the kind.
+func contains(ast map[uint64][]byte, k mapast.Kind) (found bool) {
+	mapast.Walk(ast, 0, func(key, parent uint64, node []byte) bool {
+		found = found || mapast.KindOf(node) == k
+		return !found
+	})
+	return found
+}
+
+// TestCorpus checks that the printer handles every kind of node. For each kind
+// it converts a minimal sample source file, makes sure the tree holds a node of
+// the kind, prints the tree, parses the printed code again and compares it
+// with the golden file of the kind, testdata/corpus/Kind.golden. The printed
+// code of the samples that do not parse is not parsed again.
+func TestCorpus(t *testing.T) {
+	for k := mapast.KindRootMatter; k < mapast.KindInvalid; k++ {
+		t.Run(k.String(), func(t *testing.T) {
+			var ast map[uint64][]byte
+			var err error
+			src, ok := sample(k)
+			if ok {
+				ast, err = convert.Parse([]byte(src))
+			} else if src, ok = tolerant[k]; ok {
+				ast, _, err = convert.ParseTolerant(token.NewFileSet(), "", []byte(src))
+			} else if reason, ok := reserved[k]; ok {
+				t.Skip("no sample, " + reason)
+			} else {
+				t.Fatal("no sample, add one to corpus_test.go")
+			}
+			if err != nil {
+				t.Fatalf("sample does not parse: %v", err)
+			}
+			if !contains(ast, k) {
+				t.Fatal("sample does not produce the kind")
+			}
+			var code = sprint(ast, 0)
+			if _, bad := tolerant[k]; !bad {
+				if _, err := parser.ParseFile(token.NewFileSet(), "", code, parser.ParseComments); err != nil {
+					t.Fatalf("printed code does not parse: %v\n%s", err, code)
+				}
+			}
+			golden(t, filepath.Join("testdata", "corpus", k.String()+".golden"), code)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package mapast
+
+// DeadReason tells why DeadCode found a piece of code dead.
+type DeadReason byte
+
+// DeadUnreachable is a statement following a return, a panic, a goto, a break
+// or a continue in the same block.
+const DeadUnreachable DeadReason = 0
+
+// DeadUnusedLabel is a label no goto, break or continue refers to. Only the
+// label is dead, not the labeled statement.
+const DeadUnusedLabel DeadReason = 1
+
+// DeadFalseBranch is an if statement or an else branch that is never taken,
+// since the condition is always false or always true.
+const DeadFalseBranch DeadReason = 2
+
+// Dead is a piece of dead code found by DeadCode.
+type Dead struct {
+	// Key is the key of the dead node, in the tree as it was before
+	// EliminateDeadCode changed it.
+	Key    uint64
+	Reason DeadReason
+}
+
+// isterminating reports whether the statement node at the key never lets the
+// execution continue to the next statement of its block.
+func isterminating(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	switch {
+	case Is(node, ReturnStmt):
+		return true
+
+	case Is(node, BranchStmt):
+		var v = Variant(node)
+		return v == BranchStmtBreak || v == BranchStmtContinue || v == BranchStmtGoto
+
+	case Is(node, LblGotoCnt):
+		return Variant(node) != LblGotoCntLabel
+
+	case isexpr(node, ExpressionCall, 2):
+		return isname(ast, O(key), "panic")
+	}
+	return false
+}
+
+// ispure reports whether evaluating the expression at the key can have no
+// effect, such as a call, a receive or a run-time panic.
+func ispure(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	if Which(node) == nil {
+		return true
+	}
+	switch {
+	case isexpr(node, ExpressionIdentifier, 1), isexpr(node, ExpressionBrackets, 1), isexpr(node, ExpressionNot, 1):
+
+	case precedence(node) > 0:
+		switch Variant(node) {
+		case ExpressionDiv, ExpressionMod, ExpressionLSh, ExpressionRSh:
+			return false
+		}
+
+	default:
+		return false
+	}
+	for i := uint64(0); Poke(ast, O(key)+i); i++ {
+		if !ispure(ast, O(key)+i) {
+			return false
+		}
+	}
+	return true
+}
+
+// constbool returns the value of the boolean expression at the key, and
+// whether it is known without evaluating it. The true and false identifiers
+// are assumed to be the predeclared ones.
+func constbool(ast map[uint64][]byte, key uint64) (value bool, known bool) {
+	var node = ast[key]
+	switch {
+	case Which(node) == nil:
+		return string(node) == "true", string(node) == "true" || string(node) == "false"
+
+	case isexpr(node, ExpressionIdentifier, 1), isexpr(node, ExpressionBrackets, 1):
+		return constbool(ast, O(key))
+
+	case isexpr(node, ExpressionNot, 1):
+		value, known = constbool(ast, O(key))
+		return !value, known
+
+	case isexpr(node, ExpressionAndAnd, 2), isexpr(node, ExpressionOrOr, 2):
+		var or = Variant(node) == ExpressionOrOr
+		var l, lknown = constbool(ast, O(key))
+		var r, rknown = constbool(ast, O(key)+1)
+		if lknown && l == or || rknown && r == or {
+			return or, true
+		}
+		return !or, lknown && rknown
+	}
+	return false, false
+}
+
+// condition returns the value of the condition of the if statement at the
+// key, and whether it is known and free of effects. Statements having an
+// initialization statement are never known.
+func condition(ast map[uint64][]byte, key uint64) (value bool, known bool) {
+	var node = ast[key]
+	if !Is(node, BlocOfCode) || Count(node) != 1 ||
+		Variant(node) != BlocOfCodeIf && Variant(node) != BlocOfCodeIfElse {
+		return false, false
+	}
+	value, known = constbool(ast, O(key))
+	return value, known && ispure(ast, O(key))
+}
+
+// labels adds the keys of the labels of the function at the key that no goto,
+// break or continue refers to, to the unused labels. The bodies of the
+// function literals are not searched, since their labels are their own.
+func labels(ast map[uint64][]byte, body uint64, unused map[uint64]bool) {
+	var defined = make(map[string]uint64)
+	var used = make(map[string]bool)
+	Walk(ast, body, func(key, parent uint64, node []byte) bool {
+		switch {
+		case Is(node, ClosureExp) && key != body:
+			return false
+
+		case Is(node, LblGotoCnt) && Variant(node) == LblGotoCntLabel:
+			defined[string(ast[O(key)])] = key
+
+		case Is(node, LblGotoCnt):
+			used[string(ast[O(key)])] = true
+		}
+		return true
+	})
+	for name, key := range defined {
+		if !used[name] {
+			unused[key] = true
+		}
+	}
+}
+
+// deadcode finds the dead code of the block at the key, after the blocks
+// nested in it, and removes it if apply is set.
+func deadcode(ast map[uint64][]byte, key uint64, unused map[uint64]bool, apply bool, found []Dead) []Dead {
+	var n = childcount(ast, key)
+	for i := uint64(0); i < n; i++ {
+		found = deadcode(ast, O(key)+i, unused, apply, found)
+	}
+	var node = ast[key]
+	if !Is(node, BlocOfCode) {
+		return found
+	}
+	var remove = make(map[uint64]DeadReason)
+	var unreachable = false
+	var elsebranch = false
+	// previous is the index of the preceding statement kept, n if there is
+	// none.
+	var previous = n
+	for i := Count(node); i < n; i++ {
+		var child = O(key) + i
+		var stmt = ast[child]
+		var value, known = condition(ast, child)
+		switch {
+		case Is(stmt, CommentRow):
+			continue
+
+		case Is(stmt, LblGotoCnt) && Variant(stmt) == LblGotoCntLabel:
+			// The code following a label is reachable by a jump to it.
+			if unused[child] {
+				remove[i] = DeadUnusedLabel
+			} else {
+				unreachable = false
+			}
+
+		case unreachable:
+			remove[i] = DeadUnreachable
+
+		case elsebranch:
+			// The branches following an if that is always taken.
+			remove[i] = DeadFalseBranch
+			elsebranch = Is(stmt, BlocOfCode) && Variant(stmt) == BlocOfCodeIfElse
+
+		case known && !value:
+			remove[i] = DeadFalseBranch
+			// An else if never taken ends the if else chain.
+			if previous < n && Variant(stmt) == BlocOfCodeIf && Is(ast[O(key)+previous], BlocOfCode) &&
+				Variant(ast[O(key)+previous]) == BlocOfCodeIfElse && apply {
+				ast[O(key)+previous] = header(kindBlocOfCode, BlocOfCodeIf, Count(ast[O(key)+previous]))
+			}
+
+		case known && value && Variant(stmt) == BlocOfCodeIfElse:
+			elsebranch = true
+			if apply {
+				ast[child] = header(kindBlocOfCode, BlocOfCodeIf, Count(stmt))
+			}
+
+		default:
+			unreachable = isterminating(ast, child)
+		}
+		if _, ok := remove[i]; ok {
+			previous = n
+		} else {
+			previous = i
+		}
+	}
+	for i := n; i > Count(node); i-- {
+		if reason, ok := remove[i-1]; ok {
+			found = append(found, Dead{Key: O(key) + i - 1, Reason: reason})
+			if apply {
+				RemoveChild(ast, key, i-1)
+			}
+		}
+	}
+	return found
+}
+
+// DeadCode returns the dead code of the tree at the root: the statements that
+// cannot be reached, the labels not referred to, and the branches of the if
+// statements whose condition is a constant such as false or !true && x.
+func DeadCode(ast map[uint64][]byte, root uint64) []Dead {
+	return deadcodes(ast, root, false)
+}
+
+// EliminateDeadCode removes the dead code DeadCode finds from the tree at the
+// root and returns it. An if statement never taken is removed together with
+// its else keyword, leaving the else branch as a plain block. The removals
+// can leave variables or imports unused, and can make more code dead, so
+// EliminateDeadCode can be called again until it returns nothing.
+func EliminateDeadCode(ast map[uint64][]byte, root uint64) []Dead {
+	return deadcodes(ast, root, true)
+}
+
+func deadcodes(ast map[uint64][]byte, root uint64, apply bool) []Dead {
+	var unused = make(map[uint64]bool)
+	Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		if Is(node, ToplevFunc) || Is(node, ClosureExp) {
+			labels(ast, key, unused)
+		}
+		return true
+	})
+	return deadcode(ast, root, unused, apply, nil)
+}
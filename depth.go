@@ -0,0 +1,41 @@
+package mapast
+
+import "errors"
+
+// Depth returns the number of nodes on the longest path from the node at the
+// root down to a leaf, one for a single leaf and zero if there is no node at
+// the root. It uses an explicit stack, so it works on trees of any depth.
+func Depth(ast map[uint64][]byte, root uint64) int {
+	if !Poke(ast, root) {
+		return 0
+	}
+	type frame struct {
+		key   uint64
+		depth int
+	}
+	var stack = []frame{{root, 1}}
+	var depth = 0
+	for len(stack) > 0 {
+		var f = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.depth > depth {
+			depth = f.depth
+		}
+		for i := uint64(0); Poke(ast, O(f.key)+i); i++ {
+			stack = append(stack, frame{O(f.key) + i, f.depth + 1})
+		}
+	}
+	return depth
+}
+
+// CodeLimited generates go source code like Code does, unless the tree at the
+// iterator position is deeper than maxDepth nodes, in which case it prints
+// nothing and returns an error. Machine generated code can nest expressions
+// deep enough to make the output, or the tools reading it, impractical.
+func CodeLimited(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64, maxDepth int) error {
+	if Depth(ast, iterator) > maxDepth {
+		return errors.New("mapast: tree deeper than the limit")
+	}
+	Code(print, ast, iterator, parent)
+	return nil
+}
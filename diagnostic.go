@@ -0,0 +1,123 @@
+package mapast
+
+// EditOp is the kind of operation performed by an Edit.
+type EditOp byte
+
+// EditReplace replaces the subtree at the key by the node of the edit.
+const EditReplace EditOp = 0
+
+// EditRemove removes the node at the key from its parent, moving the children
+// that follow one position back.
+const EditRemove EditOp = 1
+
+// EditInsert inserts the node of the edit as the child at the index of the
+// node at the key.
+const EditInsert EditOp = 2
+
+// Edit is a single machine-applicable change of a tree.
+type Edit struct {
+	Op    EditOp
+	Key   uint64
+	Index uint64
+	// Node is an ast having the new node at key zero.
+	Node map[uint64][]byte
+}
+
+// Diagnostic is a problem found at the node at the key. It implements error,
+// so validating functions can return it as an error. If the problem can be
+// fixed automatically, Fix holds the edits that fix it.
+type Diagnostic struct {
+	Key     uint64
+	Message string
+	Fix     []Edit
+}
+
+// Error returns the message of the diagnostic.
+func (d *Diagnostic) Error() string {
+	return d.Message
+}
+
+// within reports whether the key is one of the roots or a descendant of one.
+func within(index map[uint64]uint64, key uint64, roots map[uint64]bool) bool {
+	for {
+		if roots[key] {
+			return true
+		}
+		parent, ok := index[key]
+		if !ok {
+			return false
+		}
+		key = parent
+	}
+}
+
+// applicable reports whether the fix can be applied: every edit must target an
+// existing node outside of the subtrees changed by the earlier fixes, and must
+// not contain such a subtree.
+func applicable(ast map[uint64][]byte, index map[uint64]uint64, fix []Edit, dirty map[uint64]bool) bool {
+	for _, e := range fix {
+		if !Poke(ast, e.Key) || within(index, e.Key, dirty) {
+			return false
+		}
+		if e.Op != EditInsert {
+			for root := range dirty {
+				if within(index, root, map[uint64]bool{e.Key: true}) {
+					return false
+				}
+			}
+		}
+		if e.Op == EditRemove {
+			if _, ok := index[e.Key]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ApplyEdits performs the edits in order.
+func ApplyEdits(ast map[uint64][]byte, edits []Edit) {
+	for _, e := range edits {
+		switch e.Op {
+		case EditReplace:
+			CopySubtree(e.Node, 0, ast, e.Key)
+
+		case EditRemove:
+			var index = BuildParentIndex(ast, 0)
+			if parent, ok := index[e.Key]; ok {
+				RemoveChild(ast, parent, e.Key-O(parent))
+			}
+
+		case EditInsert:
+			InsertChild(ast, e.Key, e.Index, e.Node)
+		}
+	}
+}
+
+// ApplyFixes applies the fixes of the diagnostics found in the tree rooted at
+// the iterator position, in order. A fix is skipped if it overlaps with the
+// subtrees changed by a fix applied before, since its keys may no longer mean
+// what they meant. ApplyFixes returns the number of fixes applied; running the
+// analysis and ApplyFixes again picks up the skipped ones.
+func ApplyFixes(ast map[uint64][]byte, iterator uint64, diags []*Diagnostic) (applied int) {
+	var dirty = make(map[uint64]bool)
+	for _, d := range diags {
+		if d == nil || len(d.Fix) == 0 {
+			continue
+		}
+		var index = BuildParentIndex(ast, iterator)
+		if !applicable(ast, index, d.Fix, dirty) {
+			continue
+		}
+		for _, e := range d.Fix {
+			if e.Op == EditRemove {
+				dirty[index[e.Key]] = true
+			} else {
+				dirty[e.Key] = true
+			}
+		}
+		ApplyEdits(ast, d.Fix)
+		applied++
+	}
+	return applied
+}
@@ -0,0 +1,88 @@
+package mapast
+
+import "strings"
+
+// IsDirective reports whether the comment is a directive, such as
+// //go:noinline, //go:generate, //line, //export or //nolint. Directives have
+// no space after the slashes.
+func IsDirective(comment string) bool {
+	if !strings.HasPrefix(comment, "//") {
+		return false
+	}
+	var text = comment[2:]
+	for _, prefix := range []string{"line ", "extern ", "export ", "nolint"} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	var colon = strings.IndexByte(text, ':')
+	if colon <= 0 || colon+1 >= len(text) {
+		return false
+	}
+	for i := 0; i < colon; i++ {
+		if c := text[i]; !('a' <= c && c <= 'z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return 'a' <= text[colon+1] && text[colon+1] <= 'z'
+}
+
+// isdirective reports whether the child is a directive CommentRow occupying
+// its own row.
+func isdirective(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	return Is(node, CommentRow) && Variant(node) != CommentRowEnder &&
+		IsDirective(string(ast[O(key)]))
+}
+
+// DirectiveComments returns the number of directive CommentRows that precede
+// the child at the index of the parent node. The directives apply to the
+// child, so edits moving or removing the child take them along. Ordinary
+// comments between the directives and the child are counted too, so that the
+// directives stay immediately before the child. A comment following an empty
+// line ends the run, except for a //go:embed directive of a VarDefStmt, which
+// may be followed by empty lines and comments. A CommentRow child has no
+// directives.
+func DirectiveComments(ast map[uint64][]byte, parent uint64, index uint64) (n uint64) {
+	var child = ast[O(parent)+index]
+	if Is(child, CommentRow) {
+		return 0
+	}
+	var run uint64
+	var blank bool
+	for run < index {
+		var key = O(parent) + index - run - 1
+		var node = ast[key]
+		if !Is(node, CommentRow) || Variant(node) == CommentRowEnder {
+			break
+		}
+		run++
+		if isdirective(ast, key) && (!blank || isembed(ast, key) && Is(child, VarDefStmt)) {
+			n = run
+		}
+		if Variant(node) == CommentRowSeparate {
+			blank = true
+		}
+	}
+	return n
+}
+
+// Directives returns the directives applying to the node at the key, in
+// order. They are the directive CommentRows preceding the node among the
+// children of its parent, which Code prints on their own rows immediately
+// before the node. Parents is the index built by BuildParentIndex, so
+// looking up the directives of many nodes does not index the tree every time.
+func Directives(ast map[uint64][]byte, parents map[uint64]uint64, key uint64) (directives []string) {
+	var parent, ok = parents[key]
+	if !ok {
+		return nil
+	}
+	var index = key - O(parent)
+	var n = DirectiveComments(ast, parent, index)
+	for i := index - n; i < index; i++ {
+		if isdirective(ast, O(parent)+i) {
+			directives = append(directives, string(ast[O(O(parent)+i)]))
+		}
+	}
+	return directives
+}
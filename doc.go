@@ -0,0 +1,84 @@
+package mapast
+
+import "strings"
+
+// DocComments returns the number of CommentRows forming the doc comment of the
+// child at the index of the parent node. The doc comments are the ones bound
+// to the declarations by go/ast, recorded in the docs side-table by the
+// converter for the key of every declaration having one; a comment separated
+// from the declaration by an empty line is not its doc comment. The directives
+// of the child are counted even when they precede an empty line, see
+// DirectiveComments. A CommentRow child has no doc comment. Edits moving or
+// removing the child take its doc comment along.
+func DocComments(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, index uint64) (n uint64) {
+	if !Poke(ast, O(parent)+index) || Is(ast[O(parent)+index], CommentRow) {
+		return 0
+	}
+	for n < index && n < docs[O(parent)+index] {
+		var node = ast[O(parent)+index-n-1]
+		if !Is(node, CommentRow) || Variant(node) == CommentRowEnder {
+			break
+		}
+		n++
+	}
+	if d := DirectiveComments(ast, parent, index); d > n {
+		n = d
+	}
+	return n
+}
+
+// docstart returns the index at which a child can be inserted into the parent
+// node at the index without separating the following child from its doc
+// comment.
+func docstart(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, index uint64) uint64 {
+	var next = index
+	for Poke(ast, O(parent)+next) && Is(ast[O(parent)+next], CommentRow) && !isender(ast[O(parent)+next]) {
+		next++
+	}
+	if !Poke(ast, O(parent)+next) {
+		return index
+	}
+	if start := next - DocComments(ast, docs, parent, next); start < index {
+		return start
+	}
+	return index
+}
+
+// shiftdocs moves the entries of the docs side-table of the children of the
+// parent node from the index on, as the children are moved by the delta.
+func shiftdocs(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, index uint64, delta int) {
+	if len(docs) == 0 {
+		return
+	}
+	var count = childcount(ast, parent)
+	var moved = make(map[uint64]uint64)
+	for i := index; i < count; i++ {
+		if n, ok := docs[O(parent)+i]; ok {
+			moved[uint64(int(O(parent)+i)+delta)] = n
+			delete(docs, O(parent)+i)
+		}
+	}
+	for key, n := range moved {
+		docs[key] = n
+	}
+}
+
+// Doc returns the doc comment of the node at the key, the texts of its
+// CommentRows joined by newlines, directives excluded. It returns an empty
+// string if the node has no doc comment. Docs is the side-table of the doc
+// comments recorded by the converter, and parents the index built by
+// BuildParentIndex.
+func Doc(ast map[uint64][]byte, docs map[uint64]uint64, parents map[uint64]uint64, key uint64) string {
+	var parent, ok = parents[key]
+	if !ok {
+		return ""
+	}
+	var index = key - O(parent)
+	var lines []string
+	for i := index - DocComments(ast, docs, parent, index); i < index; i++ {
+		if !isdirective(ast, O(parent)+i) {
+			lines = append(lines, string(ast[O(O(parent)+i)]))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
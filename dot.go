@@ -0,0 +1,47 @@
+package mapast
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// dotlimit is the length above which string leaves are shortened in the DOT
+// graph labels.
+const dotlimit = 40
+
+// Dot writes the subtree at the root position as a graphviz DOT graph. Nodes
+// are boxes labeled with their kind name and element count, string leaves are
+// ellipses labeled with the string, and the edges are labeled with the child
+// index. Render it with dot -Tsvg, for example.
+func Dot(w io.Writer, ast map[uint64][]byte, root uint64) error {
+	if !Poke(ast, root) {
+		return errors.New("mapast: no node at the root position")
+	}
+	var bw = bufio.NewWriter(w)
+	bw.WriteString("digraph mapast {\n\tnode [shape=box];\n")
+	Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		var id = "n" + strconv.FormatUint(key, 10)
+		if Which(node) == nil {
+			var s = string(node)
+			if len(s) > dotlimit {
+				s = s[:dotlimit] + "..."
+			}
+			bw.WriteString("\t" + id + " [shape=ellipse, label=" + strconv.Quote(s) + "];\n")
+		} else {
+			var label = KindOf(node).String()
+			if Count(node) != 0 {
+				label += " " + strconv.FormatUint(Count(node), 10)
+			}
+			bw.WriteString("\t" + id + " [label=" + strconv.Quote(label) + "];\n")
+		}
+		if key != parent {
+			bw.WriteString("\tn" + strconv.FormatUint(parent, 10) + " -> " + id +
+				" [label=" + strconv.Quote(strconv.FormatUint(key-O(parent), 10)) + "];\n")
+		}
+		return true
+	})
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,100 @@
+package mapast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dumpNode is a single node of the structured dump. String leaves have only
+// the key and the string set.
+type dumpNode struct {
+	Key      uint64      `json:"key,string"`
+	Kind     string      `json:"kind,omitempty"`
+	Variant  string      `json:"variant,omitempty"`
+	Count    uint64      `json:"count,omitempty"`
+	String   *string     `json:"string,omitempty"`
+	Children []*dumpNode `json:"children,omitempty"`
+}
+
+// variantname returns the name of the variant of the node, such as Call for
+// an ExpressionCall, or the variant number for the kinds without named
+// variants. It returns an empty string for the zero variant of those kinds.
+func variantname(node []byte) string {
+	var name = kindnames[kindof(node)]
+	if names, ok := variantnames[name]; ok && int(Variant(node)) < len(names) {
+		return names[Variant(node)]
+	}
+	if Variant(node) == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(Variant(node)))
+}
+
+func todump(ast map[uint64][]byte, iterator uint64) *dumpNode {
+	var node = ast[iterator]
+	var d = dumpNode{Key: iterator}
+	if kindof(node) < 0 {
+		var s = string(node)
+		d.String = &s
+	} else {
+		d.Kind = kindnames[kindof(node)]
+		d.Variant = variantname(node)
+		d.Count = Count(node)
+	}
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		d.Children = append(d.Children, todump(ast, O(iterator)+i))
+	}
+	return &d
+}
+
+// DumpJSON writes the subtree at the root position as an indented JSON
+// document meant for other tools and for diffing. Every node is an object
+// carrying its key as a string, its kind name, its variant name, its element
+// count and an array of its children. String leaves carry their key and the
+// string. Unlike MarshalJSON, the document is not meant to be read back.
+func DumpJSON(w io.Writer, ast map[uint64][]byte, root uint64) error {
+	if !Poke(ast, root) {
+		return errors.New("mapast: no node at the root position")
+	}
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(todump(ast, root))
+}
+
+// yaml writes the node as a YAML mapping indented by the prefix, the first
+// line prefixed by the first string instead.
+func (d *dumpNode) yaml(bw *bufio.Writer, first string, prefix string) {
+	bw.WriteString(first + "key: " + strconv.FormatUint(d.Key, 10) + "\n")
+	if d.String != nil {
+		bw.WriteString(prefix + "string: " + strconv.Quote(*d.String) + "\n")
+		return
+	}
+	bw.WriteString(prefix + "kind: " + d.Kind + "\n")
+	if d.Variant != "" {
+		bw.WriteString(prefix + "variant: " + strconv.Quote(d.Variant) + "\n")
+	}
+	if d.Count != 0 {
+		bw.WriteString(prefix + "count: " + strconv.FormatUint(d.Count, 10) + "\n")
+	}
+	if len(d.Children) > 0 {
+		bw.WriteString(prefix + "children:\n")
+		for _, child := range d.Children {
+			child.yaml(bw, prefix+"  - ", prefix+strings.Repeat(" ", 4))
+		}
+	}
+}
+
+// DumpYAML writes the subtree at the root position as a YAML document with
+// the same structure as the one written by DumpJSON.
+func DumpYAML(w io.Writer, ast map[uint64][]byte, root uint64) error {
+	if !Poke(ast, root) {
+		return errors.New("mapast: no node at the root position")
+	}
+	var bw = bufio.NewWriter(w)
+	todump(ast, root).yaml(bw, "", "")
+	return bw.Flush()
+}
@@ -0,0 +1,300 @@
+package mapast
+
+// subtree is a node together with its children, detached from any ast.
+type subtree struct {
+	node     []byte
+	children []subtree
+}
+
+// cut removes the subtree at the iterator position from the ast and returns it.
+func cut(ast map[uint64][]byte, iterator uint64) subtree {
+	var s = subtree{node: ast[iterator]}
+	for _, child := range ChildKeys(ast, iterator) {
+		s.children = append(s.children, cut(ast, child))
+	}
+	delete(ast, iterator)
+	return s
+}
+
+// clone returns a detached copy of the subtree at the iterator position.
+func clone(ast map[uint64][]byte, iterator uint64) subtree {
+	var s = subtree{node: ast[iterator]}
+	if Which(s.node) == nil && s.node != nil {
+		s.node = append([]byte{}, s.node...)
+	}
+	for _, child := range ChildKeys(ast, iterator) {
+		s.children = append(s.children, clone(ast, child))
+	}
+	return s
+}
+
+// paste stores a detached subtree into the ast at the iterator position.
+func paste(ast map[uint64][]byte, iterator uint64, s subtree) {
+	ast[iterator] = s.node
+	for i := range s.children {
+		paste(ast, O(iterator)+uint64(i), s.children[i])
+	}
+}
+
+// childcount returns the number of consecutive children of the node.
+func childcount(ast map[uint64][]byte, iterator uint64) (n uint64) {
+	var first = O(iterator)
+	for Poke(ast, first+n) {
+		n++
+	}
+	return n
+}
+
+// ChildKeys returns the keys of the children of the node at the key, in order.
+// The key of the first child is computed once, and the following slots are
+// probed until the first missing one.
+func ChildKeys(ast map[uint64][]byte, key uint64) []uint64 {
+	var first = O(key)
+	var keys []uint64
+	for i := first; Poke(ast, i); i++ {
+		keys = append(keys, i)
+	}
+	return keys
+}
+
+// insertslots moves the children of the node at positions index and above
+// n positions further, leaving a gap of n empty child slots at index.
+func insertslots(ast map[uint64][]byte, iterator uint64, index uint64, n uint64) {
+	var first = O(iterator)
+	for i := childcount(ast, iterator); i > index; i-- {
+		paste(ast, first+i-1+n, cut(ast, first+i-1))
+	}
+}
+
+// CopySubtree copies the node at the source key, together with all of its
+// descendants, from the source ast to the destination ast at the destination
+// key. The keys of the copied descendants are derived from the destination
+// key using O. Any subtree previously stored at the destination key is removed
+// first. String nodes are copied, not shared. Source and destination can be
+// the same ast.
+func CopySubtree(src map[uint64][]byte, srcKey uint64, dst map[uint64][]byte, dstKey uint64) {
+	if !Poke(src, srcKey) {
+		return
+	}
+	var s = clone(src, srcKey)
+	cut(dst, dstKey)
+	paste(dst, dstKey, s)
+}
+
+// counted returns the range of the indexes of the children counted by the
+// element count of the node. Expression and AssignStmt nodes count all their
+// children, BlocOfCode nodes their header elements and ToplevFunc, ClosureExp
+// and IfceMethod nodes their parameters. It returns false for the other nodes.
+func counted(node []byte) (first uint64, last uint64, ok bool) {
+	var variant, count = params(node)
+	switch kindof(node) {
+	case kindExpression, kindAssignStmt:
+		return 0, ^uint64(0), true
+
+	case kindBlocOfCode, kindClosureExp:
+		return 0, count, true
+
+	case kindToplevFunc:
+		return variant + 1, variant + count + 1, true
+
+	case kindIfceMethod:
+		return 1, count + 1, true
+	}
+	return 0, 0, false
+}
+
+// recount adjusts the element count of the parent node after delta children
+// were inserted (or removed, if negative) at the index. The header elements of
+// BlocOfCode and the parameters of ToplevFunc, ClosureExp and IfceMethod are
+// counted only if the index lies strictly inside of them.
+func recount(ast map[uint64][]byte, parent uint64, index uint64, delta int) {
+	var node = ast[parent]
+	var first, last, ok = counted(node)
+	if !ok {
+		return
+	}
+	if index >= first && index < last {
+		var variant, count = params(node)
+		ast[parent] = makenode(kindof(node), variant, uint64(int(count)+delta))
+	}
+}
+
+// InsertChild inserts subtrees as children of the parent node, starting at the
+// index. Each subtree is an ast having its node at key zero. The children
+// previously at the index and above are moved after the inserted ones and
+// their descendants are re-keyed accordingly. The element count of the parent
+// node is updated. Subtrees having no node at key zero are skipped, so they
+// leave no gap among the children.
+func InsertChild(ast map[uint64][]byte, parent uint64, index uint64, subtrees ...map[uint64][]byte) {
+	var nonempty = make([]map[uint64][]byte, 0, len(subtrees))
+	for _, s := range subtrees {
+		if Poke(s, 0) {
+			nonempty = append(nonempty, s)
+		}
+	}
+	subtrees = nonempty
+	if len(subtrees) == 0 {
+		return
+	}
+	if n := childcount(ast, parent); index > n {
+		index = n
+	}
+	insertslots(ast, parent, index, uint64(len(subtrees)))
+	for i := range subtrees {
+		CopySubtree(subtrees[i], 0, ast, O(parent)+index+uint64(i))
+	}
+	recount(ast, parent, index, len(subtrees))
+}
+
+// RemoveChild removes the child at the index of the parent node, with all of
+// its descendants. The children that follow are moved one position back and
+// their descendants are re-keyed accordingly. The element count of the parent
+// node is updated.
+func RemoveChild(ast map[uint64][]byte, parent uint64, index uint64) {
+	var n = childcount(ast, parent)
+	if index >= n {
+		return
+	}
+	cut(ast, O(parent)+index)
+	for i := index + 1; i < n; i++ {
+		paste(ast, O(parent)+i-1, cut(ast, O(parent)+i))
+	}
+	recount(ast, parent, index, -1)
+}
+
+// CommentPolicy tells MoveStatement and RemoveStatement what to do with the
+// CommentRowEnder comments that end the line of the edited statement.
+type CommentPolicy byte
+
+// CommentPolicyFollow moves the ender comments together with their statement
+// and removes them together with their statement.
+const CommentPolicyFollow CommentPolicy = 0
+
+// CommentPolicyDetach leaves the ender comments in place, turning them into
+// CommentRowNormal comments so they do not end the line of another statement.
+const CommentPolicyDetach CommentPolicy = 1
+
+// isender reports whether the node is a CommentRowEnder comment.
+func isender(node []byte) bool {
+	return Is(node, CommentRow) && Variant(node) == CommentRowEnder
+}
+
+// EnderComments returns the number of CommentRowEnder comments that follow
+// the child at the index of the parent node. These comments share the line
+// of the child, so they describe it.
+func EnderComments(ast map[uint64][]byte, parent uint64, index uint64) (n uint64) {
+	for Poke(ast, O(parent)+index+1+n) && isender(ast[O(parent)+index+1+n]) {
+		n++
+	}
+	return n
+}
+
+// detachenders turns the ender comments of the child into normal comments.
+// They are counted before any is detached, since a detached comment no longer
+// counts as an ender.
+func detachenders(ast map[uint64][]byte, parent uint64, index uint64) {
+	var n = EnderComments(ast, parent, index)
+	for i := uint64(1); i <= n; i++ {
+		ast[O(parent)+index+i] = CommentRowNode(CommentRowNormal)
+	}
+}
+
+// RemoveStatement removes the statement at the index of the parent node, which
+// is usually a FileMatter or a BlocOfCode. The ender comments of the statement
+// are handled according to the policy. The doc comment of the statement,
+// including its directives, is removed with it. Docs is the side-table of the
+// doc comments, see DocComments, kept up to date with the children moved, or
+// nil.
+func RemoveStatement(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, index uint64, policy CommentPolicy) {
+	if !Poke(ast, O(parent)+index) {
+		return
+	}
+	var n = EnderComments(ast, parent, index)
+	if policy == CommentPolicyDetach {
+		detachenders(ast, parent, index)
+		n = 0
+	}
+	var d = DocComments(ast, docs, parent, index)
+	delete(docs, O(parent)+index)
+	shiftdocs(ast, docs, parent, index+n+1, -int(n+d+1))
+	for i := uint64(0); i <= n+d; i++ {
+		RemoveChild(ast, parent, index-d)
+	}
+}
+
+// MoveStatement moves the statement at the from index of the parent node, so
+// that it starts at the to index once moved. The ender comments of the moved
+// statement are handled according to the policy, while its doc comment,
+// including its directives, always moves with it. A statement is never placed
+// between another statement and its ender comments or doc comment; it is
+// placed after the ender comments and before the doc comment. Docs is the
+// side-table of the doc comments, kept up to date, or nil.
+func MoveStatement(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, from uint64, to uint64,
+	policy CommentPolicy) {
+	if !Poke(ast, O(parent)+from) {
+		return
+	}
+	putstatement(ast, docs, parent, to, takestatement(ast, docs, parent, from, policy))
+}
+
+// statement is a statement taken by takestatement: the children removed in
+// order, the index of the statement among them, and the number of
+// CommentRows of its doc comment recorded in the docs side-table.
+type statement struct {
+	children []subtree
+	at       uint64
+	doc      uint64
+}
+
+// takestatement removes the statement at the index of the parent node together
+// with its doc comment, and its ender comments according to the policy.
+func takestatement(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, from uint64,
+	policy CommentPolicy) statement {
+	var n = EnderComments(ast, parent, from)
+	if policy == CommentPolicyDetach {
+		detachenders(ast, parent, from)
+		n = 0
+	}
+	var d = DocComments(ast, docs, parent, from)
+	var taken = statement{children: make([]subtree, 0, n+d+1), at: d, doc: docs[O(parent)+from]}
+	delete(docs, O(parent)+from)
+	shiftdocs(ast, docs, parent, from+n+1, -int(n+d+1))
+	from -= d
+	for i := uint64(0); i <= n+d; i++ {
+		taken.children = append(taken.children, clone(ast, O(parent)+from))
+		RemoveChild(ast, parent, from)
+	}
+	return taken
+}
+
+// putstatement inserts the statement taken by takestatement into the parent
+// node, starting at the index or at the closest position not separating
+// another statement from its ender comments or doc comment.
+func putstatement(ast map[uint64][]byte, docs map[uint64]uint64, parent uint64, to uint64, taken statement) {
+	var count = childcount(ast, parent)
+	if to > count {
+		to = count
+	}
+	for to < count && to > 0 && isender(ast[O(parent)+to]) {
+		to++
+	}
+	to = docstart(ast, docs, parent, to)
+	shiftdocs(ast, docs, parent, to, len(taken.children))
+	insertslots(ast, parent, to, uint64(len(taken.children)))
+	for i := range taken.children {
+		paste(ast, O(parent)+to+uint64(i), taken.children[i])
+	}
+	recount(ast, parent, to, len(taken.children))
+	if docs != nil && taken.doc > 0 {
+		docs[O(parent)+to+taken.at] = taken.doc
+	}
+}
+
+// ReplaceSubtree removes the node at the key together with all of its
+// descendants, then calls newNode to build the replacement at the same key.
+// NewNode receives the destination key and the ast, and it is expected to
+// store the new node and its children there.
+func ReplaceSubtree(ast map[uint64][]byte, key uint64, newNode func(dstKey uint64, ast map[uint64][]byte)) {
+	cut(ast, key)
+	newNode(key, ast)
+}
@@ -0,0 +1,191 @@
+// Package edit records the changes made to a mapast tree, so they can be
+// rolled back, undone and redone.
+//
+// A Tx wraps a tree. Edits are made through its methods, and Commit groups the
+// edits made since the previous Commit into a single step of the undo stack:
+//
+//	var tx = edit.Begin(ast)
+//	tx.RemoveChild(body, 0)
+//	if err := rewrite(tx); err != nil {
+//		tx.Rollback()
+//	} else {
+//		tx.Commit()
+//	}
+//	tx.Undo()
+package edit
+
+import (
+	"github.com/go-li/mapast"
+)
+
+// change is the change of the value at a single key of the tree. A missing
+// value is a key that is not in the tree.
+type change struct {
+	key            uint64
+	old, new       []byte
+	hadold, hasnew bool
+}
+
+// Tx records the changes made to a tree through it.
+type Tx struct {
+	ast  map[uint64][]byte
+	log  []change
+	undo [][]change
+	redo [][]change
+}
+
+// Begin returns a Tx editing the tree.
+func Begin(ast map[uint64][]byte) *Tx {
+	return &Tx{ast: ast}
+}
+
+// Tree returns the edited tree. It must not be changed other than through the
+// Tx.
+func (tx *Tx) Tree() map[uint64][]byte {
+	return tx.ast
+}
+
+// set stores the value at the key and records the change.
+func (tx *Tx) set(key uint64, value []byte, has bool) {
+	var old, hadold = tx.ast[key]
+	tx.log = append(tx.log, change{key: key, old: old, new: value, hadold: hadold, hasnew: has})
+	put(tx.ast, key, value, has)
+}
+
+func put(ast map[uint64][]byte, key uint64, value []byte, has bool) {
+	if has {
+		ast[key] = value
+	} else {
+		delete(ast, key)
+	}
+}
+
+// Set stores the node or the string at the key.
+func (tx *Tx) Set(key uint64, node []byte) {
+	tx.set(key, node, true)
+}
+
+// Delete deletes the key from the tree. It does not delete the descendants.
+func (tx *Tx) Delete(key uint64) {
+	tx.set(key, nil, false)
+}
+
+// Do calls fn with the tree and records the changes it makes to the subtree
+// at the key. Fn must not change the tree outside of that subtree. Do makes
+// any mapast editing function available to the Tx, for example:
+//
+//	tx.Do(parent, func(ast map[uint64][]byte) {
+//		mapast.MoveStatement(ast, nil, parent, 2, 0, mapast.CommentPolicyFollow)
+//	})
+func (tx *Tx) Do(key uint64, fn func(ast map[uint64][]byte)) {
+	var before = make(map[uint64][]byte)
+	mapast.Walk(tx.ast, key, func(key, parent uint64, node []byte) bool {
+		before[key] = node
+		return true
+	})
+	fn(tx.ast)
+	var after = make(map[uint64]bool)
+	mapast.Walk(tx.ast, key, func(key, parent uint64, node []byte) bool {
+		after[key] = true
+		var old, hadold = before[key]
+		if !hadold || string(old) != string(node) || (old == nil) != (node == nil) {
+			tx.log = append(tx.log, change{key: key, old: old, new: node, hadold: hadold, hasnew: true})
+		}
+		return true
+	})
+	for key, old := range before {
+		if !after[key] {
+			delete(tx.ast, key)
+			tx.log = append(tx.log, change{key: key, old: old, hadold: true})
+		}
+	}
+}
+
+// InsertChild calls mapast.InsertChild through the Tx.
+func (tx *Tx) InsertChild(parent uint64, index uint64, subtrees ...map[uint64][]byte) {
+	tx.Do(parent, func(ast map[uint64][]byte) {
+		mapast.InsertChild(ast, parent, index, subtrees...)
+	})
+}
+
+// RemoveChild calls mapast.RemoveChild through the Tx.
+func (tx *Tx) RemoveChild(parent uint64, index uint64) {
+	tx.Do(parent, func(ast map[uint64][]byte) {
+		mapast.RemoveChild(ast, parent, index)
+	})
+}
+
+// CopySubtree calls mapast.CopySubtree through the Tx, copying into the
+// edited tree at the destination key.
+func (tx *Tx) CopySubtree(src map[uint64][]byte, srcKey uint64, dstKey uint64) {
+	tx.Do(dstKey, func(ast map[uint64][]byte) {
+		mapast.CopySubtree(src, srcKey, ast, dstKey)
+	})
+}
+
+// revert reverts the changes in reverse order.
+func (tx *Tx) revert(changes []change) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		put(tx.ast, changes[i].key, changes[i].old, changes[i].hadold)
+	}
+}
+
+// replay makes the changes again in order.
+func (tx *Tx) replay(changes []change) {
+	for _, c := range changes {
+		put(tx.ast, c.key, c.new, c.hasnew)
+	}
+}
+
+// Pending reports whether there are changes made since the last Commit or
+// Rollback.
+func (tx *Tx) Pending() bool {
+	return len(tx.log) > 0
+}
+
+// Commit makes the changes made since the last Commit or Rollback a step of
+// the undo stack, and clears the redo stack. It does nothing if there are no
+// such changes.
+func (tx *Tx) Commit() {
+	if len(tx.log) == 0 {
+		return
+	}
+	tx.undo = append(tx.undo, tx.log)
+	tx.log = nil
+	tx.redo = nil
+}
+
+// Rollback reverts the changes made since the last Commit or Rollback.
+func (tx *Tx) Rollback() {
+	tx.revert(tx.log)
+	tx.log = nil
+}
+
+// Undo rolls back the pending changes and reverts the last committed step,
+// moving it to the redo stack. It reports whether there was a step to undo.
+func (tx *Tx) Undo() bool {
+	tx.Rollback()
+	if len(tx.undo) == 0 {
+		return false
+	}
+	var step = tx.undo[len(tx.undo)-1]
+	tx.undo = tx.undo[:len(tx.undo)-1]
+	tx.revert(step)
+	tx.redo = append(tx.redo, step)
+	return true
+}
+
+// Redo rolls back the pending changes and makes the last undone step again,
+// moving it back to the undo stack. It reports whether there was a step to
+// redo.
+func (tx *Tx) Redo() bool {
+	tx.Rollback()
+	if len(tx.redo) == 0 {
+		return false
+	}
+	var step = tx.redo[len(tx.redo)-1]
+	tx.redo = tx.redo[:len(tx.redo)-1]
+	tx.replay(step)
+	tx.undo = append(tx.undo, step)
+	return true
+}
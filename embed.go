@@ -0,0 +1,173 @@
+package mapast
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// EmbedDirective is a //go:embed directive found among the children of
+// a FileMatter node.
+type EmbedDirective struct {
+	// Comment is the key of the CommentRow holding the directive.
+	Comment uint64
+	// Decl is the key of the declaration following the directive, or zero
+	// if no declaration follows.
+	Decl uint64
+	// Patterns are the unquoted file patterns of the directive.
+	Patterns []string
+}
+
+// embedpatterns splits the arguments of a //go:embed directive. It returns
+// false if the comment is not an embed directive.
+func embedpatterns(comment string) ([]string, bool) {
+	const prefix = "//go:embed"
+	if !strings.HasPrefix(comment, prefix) {
+		return nil, false
+	}
+	var rest = comment[len(prefix):]
+	if len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+		return nil, false
+	}
+	var patterns []string
+	for rest = strings.TrimSpace(rest); len(rest) > 0; rest = strings.TrimSpace(rest) {
+		var end = strings.IndexAny(rest, " \t")
+		if rest[0] == '"' || rest[0] == '`' {
+			end = strings.IndexByte(rest[1:], rest[0]) + 2
+		}
+		if end <= 0 {
+			end = len(rest)
+		}
+		var pattern = rest[:end]
+		if unq, err := strconv.Unquote(pattern); err == nil {
+			pattern = unq
+		}
+		patterns = append(patterns, pattern)
+		rest = rest[end:]
+	}
+	return patterns, true
+}
+
+// isembed reports whether the child is a CommentRow holding a //go:embed
+// directive.
+func isembed(ast map[uint64][]byte, key uint64) bool {
+	var _, ok = embedpatterns(string(ast[O(key)]))
+	return Is(ast[key], CommentRow) && ok
+}
+
+// EmbedDirectives finds all //go:embed directives of the file, together with
+// the declarations they apply to.
+func EmbedDirectives(ast map[uint64][]byte, file uint64) (directives []EmbedDirective) {
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		var node = ast[O(file)+i]
+		if !Is(node, CommentRow) {
+			continue
+		}
+		var patterns, ok = embedpatterns(string(ast[O(O(file)+i)]))
+		if !ok {
+			continue
+		}
+		var d = EmbedDirective{Comment: O(file) + i, Patterns: patterns}
+		for j := i + 1; Poke(ast, O(file)+j); j++ {
+			var next = ast[O(file)+j]
+			if !Is(next, CommentRow) {
+				d.Decl = O(file) + j
+				break
+			}
+		}
+		directives = append(directives, d)
+	}
+	return directives
+}
+
+// CheckEmbed validates an embed directive of the file. The directive must be
+// followed by a single variable without an initial value, whose type is
+// string, []byte or embed.FS, and the file must import the embed package.
+// A missing import is reported with a fix adding it.
+func CheckEmbed(ast map[uint64][]byte, file uint64, d EmbedDirective) *Diagnostic {
+	if len(d.Patterns) == 0 {
+		return &Diagnostic{Key: d.Comment, Message: "mapast: go:embed directive without patterns"}
+	}
+	var decl = ast[d.Decl]
+	if d.Decl == 0 || !Is(decl, VarDefStmt) ||
+		Variant(decl) != VarDefStmtVar {
+		return &Diagnostic{Key: d.Comment, Message: "mapast: go:embed directive not followed by a var declaration"}
+	}
+	var row = ast[O(d.Decl)]
+	if Poke(ast, O(d.Decl)+1) || !Is(row, AssignStmt) {
+		return &Diagnostic{Key: d.Decl, Message: "mapast: go:embed applies to a single variable only"}
+	}
+	if Variant(row) != AssignStmtTypeIsLast || Count(row) != 2 {
+		return &Diagnostic{Key: d.Decl, Message: "mapast: go:embed variable must have a type and no initial value"}
+	}
+	var typ = sprint(ast, O(O(d.Decl))+1, O(d.Decl))
+	var embedname, imported = importname(ast, file, "embed")
+	if !imported {
+		var name = "_"
+		if typ == "embed.FS" {
+			name = ""
+		}
+		return &Diagnostic{Key: d.Comment, Message: "mapast: go:embed requires an import of the embed package",
+			Fix: importedits(ast, file, name, "\"embed\"")}
+	}
+	switch typ {
+	case "string", "[]byte":
+		if len(d.Patterns) != 1 {
+			return &Diagnostic{Key: d.Comment, Message: "mapast: go:embed of a " + typ + " variable takes exactly one pattern"}
+		}
+
+	case embedname + ".FS":
+
+	default:
+		return &Diagnostic{Key: d.Decl, Message: "mapast: go:embed cannot apply to a variable of type " + typ}
+	}
+	return nil
+}
+
+// AddEmbed appends to the file a declaration of an embedded file variable
+// preceded by its //go:embed directive. Typ is one of string, []byte or
+// embed.FS. The embed package import is added if it is missing.
+func AddEmbed(ast map[uint64][]byte, file uint64, name string, typ string, patterns ...string) error {
+	var typenode subtree
+	switch typ {
+	case "string":
+		typenode = subtree{node: []byte("string")}
+
+	case "[]byte":
+		typenode = subtree{node: ExpressionNode(ExpressionSliceType, 1),
+			children: []subtree{{node: []byte("byte")}}}
+
+	case "embed.FS":
+		typenode = subtree{node: ExpressionNode(ExpressionDot, 2),
+			children: []subtree{{node: []byte("embed")}, {node: []byte("FS")}}}
+
+	default:
+		return errors.New("mapast: go:embed cannot apply to a variable of type " + typ)
+	}
+	if err := ValidIdent(name); err != nil {
+		return err
+	}
+	if len(patterns) == 0 {
+		return errors.New("mapast: go:embed requires patterns")
+	}
+	var directive = "//go:embed"
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, " \t\"`") {
+			pattern = strconv.Quote(pattern)
+		}
+		directive += " " + pattern
+	}
+	if typ == "embed.FS" {
+		ensureimport(ast, file, "", "\"embed\"")
+	} else {
+		ensureimport(ast, file, "_", "\"embed\"")
+	}
+	var n = childcount(ast, file)
+	paste(ast, O(file)+n, subtree{node: CommentRowNode(CommentRowSeparate),
+		children: []subtree{{node: []byte(directive)}}})
+	paste(ast, O(file)+n+1, subtree{node: VarDefStmtNode(VarDefStmtVar),
+		children: []subtree{{node: AssignStmtNode(AssignStmtTypeIsLast, 2),
+			children: []subtree{{node: []byte(name)},
+				{node: RootOfType, children: []subtree{typenode}}}}}})
+	return nil
+}
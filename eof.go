@@ -0,0 +1,57 @@
+package mapast
+
+// EOFPolicy tells CodeEOF how to end the generated code.
+type EOFPolicy byte
+
+// EOFSingleNewline ends the code with exactly one newline.
+const EOFSingleNewline EOFPolicy = 0
+
+// EOFNoNewline ends the code without a newline.
+const EOFNoNewline EOFPolicy = 1
+
+// EOFPreserve ends the code with as many newlines as the source file had,
+// according to the FileMatter variant. It falls back to a single newline if
+// the number is unknown.
+const EOFPreserve EOFPolicy = 2
+
+// CodeEOF generates go source code from an abstract syntax tree like Code
+// does, but the trailing newlines of the code are replaced according to the
+// policy.
+func CodeEOF(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64, policy EOFPolicy) {
+	var code = sprint(ast, iterator, parent)
+	var end = len(code)
+	for end > 0 && code[end-1] == '\n' {
+		end--
+	}
+	var newlines = 1
+	switch policy {
+	case EOFNoNewline:
+		newlines = 0
+
+	case EOFPreserve:
+		var file = iterator
+		if node := ast[file]; Is(node, RootMatter) {
+			file = O(file)
+		}
+		if node := ast[file]; Is(node, FileMatter) &&
+			Variant(node) != FileMatterUnknownEOF {
+			newlines = int(Variant(node))
+		}
+	}
+	var start = 0
+	for i := 0; i < end; i++ {
+		if code[i] == '\n' {
+			if i > start {
+				print(code[start:i])
+			}
+			print("")
+			start = i + 1
+		}
+	}
+	if end > start {
+		print(code[start:end])
+	}
+	for i := 0; i < newlines; i++ {
+		print("")
+	}
+}
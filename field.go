@@ -0,0 +1,49 @@
+package mapast
+
+import "errors"
+
+// FieldNames returns the keys of the names of the TypedIdent at the key, the
+// string children preceding its RootOfType.
+func FieldNames(ast map[uint64][]byte, key uint64) (names []uint64) {
+	if !Is(ast[key], TypedIdent) {
+		return nil
+	}
+	for _, child := range ChildKeys(ast, key) {
+		if Which(ast[child]) != nil {
+			break
+		}
+		names = append(names, child)
+	}
+	return names
+}
+
+// IsEmbedded reports whether the TypedIdent at the key is an embedded field of
+// a StructType: it has a type but no names.
+func IsEmbedded(ast map[uint64][]byte, key uint64) bool {
+	return Is(ast[key], TypedIdent) && len(FieldNames(ast, key)) == 0 &&
+		Is(ast[O(key)], RootOfType)
+}
+
+// BuildEmbeddedField builds an embedded field of a StructType, having the type,
+// or the pointer to the type if pointer is true. The type is an ast having
+// a type name string or an ExpressionDot qualified type name at key zero. The
+// tag is the quoted field tag, or empty for an untagged field.
+func BuildEmbeddedField(typ map[uint64][]byte, pointer bool, tag string) (map[uint64][]byte, error) {
+	var node = typ[0]
+	if Which(node) != nil && !(Is(node, Expression) && Variant(node) == ExpressionDot) {
+		return nil, errors.New("mapast: embedded field type must be a type name")
+	}
+	var t = clone(typ, 0)
+	if pointer {
+		t = subtree{node: ExpressionNode(ExpressionMul, 1), children: []subtree{t}}
+	}
+	var field = subtree{node: TypedIdentNode(TypedIdentNormal),
+		children: []subtree{{node: RootOfType, children: []subtree{t}}}}
+	if tag != "" {
+		field.node = TypedIdentNode(TypedIdentTagged)
+		field.children = append(field.children, subtree{node: []byte(tag)})
+	}
+	var ast = make(map[uint64][]byte)
+	paste(ast, 0, field)
+	return ast, nil
+}
@@ -0,0 +1,127 @@
+package mapast
+
+import "errors"
+
+// importalias returns the alias of the ImportStmt node, or an empty string if
+// the import is not renamed.
+func importalias(ast map[uint64][]byte, stmt uint64) string {
+	if childcount(ast, stmt) > 1 {
+		return string(ast[O(stmt)])
+	}
+	return ""
+}
+
+// importconflict reports whether the file imports the unquoted path under an
+// alias that cannot serve an import under the given alias. A blank import is
+// served by any import of the same path.
+func importconflict(ast map[uint64][]byte, file uint64, path string, alias string) bool {
+	for _, stmt := range importstmts(ast, file) {
+		if paths := ImportPaths(ast, stmt); len(paths) == 1 && paths[0] == path {
+			return alias != "_" && importalias(ast, stmt) != alias
+		}
+	}
+	return false
+}
+
+// mergeimport adds an import of the unquoted path to the file, unless the file
+// already has an import serving it.
+func mergeimport(ast map[uint64][]byte, file uint64, path string, alias string) error {
+	if importconflict(ast, file, path, alias) {
+		return errors.New("mapast: " + path + " is already imported under another name")
+	}
+	if _, ok := importname(ast, file, path); !ok {
+		return AddImport(ast, file, path, alias)
+	}
+	return nil
+}
+
+// embedalias returns the alias under which the file imports the embed
+// package. A file without the import gets a blank import.
+func embedalias(ast map[uint64][]byte, file uint64) string {
+	for _, stmt := range importstmts(ast, file) {
+		if paths := ImportPaths(ast, stmt); len(paths) == 1 && paths[0] == "embed" {
+			return importalias(ast, stmt)
+		}
+	}
+	return "_"
+}
+
+// MoveDecl moves the top level declaration at the index of the src FileMatter
+// to the dst FileMatter, so that it starts at the to index once moved. The
+// doc comment and the directives of the declaration move with it, and its
+// ender comments are handled according to the policy. If the declaration has
+// a //go:embed directive, the embed package import is added to dst. Docs is
+// the side-table of the doc comments, see DocComments, kept up to date, or nil.
+func MoveDecl(ast map[uint64][]byte, docs map[uint64]uint64, src uint64, index uint64, dst uint64, to uint64,
+	policy CommentPolicy) error {
+	if !Is(ast[src], FileMatter) || !Is(ast[dst], FileMatter) {
+		return errors.New("mapast: not a FileMatter node")
+	}
+	if !Poke(ast, O(src)+index) {
+		return errors.New("mapast: declaration index out of range")
+	}
+	switch kindof(ast[O(src)+index]) {
+	case kindPackageDef, kindImportStmt, kindImportsDef, kindCommentRow:
+		return errors.New("mapast: not a declaration")
+	}
+	if src == dst {
+		MoveStatement(ast, docs, src, index, to, policy)
+		return nil
+	}
+	var d = DocComments(ast, docs, src, index)
+	for i := index - d; i < index; i++ {
+		if isembed(ast, O(src)+i) {
+			if err := mergeimport(ast, dst, "embed", embedalias(ast, src)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	putstatement(ast, docs, dst, to, takestatement(ast, docs, src, index, policy))
+	return nil
+}
+
+// MergeFiles moves the declarations of the src FileMatter to the end of the
+// dst FileMatter, in order and together with their comments and directives.
+// The imports of src are added to dst, so //go:embed directives keep their
+// embed package import. The package clause, the imports and the comments
+// above them stay in src. If an import of src conflicts with an import of
+// dst, nothing is moved.
+func MergeFiles(ast map[uint64][]byte, dst uint64, src uint64) error {
+	if !Is(ast[src], FileMatter) || !Is(ast[dst], FileMatter) {
+		return errors.New("mapast: not a FileMatter node")
+	}
+	if src == dst {
+		return errors.New("mapast: cannot merge a file with itself")
+	}
+	var stmts = importstmts(ast, src)
+	for _, stmt := range stmts {
+		if paths := ImportPaths(ast, stmt); len(paths) == 1 &&
+			importconflict(ast, dst, paths[0], importalias(ast, stmt)) {
+			return errors.New("mapast: " + paths[0] + " is already imported under another name")
+		}
+	}
+	for _, stmt := range stmts {
+		if paths := ImportPaths(ast, stmt); len(paths) == 1 {
+			if err := mergeimport(ast, dst, paths[0], importalias(ast, stmt)); err != nil {
+				return err
+			}
+		}
+	}
+	var start, found = importindex(ast, src)
+	if found {
+		start++
+	}
+	var moved []subtree
+	for Poke(ast, O(src)+start) {
+		moved = append(moved, clone(ast, O(src)+start))
+		RemoveChild(ast, src, start)
+	}
+	var count = childcount(ast, dst)
+	insertslots(ast, dst, count, uint64(len(moved)))
+	for i := range moved {
+		paste(ast, O(dst)+count+uint64(i), moved[i])
+	}
+	recount(ast, dst, count, len(moved))
+	return nil
+}
@@ -0,0 +1,109 @@
+package mapast
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// FoldKind tells what a folding Range collapses.
+type FoldKind byte
+
+// FoldFunc is a function declaration, from the func keyword.
+const FoldFunc FoldKind = 0
+
+// FoldBlock is a block of statements other than a function body, such as the
+// block of an if statement, a case clause or the body of a function literal.
+const FoldBlock FoldKind = 1
+
+// FoldImports is a group of imports.
+const FoldImports FoldKind = 2
+
+// FoldStruct is the body of a struct or of an interface type.
+const FoldStruct FoldKind = 3
+
+// FoldComment is a run of line comments or a block comment.
+const FoldComment FoldKind = 4
+
+// Range is a region of a file an editor can collapse.
+type Range struct {
+	// Key is the key of the node folded, the first CommentRow of a run of
+	// comments.
+	Key  uint64
+	Kind FoldKind
+	// Start is the position of the node, and End the end of its last string
+	// or the position of its last keyword, so the closing bracket of a block
+	// is left out and stays visible.
+	Start token.Pos
+	End   token.Pos
+}
+
+// FoldingRanges returns the folding ranges of the file at the key, ordered by
+// their start, using the position side-table of the file as returned by
+// convert.ParsePositions. The nodes missing from the side-table, such as
+// the ones inserted after the file was converted, are not folded. Ranges
+// within a single line are returned as well; the editor turning the positions
+// into lines with the file set drops them.
+func FoldingRanges(ast map[uint64][]byte, file uint64, positions map[uint64]token.Pos) (ranges []Range) {
+	var ends = make(map[uint64]token.Pos)
+	WalkPrePost(ast, file, nil, func(key, parent uint64, node []byte) {
+		if pos, ok := positions[key]; ok && Which(node) == nil {
+			ends[key] = pos + token.Pos(len(node))
+		} else if ok {
+			ends[key] = pos
+		}
+		for i := uint64(0); Poke(ast, O(key)+i); i++ {
+			if ends[O(key)+i] > ends[key] {
+				ends[key] = ends[O(key)+i]
+			}
+		}
+	})
+	var fold = func(key uint64, last uint64, kind FoldKind) {
+		if pos, ok := positions[key]; ok && pos.IsValid() && ends[last] > pos {
+			ranges = append(ranges, Range{Key: key, Kind: kind, Start: pos, End: ends[last]})
+		}
+	}
+	Walk(ast, file, func(key, parent uint64, node []byte) bool {
+		switch {
+		case Is(node, ToplevFunc):
+			fold(key, key, FoldFunc)
+
+		case Is(node, BlocOfCode) && !Is(ast[parent], ToplevFunc):
+			fold(key, key, FoldBlock)
+
+		case Is(node, ImportsDef):
+			fold(key, key, FoldImports)
+
+		case Is(node, StructType), Is(node, IfceTypExp):
+			fold(key, key, FoldStruct)
+		}
+		// The comments occupying their own rows and not separated by an
+		// empty row make a run.
+		var run, last uint64
+		var n = 0
+		for i := uint64(0); Poke(ast, O(key)+i); i++ {
+			var child = ast[O(key)+i]
+			if !Is(child, CommentRow) || Variant(child) == CommentRowEnder {
+				n = 0
+				continue
+			}
+			if n == 0 || Variant(child) == CommentRowSeparate {
+				run, n = O(key)+i, 0
+			}
+			last = O(key) + i
+			n++
+			var next = ast[O(key)+i+1]
+			if Is(next, CommentRow) && Variant(next) == CommentRowNormal {
+				continue
+			}
+			if n > 1 || strings.HasPrefix(string(ast[O(run)]), "/*") && strings.Contains(string(ast[O(run)]), "\n") {
+				fold(run, last, FoldComment)
+			}
+		}
+		return true
+	})
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	return ranges
+}
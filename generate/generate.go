@@ -0,0 +1,295 @@
+// Package generate produces new go files as mapast trees from existing ones.
+//
+// The code is written as go source text from the declarations of the trees,
+// and converted, so the trees returned are shaped exactly like the ones of
+// convert.Parse.
+package generate
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// field is a parameter or a result of a function, as a field of the table of
+// test cases.
+type field struct {
+	name     string
+	typ      string
+	variadic bool
+}
+
+// function is an exported function or method to be tested.
+type function struct {
+	// call is the name the function is called by, such as F or T.M, and test
+	// the name of its test, such as TestF or TestT_M.
+	call    string
+	test    string
+	recv    string
+	params  []field
+	results []field
+}
+
+// qualifiers adds the package names the type at the key refers to, such as
+// io of io.Reader.
+func qualifiers(ast map[uint64][]byte, key uint64, names map[string]bool) {
+	mapast.Walk(ast, key, func(at, parent uint64, node []byte) bool {
+		if mapast.Is(node, mapast.Expression) && mapast.Variant(node) == mapast.ExpressionDot &&
+			mapast.Count(node) == 2 && mapast.Which(ast[mapast.O(at)]) == nil {
+			names[string(ast[mapast.O(at)])] = true
+		}
+		return true
+	})
+}
+
+// fields returns the fields of the TypedIdent nodes at the keys, named after
+// their names, or after the prefix and their index if they have none.
+func fields(ast map[uint64][]byte, keys []uint64, prefix string, names map[string]bool) (list []field) {
+	for _, key := range keys {
+		var children = mapast.ChildKeys(ast, key)
+		if len(children) == 0 {
+			continue
+		}
+		var typ = children[len(children)-1]
+		qualifiers(ast, typ, names)
+		var f = field{typ: mapast.SprintNode(ast, typ), variadic: mapast.Variant(ast[key]) == mapast.TypedIdentEllipsis}
+		var idents = mapast.FieldNames(ast, key)
+		if len(idents) == 0 {
+			idents = []uint64{0}
+		}
+		for _, ident := range idents {
+			f.name = prefix + strconv.Itoa(len(list))
+			if ident != 0 && string(ast[ident]) != "_" {
+				f.name = string(ast[ident])
+			}
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+// functions returns the exported functions of the file, and the exported
+// methods of its exported types, adding the package names their signatures
+// refer to.
+func functions(ast map[uint64][]byte, file uint64, names map[string]bool) (list []function) {
+	for _, key := range mapast.ChildKeys(ast, file) {
+		var node = ast[key]
+		var children = mapast.ChildKeys(ast, key)
+		if !mapast.Is(node, mapast.ToplevFunc) || len(children) == 0 ||
+			!token.IsExported(string(ast[children[0]])) {
+			continue
+		}
+		var name = string(ast[children[0]])
+		var first = 1 + int(mapast.Variant(node))
+		var last = len(children)
+		if mapast.Is(ast[children[last-1]], mapast.BlocOfCode) {
+			last--
+		}
+		var f = function{call: name, test: "Test" + name}
+		if mapast.Variant(node) == 1 {
+			var recv = mapast.ChildKeys(ast, children[1])
+			if len(recv) == 0 {
+				continue
+			}
+			f.recv = mapast.SprintNode(ast, recv[len(recv)-1])
+			var typ = strings.TrimPrefix(f.recv, "*")
+			if i := strings.IndexByte(typ, '['); i >= 0 {
+				typ = typ[:i]
+			}
+			if !token.IsExported(typ) {
+				continue
+			}
+			qualifiers(ast, recv[len(recv)-1], names)
+			f.call, f.test = typ+"."+name, "Test"+typ+"_"+name
+		}
+		var params = first + int(mapast.Count(node))
+		if params > last {
+			continue
+		}
+		f.params = fields(ast, children[first:params], "arg", names)
+		f.results = fields(ast, children[params:last], "result", names)
+		list = append(list, f)
+	}
+	return list
+}
+
+// tested returns the names of the test functions of the tree.
+func tested(tests map[uint64][]byte) map[string]bool {
+	var names = make(map[string]bool)
+	if tests == nil {
+		return names
+	}
+	mapast.Walk(tests, 0, func(key, parent uint64, node []byte) bool {
+		if mapast.Is(node, mapast.ToplevFunc) && mapast.Variant(node) == 0 {
+			if name := string(tests[mapast.O(key)]); strings.HasPrefix(name, "Test") {
+				names[name] = true
+			}
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// test writes the table-driven test of the function. The fields of the table
+// are renamed if they clash with the name of the case or the wanted results.
+func test(out *strings.Builder, f function) {
+	var used = map[string]bool{"name": true, "recv": f.recv != "", "wantErr": true}
+	var unique = func(name string) string {
+		for used[name] {
+			name += "Arg"
+		}
+		used[name] = true
+		return name
+	}
+	var results = f.results
+	var witherr = len(results) > 0 && results[len(results)-1].typ == "error"
+	if witherr {
+		results = results[:len(results)-1]
+	}
+	var got, want, args []string
+	for i := range results {
+		var suffix = ""
+		if i > 0 {
+			suffix = strconv.Itoa(i)
+		}
+		got, want = append(got, "got"+suffix), append(want, unique("want"+suffix))
+	}
+	var params = make([]field, len(f.params))
+	for i, p := range f.params {
+		params[i] = p
+		params[i].name = unique(p.name)
+		var arg = "tt." + params[i].name
+		if p.variadic {
+			params[i].typ = "[]" + p.typ
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	out.WriteString("\nfunc " + f.test + "(t *testing.T) {\n\ttests := []struct {\n\t\tname string\n")
+	if f.recv != "" {
+		out.WriteString("\t\trecv " + f.recv + "\n")
+	}
+	for _, p := range params {
+		out.WriteString("\t\t" + p.name + " " + p.typ + "\n")
+	}
+	for i, r := range results {
+		out.WriteString("\t\t" + want[i] + " " + r.typ + "\n")
+	}
+	if witherr {
+		out.WriteString("\t\twantErr bool\n")
+	}
+	out.WriteString("\t}{}\n\tfor _, tt := range tests {\n\t\tt.Run(tt.name, func(t *testing.T) {\n\t\t\t")
+	var lhs = append(append([]string{}, got...), "err")
+	if !witherr {
+		lhs = lhs[:len(got)]
+	}
+	if len(lhs) > 0 {
+		out.WriteString(strings.Join(lhs, ", ") + " := ")
+	}
+	var call = f.call
+	if f.recv != "" {
+		call = "tt.recv." + call[strings.IndexByte(call, '.')+1:]
+	}
+	out.WriteString(call + "(" + strings.Join(args, ", ") + ")\n")
+	if witherr {
+		out.WriteString("\t\t\tif (err != nil) != tt.wantErr {\n\t\t\t\tt.Errorf(\"" + f.call +
+			"() error = %v, wantErr %v\", err, tt.wantErr)\n\t\t\t\treturn\n\t\t\t}\n")
+	}
+	for i, r := range results {
+		var label = f.call + "() " + got[i]
+		if len(results) == 1 {
+			label = f.call + "()"
+		}
+		// Functions are only equal if they are nil.
+		if strings.HasPrefix(r.typ, "func(") {
+			out.WriteString("\t\t\tif (" + got[i] + " == nil) != (tt." + want[i] + " == nil) {\n\t\t\t\tt.Errorf(\"" +
+				label + " is nil = %v, want %v\", " + got[i] + " == nil, tt." + want[i] + " == nil)\n\t\t\t}\n")
+			continue
+		}
+		out.WriteString("\t\t\tif !reflect.DeepEqual(" + got[i] + ", tt." + want[i] + ") {\n\t\t\t\tt.Errorf(\"" +
+			label + " = %v, want %v\", " + got[i] + ", tt." + want[i] + ")\n\t\t\t}\n")
+	}
+	out.WriteString("\t\t})\n\t}\n}\n")
+}
+
+// Tests returns a _test.go file of the package of the file at the key, having
+// a table-driven test for each of the exported functions of the file and the
+// exported methods of its exported types. The tests of a function F and of
+// a method T.M are named TestF and TestT_M, and they are left out if the tests
+// tree has a test function of their name. The tests tree is the existing test
+// files of the package, or nil. The table of every test is empty, for the
+// test cases to be filled in. The file returned is the first child of
+// a RootMatter at key zero, or nil if there is no test to generate. An error
+// is returned if the generated code does not convert, such as when a signature
+// uses a construct mapast cannot represent.
+func Tests(ast map[uint64][]byte, file uint64, tests map[uint64][]byte) (map[uint64][]byte, error) {
+	var pkg string
+	for _, key := range mapast.ChildKeys(ast, file) {
+		if mapast.Is(ast[key], mapast.PackageDef) {
+			pkg = string(ast[mapast.O(key)])
+		}
+	}
+	if pkg == "" {
+		return nil, nil
+	}
+	var names = make(map[string]bool)
+	var existing = tested(tests)
+	var body strings.Builder
+	var deep bool
+	for _, f := range functions(ast, file, names) {
+		if existing[f.test] {
+			continue
+		}
+		existing[f.test] = true
+		test(&body, f)
+		deep = deep || len(f.results) > 1 || len(f.results) == 1 && f.results[0].typ != "error"
+	}
+	if body.Len() == 0 {
+		return nil, nil
+	}
+	var imports = []string{"\"testing\""}
+	if deep {
+		imports = append(imports, "\"reflect\"")
+	}
+	mapast.Walk(ast, file, func(key, parent uint64, node []byte) bool {
+		if !mapast.Is(node, mapast.ImportStmt) {
+			return key == file || mapast.Is(node, mapast.ImportsDef)
+		}
+		var paths = mapast.ImportPaths(ast, key)
+		if len(paths) != 1 {
+			return false
+		}
+		var name = paths[0][strings.LastIndexByte(paths[0], '/')+1:]
+		var children = mapast.ChildKeys(ast, key)
+		if len(children) > 1 {
+			name = string(ast[children[0]])
+		}
+		if names[name] && name != "testing" && name != "reflect" {
+			var spec = strconv.Quote(paths[0])
+			if len(children) > 1 {
+				spec = name + " " + spec
+			}
+			imports = append(imports, spec)
+		}
+		return false
+	})
+	sort.Slice(imports, func(i, j int) bool {
+		return imports[i][strings.IndexByte(imports[i], '"'):] < imports[j][strings.IndexByte(imports[j], '"'):]
+	})
+	var src strings.Builder
+	src.WriteString("package " + pkg + "\n\nimport (\n")
+	for _, spec := range imports {
+		src.WriteString("\t" + spec + "\n")
+	}
+	src.WriteString(")\n" + body.String())
+	var tree, err = convert.Parse([]byte(src.String()))
+	if err != nil {
+		return nil, errors.New("generate: the generated tests do not convert: " + err.Error())
+	}
+	return tree, nil
+}
@@ -0,0 +1,9 @@
+package mapast
+
+// Hash returns the structural hash of the subtree at the key. Only the nodes,
+// the strings and the shape of the subtree are hashed, not the keys, so two
+// identical subtrees at different keys, or in different trees, hash equal.
+// The hash is a SHA-256 sum, stable across runs and machines.
+func Hash(ast map[uint64][]byte, key uint64) [32]byte {
+	return fingerprint(ast, key)
+}
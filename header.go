@@ -0,0 +1,195 @@
+package mapast
+
+import "errors"
+
+// BlockHeader is a view of the header elements of a BlocOfCode node. Every
+// field holds the key of the element, or zero if the element is missing.
+type BlockHeader struct {
+	// Init is the init statement of an if, for, switch or type switch.
+	Init uint64
+	// Cond is the condition of an if or for, the range clause of a for, the
+	// range expression of a for range, the tag of a switch, the guard of
+	// a type switch, or the statement of a communicate clause.
+	Cond uint64
+	// Post is the post statement of a for loop.
+	Post uint64
+	// Cases are the expressions of a case clause.
+	Cases []uint64
+}
+
+// issemi reports whether the node is a BranchStmtSemi separating header
+// elements.
+func issemi(node []byte) bool {
+	return Is(node, BranchStmt) && Variant(node) == BranchStmtSemi
+}
+
+// Header returns the header elements of a BlocOfCode node.
+func Header(ast map[uint64][]byte, block uint64) (h BlockHeader, err error) {
+	var count, e = bodystart(ast, block)
+	if e != nil {
+		return h, e
+	}
+	var groups = [][]uint64{nil}
+	for i := uint64(0); i < count; i++ {
+		var key = O(block) + i
+		if issemi(ast[key]) {
+			groups = append(groups, nil)
+		} else {
+			groups[len(groups)-1] = append(groups[len(groups)-1], key)
+		}
+	}
+	var first = func(g []uint64) uint64 {
+		if len(g) == 0 {
+			return 0
+		}
+		return g[0]
+	}
+	switch Variant(ast[block]) {
+	case BlocOfCodeCase:
+		h.Cases = groups[0]
+
+	default:
+		switch len(groups) {
+		case 1:
+			h.Cond = first(groups[0])
+
+		case 2:
+			h.Init, h.Cond = first(groups[0]), first(groups[1])
+
+		default:
+			h.Init, h.Cond, h.Post = first(groups[0]), first(groups[1]), first(groups[2])
+		}
+	}
+	return h, nil
+}
+
+// headerelem turns an ast having its node at key zero into a header element,
+// wrapping strings by ExpressionIdentifier as the block does not print them.
+func headerelem(elem map[uint64][]byte) subtree {
+	var s = clone(elem, 0)
+	if Which(s.node) == nil {
+		s = subtree{node: ExpressionNode(ExpressionIdentifier, 1), children: []subtree{s}}
+	}
+	return s
+}
+
+// setheader replaces the header elements of the block by the given ones.
+func setheader(ast map[uint64][]byte, block uint64, elems []subtree) {
+	var old, _ = bodystart(ast, block)
+	var n = childcount(ast, block)
+	var body = make([]subtree, 0, n-old)
+	for i := uint64(0); i < n; i++ {
+		if i < old {
+			cut(ast, O(block)+i)
+		} else {
+			body = append(body, cut(ast, O(block)+i))
+		}
+	}
+	for i := range elems {
+		paste(ast, O(block)+uint64(i), elems[i])
+	}
+	for i := range body {
+		paste(ast, O(block)+uint64(len(elems)+i), body[i])
+	}
+	ast[block] = BlocOfCodeNode(Variant(ast[block]), uint64(len(elems)))
+}
+
+// SetHeader replaces the init, condition and post elements of the header of
+// a BlocOfCode node. Each element is an ast having its node at key zero, or nil
+// if the element is missing. The semicolons are put where they are needed.
+// Case clauses use SetCases instead.
+func SetHeader(ast map[uint64][]byte, block uint64, init, cond, post map[uint64][]byte) error {
+	if _, err := bodystart(ast, block); err != nil {
+		return err
+	}
+	var semi = subtree{node: BranchStmtNode(BranchStmtSemi)}
+	var elems []subtree
+	var add = func(elem map[uint64][]byte) {
+		if elem != nil {
+			elems = append(elems, headerelem(elem))
+		}
+	}
+	var variant = Variant(ast[block])
+	switch variant {
+	case BlocOfCodeIf, BlocOfCodeIfElse, BlocOfCodeSwitch, BlocOfCodeTypeSwitch:
+		if post != nil {
+			return errors.New("mapast: only for loops have a post statement")
+		}
+		if init != nil {
+			add(init)
+			elems = append(elems, semi)
+		}
+		add(cond)
+
+	case BlocOfCodeFor:
+		if init != nil || post != nil {
+			add(init)
+			elems = append(elems, semi)
+			add(cond)
+			elems = append(elems, semi)
+			add(post)
+		} else {
+			add(cond)
+		}
+
+	case BlocOfCodeForRange, BlocOfCodeCommunicate:
+		if init != nil || post != nil || cond == nil {
+			return errors.New("mapast: the block header has exactly one element")
+		}
+		add(cond)
+
+	default:
+		return errors.New("mapast: the block has no init, condition or post header")
+	}
+	setheader(ast, block, elems)
+	return nil
+}
+
+// SetCases replaces the expressions of a BlocOfCodeCase node. Each expression
+// is an ast having its node at key zero.
+func SetCases(ast map[uint64][]byte, block uint64, cases ...map[uint64][]byte) error {
+	if _, err := bodystart(ast, block); err != nil {
+		return err
+	}
+	if Variant(ast[block]) != BlocOfCodeCase {
+		return errors.New("mapast: not a case clause")
+	}
+	if len(cases) == 0 {
+		return errors.New("mapast: a case clause needs expressions")
+	}
+	var elems []subtree
+	for _, c := range cases {
+		elems = append(elems, headerelem(c))
+	}
+	setheader(ast, block, elems)
+	return nil
+}
+
+// BuildRangeLoop builds a for range loop over the expression, with an empty
+// body. The variables are the one or two iteration variables, each an ast
+// having its node at key zero, declared if define is true and assigned
+// otherwise. Without variables, the loop is a BlocOfCodeForRange. The
+// expression is an ast having its node at key zero, of any type go can range
+// over, such as a slice, a map, an integer or an iterator function.
+func BuildRangeLoop(vars []map[uint64][]byte, define bool, expr map[uint64][]byte) (map[uint64][]byte, error) {
+	if !Poke(expr, 0) {
+		return nil, errors.New("mapast: range expression without node at key zero")
+	}
+	var loop subtree
+	if len(vars) == 0 {
+		loop = subtree{node: BlocOfCodeNode(BlocOfCodeForRange, 1), children: []subtree{headerelem(expr)}}
+	} else {
+		var operator = "= range"
+		if define {
+			operator = ":= range"
+		}
+		var assign, err = BuildAssignStmt(vars, operator, nil, expr)
+		if err != nil {
+			return nil, err
+		}
+		loop = subtree{node: BlocOfCodeNode(BlocOfCodeFor, 1), children: []subtree{clone(assign, 0)}}
+	}
+	var ast = make(map[uint64][]byte)
+	paste(ast, 0, loop)
+	return ast, nil
+}
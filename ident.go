@@ -0,0 +1,60 @@
+package mapast
+
+import (
+	"errors"
+	"go/token"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidIdent checks that the name is a valid go identifier, so it can be put
+// into the tree as an identifier string. Identifiers start with a unicode
+// letter or an underscore, followed by letters, underscores or unicode digits.
+// Keywords and empty strings are rejected.
+func ValidIdent(name string) error {
+	if name == "" {
+		return errors.New("mapast: empty identifier")
+	}
+	if token.Lookup(name).IsKeyword() {
+		return errors.New("mapast: identifier " + name + " is a keyword")
+	}
+	for i, r := range name {
+		if r == utf8.RuneError {
+			return errors.New("mapast: identifier " + strconv.Quote(name) + " is not valid utf-8")
+		}
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return errors.New("mapast: identifier " + strconv.Quote(name) + " contains " + strconv.QuoteRune(r))
+	}
+	return nil
+}
+
+// BuildSelector writes the selector expression of the parts at the destination
+// key, replacing the subtree there. The parts pkg, Type and Field make the
+// selector pkg.Type.Field. The parts become a chain of nested ExpressionDot nodes with
+// string children, the leftmost being the innermost. A single part becomes an
+// ExpressionIdentifier.
+func BuildSelector(ast map[uint64][]byte, dstKey uint64, parts ...string) error {
+	if len(parts) == 0 {
+		return errors.New("mapast: selector without parts")
+	}
+	for _, part := range parts {
+		if err := ValidIdent(part); err != nil {
+			return err
+		}
+	}
+	var s = subtree{node: ExpressionNode(ExpressionIdentifier, 1),
+		children: []subtree{{node: []byte(parts[0])}}}
+	if len(parts) > 1 {
+		s = subtree{node: []byte(parts[0])}
+	}
+	for _, part := range parts[1:] {
+		s = subtree{node: ExpressionNode(ExpressionDot, 2),
+			children: []subtree{s, {node: []byte(part)}}}
+	}
+	cut(ast, dstKey)
+	paste(ast, dstKey, s)
+	return nil
+}
@@ -0,0 +1,171 @@
+package mapast
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImportPaths returns the unquoted import paths of all ImportStmt nodes found
+// under the iterator position. The iterator can be a RootMatter, a FileMatter
+// or an ImportsDef node. Paths are returned in tree order, possibly repeated.
+func ImportPaths(ast map[uint64][]byte, iterator uint64) (paths []string) {
+	switch kindof(ast[iterator]) {
+	case kindImportStmt:
+		var path = ast[O(iterator)]
+		if another, ok := ast[O(iterator)+1]; ok && Which(another) == nil {
+			path = another
+		}
+		if unq, err := strconv.Unquote(string(path)); err == nil {
+			return []string{unq}
+		}
+		return []string{string(path)}
+
+	case kindRootMatter, kindFileMatter, kindImportsDef:
+		for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+			paths = append(paths, ImportPaths(ast, O(iterator)+i)...)
+		}
+	}
+	return paths
+}
+
+// ImportGraph builds the import graph of several loaded package trees. Pkgs maps
+// the import path of each package to its tree, having RootMatter at key zero.
+// The result maps each package to its sorted and deduplicated imports.
+func ImportGraph(pkgs map[string]map[uint64][]byte) map[string][]string {
+	var graph = make(map[string][]string, len(pkgs))
+	for name, ast := range pkgs {
+		var seen = make(map[string]struct{})
+		var edges = []string{}
+		for _, path := range ImportPaths(ast, 0) {
+			if _, ok := seen[path]; !ok {
+				seen[path] = struct{}{}
+				edges = append(edges, path)
+			}
+		}
+		sort.Strings(edges)
+		graph[name] = edges
+	}
+	return graph
+}
+
+// ImportCycles reports every elementary import cycle among the packages of
+// the graph, those not passing a package twice, found by the algorithm of
+// Johnson. Each cycle is listed starting from its lexically smallest package,
+// and the first package is not repeated at the end. The cycles are sorted by
+// their first package. Imports of packages that are not keys of the graph are
+// ignored.
+func ImportCycles(graph map[string][]string) (cycles [][]string) {
+	var names = make([]string, 0, len(graph))
+	var edges = make(map[string][]string, len(graph))
+	var reverse = make(map[string][]string, len(graph))
+	for name, imports := range graph {
+		names = append(names, name)
+		var seen = make(map[string]struct{})
+		for _, next := range imports {
+			if _, loaded := graph[next]; !loaded {
+				continue
+			}
+			if _, ok := seen[next]; !ok {
+				seen[next] = struct{}{}
+				edges[name] = append(edges[name], next)
+				reverse[next] = append(reverse[next], name)
+			}
+		}
+	}
+	sort.Strings(names)
+	// reach returns the packages not smaller than the start reachable from it
+	// by the edges.
+	var reach = func(start string, edges map[string][]string) map[string]bool {
+		var found = map[string]bool{start: true}
+		var queue = []string{start}
+		for len(queue) > 0 {
+			var name = queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			for _, next := range edges[name] {
+				if next >= start && !found[next] {
+					found[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		return found
+	}
+	for _, start := range names {
+		// The cycles starting from the package stay within its strongly
+		// connected component among the packages not smaller than it.
+		var component = reach(start, edges)
+		var back = reach(start, reverse)
+		for name := range component {
+			if !back[name] {
+				delete(component, name)
+			}
+		}
+		var blocked = make(map[string]bool)
+		var waiting = make(map[string]map[string]struct{})
+		var stack []string
+		var unblock func(string)
+		unblock = func(name string) {
+			blocked[name] = false
+			for other := range waiting[name] {
+				delete(waiting[name], other)
+				if blocked[other] {
+					unblock(other)
+				}
+			}
+		}
+		var circuit func(string) bool
+		circuit = func(name string) (found bool) {
+			stack = append(stack, name)
+			blocked[name] = true
+			for _, next := range edges[name] {
+				switch {
+				case !component[next]:
+
+				case next == start:
+					cycles = append(cycles, append([]string{}, stack...))
+					found = true
+
+				case !blocked[next] && circuit(next):
+					found = true
+				}
+			}
+			if found {
+				unblock(name)
+			} else {
+				for _, next := range edges[name] {
+					if component[next] {
+						if waiting[next] == nil {
+							waiting[next] = make(map[string]struct{})
+						}
+						waiting[next][name] = struct{}{}
+					}
+				}
+			}
+			stack = stack[:len(stack)-1]
+			return found
+		}
+		circuit(start)
+	}
+	return cycles
+}
+
+// UnusedDependencies returns those modules (for instance the require list of
+// a go.mod file) that no package of the graph imports. A module is used when
+// some import path equals the module path or lies beneath it.
+func UnusedDependencies(graph map[string][]string, modules []string) (unused []string) {
+	for _, mod := range modules {
+		var used = false
+		for _, edges := range graph {
+			for _, path := range edges {
+				if path == mod || strings.HasPrefix(path, mod+"/") {
+					used = true
+				}
+			}
+		}
+		if !used {
+			unused = append(unused, mod)
+		}
+	}
+	return unused
+}
@@ -0,0 +1,214 @@
+package mapast
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// importindex returns the position of the last import declaration among the
+// children of the file, or the position of the PackageDef if there is no
+// import. The second result is false if there is neither.
+func importindex(ast map[uint64][]byte, file uint64) (uint64, bool) {
+	var where uint64
+	var found bool
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		switch kindof(ast[O(file)+i]) {
+		case kindPackageDef, kindImportStmt, kindImportsDef:
+			where, found = i, true
+		}
+	}
+	return where, found
+}
+
+// importedits returns the edits adding an import of the quoted path to the
+// file, or nil if the file already imports it. A new import is appended to the
+// existing ImportsDef. Otherwise the last standalone import becomes an
+// ImportsDef holding both imports, or the new import becomes a standalone
+// import after the PackageDef if the file has no imports.
+func importedits(ast map[uint64][]byte, file uint64, name string, path string) []Edit {
+	for _, p := range ImportPaths(ast, file) {
+		if "\""+p+"\"" == path {
+			return nil
+		}
+	}
+	var stmt = subtree{node: ImportStmt, children: []subtree{{node: []byte(path)}}}
+	if name != "" {
+		stmt.children = []subtree{{node: []byte(name)}, {node: []byte(path)}}
+	}
+	var tree = make(map[uint64][]byte)
+	var standalone uint64
+	var found bool
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		var node = ast[O(file)+i]
+		if Is(node, ImportsDef) {
+			paste(tree, 0, stmt)
+			return []Edit{{Op: EditInsert, Key: O(file) + i,
+				Index: childcount(ast, O(file)+i), Node: tree}}
+		}
+		if Is(node, ImportStmt) {
+			standalone, found = O(file)+i, true
+		}
+	}
+	if found {
+		paste(tree, 0, subtree{node: ImportsDef, children: []subtree{clone(ast, standalone), stmt}})
+		return []Edit{{Op: EditReplace, Key: standalone, Node: tree}}
+	}
+	paste(tree, 0, stmt)
+	var where, after = importindex(ast, file)
+	if after {
+		where++
+	}
+	return []Edit{{Op: EditInsert, Key: file, Index: where, Node: tree}}
+}
+
+// ensureimport adds an import of the quoted path to the file, unless the file
+// already imports it.
+func ensureimport(ast map[uint64][]byte, file uint64, name string, path string) {
+	ApplyEdits(ast, importedits(ast, file, name, path))
+}
+
+// importname returns the name under which the file imports the unquoted path.
+// The name is the last path element unless the import is renamed.
+func importname(ast map[uint64][]byte, file uint64, path string) (string, bool) {
+	var stmts []uint64
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		var node = ast[O(file)+i]
+		if Is(node, ImportStmt) {
+			stmts = append(stmts, O(file)+i)
+		}
+		if Is(node, ImportsDef) {
+			for j := uint64(0); Poke(ast, O(O(file)+i)+j); j++ {
+				stmts = append(stmts, O(O(file)+i)+j)
+			}
+		}
+	}
+	for _, stmt := range stmts {
+		var paths = ImportPaths(ast, stmt)
+		if len(paths) != 1 || paths[0] != path {
+			continue
+		}
+		if another, ok := ast[O(stmt)+1]; ok && Which(another) == nil {
+			return string(ast[O(stmt)]), true
+		}
+		var name = path
+		for i := len(path) - 1; i >= 0; i-- {
+			if path[i] == '/' {
+				name = path[i+1:]
+				break
+			}
+		}
+		return name, true
+	}
+	return "", false
+}
+
+// importstmts returns the keys of the ImportStmt nodes of the file, both the
+// standalone ones and the ones within an ImportsDef.
+func importstmts(ast map[uint64][]byte, file uint64) (stmts []uint64) {
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		var node = ast[O(file)+i]
+		if Is(node, ImportStmt) {
+			stmts = append(stmts, O(file)+i)
+		}
+		if Is(node, ImportsDef) {
+			for j := uint64(0); Poke(ast, O(O(file)+i)+j); j++ {
+				if Is(ast[O(O(file)+i)+j], ImportStmt) {
+					stmts = append(stmts, O(O(file)+i)+j)
+				}
+			}
+		}
+	}
+	return stmts
+}
+
+// AddImport adds an import of the unquoted path to the file, under the alias
+// unless the alias is empty. If the file already has an ImportsDef, the import
+// is appended to it. A single standalone import is turned into an ImportsDef
+// holding both imports. Adding an import the file already has does nothing,
+// unless the existing import uses a different alias.
+func AddImport(ast map[uint64][]byte, file uint64, path string, alias string) error {
+	if !Is(ast[file], FileMatter) {
+		return errors.New("mapast: not a FileMatter node")
+	}
+	if path == "" {
+		return errors.New("mapast: empty import path")
+	}
+	if alias != "" && alias != "_" && alias != "." {
+		if err := ValidIdent(alias); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range importstmts(ast, file) {
+		if paths := ImportPaths(ast, stmt); len(paths) == 1 && paths[0] == path {
+			if importalias(ast, stmt) != alias {
+				return errors.New("mapast: " + path + " is already imported under another name")
+			}
+			return nil
+		}
+	}
+	ApplyEdits(ast, importedits(ast, file, alias, strconv.Quote(path)))
+	return nil
+}
+
+// RemoveImport removes the import of the unquoted path from the file. An
+// ImportsDef left without imports is removed too.
+func RemoveImport(ast map[uint64][]byte, file uint64, path string) error {
+	var index = BuildParentIndex(ast, file)
+	for _, stmt := range importstmts(ast, file) {
+		if paths := ImportPaths(ast, stmt); len(paths) != 1 || paths[0] != path {
+			continue
+		}
+		var parent = index[stmt]
+		RemoveChild(ast, parent, stmt-O(parent))
+		if parent != file && !Poke(ast, O(parent)) {
+			RemoveChild(ast, file, parent-O(file))
+		}
+		return nil
+	}
+	return errors.New("mapast: " + path + " is not imported")
+}
+
+// SortImports sorts the imports of every ImportsDef of the file by their
+// paths. Imports separated by other nodes, such as comments, are sorted
+// separately.
+func SortImports(ast map[uint64][]byte, file uint64) {
+	for i := uint64(0); Poke(ast, O(file)+i); i++ {
+		var def = O(file) + i
+		if !Is(ast[def], ImportsDef) {
+			continue
+		}
+		var n = childcount(ast, def)
+		for start := uint64(0); start < n; start++ {
+			var end = start
+			for end < n && Is(ast[O(def)+end], ImportStmt) {
+				end++
+			}
+			if end-start > 1 {
+				var run []subtree
+				for j := start; j < end; j++ {
+					run = append(run, cut(ast, O(def)+j))
+				}
+				sort.SliceStable(run, func(a, b int) bool {
+					return importpath(run[a]) < importpath(run[b])
+				})
+				for j := range run {
+					paste(ast, O(def)+start+uint64(j), run[j])
+				}
+			}
+			start = end
+		}
+	}
+}
+
+// importpath returns the unquoted path of a detached ImportStmt.
+func importpath(stmt subtree) string {
+	if len(stmt.children) == 0 {
+		return ""
+	}
+	var path = string(stmt.children[len(stmt.children)-1].node)
+	if unq, err := strconv.Unquote(path); err == nil {
+		return unq
+	}
+	return path
+}
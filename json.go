@@ -0,0 +1,81 @@
+package mapast
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// jsonNode is a single node of the JSON document. String leaves have only the
+// String field set, nil leaves are encoded as a JSON null.
+type jsonNode struct {
+	Kind     string      `json:"kind,omitempty"`
+	Variant  uint64      `json:"variant,omitempty"`
+	Count    uint64      `json:"count,omitempty"`
+	String   *string     `json:"string,omitempty"`
+	Children []*jsonNode `json:"children,omitempty"`
+}
+
+func tojson(ast map[uint64][]byte, iterator uint64) *jsonNode {
+	var node = ast[iterator]
+	if node == nil {
+		return nil
+	}
+	var j jsonNode
+	if kind := kindof(node); kind < 0 {
+		var s = string(node)
+		j.String = &s
+	} else {
+		j.Kind = kindnames[kind]
+		j.Variant, j.Count = params(node)
+	}
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		j.Children = append(j.Children, tojson(ast, O(iterator)+i))
+	}
+	return &j
+}
+
+func fromjson(j *jsonNode, ast map[uint64][]byte, iterator uint64) error {
+	if j == nil {
+		ast[iterator] = nil
+		return nil
+	}
+	if j.String != nil {
+		ast[iterator] = []byte(*j.String)
+		if Which(ast[iterator]) != nil {
+			return errors.New("mapast: JSON string starts with NodeTag")
+		}
+	} else {
+		var node = makenode(kindnumber(j.Kind), j.Variant, j.Count)
+		if node == nil {
+			return errors.New("mapast: invalid JSON node of kind " + j.Kind)
+		}
+		ast[iterator] = node
+	}
+	for i := range j.Children {
+		if err := fromjson(j.Children[i], ast, O(iterator)+uint64(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the subtree at the iterator position as a nested JSON
+// document. Every node is an object carrying the kind name, the variant and
+// the element count of the node, and an array of children. String leaves are
+// objects with a single string field.
+func MarshalJSON(ast map[uint64][]byte, iterator uint64) ([]byte, error) {
+	if !Poke(ast, iterator) {
+		return nil, errors.New("mapast: no node at the iterator position")
+	}
+	return json.Marshal(tojson(ast, iterator))
+}
+
+// UnmarshalJSON decodes a document produced by MarshalJSON and stores the
+// subtree into the ast at the iterator position.
+func UnmarshalJSON(ast map[uint64][]byte, iterator uint64, data []byte) error {
+	var j *jsonNode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return fromjson(j, ast, iterator)
+}
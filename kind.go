@@ -0,0 +1,193 @@
+package mapast
+
+// Kind enumerates the kinds of nodes together with their variants, so that
+// every AssignStmt, BlocOfCode or Expression variant has its own Kind. Kinds
+// whose variant is a number rather than a named variant, such as ToplevFunc
+// or FileMatter, have a single Kind.
+type Kind uint8
+
+// The kinds. KindString is the kind of strings and nil leaves. KindInvalid is
+// the kind of nodes whose variant is out of range.
+const (
+	KindString Kind = iota
+	KindRootMatter
+	KindFileMatter
+	KindPackageDefNormal
+	KindPackageDefSeparate
+	KindImportStmt
+	KindImportsDef
+	KindTypedIdentNormal
+	KindTypedIdentEquals
+	KindTypedIdentEllipsis
+	KindTypedIdentTagged
+	KindRootOfType
+	KindTypDefStmtNormal
+	KindTypDefStmtAlias
+	KindStructType
+	KindBranchStmtSemi
+	KindBranchStmtBreak
+	KindBranchStmtContinue
+	KindBranchStmtFallthrough
+	KindBranchStmtGoto
+	KindGoDferStmtGo
+	KindGoDferStmtDefer
+	KindReturnStmt
+	KindIncDecStmtPlusPlus
+	KindIncDecStmtMinusMinus
+	KindVarDefStmtVar
+	KindVarDefStmtConst
+	KindLblGotoCntLabel
+	KindLblGotoCntGoto
+	KindLblGotoCntContinue
+	KindLblGotoCntBreak
+	KindIfceTypExp
+	KindCommentRowEnder
+	KindCommentRowNormal
+	KindCommentRowSeparate
+	KindGenericExp
+	KindExpressionBrackets
+	KindExpressionOrOr
+	KindExpressionAndAnd
+	KindExpressionEqual
+	KindExpressionNotEq
+	KindExpressionLessThan
+	KindExpressionLessEq
+	KindExpressionGrtEq
+	KindExpressionGrtThan
+	KindExpressionPlus
+	KindExpressionMinus
+	KindExpressionOr
+	KindExpressionXor
+	KindExpressionMul
+	KindExpressionDiv
+	KindExpressionMod
+	KindExpressionAnd
+	KindExpressionAndNot
+	KindExpressionLSh
+	KindExpressionRSh
+	KindExpressionNot
+	KindExpressionDot
+	KindExpressionSlice
+	KindExpressionComposite
+	KindExpressionCall
+	KindExpressionArrow
+	KindExpressionArrayType
+	KindExpressionSliceType
+	KindExpressionKeyVal
+	KindExpressionType
+	KindExpressionCallDotDotDot
+	KindExpressionComposed
+	KindExpressionIndex
+	KindExpressionMap
+	KindExpressionIdentifier
+	KindExpressionChan
+	KindExpressionInChan
+	KindExpressionOutChan
+	KindExpressionTilde
+	KindBlocOfCodePlain
+	KindBlocOfCodeIf
+	KindBlocOfCodeIfElse
+	KindBlocOfCodeSwitch
+	KindBlocOfCodeFor
+	KindBlocOfCodeForRange
+	KindBlocOfCodeTypeSwitch
+	KindBlocOfCodeSelect
+	KindBlocOfCodeCase
+	KindBlocOfCodeDefault
+	KindBlocOfCodeNone
+	KindBlocOfCodeCommunicate
+	KindBlocOfCodeCommunicateDefault
+	KindToplevFunc
+	KindAssignStmtEqual
+	KindAssignStmtColonEq
+	KindAssignStmtAndNot
+	KindAssignStmtAdd
+	KindAssignStmtSub
+	KindAssignStmtMul
+	KindAssignStmtQuo
+	KindAssignStmtRem
+	KindAssignStmtAnd
+	KindAssignStmtOr
+	KindAssignStmtXor
+	KindAssignStmtShl
+	KindAssignStmtShr
+	KindAssignStmtIotaIsLast
+	KindAssignStmtTypeIsLast
+	KindAssignStmtMoreEqual
+	KindAssignStmtMoreColonEq
+	KindAssignStmtMoreEqualRange
+	KindAssignStmtMoreColonEqRange
+	KindClosureExp
+	KindIfceMethod
+	KindPlaceholder
+	KindInvalid
+)
+
+// kindfirst holds the first Kind of each kind number.
+var kindfirst [kindTotalCount]Kind
+
+// kindstrings holds the names of kinds, indexed by Kind.
+var kindstrings []string
+
+func init() {
+	kindstrings = append(kindstrings, "String")
+	for i, name := range kindnames {
+		kindfirst[i] = Kind(len(kindstrings))
+		if variants, ok := variantnames[name]; ok {
+			for _, v := range variants {
+				kindstrings = append(kindstrings, name+v)
+			}
+		} else {
+			kindstrings = append(kindstrings, name)
+		}
+	}
+	kindstrings = append(kindstrings, "Invalid")
+}
+
+// KindOf returns the Kind of the node.
+func KindOf(node []byte) Kind {
+	var kind = kindof(node)
+	if kind < 0 {
+		return KindString
+	}
+	if _, ok := variantnames[kindnames[kind]]; !ok {
+		return kindfirst[kind]
+	}
+	if int(Variant(node)) >= len(variantnames[kindnames[kind]]) {
+		return KindInvalid
+	}
+	return kindfirst[kind] + Kind(Variant(node))
+}
+
+// String returns the name of the kind, such as ExpressionCall or StructType.
+func (k Kind) String() string {
+	if int(k) < len(kindstrings) {
+		return kindstrings[k]
+	}
+	return "Invalid"
+}
+
+// ParseKind returns the Kind of the given name, as returned by String. It
+// returns false if there is no such kind.
+func ParseKind(name string) (Kind, bool) {
+	for i := range kindstrings {
+		if kindstrings[i] == name {
+			return Kind(i), true
+		}
+	}
+	return KindInvalid, false
+}
+
+// Node returns a node of the kind. The element count is zero, and the variant
+// is the one of the kind, or zero for the kinds with numeric variants. It
+// returns nil for KindString and KindInvalid.
+func (k Kind) Node() []byte {
+	if k == KindString || k >= KindInvalid {
+		return nil
+	}
+	var kind = kindTotalCount - 1
+	for kindfirst[kind] > k {
+		kind--
+	}
+	return header(byte(kind), byte(k-kindfirst[kind]), 0)
+}
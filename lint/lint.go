@@ -0,0 +1,140 @@
+// Package lint runs checks, called rules, over the files of a mapast tree.
+//
+// A rule walks a file and reports the problems it finds at the keys of the
+// nodes causing them. The rules registered with Register are run by default;
+// the package registers a few example rules:
+//
+//	nakedret  naked returns in long functions having named results
+//	funlen    functions having too many statements
+//	println   fmt.Print, fmt.Printf and fmt.Println outside of package main
+//
+// A rule of another package registers itself in an init function:
+//
+//	func init() {
+//		lint.Register(lint.Func("noinit", func(ast map[uint64][]byte, root uint64,
+//			report func(key uint64, msg string)) {
+//			...
+//		}))
+//	}
+package lint
+
+import (
+	"github.com/go-li/mapast"
+	"sort"
+)
+
+// Rule is a single check.
+type Rule interface {
+	// Name is the short name of the rule, unique among the registered
+	// rules.
+	Name() string
+	// Check checks the file at the root, calling report for every problem
+	// found with the key of the node causing it.
+	Check(ast map[uint64][]byte, root uint64, report func(key uint64, msg string))
+}
+
+// funcrule is a rule made of a function.
+type funcrule struct {
+	name  string
+	check func(ast map[uint64][]byte, root uint64, report func(key uint64, msg string))
+}
+
+// Name returns the name of the rule.
+func (r funcrule) Name() string {
+	return r.name
+}
+
+// Check calls the function of the rule.
+func (r funcrule) Check(ast map[uint64][]byte, root uint64, report func(key uint64, msg string)) {
+	r.check(ast, root, report)
+}
+
+// Func returns a rule of the name checking files with the function.
+func Func(name string, check func(ast map[uint64][]byte, root uint64, report func(key uint64, msg string))) Rule {
+	return funcrule{name: name, check: check}
+}
+
+// registered holds the registered rules by name.
+var registered = make(map[string]Rule)
+
+// Register registers the rule, so that Run runs it by default. It panics if
+// a rule of the same name is registered already.
+func Register(rule Rule) {
+	if _, ok := registered[rule.Name()]; ok {
+		panic("lint: Register called twice for rule " + rule.Name())
+	}
+	registered[rule.Name()] = rule
+}
+
+// Rules returns the registered rules, sorted by name.
+func Rules() []Rule {
+	var rules = make([]Rule, 0, len(registered))
+	for _, rule := range registered {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name() < rules[j].Name()
+	})
+	return rules
+}
+
+// Lookup returns the registered rule of the name.
+func Lookup(name string) (Rule, bool) {
+	var rule, ok = registered[name]
+	return rule, ok
+}
+
+// Problem is a problem reported by a rule.
+type Problem struct {
+	Rule string
+	// File is the key of the FileMatter of the file, and Key the key of the
+	// node causing the problem.
+	File    uint64
+	Key     uint64
+	Message string
+}
+
+// String returns the message prefixed by the name of the rule.
+func (p Problem) String() string {
+	return p.Rule + ": " + p.Message
+}
+
+// files returns the keys of the FileMatter nodes of the tree at the root,
+// which is either a RootMatter of a package or a single FileMatter.
+func files(ast map[uint64][]byte, root uint64) (keys []uint64) {
+	if mapast.Is(ast[root], mapast.FileMatter) {
+		return []uint64{root}
+	}
+	for _, key := range mapast.ChildKeys(ast, root) {
+		if mapast.Is(ast[key], mapast.FileMatter) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Run runs the rules over every file of the tree at the root, which is either
+// a RootMatter, whose FileMatter children are the files of a package, or
+// a single FileMatter. If rules is nil, the registered rules are run. The
+// problems are returned file by file, in the order of the code, and in the
+// order of the rules for the problems of the same node.
+func Run(ast map[uint64][]byte, root uint64, rules []Rule) (problems []Problem) {
+	if rules == nil {
+		rules = Rules()
+	}
+	for _, file := range files(ast, root) {
+		var found []Problem
+		for _, rule := range rules {
+			var name = rule.Name()
+			rule.Check(ast, file, func(key uint64, msg string) {
+				found = append(found, Problem{Rule: name, File: file, Key: key, Message: msg})
+			})
+		}
+		var _, offsets = mapast.CodeOffsets(ast, file, file)
+		sort.SliceStable(found, func(i, j int) bool {
+			return offsets[found[i].Key] < offsets[found[j].Key]
+		})
+		problems = append(problems, found...)
+	}
+	return problems
+}
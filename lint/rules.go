@@ -0,0 +1,139 @@
+package lint
+
+import (
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/metrics"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(NakedReturns{MaxStatements: 5})
+	Register(LongFunctions{MaxStatements: 50})
+	Register(Func("println", printlns))
+}
+
+// functions returns the keys of the ToplevFunc nodes of the file having
+// a body.
+func functions(ast map[uint64][]byte, file uint64) (keys []uint64) {
+	for _, key := range mapast.ChildKeys(ast, file) {
+		var children = mapast.ChildKeys(ast, key)
+		if mapast.Is(ast[key], mapast.ToplevFunc) && len(children) > 0 &&
+			mapast.Is(ast[children[len(children)-1]], mapast.BlocOfCode) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// NakedReturns reports the return statements without values of the functions
+// having named results and more than MaxStatements statements, where the
+// values returned are hard to tell. The function literals are not checked.
+type NakedReturns struct {
+	MaxStatements int
+}
+
+// Name returns nakedret.
+func (r NakedReturns) Name() string {
+	return "nakedret"
+}
+
+// Check reports the naked returns of the file.
+func (r NakedReturns) Check(ast map[uint64][]byte, root uint64, report func(key uint64, msg string)) {
+	for _, key := range functions(ast, root) {
+		var node = ast[key]
+		var children = mapast.ChildKeys(ast, key)
+		var results = children[1+int(mapast.Variant(node))+int(mapast.Count(node)) : len(children)-1]
+		if len(results) == 0 || len(mapast.FieldNames(ast, results[0])) == 0 {
+			continue
+		}
+		var m = metrics.Of(ast, key)
+		if m.Statements <= r.MaxStatements {
+			continue
+		}
+		mapast.Walk(ast, children[len(children)-1], func(at, parent uint64, node []byte) bool {
+			if mapast.Is(node, mapast.ReturnStmt) && !mapast.Poke(ast, mapast.O(at)) {
+				report(at, "naked return in "+m.Name+", which has "+strconv.Itoa(m.Statements)+" statements")
+			}
+			return !mapast.Is(node, mapast.ClosureExp)
+		})
+	}
+}
+
+// LongFunctions reports the functions having more than MaxStatements
+// statements, counting the statements of their function literals.
+type LongFunctions struct {
+	MaxStatements int
+}
+
+// Name returns funlen.
+func (r LongFunctions) Name() string {
+	return "funlen"
+}
+
+// Check reports the long functions of the file at their names.
+func (r LongFunctions) Check(ast map[uint64][]byte, root uint64, report func(key uint64, msg string)) {
+	for _, key := range functions(ast, root) {
+		if m := metrics.Of(ast, key); m.Statements > r.MaxStatements {
+			report(mapast.O(key), m.Name+" has "+strconv.Itoa(m.Statements)+" statements, more than "+
+				strconv.Itoa(r.MaxStatements))
+		}
+	}
+}
+
+// fmtname returns the name the file imports package fmt under, or an empty
+// string if it does not import it by name.
+func fmtname(ast map[uint64][]byte, file uint64) string {
+	var name string
+	mapast.Walk(ast, file, func(key, parent uint64, node []byte) bool {
+		if !mapast.Is(node, mapast.ImportStmt) {
+			return key == file || mapast.Is(node, mapast.ImportsDef)
+		}
+		var children = mapast.ChildKeys(ast, key)
+		if path, err := strconv.Unquote(string(ast[children[len(children)-1]])); err != nil || path != "fmt" {
+			return false
+		}
+		name = "fmt"
+		if len(children) > 1 {
+			name = string(ast[children[0]])
+		}
+		return false
+	})
+	if name == "_" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// printlns reports the calls of fmt.Print, fmt.Printf and fmt.Println in
+// files of packages other than main, which should not write to the standard
+// output on their own. The external test packages are not checked, since their
+// examples print on purpose.
+func printlns(ast map[uint64][]byte, root uint64, report func(key uint64, msg string)) {
+	var pkg string
+	for _, key := range mapast.ChildKeys(ast, root) {
+		if mapast.Is(ast[key], mapast.PackageDef) {
+			pkg = string(ast[mapast.O(key)])
+		}
+	}
+	var name = fmtname(ast, root)
+	if pkg == "main" || strings.HasSuffix(pkg, "_test") || name == "" {
+		return
+	}
+	mapast.Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		if !mapast.Is(node, mapast.Expression) || mapast.Variant(node) != mapast.ExpressionCall {
+			return true
+		}
+		var fun = mapast.O(key)
+		var dot = ast[fun]
+		if !mapast.Is(dot, mapast.Expression) || mapast.Variant(dot) != mapast.ExpressionDot ||
+			mapast.Count(dot) != 2 || string(ast[mapast.O(fun)]) != name {
+			return true
+		}
+		switch sel := string(ast[mapast.O(fun)+1]); sel {
+		case "Print", "Printf", "Println":
+			report(key, "fmt."+sel+" in package "+pkg)
+		}
+		return true
+	})
+}
@@ -0,0 +1,40 @@
+package mapast
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// QuoteString returns the interpreted go string literal of the string, with
+// the quotes and escapes, ready to be put into the tree as an Expression or
+// ImportStmt child.
+func QuoteString(s string) []byte {
+	return []byte(strconv.Quote(s))
+}
+
+// RawString returns the raw go string literal of the string, enclosed in back
+// quotes. A string that a raw literal cannot hold, because it contains a back
+// quote, a carriage return, a NUL, a byte order mark or invalid utf-8, which
+// the go compiler rejects in source code, gets an interpreted literal.
+func RawString(s string) []byte {
+	if strings.ContainsAny(s, "`\r\x00\uFEFF") || !utf8.ValidString(s) {
+		return QuoteString(s)
+	}
+	return []byte("`" + s + "`")
+}
+
+// UnquoteChild returns the value of the string or character literal at the
+// key, removing the quotes and resolving the escapes.
+func UnquoteChild(ast map[uint64][]byte, key uint64) (string, error) {
+	var literal, ok = ast[key]
+	if !ok || Which(literal) != nil {
+		return "", errors.New("mapast: not a string child")
+	}
+	var s, err = strconv.Unquote(string(literal))
+	if err != nil {
+		return "", errors.New("mapast: " + strconv.Quote(string(literal)) + " is not a string literal")
+	}
+	return s, nil
+}
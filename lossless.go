@@ -0,0 +1,212 @@
+package mapast
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// Span is the byte offset range of the source text that a node was converted
+// from, together with the fingerprint of the subtree taken at that time. The
+// span of a CommentRow is empty, at the offset of the comment: the converter
+// moves the comments found within a declaration after it, so the comment is
+// left out wherever the node spanning its offset is printed verbatim.
+type Span struct {
+	Start int
+	End   int
+	Sum   [sha256.Size]byte
+}
+
+// fingerprint hashes the nodes and the shape of the subtree at the key.
+func fingerprint(ast map[uint64][]byte, key uint64) (sum [sha256.Size]byte) {
+	var h = sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	var walk func(uint64)
+	walk = func(key uint64) {
+		var node = ast[key]
+		if node == nil {
+			h.Write([]byte{0})
+		} else {
+			h.Write([]byte{1})
+			h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(node)))])
+			h.Write(node)
+		}
+		var children = ChildKeys(ast, key)
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(children)))])
+		for _, child := range children {
+			walk(child)
+		}
+	}
+	walk(key)
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// NewSpan returns the span of the node at the key, converted from the source
+// text between the start and end byte offsets. The fingerprint is taken from
+// the subtree as it is now, so NewSpan is called right after the conversion.
+func NewSpan(ast map[uint64][]byte, key uint64, start int, end int) Span {
+	return Span{Start: start, End: end, Sum: fingerprint(ast, key)}
+}
+
+// CodeLossless generates go source code like Code does, except that the nodes
+// which have a span and were not modified since their span was taken are
+// printed verbatim from the original source, preserving their formatting.
+// Only the modified nodes are rendered again, so the statements of a modified
+// function that have spans of their own are still printed verbatim. The
+// CommentRows within the printed text are left out, not to print them twice,
+// and the rows between the printed nodes are those of the source, along with
+// the comments the tree does not hold.
+func CodeLossless(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64, src []byte, spans map[uint64]Span) {
+	// taken returns the span of the key if it lies within the source, valid
+	// whether the node was not modified since.
+	var taken = func(key uint64) (span Span, ok bool, valid bool) {
+		span, ok = spans[key]
+		ok = ok && span.Start >= 0 && span.Start <= span.End && span.End <= len(src)
+		return span, ok, ok && fingerprint(ast, key) == span.Sum
+	}
+	// The offsets of the comments in the tree, the other comments of the
+	// source are only found between the spans.
+	var comments []int
+	for key, span := range spans {
+		if Is(ast[key], CommentRow) {
+			comments = append(comments, span.Start)
+		}
+	}
+	sort.Ints(comments)
+	var printed []Span
+	var inside = func(span Span) bool {
+		for _, p := range printed {
+			if p.Start <= span.Start && span.Start < p.End {
+				return true
+			}
+		}
+		return false
+	}
+	// The empty rows are held until the next text. The rows after a node
+	// printed verbatim are the rows of the source up to the next node, if that
+	// has a span, otherwise the rows the printer ends the node with.
+	var held, want = 0, -1
+	var wanted uint64
+	var skipping bool
+	var emit = func(s string) {
+		if s == "" {
+			if !skipping {
+				held++
+			}
+			return
+		}
+		for ; held > 0; held-- {
+			print("")
+		}
+		print(s)
+	}
+	// lines emits the text, a row for every newline.
+	var lines = func(text []byte) {
+		for {
+			var i = bytes.IndexByte(text, '\n')
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				emit(string(text[:i]))
+			}
+			emit("")
+			text = text[i+1:]
+		}
+		if len(text) > 0 {
+			emit(string(text))
+		}
+	}
+	// The keys of the nodes entered and not yet left, the last one being the
+	// parent of the node entered next.
+	var keys = []uint64{parent}
+	// gap takes the rows after the node, which ends at the offset, from the
+	// source up to the next sibling printed, or up to the end of the file.
+	// The comments of the source between them that are not in the tree are
+	// printed too.
+	var gap = func(key uint64, end int) bool {
+		var next = key + 1
+		var span Span
+		var ok bool
+		for ; Poke(ast, next); next++ {
+			if span, ok, _ = taken(next); !ok || !Is(ast[next], CommentRow) || !inside(span) {
+				break
+			}
+		}
+		var gap []byte
+		if ok && span.Start >= end {
+			gap = src[end:span.Start]
+		} else if !Poke(ast, next) && Is(ast[keys[len(keys)-1]], FileMatter) {
+			gap = src[end:]
+		} else {
+			return false
+		}
+		var i = sort.SearchInts(comments, end)
+		if last := bytes.LastIndexByte(gap, '\n'); last >= 0 && len(bytes.TrimSpace(gap[:last])) != 0 &&
+			(i == len(comments) || comments[i] >= end+last) {
+			lines(gap[:last])
+			gap = gap[last:]
+		}
+		want, wanted = bytes.Count(gap, []byte{'\n'}), keys[len(keys)-1]
+		if want == 0 && len(bytes.TrimSpace(gap)) == 0 {
+			emit(string(gap))
+		}
+		if Poke(ast, next) && Is(ast[next], CommentRow) && Variant(ast[next]) == CommentRowSeparate && want > 0 {
+			want--
+		}
+		return true
+	}
+	codehooks(emit, func(key uint64) bool {
+		// The rows the parent ends a left out CommentRow with are left out
+		// too, up to the next node entered.
+		skipping = false
+		var span, _, valid = taken(key)
+		if valid && Is(ast[key], CommentRow) && inside(span) {
+			skipping = true
+			return true
+		}
+		if want >= 0 && wanted == keys[len(keys)-1] {
+			held, want = want, -1
+		}
+		if valid && Is(ast[key], CommentRow) {
+			// The comment is printed by the printer, the rows after it
+			// are taken from the source.
+			if text := ast[O(key)]; bytes.HasPrefix(src[span.Start:], text) {
+				gap(key, span.Start+len(text))
+			}
+		}
+		if !valid || Is(ast[key], CommentRow) {
+			keys = append(keys, key)
+			return false
+		}
+		// A node starting a row is indented like in the source.
+		if start := bytes.LastIndexByte(src[:span.Start], '\n') + 1; held > 0 &&
+			start < span.Start && len(bytes.TrimSpace(src[start:span.Start])) == 0 {
+			emit(string(src[start:span.Start]))
+		}
+		printed = append(printed, span)
+		lines(src[span.Start:span.End])
+		if !gap(key, span.End) {
+			var rows int
+			code(func(s string) {
+				if s == "" {
+					rows++
+				} else {
+					rows = 0
+				}
+			}, nil, ast, key, keys[len(keys)-1])
+			held += rows
+		}
+		return true
+	}, func(uint64) {
+		keys = keys[:len(keys)-1]
+	}, maptree(ast), iterator, parent)
+	if want >= 0 {
+		held = want
+	}
+	for ; held > 0; held-- {
+		print("")
+	}
+}
@@ -0,0 +1,214 @@
+package mapast
+
+import "crypto/sha256"
+
+// Conflict is a change made differently by both sides of a Merge.
+type Conflict struct {
+	// Key is the key in the merged tree where the conflict is. It is the key
+	// of the conflicting node, or of the parent of conflicting children.
+	Key uint64
+	// Base, Ours and Theirs are the keys of the corresponding nodes in the
+	// three merged trees.
+	Base, Ours, Theirs uint64
+}
+
+// merger holds the state of a three-way merge.
+type merger struct {
+	base, ours, theirs map[uint64][]byte
+	result             map[uint64][]byte
+	sums               [3]map[uint64][sha256.Size]byte
+	conflicts          []Conflict
+}
+
+// sum returns the memoized fingerprint of the subtree at the key of the tree
+// numbered as in the sums field.
+func (m *merger) sum(tree int, key uint64) [sha256.Size]byte {
+	if sum, ok := m.sums[tree][key]; ok {
+		return sum
+	}
+	var sum = fingerprint([]map[uint64][]byte{m.base, m.ours, m.theirs}[tree], key)
+	m.sums[tree][key] = sum
+	return sum
+}
+
+// equal reports whether the subtrees at the keys of the two trees are equal.
+func (m *merger) equal(tree1 int, keys1 []uint64, tree2 int, keys2 []uint64) bool {
+	if len(keys1) != len(keys2) {
+		return false
+	}
+	for i := range keys1 {
+		if m.sum(tree1, keys1[i]) != m.sum(tree2, keys2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// identity returns what identifies the node at the key across versions of the
+// tree: the node itself followed by the first string found by descending
+// through the first children, such as the name of a function.
+func identity(ast map[uint64][]byte, key uint64) string {
+	var id = string(ast[key])
+	for depth := 0; depth < 3 && Which(ast[key]) != nil; depth++ {
+		key = O(key)
+		if Which(ast[key]) == nil {
+			id += "\x00" + string(ast[key])
+		}
+	}
+	return id
+}
+
+// match returns, for every base child, the index of the matching child of
+// the other tree or -1, following the longest common subsequence of their
+// identities.
+func (m *merger) match(base []uint64, tree int, other []uint64) []int {
+	var ast = []map[uint64][]byte{m.base, m.ours, m.theirs}[tree]
+	var ib, io = make([]string, len(base)), make([]string, len(other))
+	for i := range base {
+		ib[i] = identity(m.base, base[i])
+	}
+	for j := range other {
+		io[j] = identity(ast, other[j])
+	}
+	var lcs = make([][]int, len(base)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(other)+1)
+	}
+	for i := len(base) - 1; i >= 0; i-- {
+		for j := len(other) - 1; j >= 0; j-- {
+			if ib[i] == io[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var matched = make([]int, len(base))
+	var i, j = 0, 0
+	for i < len(base) {
+		switch {
+		case j < len(other) && ib[i] == io[j]:
+			matched[i] = j
+			i, j = i+1, j+1
+
+		case j < len(other) && lcs[i][j+1] > lcs[i+1][j]:
+			j++
+
+		default:
+			matched[i] = -1
+			i++
+		}
+	}
+	return matched
+}
+
+// copy copies the subtrees at the keys of the tree as consecutive children of
+// the destination, starting at the index. The keys are children of the parent
+// key of the tree. It returns the next index, and adds the copied children
+// counted by the element count of the parent to n.
+func (m *merger) copy(tree int, parent uint64, keys []uint64, dst uint64, index uint64, n *uint64) uint64 {
+	var ast = []map[uint64][]byte{m.base, m.ours, m.theirs}[tree]
+	for _, key := range keys {
+		CopySubtree(ast, key, m.result, O(dst)+index)
+		m.count(ast, parent, key, n)
+		index++
+	}
+	return index
+}
+
+// count adds one to n if the child at the key is counted by the element count
+// of the parent key of the tree.
+func (m *merger) count(ast map[uint64][]byte, parent uint64, key uint64, n *uint64) {
+	if first, last, ok := counted(ast[parent]); ok && key-O(parent) >= first && key-O(parent) < last {
+		*n++
+	}
+}
+
+// merge merges the subtrees at the keys of the three trees into the result
+// at the destination key.
+func (m *merger) merge(b, o, t uint64, dst uint64) {
+	switch sb, so, st := m.sum(0, b), m.sum(1, o), m.sum(2, t); {
+	case so == st || sb == st:
+		CopySubtree(m.ours, o, m.result, dst)
+		return
+
+	case sb == so:
+		CopySubtree(m.theirs, t, m.result, dst)
+		return
+	}
+	var nb, no, nt = m.base[b], m.ours[o], m.theirs[t]
+	var node = no
+	switch {
+	case string(nb) == string(no):
+		node = nt
+
+	case string(nb) != string(nt) && string(no) != string(nt):
+		m.conflicts = append(m.conflicts, Conflict{Key: dst, Base: b, Ours: o, Theirs: t})
+		CopySubtree(m.ours, o, m.result, dst)
+		return
+	}
+	CopySubtree(map[uint64][]byte{0: node}, 0, m.result, dst)
+	var cb, co, ct = ChildKeys(m.base, b), ChildKeys(m.ours, o), ChildKeys(m.theirs, t)
+	var mo, mt = m.match(cb, 1, co), m.match(cb, 2, ct)
+	var i, j, k, index = 0, 0, 0, uint64(0)
+	// The element count of the node is recounted, since the sides may have
+	// inserted or removed counted children, such as parameters.
+	var n uint64
+	for {
+		var i2, j2, k2 = i, len(co), len(ct)
+		for i2 < len(cb) && (mo[i2] < 0 || mt[i2] < 0) {
+			i2++
+		}
+		if i2 < len(cb) {
+			j2, k2 = mo[i2], mt[i2]
+		}
+		var chb, cho, cht = cb[i:i2], co[j:j2], ct[k:k2]
+		switch {
+		case m.equal(0, chb, 1, cho):
+			index = m.copy(2, t, cht, dst, index, &n)
+
+		case m.equal(0, chb, 2, cht) || m.equal(1, cho, 2, cht):
+			index = m.copy(1, o, cho, dst, index, &n)
+
+		case len(chb) == len(cho) && len(chb) == len(cht):
+			for x := range chb {
+				m.merge(chb[x], cho[x], cht[x], O(dst)+index)
+				m.count(m.ours, o, cho[x], &n)
+				index++
+			}
+
+		default:
+			m.conflicts = append(m.conflicts, Conflict{Key: dst, Base: b, Ours: o, Theirs: t})
+			index = m.copy(1, o, cho, dst, index, &n)
+		}
+		if i2 == len(cb) {
+			break
+		}
+		m.merge(cb[i2], co[j2], ct[k2], O(dst)+index)
+		m.count(m.ours, o, co[j2], &n)
+		index++
+		i, j, k = i2+1, j2+1, k2+1
+	}
+	if _, _, ok := counted(node); ok {
+		var variant, _ = params(node)
+		m.result[dst] = makenode(kindof(node), variant, n)
+	}
+}
+
+// Merge merges the changes made to the base tree by the ours and theirs trees
+// at the root position, and returns the merged subtree at the root position
+// of a new tree. Children are matched by their kind and by their first
+// string, such as a name, so children inserted or removed on one side are
+// merged even though the keys of their siblings changed. A change made
+// differently by both sides is reported as a conflict and resolved by taking
+// ours.
+func Merge(base, ours, theirs map[uint64][]byte, root uint64) (map[uint64][]byte, []Conflict) {
+	var m = merger{base: base, ours: ours, theirs: theirs, result: make(map[uint64][]byte)}
+	for i := range m.sums {
+		m.sums[i] = make(map[uint64][sha256.Size]byte)
+	}
+	m.merge(root, root, root, root)
+	return m.result, m.conflicts
+}
@@ -0,0 +1,162 @@
+// Package metrics computes code metrics of the functions of a mapast tree.
+//
+// The metrics are computed from the structure of the ToplevFunc and
+// BlocOfCode nodes, without type checking, so they are cheap enough to be
+// computed for every function of a large corpus:
+//
+//	for key, m := range metrics.Functions(ast, 0) {
+//		if m.Complexity > 15 {
+//			report(key, m.Name)
+//		}
+//	}
+package metrics
+
+import (
+	"github.com/go-li/mapast"
+)
+
+// Func holds the metrics of a single function. The function literals in its
+// body count as a part of it.
+type Func struct {
+	// Name is the name of the function or of the method.
+	Name string
+	// Complexity is the cyclomatic complexity: one plus the number of if,
+	// for and case clauses, and of the && and || operators.
+	Complexity int
+	// Depth is the maximum nesting depth of the blocks within the body. A body
+	// without blocks has depth zero; the clauses of a switch or select are at
+	// the depth of the statement.
+	Depth int
+	// Statements is the number of statements, nested ones included. An if
+	// statement with its else branches counts as one.
+	Statements int
+	// Params and Results are the number of parameters and results, the
+	// receiver excluded.
+	Params  int
+	Results int
+}
+
+// isclause reports whether the node is a case, default or communicate clause.
+func isclause(node []byte) bool {
+	if !mapast.Is(node, mapast.BlocOfCode) {
+		return false
+	}
+	switch mapast.Variant(node) {
+	case mapast.BlocOfCodeCase, mapast.BlocOfCodeDefault, mapast.BlocOfCodeCommunicate,
+		mapast.BlocOfCodeCommunicateDefault:
+		return true
+	}
+	return false
+}
+
+// names returns the number of names declared by a TypedIdent, one if it has
+// only a type.
+func names(ast map[uint64][]byte, key uint64) (n int) {
+	for _, child := range mapast.ChildKeys(ast, key) {
+		if mapast.Which(ast[child]) == nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// measure adds the metrics of the subtree at the key, which is at the depth.
+func measure(ast map[uint64][]byte, key uint64, depth int, m *Func) {
+	var node = ast[key]
+	switch {
+	case mapast.Is(node, mapast.BlocOfCode):
+		switch mapast.Variant(node) {
+		case mapast.BlocOfCodeIf, mapast.BlocOfCodeIfElse, mapast.BlocOfCodeFor, mapast.BlocOfCodeForRange,
+			mapast.BlocOfCodeCase, mapast.BlocOfCodeCommunicate:
+			m.Complexity++
+		}
+		if !isclause(node) {
+			depth++
+		}
+		if depth > m.Depth {
+			m.Depth = depth
+		}
+		var children = mapast.ChildKeys(ast, key)
+		for i, child := range children {
+			if i >= int(mapast.Count(node)) && isstatement(ast, children, i) {
+				m.Statements++
+			}
+			measure(ast, child, depth, m)
+		}
+		return
+
+	case mapast.Is(node, mapast.Expression):
+		if v := mapast.Variant(node); v == mapast.ExpressionAndAnd || v == mapast.ExpressionOrOr {
+			m.Complexity += int(mapast.Count(node)) - 1
+		}
+	}
+	for _, child := range mapast.ChildKeys(ast, key) {
+		measure(ast, child, depth, m)
+	}
+}
+
+// isstatement reports whether the child at the index of a block is
+// a statement, not a comment, a label, a clause or an else branch.
+func isstatement(ast map[uint64][]byte, children []uint64, i int) bool {
+	var node = ast[children[i]]
+	switch {
+	case mapast.Is(node, mapast.CommentRow), isclause(node):
+		return false
+
+	case mapast.Is(node, mapast.LblGotoCnt):
+		return mapast.Variant(node) != mapast.LblGotoCntLabel
+
+	case i > 0 && mapast.Is(ast[children[i-1]], mapast.BlocOfCode) &&
+		mapast.Variant(ast[children[i-1]]) == mapast.BlocOfCodeIfElse:
+		return false
+	}
+	return true
+}
+
+// Of returns the metrics of the ToplevFunc at the key.
+func Of(ast map[uint64][]byte, key uint64) Func {
+	var node = ast[key]
+	var m = Func{Complexity: 1}
+	var children = mapast.ChildKeys(ast, key)
+	if !mapast.Is(node, mapast.ToplevFunc) || len(children) == 0 {
+		return m
+	}
+	m.Name = string(ast[children[0]])
+	var first = 1 + int(mapast.Variant(node))
+	for i, child := range children[1:] {
+		switch {
+		case mapast.Is(ast[child], mapast.BlocOfCode):
+			// The body itself is at depth zero.
+			var body = Func{}
+			measure(ast, child, -1, &body)
+			m.Complexity += body.Complexity
+			m.Depth, m.Statements = body.Depth, body.Statements
+
+		case !mapast.Is(ast[child], mapast.TypedIdent) || i+1 < first:
+
+		case i+1 < first+int(mapast.Count(node)):
+			m.Params += names(ast, child)
+
+		default:
+			m.Results += names(ast, child)
+		}
+	}
+	return m
+}
+
+// Functions returns the metrics of every ToplevFunc of the tree at the root,
+// keyed by the keys of the ToplevFunc nodes.
+func Functions(ast map[uint64][]byte, root uint64) map[uint64]Func {
+	var funcs = make(map[uint64]Func)
+	mapast.Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		if mapast.Is(node, mapast.ToplevFunc) {
+			funcs[key] = Of(ast, key)
+			return false
+		}
+		return true
+	})
+	return funcs
+}
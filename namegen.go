@@ -0,0 +1,56 @@
+package mapast
+
+import (
+	"go/token"
+	"strconv"
+)
+
+// predeclared lists the predeclared identifiers of the universe block.
+var predeclared = []string{"any", "bool", "byte", "comparable", "complex64",
+	"complex128", "error", "float32", "float64", "int", "int8", "int16",
+	"int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32",
+	"uint64", "uintptr", "true", "false", "iota", "nil", "append", "cap",
+	"clear", "close", "complex", "copy", "delete", "imag", "len", "make",
+	"max", "min", "new", "panic", "print", "println", "real", "recover"}
+
+// NameGen generates fresh identifiers that collide neither with go keywords,
+// nor with predeclared identifiers, nor with the names reserved.
+type NameGen struct {
+	taken map[string]struct{}
+}
+
+// NewNameGen creates a name generator avoiding the names. The scope package
+// creates one avoiding the names visible at a node of a tree, see
+// scope.NewNameGen.
+func NewNameGen(names ...string) *NameGen {
+	var g = &NameGen{taken: make(map[string]struct{})}
+	for _, name := range predeclared {
+		g.taken[name] = struct{}{}
+	}
+	for _, name := range names {
+		g.taken[name] = struct{}{}
+	}
+	return g
+}
+
+// Reserve marks the name as used, so it is never generated.
+func (g *NameGen) Reserve(name string) {
+	g.taken[name] = struct{}{}
+}
+
+// Fresh returns the base name, or the base name followed by the smallest
+// number that makes it unused. The returned name is reserved. An invalid base
+// name is replaced by v.
+func (g *NameGen) Fresh(base string) string {
+	if ValidIdent(base) != nil {
+		base = "v"
+	}
+	var name = base
+	for i := 1; ; i++ {
+		if _, ok := g.taken[name]; !ok && !token.Lookup(name).IsKeyword() {
+			g.taken[name] = struct{}{}
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
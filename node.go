@@ -0,0 +1,105 @@
+package mapast
+
+// kinds lists every kind of node. The position of a node in this list is its
+// kind number.
+var kinds = [][]byte{RootMatter, FileMatter, PackageDef, ImportStmt, ImportsDef,
+	TypedIdent, RootOfType, TypDefStmt, StructType, BranchStmt, GoDferStmt,
+	ReturnStmt, IncDecStmt, VarDefStmt, LblGotoCnt, IfceTypExp, CommentRow,
+	GenericExp, Expression, BlocOfCode, ToplevFunc, AssignStmt, ClosureExp,
+	IfceMethod, Placeholder}
+
+// kindnames holds the names of kinds, in the same order as kinds.
+var kindnames = []string{"RootMatter", "FileMatter", "PackageDef", "ImportStmt",
+	"ImportsDef", "TypedIdent", "RootOfType", "TypDefStmt", "StructType",
+	"BranchStmt", "GoDferStmt", "ReturnStmt", "IncDecStmt", "VarDefStmt",
+	"LblGotoCnt", "IfceTypExp", "CommentRow", "GenericExp", "Expression",
+	"BlocOfCode", "ToplevFunc", "AssignStmt", "ClosureExp", "IfceMethod",
+	"Placeholder"}
+
+// kindof returns the kind number of node, or -1 if node is a string or nil.
+func kindof(node []byte) int {
+	if Which(node) == nil {
+		return -1
+	}
+	return int(node[1])
+}
+
+// params returns the variant and the element count of a node.
+func params(node []byte) (variant uint64, count uint64) {
+	return uint64(Variant(node)), Count(node)
+}
+
+// makenode is the inverse of kindof and params. It returns nil if the kind
+// number or the variant are out of range.
+func makenode(kind int, variant uint64, count uint64) []byte {
+	if kind < 0 || kind >= len(kinds) || variant > 0xff {
+		return nil
+	}
+	return header(byte(kind), byte(variant), count)
+}
+
+// kindnumber returns the kind number of the named kind, or -1.
+func kindnumber(name string) int {
+	for i := range kindnames {
+		if kindnames[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// variantnames holds the names of variants of those kinds that have them. The
+// full name of a variant is the kind name followed by the variant name.
+var variantnames = map[string][]string{
+	"PackageDef": {"Normal", "Separate"},
+	"TypedIdent": {"Normal", "Equals", "Ellipsis", "Tagged"},
+	"TypDefStmt": {"Normal", "Alias"},
+	"BranchStmt": {"Semi", "Break", "Continue", "Fallthrough", "Goto"},
+	"GoDferStmt": {"Go", "Defer"},
+	"IncDecStmt": {"PlusPlus", "MinusMinus"},
+	"VarDefStmt": {"Var", "Const"},
+	"LblGotoCnt": {"Label", "Goto", "Continue", "Break"},
+	"CommentRow": {"Ender", "Normal", "Separate"},
+	"BlocOfCode": {"Plain", "If", "IfElse", "Switch", "For", "ForRange",
+		"TypeSwitch", "Select", "Case", "Default", "None", "Communicate",
+		"CommunicateDefault"},
+	"Expression": {"Brackets", "OrOr", "AndAnd", "Equal", "NotEq", "LessThan",
+		"LessEq", "GrtEq", "GrtThan", "Plus", "Minus", "Or", "Xor", "Mul", "Div",
+		"Mod", "And", "AndNot", "LSh", "RSh", "Not", "Dot", "Slice", "Composite",
+		"Call", "Arrow", "ArrayType", "SliceType", "KeyVal", "Type",
+		"CallDotDotDot", "Composed", "Index", "Map", "Identifier", "Chan",
+		"InChan", "OutChan", "Tilde"},
+	"AssignStmt": {"Equal", "ColonEq", "AndNot", "Add", "Sub", "Mul", "Quo",
+		"Rem", "And", "Or", "Xor", "Shl", "Shr", "IotaIsLast", "TypeIsLast",
+		"MoreEqual", "MoreColonEq", "MoreEqualRange", "MoreColonEqRange"},
+}
+
+// nodename returns the name of the node kind followed by the name of its
+// variant, such as BlocOfCodeIf. It returns an empty string for strings.
+func nodename(node []byte) string {
+	var kind = kindof(node)
+	if kind < 0 {
+		return ""
+	}
+	var name = kindnames[kind]
+	if names, ok := variantnames[name]; ok && int(Variant(node)) < len(names) {
+		return name + names[Variant(node)]
+	}
+	return name
+}
+
+// KindName returns the name of the kind of the node, such as Expression or
+// BlocOfCode. It returns an empty string if the node is a string or nil.
+func KindName(node []byte) string {
+	if kind := kindof(node); kind >= 0 {
+		return kindnames[kind]
+	}
+	return ""
+}
+
+// VariantName returns the name of the variant of the node without the kind
+// name, such as Call for an ExpressionCall node. It returns an empty string if
+// the node kind has no named variants.
+func VariantName(node []byte) string {
+	return nodename(node)[len(KindName(node)):]
+}
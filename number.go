@@ -0,0 +1,125 @@
+package mapast
+
+import (
+	"errors"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// isnumber reports whether the node is a string holding a numeric literal,
+// which starts with a digit or with a dot followed by a digit.
+func isnumber(node []byte) bool {
+	if Which(node) != nil || len(node) == 0 {
+		return false
+	}
+	if node[0] == '.' && len(node) > 1 {
+		return '0' <= node[1] && node[1] <= '9'
+	}
+	return '0' <= node[0] && node[0] <= '9'
+}
+
+// ValidNumber checks that the literal is a valid go integer, floating point or
+// imaginary literal, including the 0x, 0o and 0b prefixes, the digit
+// separators and the hexadecimal exponents.
+func ValidNumber(lit string) error {
+	var s scanner.Scanner
+	var failed bool
+	var file = token.NewFileSet().AddFile("", -1, len(lit))
+	s.Init(file, []byte(lit), func(token.Position, string) { failed = true }, 0)
+	var _, tok, text = s.Scan()
+	if tok != token.INT && tok != token.FLOAT && tok != token.IMAG || text != lit {
+		failed = true
+	}
+	for _, tok, text = s.Scan(); tok != token.EOF; _, tok, text = s.Scan() {
+		if tok != token.SEMICOLON || text != "\n" {
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("mapast: " + strconv.Quote(lit) + " is not a valid number")
+	}
+	return nil
+}
+
+// NormalNumber returns the valid numeric literal in the canonical form gofmt
+// uses. The prefixes and the exponent letters are lowercase, hexadecimal
+// digits are kept as they are, and the leading zeros of a decimal imaginary
+// literal without a fraction are dropped.
+func NormalNumber(lit string) (string, error) {
+	if err := ValidNumber(lit); err != nil {
+		return "", err
+	}
+	if len(lit) < 2 {
+		return lit, nil
+	}
+	switch lit[:2] {
+	case "0X", "0x":
+		lit = "0x" + lit[2:]
+		if i := strings.LastIndexByte(lit, 'P'); i >= 0 {
+			lit = lit[:i] + "p" + lit[i+1:]
+		}
+
+	case "0O", "0o":
+		lit = "0o" + lit[2:]
+
+	case "0B", "0b":
+		lit = "0b" + lit[2:]
+
+	default:
+		if i := strings.LastIndexByte(lit, 'E'); i >= 0 {
+			lit = lit[:i] + "e" + lit[i+1:]
+		}
+		if lit[len(lit)-1] == 'i' && strings.IndexAny(lit, ".e") < 0 {
+			lit = strings.TrimLeft(lit, "0_")
+			if lit == "i" {
+				lit = "0i"
+			}
+		}
+	}
+	return lit, nil
+}
+
+// CheckNumbers validates the numeric literals under the iterator position. An
+// invalid literal is reported by a diagnostic at its key.
+func CheckNumbers(ast map[uint64][]byte, iterator uint64) (diags []*Diagnostic) {
+	Walk(ast, iterator, func(key, parent uint64, node []byte) bool {
+		if isnumber(node) {
+			if err := ValidNumber(string(node)); err != nil {
+				diags = append(diags, &Diagnostic{Key: key, Message: err.Error()})
+			}
+		}
+		return true
+	})
+	return diags
+}
+
+// NormalizeNumbers rewrites the valid numeric literals under the iterator
+// position into their canonical form. It returns the number of literals
+// changed. Invalid literals are left alone.
+func NormalizeNumbers(ast map[uint64][]byte, iterator uint64) (n int) {
+	Walk(ast, iterator, func(key, parent uint64, node []byte) bool {
+		if !isnumber(node) {
+			return true
+		}
+		if lit, err := NormalNumber(string(node)); err == nil && lit != string(node) {
+			ast[key] = []byte(lit)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// CodeNumbers generates go source code from an abstract syntax tree like Code
+// does, but prints the valid numeric literals in their canonical form. The
+// tree is not modified.
+func CodeNumbers(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64) {
+	var normal = make(map[uint64][]byte, len(ast))
+	for key, node := range ast {
+		normal[key] = node
+	}
+	NormalizeNumbers(normal, iterator)
+	Code(print, normal, iterator, parent)
+}
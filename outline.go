@@ -0,0 +1,196 @@
+package mapast
+
+// SymbolKind tells what a Symbol of an Outline declares.
+type SymbolKind byte
+
+// SymbolType is a type or an alias declaration.
+const SymbolType SymbolKind = 0
+
+// SymbolFunc is a function declaration.
+const SymbolFunc SymbolKind = 1
+
+// SymbolMethod is a method declaration or a method of an interface type.
+const SymbolMethod SymbolKind = 2
+
+// SymbolConst is a constant of a const declaration.
+const SymbolConst SymbolKind = 3
+
+// SymbolVar is a variable of a var declaration.
+const SymbolVar SymbolKind = 4
+
+// SymbolField is a field of a struct type, embedded fields included.
+const SymbolField SymbolKind = 5
+
+var symbolkindnames = [...]string{"type", "func", "method", "const", "var", "field"}
+
+// String returns the keyword like name of the kind, such as func or field.
+func (k SymbolKind) String() string {
+	if int(k) < len(symbolkindnames) {
+		return symbolkindnames[k]
+	}
+	return "invalid"
+}
+
+// Symbol is a named declaration of an Outline.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	// Key is the key of the string declaring the name, and Decl the key of
+	// the declaring node: the TypDefStmt, the ToplevFunc, the AssignStmt row
+	// of a const or var declaration, the TypedIdent of a field or the
+	// IfceMethod of an interface method.
+	Key  uint64
+	Decl uint64
+	// Receiver is the receiver type of a method declaration, such as *T.
+	Receiver string
+	// Children are the fields of a struct type, the methods of an interface
+	// type, and the methods declared in the file for a type.
+	Children []Symbol
+}
+
+// receivername returns the name of the base type of the receiver or embedded
+// field type at the key, such as T for *T, T[K] or pkg.T, and the key of its
+// string.
+func receivername(ast map[uint64][]byte, key uint64) (string, uint64) {
+	for {
+		var node = ast[key]
+		switch {
+		case Which(node) == nil:
+			return string(node), key
+
+		case Is(node, RootOfType), isexpr(node, ExpressionMul, 1), isexpr(node, ExpressionBrackets, 1),
+			Is(node, Expression) && Variant(node) == ExpressionIndex:
+			key = O(key)
+
+		case isexpr(node, ExpressionDot, 2):
+			key = O(key) + 1
+
+		default:
+			return "", 0
+		}
+	}
+}
+
+// assigned returns the keys of the names declared by the AssignStmt row of
+// a const or var declaration.
+func assigned(ast map[uint64][]byte, row uint64) (names []uint64) {
+	var children = ChildKeys(ast, row)
+	var n = len(children) / 2
+	switch Variant(ast[row]) {
+	case AssignStmtIotaIsLast:
+		n = len(children)
+
+	case AssignStmtMoreEqual:
+		n = len(children) - 1
+	}
+	for i, child := range children {
+		if Is(ast[child], RootOfType) {
+			n = i
+			break
+		}
+	}
+	for _, child := range children[:n] {
+		if isexpr(ast[child], ExpressionIdentifier, 1) {
+			child = O(child)
+		}
+		if Which(ast[child]) == nil {
+			names = append(names, child)
+		}
+	}
+	return names
+}
+
+// members returns the fields of the struct type or the methods of the
+// interface type at the key. The embedded interfaces and the type sets of an
+// interface are not members.
+func members(ast map[uint64][]byte, key uint64) (symbols []Symbol) {
+	if Is(ast[key], RootOfType) {
+		key = O(key)
+	}
+	for _, child := range ChildKeys(ast, key) {
+		switch {
+		case Is(ast[key], StructType) && IsEmbedded(ast, child):
+			var name, at = receivername(ast, O(child))
+			symbols = append(symbols, Symbol{Name: name, Kind: SymbolField, Key: at, Decl: child})
+
+		case Is(ast[key], StructType) && Is(ast[child], TypedIdent):
+			for _, name := range FieldNames(ast, child) {
+				symbols = append(symbols, Symbol{Name: string(ast[name]), Kind: SymbolField, Key: name, Decl: child})
+			}
+
+		case Is(ast[key], IfceTypExp) && Is(ast[child], IfceMethod):
+			var name = O(O(child))
+			symbols = append(symbols, Symbol{Name: string(ast[name]), Kind: SymbolMethod, Key: name, Decl: child})
+		}
+	}
+	return symbols
+}
+
+// Outline returns the symbols declared at the top level of the file at the
+// key, in the order of the code: the types with their fields and methods, the
+// functions, the constants and the variables. The methods are children of
+// their receiver type if the file declares it, the others are listed after
+// the top level declarations. The blank identifiers are left out.
+func Outline(ast map[uint64][]byte, file uint64) (symbols []Symbol) {
+	var types = make(map[string]int)
+	var methods []Symbol
+	var receivers []string
+	for _, key := range ChildKeys(ast, file) {
+		var node = ast[key]
+		var children = ChildKeys(ast, key)
+		switch {
+		case Is(node, TypDefStmt) && len(children) > 0:
+			var s = Symbol{Name: string(ast[children[0]]), Kind: SymbolType, Key: children[0], Decl: key}
+			if len(children) > 1 && Variant(node) == TypDefStmtNormal {
+				s.Children = members(ast, children[1])
+			}
+			types[s.Name] = len(symbols)
+			symbols = append(symbols, s)
+
+		case Is(node, VarDefStmt):
+			var kind = SymbolVar
+			if Variant(node) == VarDefStmtConst {
+				kind = SymbolConst
+			}
+			for _, row := range children {
+				if !Is(ast[row], AssignStmt) {
+					continue
+				}
+				for _, name := range assigned(ast, row) {
+					if string(ast[name]) != "_" {
+						symbols = append(symbols, Symbol{Name: string(ast[name]), Kind: kind, Key: name, Decl: row})
+					}
+				}
+			}
+
+		case Is(node, ToplevFunc) && len(children) > 0 && Variant(node) == 0:
+			symbols = append(symbols, Symbol{Name: string(ast[children[0]]), Kind: SymbolFunc, Key: children[0],
+				Decl: key})
+
+		case Is(node, ToplevFunc) && len(children) > 1:
+			var typ = children[1]
+			for _, child := range ChildKeys(ast, children[1]) {
+				if Is(ast[child], RootOfType) {
+					typ = child
+				}
+			}
+			var name, _ = receivername(ast, typ)
+			var s = Symbol{Name: string(ast[children[0]]), Kind: SymbolMethod, Key: children[0], Decl: key}
+			if Which(ast[O(typ)]) == nil {
+				s.Receiver = string(ast[O(typ)])
+			} else if typ != children[1] {
+				s.Receiver = SprintNode(ast, O(typ))
+			}
+			methods = append(methods, s)
+			receivers = append(receivers, name)
+		}
+	}
+	for i, s := range methods {
+		if t, ok := types[receivers[i]]; ok {
+			symbols[t].Children = append(symbols[t].Children, s)
+		} else {
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,150 @@
+package mapast
+
+// Overlay layers edits over a base tree without copying or changing it. Reads
+// see the edits first and fall back to the base, so trying a transformation
+// on a large tree costs only the size of the change. The base must not be
+// changed while the overlay is in use.
+type Overlay struct {
+	base    map[uint64][]byte
+	changed map[uint64][]byte
+	deleted map[uint64]bool
+}
+
+// NewOverlay returns an overlay having no edits over the base tree.
+func NewOverlay(base map[uint64][]byte) *Overlay {
+	return &Overlay{base: base, changed: make(map[uint64][]byte), deleted: make(map[uint64]bool)}
+}
+
+// Get returns the node or the string at the key, and whether the key is in
+// the tree.
+func (o *Overlay) Get(key uint64) ([]byte, bool) {
+	if node, ok := o.changed[key]; ok {
+		return node, true
+	}
+	if o.deleted[key] {
+		return nil, false
+	}
+	var node, ok = o.base[key]
+	return node, ok
+}
+
+// Poke reports whether the key is in the tree, like the Poke function.
+func (o *Overlay) Poke(key uint64) bool {
+	var _, ok = o.Get(key)
+	return ok
+}
+
+// Set stores the node or the string at the key.
+func (o *Overlay) Set(key uint64, node []byte) {
+	o.changed[key] = node
+	delete(o.deleted, key)
+}
+
+// Delete deletes the key from the tree. It does not delete the descendants.
+func (o *Overlay) Delete(key uint64) {
+	delete(o.changed, key)
+	if _, ok := o.base[key]; ok {
+		o.deleted[key] = true
+	}
+}
+
+func (o *Overlay) walk(key uint64, parent uint64, fn func(key, parent uint64, node []byte) bool) {
+	var node, _ = o.Get(key)
+	if fn(key, parent, node) {
+		for i := uint64(0); o.Poke(O(key) + i); i++ {
+			o.walk(O(key)+i, key, fn)
+		}
+	}
+}
+
+// Walk traverses the subtree at the root like the Walk function.
+func (o *Overlay) Walk(root uint64, fn func(key, parent uint64, node []byte) bool) {
+	if o.Poke(root) {
+		o.walk(root, root, fn)
+	}
+}
+
+// Subtree returns a new tree holding the subtree at the key, at the same
+// keys. It can be passed to the functions taking a tree, such as Code or
+// DumpJSON, with the key as their root.
+func (o *Overlay) Subtree(key uint64) map[uint64][]byte {
+	var ast = make(map[uint64][]byte)
+	o.Walk(key, func(key, parent uint64, node []byte) bool {
+		ast[key] = node
+		return true
+	})
+	return ast
+}
+
+// Code prints the subtree at the iterator position like the Code function,
+// reading the nodes through the overlay.
+func (o *Overlay) Code(print func(string), iterator uint64, parent uint64) {
+	codehooks(print, nil, nil, o, iterator, parent)
+}
+
+// Edit calls fn with the subtree at the key, as returned by Subtree, and
+// records the changes fn makes to it into the overlay. Edit makes the editing
+// functions, such as InsertChild or RemoveChild, available to the overlay:
+//
+//	o.Edit(parent, func(ast map[uint64][]byte) {
+//		RemoveChild(ast, parent, 0)
+//	})
+//
+// The subtree is copied once per call, so the key is best the nearest node
+// enclosing the edit, such as the parent of the children inserted or removed.
+// The changes are found by reading the overlay, which fn does not change.
+func (o *Overlay) Edit(key uint64, fn func(ast map[uint64][]byte)) {
+	var ast = o.Subtree(key)
+	var keys = make([]uint64, 0, len(ast))
+	for k := range ast {
+		keys = append(keys, k)
+	}
+	fn(ast)
+	for _, k := range keys {
+		if _, ok := ast[k]; !ok {
+			o.Delete(k)
+		}
+	}
+	for k, node := range ast {
+		if old, ok := o.Get(k); !ok || string(old) != string(node) || (old == nil) != (node == nil) {
+			o.Set(k, node)
+		}
+	}
+}
+
+// Changes returns the number of keys changed or deleted by the overlay.
+func (o *Overlay) Changes() int {
+	return len(o.changed) + len(o.deleted)
+}
+
+// Discard drops all the edits of the overlay.
+func (o *Overlay) Discard() {
+	o.changed = make(map[uint64][]byte)
+	o.deleted = make(map[uint64]bool)
+}
+
+// Flatten returns a new tree holding the base with the edits applied. The
+// base is left unchanged.
+func (o *Overlay) Flatten() map[uint64][]byte {
+	var ast = make(map[uint64][]byte, len(o.base)+len(o.changed))
+	for key, node := range o.base {
+		if !o.deleted[key] {
+			ast[key] = node
+		}
+	}
+	for key, node := range o.changed {
+		ast[key] = node
+	}
+	return ast
+}
+
+// Commit applies the edits to the base and drops them from the overlay.
+func (o *Overlay) Commit() {
+	for key := range o.deleted {
+		delete(o.base, key)
+	}
+	for key, node := range o.changed {
+		o.base[key] = node
+	}
+	o.Discard()
+}
@@ -0,0 +1,192 @@
+package mapast
+
+// precedence returns the precedence of the binary operator of the node, from
+// one for || to five for the multiplicative operators, or zero if the node is
+// not a binary operation.
+func precedence(node []byte) int {
+	if !Is(node, Expression) || Count(node) < 2 {
+		return 0
+	}
+	switch Variant(node) {
+	case ExpressionOrOr:
+		return 1
+
+	case ExpressionAndAnd:
+		return 2
+
+	case ExpressionEqual, ExpressionNotEq, ExpressionLessThan, ExpressionLessEq, ExpressionGrtEq,
+		ExpressionGrtThan:
+		return 3
+
+	case ExpressionPlus, ExpressionMinus, ExpressionOr, ExpressionXor:
+		return 4
+
+	case ExpressionMul, ExpressionDiv, ExpressionMod, ExpressionAnd, ExpressionAndNot, ExpressionLSh,
+		ExpressionRSh:
+		return 5
+	}
+	return 0
+}
+
+// isunary reports whether the node is an unary operation.
+func isunary(node []byte) bool {
+	if !Is(node, Expression) || Count(node) != 1 {
+		return false
+	}
+	switch Variant(node) {
+	case ExpressionPlus, ExpressionMinus, ExpressionXor, ExpressionMul, ExpressionAnd, ExpressionNot,
+		ExpressionArrow, ExpressionTilde:
+		return true
+	}
+	return false
+}
+
+// isoperand reports whether the child at the index of the node is the operand
+// of a primary expression, such as the function of a call or the operand of a
+// selector.
+func isoperand(node []byte, index uint64) bool {
+	if !Is(node, Expression) || index != 0 {
+		return false
+	}
+	switch Variant(node) {
+	case ExpressionCall, ExpressionCallDotDotDot, ExpressionIndex, ExpressionSlice:
+		return true
+
+	case ExpressionDot, ExpressionType:
+		return Count(node) == 2
+	}
+	return false
+}
+
+// isfunctype reports whether the node at the key is a function type, which is
+// a ClosureExp without a body.
+func isfunctype(ast map[uint64][]byte, key uint64) bool {
+	if !Is(ast[key], ClosureExp) {
+		return false
+	}
+	var last = childcount(ast, key)
+	return last == 0 || !Is(ast[O(key)+last-1], BlocOfCode)
+}
+
+// mustwrap reports whether the child at the key, being at the index of the
+// node, needs round brackets to be printed with the meaning it has in the
+// tree. Composite literals are wrapped when they are operands of binary
+// operators, since the conditions of statements require it.
+func mustwrap(ast map[uint64][]byte, node []byte, index uint64, key uint64) bool {
+	var child = ast[key]
+	var inner = precedence(child)
+	switch {
+	case precedence(node) > 0:
+		return inner > 0 && (inner < precedence(node) || inner == precedence(node) && index > 0) ||
+			Is(child, Expression) && Variant(child) == ExpressionComposite
+
+	case isunary(node):
+		if inner > 0 {
+			return true
+		}
+		// The operators would print as a single token, such as -- or &&.
+		var v = Variant(node)
+		return isunary(child) && Variant(child) == v &&
+			(v == ExpressionPlus || v == ExpressionMinus || v == ExpressionAnd)
+
+	case isoperand(node, index):
+		// A channel or function type would take the operand as its element
+		// or result type.
+		return inner > 0 || isunary(child) || isfunctype(ast, key) || Is(child, Expression) &&
+			(Variant(child) == ExpressionChan || Variant(child) == ExpressionInChan ||
+				Variant(child) == ExpressionOutChan)
+	}
+	return false
+}
+
+// isargument reports whether the child at the index of the node is an
+// argument of a call or an index of an index or slice expression, which are
+// printed between brackets already.
+func isargument(node []byte, index uint64) bool {
+	if !Is(node, Expression) || index == 0 {
+		return false
+	}
+	switch Variant(node) {
+	case ExpressionCall, ExpressionCallDotDotDot, ExpressionIndex, ExpressionSlice:
+		return true
+	}
+	return false
+}
+
+// canunwrap reports whether the round brackets around the child at the key,
+// being at the index of the node, can be removed without changing the meaning
+// of the code. Brackets outside of expressions are kept, since the ones around
+// the conditions of statements keep composite literals apart from the blocks.
+func canunwrap(ast map[uint64][]byte, node []byte, index uint64, key uint64) bool {
+	var child = ast[key]
+	if !Is(node, Expression) || mustwrap(ast, node, index, key) {
+		return false
+	}
+	if Which(child) == nil || isexpr(node, ExpressionBrackets, 1) {
+		return true
+	}
+	if isargument(node, index) {
+		return true
+	}
+	if precedence(node) == 0 && !isunary(node) && !isoperand(node, index) {
+		return false
+	}
+	return precedence(child) > 0 || isunary(child) || Is(child, Expression) && isprimaryvariant(child)
+}
+
+// isprimaryvariant reports whether the expression node is a primary
+// expression, see isprimary.
+func isprimaryvariant(node []byte) bool {
+	switch Variant(node) {
+	case ExpressionIdentifier, ExpressionBrackets, ExpressionCall, ExpressionCallDotDotDot,
+		ExpressionIndex, ExpressionSlice, ExpressionType:
+		return true
+
+	case ExpressionDot:
+		return Count(node) == 2
+	}
+	return false
+}
+
+// Parenthesize rewrites the round brackets of the expressions under the
+// iterator position to the minimal ones required by the operator precedence:
+// it removes the ExpressionBrackets nodes not needed and inserts the missing
+// ones, so that the code printed has the meaning of the tree. Parent is the
+// key of the parent of the iterator node. Parenthesize returns the number of
+// brackets removed and inserted.
+func Parenthesize(ast map[uint64][]byte, iterator uint64, parent uint64) (n int) {
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		n += Parenthesize(ast, O(iterator)+i, iterator)
+	}
+	var node = ast[iterator]
+	if !Is(node, Expression) {
+		return n
+	}
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		var key = O(iterator) + i
+		for isexpr(ast[key], ExpressionBrackets, 1) && canunwrap(ast, node, i, O(key)) {
+			replace(ast, key, iterator, clone(ast, O(key)))
+			n++
+		}
+		if mustwrap(ast, node, i, key) {
+			var s = clone(ast, key)
+			cut(ast, key)
+			paste(ast, key, subtree{node: ExpressionNode(ExpressionBrackets, 1), children: []subtree{s}})
+			n++
+		}
+	}
+	return n
+}
+
+// CodeParenthesized generates go source code from an abstract syntax tree
+// like Code does, printing the expressions with the brackets Parenthesize
+// would leave. The tree is not changed.
+func CodeParenthesized(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64) {
+	var copied = make(map[uint64][]byte)
+	CopySubtree(ast, iterator, copied, iterator)
+	if node, ok := ast[parent]; ok && parent != iterator {
+		copied[parent] = node
+	}
+	Parenthesize(copied, iterator, parent)
+	Code(print, copied, iterator, parent)
+}
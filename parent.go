@@ -0,0 +1,40 @@
+package mapast
+
+// BuildParentIndex maps the key of every node under the iterator position to
+// the key of its parent. The iterator node itself is not part of the index.
+// The index must be rebuilt after the tree is edited.
+func BuildParentIndex(ast map[uint64][]byte, iterator uint64) map[uint64]uint64 {
+	var index = make(map[uint64]uint64)
+	var build func(uint64)
+	build = func(iterator uint64) {
+		for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+			index[O(iterator)+i] = iterator
+			build(O(iterator) + i)
+		}
+	}
+	build(iterator)
+	return index
+}
+
+// Parent returns the key of the parent of the node at the key. It returns
+// false if the key is not in the index.
+func Parent(index map[uint64]uint64, key uint64) (uint64, bool) {
+	parent, ok := index[key]
+	return parent, ok
+}
+
+// Enclosing returns the key of the nearest ancestor of the node at the key
+// whose kind is the given kind, such as ToplevFunc or BlocOfCode. It returns
+// false if there is no such ancestor.
+func Enclosing(ast map[uint64][]byte, index map[uint64]uint64, key uint64, kind []byte) (uint64, bool) {
+	for {
+		parent, ok := index[key]
+		if !ok {
+			return 0, false
+		}
+		if Is(ast[parent], kind) {
+			return parent, true
+		}
+		key = parent
+	}
+}
@@ -0,0 +1,238 @@
+package mapast
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+// PatchOp is a single operation of a Patch. Unlike an Edit, it addresses
+// nodes by their path, so it applies to any tree of the same shape.
+type PatchOp struct {
+	Op EditOp `json:"op"`
+	// Path holds the child indexes leading from the root at key zero to the
+	// node replaced or removed, or to the parent of the inserted node.
+	Path  []uint64 `json:"path"`
+	Index uint64   `json:"index,omitempty"`
+	// Node is the replacing or inserted subtree, encoded by MarshalJSON.
+	Node json.RawMessage `json:"node,omitempty"`
+}
+
+// Patch is a list of operations turning a tree into another, applied in
+// order. It is serializable with encoding/json, so a transformation recorded
+// once can be replayed on other files or machines.
+type Patch []PatchOp
+
+// differ holds the state of MakePatch. The work tree is a copy of the before
+// tree kept up to date with the operations found, so the paths of later
+// operations are correct.
+type differ struct {
+	work, after map[uint64][]byte
+	patch       Patch
+}
+
+// subtreeJSON returns the subtree at the key encoded by MarshalJSON.
+func subtreeJSON(ast map[uint64][]byte, key uint64) json.RawMessage {
+	var data, _ = json.Marshal(tojson(ast, key))
+	return data
+}
+
+// at returns a copy of the path extended by the index.
+func at(path []uint64, index uint64) []uint64 {
+	return append(append([]uint64{}, path...), index)
+}
+
+// lcsruns aligns two sequences of children by the longest common subsequence
+// of their fingerprints, and calls fn for every run of children that differ,
+// from i to i2 in the first sequence and from j to j2 in the second one. The
+// children between the runs are equal, so j is also the index of the run once
+// the runs before it are turned into the second sequence.
+func lcsruns(ws, as [][sha256.Size]byte, fn func(i, i2, j, j2 int)) {
+	var lcs = make([][]int, len(ws)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(as)+1)
+	}
+	for i := len(ws) - 1; i >= 0; i-- {
+		for j := len(as) - 1; j >= 0; j-- {
+			if ws[i] == as[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var i, j = 0, 0
+	for i < len(ws) || j < len(as) {
+		if i < len(ws) && j < len(as) && ws[i] == as[j] {
+			i, j = i+1, j+1
+			continue
+		}
+		var i2, j2 = i, j
+		for i2 < len(ws) || j2 < len(as) {
+			if i2 < len(ws) && j2 < len(as) && ws[i2] == as[j2] {
+				break
+			}
+			if j2 < len(as) && (i2 == len(ws) || lcs[i2][j2+1] >= lcs[i2+1][j2]) {
+				j2++
+			} else {
+				i2++
+			}
+		}
+		fn(i, i2, j, j2)
+		i, j = i2, j2
+	}
+}
+
+func (d *differ) replace(wkey, akey uint64, path []uint64) {
+	d.patch = append(d.patch, PatchOp{Op: EditReplace, Path: path, Node: subtreeJSON(d.after, akey)})
+	CopySubtree(d.after, akey, d.work, wkey)
+}
+
+func (d *differ) diff(wkey, akey uint64, path []uint64) {
+	if fingerprint(d.work, wkey) == fingerprint(d.after, akey) {
+		return
+	}
+	var wn, an = d.work[wkey], d.after[akey]
+	if Which(wn) == nil || Which(an) == nil || kindof(wn) != kindof(an) || Variant(wn) != Variant(an) {
+		d.replace(wkey, akey, path)
+		return
+	}
+	var mark = len(d.patch)
+	var wc, ac = ChildKeys(d.work, wkey), ChildKeys(d.after, akey)
+	var ws, as = make([][sha256.Size]byte, len(wc)), make([][sha256.Size]byte, len(ac))
+	for i := range wc {
+		ws[i] = fingerprint(d.work, wc[i])
+	}
+	for j := range ac {
+		as[j] = fingerprint(d.after, ac[j])
+	}
+	lcsruns(ws, as, func(i, i2, j, j2 int) {
+		for ; i < i2 && j < j2; i, j = i+1, j+1 {
+			d.diff(O(wkey)+uint64(j), ac[j], at(path, uint64(j)))
+		}
+		for ; i < i2; i++ {
+			d.patch = append(d.patch, PatchOp{Op: EditRemove, Path: at(path, uint64(j))})
+			RemoveChild(d.work, wkey, uint64(j))
+		}
+		for ; j < j2; j++ {
+			d.patch = append(d.patch, PatchOp{Op: EditInsert, Path: path, Index: uint64(j),
+				Node: subtreeJSON(d.after, ac[j])})
+			var node = make(map[uint64][]byte)
+			CopySubtree(d.after, ac[j], node, 0)
+			InsertChild(d.work, wkey, uint64(j), node)
+		}
+	})
+	if string(d.work[wkey]) != string(an) {
+		d.patch = d.patch[:mark]
+		d.replace(wkey, akey, path)
+	}
+}
+
+// MakePatch returns the patch turning the tree rooted at key zero of before
+// into the one of after. Children left unchanged are matched by their
+// content, and the changed ones are patched as deep in the tree as possible.
+func MakePatch(before, after map[uint64][]byte) Patch {
+	var d = differ{work: make(map[uint64][]byte), after: after, patch: Patch{}}
+	CopySubtree(before, 0, d.work, 0)
+	d.diff(0, 0, nil)
+	return d.patch
+}
+
+// ApplyPatch applies the operations of the patch in order to the tree rooted
+// at key zero. It stops at the first operation whose path does not lead to a
+// node or whose node cannot be decoded, leaving the operations before it
+// applied.
+func ApplyPatch(ast map[uint64][]byte, patch Patch) error {
+	for _, op := range patch {
+		var key, parent = uint64(0), uint64(0)
+		for _, index := range op.Path {
+			if !Poke(ast, key) || Which(ast[key]) == nil {
+				return errors.New("mapast: patch path does not lead to a node")
+			}
+			key, parent = O(key)+index, key
+		}
+		if !Poke(ast, key) {
+			return errors.New("mapast: patch path does not lead to a node")
+		}
+		var node = make(map[uint64][]byte)
+		if op.Op != EditRemove {
+			if err := UnmarshalJSON(node, 0, op.Node); err != nil {
+				return err
+			}
+		}
+		switch op.Op {
+		case EditReplace:
+			CopySubtree(node, 0, ast, key)
+
+		case EditRemove:
+			if len(op.Path) == 0 {
+				return errors.New("mapast: patch removes the root")
+			}
+			RemoveChild(ast, parent, key-O(parent))
+
+		case EditInsert:
+			if Which(ast[key]) == nil {
+				return errors.New("mapast: patch inserts into a string")
+			}
+			InsertChild(ast, key, op.Index, node)
+
+		default:
+			return errors.New("mapast: invalid patch operation")
+		}
+	}
+	return nil
+}
+
+// Change is a difference between two trees found by Diff. Before and After
+// are the keys of the node replaced in the before tree and of its replacement
+// in the after tree. The node removed is at Before, and After holds the key
+// of the parent it is removed from, as found in the after tree. The node
+// inserted is at After, and Before holds the key of the parent it is inserted
+// into, as found in the before tree.
+type Change struct {
+	Op     EditOp
+	Before uint64
+	After  uint64
+}
+
+// Diff returns the changes between the tree of before at the key broot and the
+// tree of after at the key aroot, ordered as in the trees. Like MakePatch it
+// matches the unchanged children by their content and reports the changed
+// ones as deep as possible, but it leaves both trees alone and addresses the
+// nodes by their keys, so the caller can find their positions or source.
+func Diff(before map[uint64][]byte, broot uint64, after map[uint64][]byte, aroot uint64) (changes []Change) {
+	var diff func(bkey, akey uint64)
+	diff = func(bkey, akey uint64) {
+		if fingerprint(before, bkey) == fingerprint(after, akey) {
+			return
+		}
+		var bn, an = before[bkey], after[akey]
+		if Which(bn) == nil || Which(an) == nil || string(bn) != string(an) {
+			changes = append(changes, Change{Op: EditReplace, Before: bkey, After: akey})
+			return
+		}
+		var bc, ac = ChildKeys(before, bkey), ChildKeys(after, akey)
+		var bs, as = make([][sha256.Size]byte, len(bc)), make([][sha256.Size]byte, len(ac))
+		for i := range bc {
+			bs[i] = fingerprint(before, bc[i])
+		}
+		for j := range ac {
+			as[j] = fingerprint(after, ac[j])
+		}
+		lcsruns(bs, as, func(i, i2, j, j2 int) {
+			for ; i < i2 && j < j2; i, j = i+1, j+1 {
+				diff(bc[i], ac[j])
+			}
+			for ; i < i2; i++ {
+				changes = append(changes, Change{Op: EditRemove, Before: bc[i], After: akey})
+			}
+			for ; j < j2; j++ {
+				changes = append(changes, Change{Op: EditInsert, Before: bkey, After: ac[j]})
+			}
+		})
+	}
+	diff(broot, aroot)
+	return changes
+}
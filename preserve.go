@@ -0,0 +1,110 @@
+package mapast
+
+import (
+	"errors"
+	"strconv"
+)
+
+// CommentGuard records the comments of a tree before a transformation, so the
+// comments the transformation drops by mistake can be found afterwards.
+// Comments are matched by their text, so comments moved to another place are
+// not reported.
+type CommentGuard struct {
+	texts   []string
+	deleted map[string]int
+}
+
+// CommentReport is the result of checking a tree against a CommentGuard.
+type CommentReport struct {
+	// Dropped are the texts of the comments that were lost without being
+	// deleted through the guard, in the order they appeared in the tree.
+	Dropped []string
+	// Deleted are the texts of the comments deleted through the guard.
+	Deleted []string
+	// Added are the texts of the comments that were not in the tree before.
+	Added []string
+}
+
+// Err returns an error if comments were dropped, nil otherwise.
+func (r *CommentReport) Err() error {
+	if len(r.Dropped) == 0 {
+		return nil
+	}
+	return errors.New("mapast: " + strconv.Itoa(len(r.Dropped)) + " comments dropped, first: " + r.Dropped[0])
+}
+
+// commenttexts returns the texts of the CommentRows of the subtree at the
+// root, in depth first order.
+func commenttexts(ast map[uint64][]byte, root uint64) (texts []string) {
+	Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		if Is(node, CommentRow) {
+			texts = append(texts, string(ast[O(key)]))
+			return false
+		}
+		return true
+	})
+	return texts
+}
+
+// GuardComments returns a guard holding the comments of the subtree at the
+// root.
+func GuardComments(ast map[uint64][]byte, root uint64) *CommentGuard {
+	return &CommentGuard{texts: commenttexts(ast, root), deleted: make(map[string]int)}
+}
+
+// Delete tells the guard that a comment having the text is deleted on purpose,
+// so its absence is not reported as dropped.
+func (g *CommentGuard) Delete(text string) {
+	g.deleted[text]++
+}
+
+// DeleteComments tells the guard that the comments of the subtree at the key
+// are deleted on purpose. It is called before the subtree is removed.
+func (g *CommentGuard) DeleteComments(ast map[uint64][]byte, key uint64) {
+	for _, text := range commenttexts(ast, key) {
+		g.Delete(text)
+	}
+}
+
+// Check compares the comments of the subtree at the root with the ones
+// recorded by the guard.
+func (g *CommentGuard) Check(ast map[uint64][]byte, root uint64) *CommentReport {
+	var report = &CommentReport{}
+	var now = make(map[string]int)
+	for _, text := range commenttexts(ast, root) {
+		now[text]++
+	}
+	var deleted = make(map[string]int, len(g.deleted))
+	for text, n := range g.deleted {
+		deleted[text] = n
+	}
+	for _, text := range g.texts {
+		switch {
+		case now[text] > 0:
+			now[text]--
+
+		case deleted[text] > 0:
+			deleted[text]--
+			report.Deleted = append(report.Deleted, text)
+
+		default:
+			report.Dropped = append(report.Dropped, text)
+		}
+	}
+	for _, text := range commenttexts(ast, root) {
+		if now[text] > 0 {
+			now[text]--
+			report.Added = append(report.Added, text)
+		}
+	}
+	return report
+}
+
+// PreserveComments calls fn with the tree and a guard holding the comments of
+// the subtree at the root, and returns the report of the comments after fn
+// returned. Fn deletes comments on purpose through the guard.
+func PreserveComments(ast map[uint64][]byte, root uint64, fn func(ast map[uint64][]byte, g *CommentGuard)) *CommentReport {
+	var g = GuardComments(ast, root)
+	fn(ast, g)
+	return g.Check(ast, root)
+}
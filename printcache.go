@@ -0,0 +1,66 @@
+package mapast
+
+import "crypto/sha256"
+
+// PrintCache memoizes the code of the top level declarations of files, keyed
+// by the fingerprint of their subtree. Printing an evolving tree repeatedly
+// through the same PrintCache renders again only the declarations that
+// changed since the previous print. A PrintCache must not be used by several
+// goroutines at once.
+type PrintCache struct {
+	texts map[[sha256.Size]byte][]string
+}
+
+// NewPrintCache creates an empty print cache.
+func NewPrintCache() *PrintCache {
+	return &PrintCache{texts: make(map[[sha256.Size]byte][]string)}
+}
+
+// toplevel maps the keys of the top level declarations under the iterator,
+// which can be a RootMatter or a FileMatter, to the keys of their files.
+func toplevel(ast map[uint64][]byte, iterator uint64) map[uint64]uint64 {
+	var keys = make(map[uint64]uint64)
+	var files = []uint64{iterator}
+	if Is(ast[iterator], RootMatter) {
+		files = ChildKeys(ast, iterator)
+	}
+	for _, file := range files {
+		if !Is(ast[file], FileMatter) {
+			continue
+		}
+		for _, key := range ChildKeys(ast, file) {
+			switch kindof(ast[key]) {
+			case kindImportStmt, kindImportsDef, kindVarDefStmt, kindTypDefStmt, kindToplevFunc:
+				keys[key] = file
+			}
+		}
+	}
+	return keys
+}
+
+// Code generates go source code like Code does, taking the code of the top
+// level declarations that did not change from the cache. The cache keeps only
+// the declarations printed by the latest call.
+func (c *PrintCache) Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64) {
+	var decls = toplevel(ast, iterator)
+	var texts = make(map[[sha256.Size]byte][]string)
+	code(print, func(key uint64) bool {
+		var file, ok = decls[key]
+		if !ok {
+			return false
+		}
+		var sum = fingerprint(ast, key)
+		var text, cached = c.texts[sum]
+		if !cached {
+			code(func(s string) {
+				text = append(text, s)
+			}, nil, ast, key, file)
+		}
+		texts[sum] = text
+		for _, s := range text {
+			print(s)
+		}
+		return true
+	}, ast, iterator, parent)
+	c.texts = texts
+}
@@ -0,0 +1,215 @@
+// Package query finds nodes of a mapast tree using selectors.
+//
+// A selector is a sequence of compound selectors separated by combinators,
+// similar to CSS. A compound selector is a kind name, optionally followed by
+// attribute tests in square brackets. The kind name is a mapast node kind such
+// as ToplevFunc or Expression, a kind with its variant such as BlocOfCodeIf,
+// the word string matching string leaves, or * matching anything. Func is an
+// alias of ToplevFunc. The attribute tests are:
+//
+//	[kind=Call]     the variant name of the node is Call
+//	[text=foo]      the string leaf is foo
+//	[name=foo]      the first child of the node is the string foo
+//	[children=2]    the node has exactly two children
+//
+// A space between compound selectors matches any descendant, while > matches
+// a direct child. For example Func > BlocOfCode Expression[kind=Call] matches
+// all calls within function bodies.
+package query
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+	"strconv"
+	"strings"
+)
+
+// attr is a single attribute test.
+type attr struct {
+	name  string
+	value string
+}
+
+// compound is a kind name with attribute tests.
+type compound struct {
+	kind  string
+	attrs []attr
+	// child is true if the compound must be the parent of the next one,
+	// false if it must be an ancestor.
+	child bool
+}
+
+// Matcher is a compiled selector.
+type Matcher struct {
+	parts []compound
+}
+
+// aliases maps short kind names to the mapast kind names.
+var aliases = map[string]string{
+	"Func": "ToplevFunc",
+}
+
+// Compile parses a selector.
+func Compile(selector string) (*Matcher, error) {
+	var m Matcher
+	var s = strings.TrimSpace(selector)
+	var child bool
+	for len(s) > 0 {
+		if s[0] == '>' {
+			if len(m.parts) == 0 || child {
+				return nil, errors.New("query: misplaced > in " + strconv.Quote(selector))
+			}
+			child = true
+			s = strings.TrimSpace(s[1:])
+			continue
+		}
+		var end = strings.IndexAny(s, " \t\n>[")
+		if end < 0 {
+			end = len(s)
+		}
+		var c = compound{kind: s[:end]}
+		if c.kind == "" {
+			return nil, errors.New("query: missing kind name in " + strconv.Quote(selector))
+		}
+		if alias, ok := aliases[c.kind]; ok {
+			c.kind = alias
+		}
+		s = s[end:]
+		for len(s) > 0 && s[0] == '[' {
+			var close = strings.IndexByte(s, ']')
+			if close < 0 {
+				return nil, errors.New("query: unterminated [ in " + strconv.Quote(selector))
+			}
+			var test = s[1:close]
+			var eq = strings.IndexByte(test, '=')
+			if eq < 0 {
+				return nil, errors.New("query: attribute test without = in " + strconv.Quote(selector))
+			}
+			var a = attr{name: strings.TrimSpace(test[:eq]), value: strings.TrimSpace(test[eq+1:])}
+			switch a.name {
+			case "kind", "text", "name", "children":
+
+			default:
+				return nil, errors.New("query: unknown attribute " + a.name)
+			}
+			c.attrs = append(c.attrs, a)
+			s = s[close+1:]
+		}
+		if len(m.parts) > 0 {
+			m.parts[len(m.parts)-1].child = child
+		}
+		child = false
+		m.parts = append(m.parts, c)
+		s = strings.TrimLeft(s, " \t\n")
+	}
+	if len(m.parts) == 0 || child {
+		return nil, errors.New("query: incomplete selector " + strconv.Quote(selector))
+	}
+	return &m, nil
+}
+
+// MustCompile is like Compile but panics if the selector cannot be parsed.
+func MustCompile(selector string) *Matcher {
+	m, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// children returns the number of children of the node at the key.
+func children(ast map[uint64][]byte, key uint64) (n uint64) {
+	for mapast.Poke(ast, mapast.O(key)+n) {
+		n++
+	}
+	return n
+}
+
+// matches tests a single compound selector against the node at the key.
+func (c *compound) matches(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	var kind = mapast.KindName(node)
+	switch c.kind {
+	case "*":
+
+	case "string":
+		if kind != "" || node == nil {
+			return false
+		}
+
+	case kind:
+
+	default:
+		if kind == "" || c.kind != kind+mapast.VariantName(node) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		switch a.name {
+		case "kind":
+			if mapast.VariantName(node) != a.value {
+				return false
+			}
+
+		case "text":
+			if kind != "" || string(node) != a.value {
+				return false
+			}
+
+		case "name":
+			var first = ast[mapast.O(key)]
+			if !mapast.Poke(ast, mapast.O(key)) || mapast.Which(first) != nil || string(first) != a.value {
+				return false
+			}
+
+		case "children":
+			if strconv.FormatUint(children(ast, key), 10) != a.value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchfrom tests the compound selectors up to the part index against the
+// ancestors, the last of which is tested against the part.
+func (m *Matcher) matchfrom(ast map[uint64][]byte, ancestors []uint64, part int) bool {
+	if !m.parts[part].matches(ast, ancestors[len(ancestors)-1]) {
+		return false
+	}
+	if part == 0 {
+		return true
+	}
+	var rest = ancestors[:len(ancestors)-1]
+	if m.parts[part-1].child {
+		return len(rest) > 0 && m.matchfrom(ast, rest, part-1)
+	}
+	for i := len(rest); i > 0; i-- {
+		if m.matchfrom(ast, rest[:i], part-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the keys of all nodes under the iterator position, including
+// the iterator node, that the selector matches. The keys are returned in
+// depth first order.
+func (m *Matcher) Match(ast map[uint64][]byte, iterator uint64) (keys []uint64) {
+	var ancestors []uint64
+	var walk func(uint64)
+	walk = func(key uint64) {
+		ancestors = append(ancestors, key)
+		if m.matchfrom(ast, ancestors, len(m.parts)-1) {
+			keys = append(keys, key)
+		}
+		for i := uint64(0); mapast.Poke(ast, mapast.O(key)+i); i++ {
+			walk(mapast.O(key) + i)
+		}
+		ancestors = ancestors[:len(ancestors)-1]
+	}
+	if mapast.Poke(ast, iterator) {
+		walk(iterator)
+	}
+	return keys
+}
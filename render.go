@@ -0,0 +1,182 @@
+package mapast
+
+import (
+	"sort"
+	"strings"
+)
+
+// Rendering is the code printed from a tree, together with the range of the
+// code printed by every node, so it can be kept up to date by printing again
+// only the nodes that changed. A Rendering must not be used by several
+// goroutines at once.
+type Rendering struct {
+	// Text is the printed code.
+	Text     string
+	root     uint64
+	parent   uint64
+	spans    map[uint64][2]int
+	parents  map[uint64]uint64
+	children map[uint64][]uint64
+	nodes    map[uint64]string
+}
+
+// render prints the subtree at the iterator into the builder, recording the
+// spans, shifted by the offset, and the structure of the printed nodes.
+func (r *Rendering) render(out *strings.Builder, ast map[uint64][]byte, iterator uint64, parent uint64, offset int) {
+	var stack []uint64
+	codehooks(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, func(key uint64) bool {
+		if len(stack) > 0 {
+			var top = stack[len(stack)-1]
+			r.parents[key] = top
+			r.children[top] = append(r.children[top], key)
+		}
+		stack = append(stack, key)
+		r.spans[key] = [2]int{offset + out.Len(), 0}
+		if Which(ast[key]) != nil {
+			r.nodes[key] = string(ast[key])
+		}
+		return false
+	}, func(key uint64) {
+		stack = stack[:len(stack)-1]
+		r.spans[key] = [2]int{r.spans[key][0], offset + out.Len()}
+	}, maptree(ast), iterator, parent)
+}
+
+// Render prints the tree at the iterator position like Code does and returns
+// the Rendering of it.
+func Render(ast map[uint64][]byte, iterator uint64, parent uint64) *Rendering {
+	var r = &Rendering{root: iterator, parent: parent}
+	r.reset(ast)
+	return r
+}
+
+// reset prints the whole tree again.
+func (r *Rendering) reset(ast map[uint64][]byte) {
+	r.spans = make(map[uint64][2]int)
+	r.parents = make(map[uint64]uint64)
+	r.children = make(map[uint64][]uint64)
+	r.nodes = make(map[uint64]string)
+	var out strings.Builder
+	r.render(&out, ast, r.root, r.parent, 0)
+	r.Text = out.String()
+}
+
+// Span returns the range of the text printed by the node at the key, as of
+// the latest Render or Update, and whether the node was printed.
+func (r *Rendering) Span(key uint64) (start int, end int, ok bool) {
+	var span, found = r.spans[key]
+	return span[0], span[1], found
+}
+
+// subtree adds the key and the keys of its recorded descendants to the set.
+func (r *Rendering) subtree(key uint64, set map[uint64]bool) {
+	set[key] = true
+	for _, child := range r.children[key] {
+		r.subtree(child, set)
+	}
+}
+
+// forget drops the recorded structure of the descendants of the key.
+func (r *Rendering) forget(key uint64) {
+	for _, child := range r.children[key] {
+		r.forget(child)
+		delete(r.spans, child)
+		delete(r.parents, child)
+		delete(r.nodes, child)
+	}
+	delete(r.children, key)
+}
+
+// ancestor reports whether the key a is the key b or one of its ancestors.
+func (r *Rendering) ancestor(a uint64, b uint64) bool {
+	for {
+		if a == b {
+			return true
+		}
+		var parent, ok = r.parents[b]
+		if !ok {
+			return false
+		}
+		b = parent
+	}
+}
+
+// Update brings the rendering up to date with the tree after the subtrees at
+// the dirty keys changed, such as the parents passed to InsertChild or
+// RemoveChild, or the nodes replaced in place. Only the dirty nodes are
+// printed again and spliced into the text; a dirty node whose own header
+// changed is printed again together with its parent, since the parent prints
+// the separators around its children. A dirty key that was not printed by the
+// latest rendering makes Update print the whole tree again. Strings are
+// printed by their parents, so a dirty string makes its parent dirty.
+func (r *Rendering) Update(ast map[uint64][]byte, dirty []uint64) {
+	var regions []uint64
+	for _, key := range dirty {
+		if _, ok := r.spans[key]; !ok {
+			r.reset(ast)
+			return
+		}
+		for key != r.root {
+			var node, ok = ast[key]
+			if ok && Which(node) != nil && r.nodes[key] == string(node) {
+				break
+			}
+			key = r.parents[key]
+		}
+		if key == r.root {
+			r.reset(ast)
+			return
+		}
+		regions = append(regions, key)
+	}
+	var outer []uint64
+	for _, key := range regions {
+		var nested = false
+		for _, other := range regions {
+			if other != key && r.ancestor(other, key) {
+				nested = true
+			}
+		}
+		if !nested {
+			outer = append(outer, key)
+		}
+	}
+	sort.Slice(outer, func(i, j int) bool {
+		return r.spans[outer[i]][0] > r.spans[outer[j]][0]
+	})
+	for i, key := range outer {
+		if i > 0 && key == outer[i-1] {
+			continue
+		}
+		var span = r.spans[key]
+		var parent = r.parents[key]
+		r.forget(key)
+		var out strings.Builder
+		r.render(&out, ast, key, parent, span[0])
+		var delta = out.Len() - (span[1] - span[0])
+		r.Text = r.Text[:span[0]] + out.String() + r.Text[span[1]:]
+		if delta == 0 {
+			continue
+		}
+		var printed = make(map[uint64]bool)
+		r.subtree(key, printed)
+		for k, s := range r.spans {
+			if printed[k] {
+				continue
+			}
+			if s[0] >= span[1] {
+				s[0] += delta
+			}
+			if s[1] >= span[1] {
+				s[1] += delta
+			}
+			r.spans[k] = s
+		}
+	}
+}
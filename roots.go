@@ -0,0 +1,47 @@
+package mapast
+
+// NewRoot reserves a free key of the ast for another tree and stores a
+// RootMatter node there. The files of the new tree are its children, as they
+// are for the RootMatter at key zero, so scratch trees can be built next to
+// the main one in the same map. The keys are tried in increasing order from
+// one, far from the keys O derives.
+func NewRoot(ast map[uint64][]byte) uint64 {
+	var root = uint64(1)
+	for Poke(ast, root) || Poke(ast, O(root)) {
+		root++
+	}
+	ast[root] = RootMatter
+	return root
+}
+
+// Detach removes the subtree at the root from the ast and returns it as a new
+// tree having the root at key zero, so it can be used by the functions
+// expecting the RootMatter there.
+func Detach(ast map[uint64][]byte, root uint64) map[uint64][]byte {
+	var detached = make(map[uint64][]byte)
+	if Poke(ast, root) {
+		paste(detached, 0, cut(ast, root))
+	}
+	return detached
+}
+
+// Mount copies the tree having its root at key zero into the ast under a new
+// root returned by NewRoot, and returns that root. It is the reverse of
+// Detach.
+func Mount(ast map[uint64][]byte, tree map[uint64][]byte) uint64 {
+	var root = NewRoot(ast)
+	CopySubtree(tree, 0, ast, root)
+	return root
+}
+
+// Compact returns a new tree holding only the subtree at the root, re-keyed
+// so that the root is at key zero and every node at the key O derives from
+// its parent, leaving out the keys of the ast that are not reachable from the
+// root, such as the ones left behind by heavy editing or unfinished
+// conversions. The strings are copied, so the result shares no memory with
+// the ast. It is suitable before serialization.
+func Compact(ast map[uint64][]byte, root uint64) map[uint64][]byte {
+	var compact = make(map[uint64][]byte)
+	CopySubtree(ast, root, compact, 0)
+	return compact
+}
@@ -0,0 +1,36 @@
+package scope
+
+import (
+	"github.com/go-li/mapast"
+)
+
+// Enclosing returns the innermost scope enclosing the node at the key, the
+// scope it opens if it opens one. Parents is the parent index, built by
+// mapast.BuildParentIndex, of the tree the scopes were built for.
+func (info *Info) Enclosing(parents map[uint64]uint64, key uint64) *Scope {
+	for {
+		if s, ok := info.Scopes[key]; ok {
+			return s
+		}
+		var parent, ok = parents[key]
+		if !ok {
+			return info.Universe
+		}
+		key = parent
+	}
+}
+
+// NewNameGen creates a name generator avoiding the names visible at the node
+// at the key of the tree at the root, so a fresh name declared there shadows
+// nothing and captures no reference. The names declared anywhere in the
+// enclosing blocks are avoided, including the ones declared after the node.
+func NewNameGen(ast map[uint64][]byte, root uint64, key uint64) *mapast.NameGen {
+	var info = Build(ast, root)
+	var names []string
+	for s := info.Enclosing(mapast.BuildParentIndex(ast, root), key); s != nil; s = s.Parent {
+		for name := range s.Names {
+			names = append(names, name)
+		}
+	}
+	return mapast.NewNameGen(names...)
+}
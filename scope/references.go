@@ -0,0 +1,28 @@
+package scope
+
+import (
+	"github.com/go-li/mapast"
+	"sort"
+)
+
+// References returns the keys of all the identifier strings of the tree at the
+// root resolving to the same declaration as the identifier string at the key,
+// in the order they appear in the code. The key can be the key of the
+// declaration or of any of its uses. The declaration itself is not included.
+// It returns nil if the identifier does not resolve to a declaration in the
+// tree.
+func References(ast map[uint64][]byte, root uint64, key uint64) []uint64 {
+	var info = Build(ast, root)
+	if def, ok := info.Uses[key]; ok {
+		key = def
+	}
+	if _, ok := info.Defs[key]; !ok || key == 0 {
+		return nil
+	}
+	var keys = info.References(key)
+	var _, offsets = mapast.CodeOffsets(ast, root, 0)
+	sort.Slice(keys, func(i, j int) bool {
+		return offsets[keys[i]] < offsets[keys[j]]
+	})
+	return keys
+}
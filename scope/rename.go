@@ -0,0 +1,74 @@
+package scope
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+)
+
+// within reports whether the scope is the outer scope or nested in it.
+func within(s *Scope, outer *Scope) bool {
+	for ; s != nil; s = s.Parent {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// References returns the keys of the identifier strings resolving to the
+// declaration at the key, not including the declaration itself, in no
+// particular order.
+func (info *Info) References(def uint64) (keys []uint64) {
+	for key, to := range info.Uses {
+		if to == def && key != def {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Rename renames the identifier declared at the key, and all the identifiers
+// resolving to it, within the tree at the root. The key can also be the key of
+// an identifier resolving to the declaration. Rename refuses to rename if the
+// new name is already declared in the same scope, if a reference would
+// resolve to another declaration of the new name, or if another identifier
+// of the new name would resolve to the renamed declaration. Selectors and
+// struct fields are not resolved, so renaming fields and methods is refused.
+func Rename(ast map[uint64][]byte, root uint64, key uint64, name string) error {
+	if err := mapast.ValidIdent(name); err != nil {
+		return err
+	}
+	var info = Build(ast, root)
+	if def, ok := info.Uses[key]; ok {
+		key = def
+	}
+	var s, ok = info.Defs[key]
+	if !ok || key == 0 {
+		return errors.New("scope: not a declaration of a local or package identifier")
+	}
+	if string(ast[key]) == name {
+		return nil
+	}
+	if _, ok := s.Names[name]; ok {
+		return errors.New("scope: " + name + " is already declared in the scope")
+	}
+	var refs = info.References(key)
+	for _, ref := range refs {
+		if found, _, ok := info.In[ref].Lookup(name); ok && within(found, s) {
+			return errors.New("scope: " + name + " would shadow the renamed identifier")
+		}
+	}
+	for use, def := range info.Uses {
+		if string(ast[use]) != name || !within(info.In[use], s) {
+			continue
+		}
+		if found := info.Defs[def]; def == 0 || found == nil || !within(found, s) {
+			return errors.New("scope: the renamed identifier would shadow " + name)
+		}
+	}
+	ast[key] = []byte(name)
+	for _, ref := range refs {
+		ast[ref] = []byte(name)
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+// Package scope builds the lexical scopes of a mapast tree.
+//
+// Every scope maps the identifiers declared in it to the keys of the strings
+// declaring them. The scopes are nested: the universe scope holds the
+// predeclared identifiers, the package scope holds the top level declarations
+// of all files, a file scope holds the imports of a file, and function and
+// block scopes hold the parameters and local declarations. Identifiers are
+// resolved syntactically, so selectors such as the b of a.b, struct fields,
+// interface methods, identifiers used as keys of composite literals and labels
+// are not resolved.
+package scope
+
+import (
+	"github.com/go-li/mapast"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// Kind is the kind of a scope.
+type Kind byte
+
+// Universe is the scope of the predeclared identifiers.
+const Universe Kind = 0
+
+// Package is the scope of the top level declarations of all files.
+const Package Kind = 1
+
+// File is the scope of the imports of a single file.
+const File Kind = 2
+
+// Func is the scope of the receiver, parameters, results and body statements
+// of a ToplevFunc or a ClosureExp.
+const Func Kind = 3
+
+// Block is the scope of a BlocOfCode, including the declarations in its
+// header.
+const Block Kind = 4
+
+// Scope is a single lexical scope.
+type Scope struct {
+	Kind Kind
+	// Key is the key of the node opening the scope. It is zero for the
+	// universe.
+	Key      uint64
+	Parent   *Scope
+	Children []*Scope
+	// Names maps the identifiers declared in the scope to the keys of the
+	// strings declaring them. Predeclared identifiers map to zero.
+	Names map[string]uint64
+}
+
+// Lookup finds the identifier in the scope or in its enclosing scopes. It
+// returns the scope declaring the identifier and the key of its declaration.
+func (s *Scope) Lookup(name string) (*Scope, uint64, bool) {
+	for ; s != nil; s = s.Parent {
+		if key, ok := s.Names[name]; ok {
+			return s, key, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Info holds the scopes of a tree.
+type Info struct {
+	Universe *Scope
+	// Scopes maps the keys of the nodes opening scopes to the scopes. The
+	// body BlocOfCode of a function shares the scope of its function.
+	Scopes map[uint64]*Scope
+	// Defs maps the keys of declaring strings to the scopes they declare the
+	// identifier in.
+	Defs map[uint64]*Scope
+	// Uses maps the keys of identifier strings resolved to a declaration to
+	// the key of the declaring string, or to zero for predeclared identifiers.
+	Uses map[uint64]uint64
+	// In maps the keys of the declaring and resolved strings to the innermost
+	// scope enclosing them.
+	In map[uint64]*Scope
+}
+
+// builder walks a tree, building the scopes.
+type builder struct {
+	ast  map[uint64][]byte
+	info *Info
+}
+
+// open creates a new scope nested in the parent scope.
+func (b *builder) open(parent *Scope, kind Kind, key uint64) *Scope {
+	var s = &Scope{Kind: kind, Key: key, Parent: parent, Names: make(map[string]uint64)}
+	parent.Children = append(parent.Children, s)
+	b.info.Scopes[key] = s
+	return s
+}
+
+// def declares the identifier string at the key in the scope.
+func (b *builder) def(s *Scope, key uint64) {
+	var name = string(b.ast[key])
+	b.info.In[key] = s
+	if name == "_" || mapast.ValidIdent(name) != nil {
+		return
+	}
+	s.Names[name] = key
+	b.info.Defs[key] = s
+}
+
+// use resolves the identifier string at the key in the scope.
+func (b *builder) use(s *Scope, key uint64) {
+	var name = string(b.ast[key])
+	if mapast.ValidIdent(name) != nil {
+		return
+	}
+	if _, def, ok := s.Lookup(name); ok {
+		b.info.Uses[key] = def
+		b.info.In[key] = s
+	}
+}
+
+// ident returns the key of the identifier string of the node at the key,
+// which is either the string itself or an ExpressionIdentifier holding it.
+func (b *builder) ident(key uint64) (uint64, bool) {
+	var node = b.ast[key]
+	if mapast.Is(node, mapast.Expression) && mapast.Variant(node) == mapast.ExpressionIdentifier {
+		key, node = mapast.O(key), b.ast[mapast.O(key)]
+	}
+	return key, mapast.Which(node) == nil
+}
+
+// lhs returns the number of left hand side elements of an AssignStmt.
+func lhs(ast map[uint64][]byte, key uint64) int {
+	var children = mapast.ChildKeys(ast, key)
+	for i, child := range children {
+		if mapast.Is(ast[child], mapast.RootOfType) {
+			return i
+		}
+	}
+	switch mapast.Variant(ast[key]) {
+	case mapast.AssignStmtIotaIsLast:
+		return len(children)
+
+	case mapast.AssignStmtMoreEqual, mapast.AssignStmtMoreColonEq,
+		mapast.AssignStmtMoreEqualRange, mapast.AssignStmtMoreColonEqRange:
+		return len(children) - 1
+	}
+	return len(children) / 2
+}
+
+// declare walks an AssignStmt of a VarDefStmt, or a short variable
+// declaration if short is true. The values and the type are resolved before
+// the variables are declared.
+func (b *builder) declare(s *Scope, key uint64, short bool) {
+	var children = mapast.ChildKeys(b.ast, key)
+	var n = lhs(b.ast, key)
+	for _, child := range children[n:] {
+		b.walk(s, child, key)
+	}
+	for _, child := range children[:n] {
+		var id, ok = b.ident(child)
+		if !ok {
+			b.walk(s, child, key)
+			continue
+		}
+		if _, redeclared := s.Names[string(b.ast[id])]; short && redeclared {
+			b.use(s, id)
+		} else {
+			b.def(s, id)
+		}
+	}
+}
+
+// types walks the RootOfType children of a TypedIdent, skipping its names.
+func (b *builder) types(s *Scope, key uint64) {
+	for _, child := range mapast.ChildKeys(b.ast, key) {
+		if mapast.Is(b.ast[child], mapast.RootOfType) {
+			b.walk(s, child, key)
+		}
+	}
+}
+
+// function walks a ToplevFunc or a ClosureExp, starting at its first
+// TypedIdent child. A function without a body, such as a ClosureExp being
+// a function type, has no scope, so the names of its parameters are not
+// declared and only their types are walked.
+func (b *builder) function(s *Scope, key uint64, first int) {
+	var children = mapast.ChildKeys(b.ast, key)[first:]
+	if len(children) == 0 || !mapast.Is(b.ast[children[len(children)-1]], mapast.BlocOfCode) {
+		for _, child := range children {
+			if mapast.Is(b.ast[child], mapast.TypedIdent) {
+				b.types(s, child)
+			} else {
+				b.walk(s, child, key)
+			}
+		}
+		return
+	}
+	var f = b.open(s, Func, key)
+	for _, child := range children {
+		var node = b.ast[child]
+		switch {
+		case mapast.Is(node, mapast.TypedIdent):
+			b.types(f, child)
+			for _, name := range mapast.ChildKeys(b.ast, child) {
+				if mapast.Which(b.ast[name]) == nil {
+					b.def(f, name)
+				} else if mapast.Is(b.ast[name], mapast.RootOfType) {
+					break
+				}
+			}
+
+		case mapast.Is(node, mapast.BlocOfCode):
+			b.info.Scopes[child] = f
+			b.children(f, child)
+
+		default:
+			b.walk(f, child, key)
+		}
+	}
+}
+
+// children walks the children of the node in the scope. The else branch
+// following a BlocOfCodeIfElse is walked in the scope of the if statement,
+// which declares the variables of its header.
+func (b *builder) children(s *Scope, key uint64) {
+	var in = s
+	for _, child := range mapast.ChildKeys(b.ast, key) {
+		b.walk(in, child, key)
+		in = s
+		if node := b.ast[child]; mapast.Is(node, mapast.BlocOfCode) && mapast.Variant(node) == mapast.BlocOfCodeIfElse {
+			in = b.info.Scopes[child]
+		}
+	}
+}
+
+// walk walks the node at the key in the scope.
+func (b *builder) walk(s *Scope, key uint64, parent uint64) {
+	var node = b.ast[key]
+	if mapast.Which(node) == nil {
+		b.use(s, key)
+		return
+	}
+	var children = mapast.ChildKeys(b.ast, key)
+	switch {
+	case mapast.Is(node, mapast.PackageDef), mapast.Is(node, mapast.ImportStmt),
+		mapast.Is(node, mapast.ImportsDef), mapast.Is(node, mapast.CommentRow),
+		mapast.Is(node, mapast.LblGotoCnt), mapast.Is(node, mapast.BranchStmt):
+
+	case mapast.Is(node, mapast.TypDefStmt):
+		if s.Kind != File && len(children) > 0 {
+			b.def(s, children[0])
+		}
+		for _, child := range children[1:] {
+			b.walk(s, child, key)
+		}
+
+	case mapast.Is(node, mapast.VarDefStmt):
+		for _, child := range children {
+			if s.Kind != File && mapast.Is(b.ast[child], mapast.AssignStmt) {
+				b.declare(s, child, false)
+			} else if mapast.Is(b.ast[child], mapast.AssignStmt) {
+				var n = lhs(b.ast, child)
+				for _, value := range mapast.ChildKeys(b.ast, child)[n:] {
+					b.walk(s, value, child)
+				}
+			}
+		}
+
+	case mapast.Is(node, mapast.AssignStmt):
+		switch mapast.Variant(node) {
+		case mapast.AssignStmtColonEq, mapast.AssignStmtMoreColonEq, mapast.AssignStmtMoreColonEqRange:
+			b.declare(s, key, true)
+
+		default:
+			b.children(s, key)
+		}
+
+	case mapast.Is(node, mapast.ToplevFunc):
+		b.function(s, key, 1)
+
+	case mapast.Is(node, mapast.ClosureExp):
+		b.function(s, key, 0)
+
+	case mapast.Is(node, mapast.TypedIdent), mapast.Is(node, mapast.IfceMethod):
+		for _, child := range children {
+			if mapast.Is(b.ast[child], mapast.TypedIdent) {
+				b.types(s, child)
+			} else if mapast.Is(b.ast[child], mapast.RootOfType) {
+				b.walk(s, child, key)
+			}
+		}
+
+	case mapast.Is(node, mapast.BlocOfCode):
+		b.children(b.open(s, Block, key), key)
+
+	case mapast.Is(node, mapast.Expression):
+		switch mapast.Variant(node) {
+		case mapast.ExpressionDot:
+			children = children[:1]
+
+		case mapast.ExpressionKeyVal:
+			// A key made of an identifier alone may be a field name, but
+			// any other key is an expression.
+			if p := b.ast[parent]; mapast.Is(p, mapast.Expression) &&
+				mapast.Variant(p) == mapast.ExpressionComposite && mapast.Which(b.ast[children[0]]) == nil {
+				children = children[1:]
+			}
+		}
+		for _, child := range children {
+			b.walk(s, child, key)
+		}
+
+	default:
+		b.children(s, key)
+	}
+}
+
+// importname returns the name declared by the ImportStmt at the key and the
+// key of the string declaring it.
+func importname(ast map[uint64][]byte, key uint64) (string, uint64) {
+	var children = mapast.ChildKeys(ast, key)
+	if len(children) == 0 {
+		return "", 0
+	}
+	if len(children) > 1 {
+		return string(ast[children[0]]), children[0]
+	}
+	var path, err = strconv.Unquote(string(ast[children[0]]))
+	if err != nil {
+		return "", 0
+	}
+	return path[strings.LastIndexByte(path, '/')+1:], children[0]
+}
+
+// toplevel declares the top level declarations of the file in the package
+// scope and its imports in the file scope.
+func (b *builder) toplevel(pkg *Scope, file *Scope) {
+	for _, key := range mapast.ChildKeys(b.ast, file.Key) {
+		var node = b.ast[key]
+		var children = mapast.ChildKeys(b.ast, key)
+		switch {
+		case mapast.Is(node, mapast.ImportsDef), mapast.Is(node, mapast.ImportStmt):
+			var stmts = []uint64{key}
+			if mapast.Is(node, mapast.ImportsDef) {
+				stmts = children
+			}
+			for _, stmt := range stmts {
+				if name, def := importname(b.ast, stmt); name != "" && name != "." {
+					b.info.In[def] = file
+					if name != "_" {
+						file.Names[name] = def
+						b.info.Defs[def] = file
+					}
+				}
+			}
+
+		case mapast.Is(node, mapast.TypDefStmt):
+			if len(children) > 0 {
+				b.def(pkg, children[0])
+			}
+
+		case mapast.Is(node, mapast.VarDefStmt):
+			for _, child := range children {
+				if mapast.Is(b.ast[child], mapast.AssignStmt) {
+					for _, name := range mapast.ChildKeys(b.ast, child)[:lhs(b.ast, child)] {
+						if id, ok := b.ident(name); ok {
+							b.def(pkg, id)
+						}
+					}
+				}
+			}
+
+		case mapast.Is(node, mapast.ToplevFunc):
+			if mapast.Variant(node) == 0 && len(children) > 0 && string(b.ast[children[0]]) != "init" {
+				b.def(pkg, children[0])
+			}
+		}
+	}
+}
+
+// Build builds the scopes of the tree at the root, which is either
+// a RootMatter, whose FileMatter children are the files of a package, or
+// a single FileMatter.
+func Build(ast map[uint64][]byte, root uint64) *Info {
+	var info = &Info{
+		Universe: &Scope{Kind: Universe, Names: make(map[string]uint64)},
+		Scopes:   make(map[uint64]*Scope),
+		Defs:     make(map[uint64]*Scope),
+		Uses:     make(map[uint64]uint64),
+		In:       make(map[uint64]*Scope),
+	}
+	for _, name := range types.Universe.Names() {
+		info.Universe.Names[name] = 0
+	}
+	var b = &builder{ast: ast, info: info}
+	var pkg = b.open(info.Universe, Package, root)
+	var files = []uint64{root}
+	if mapast.Is(ast[root], mapast.RootMatter) {
+		files = mapast.ChildKeys(ast, root)
+	}
+	var scopes []*Scope
+	for _, file := range files {
+		if mapast.Is(ast[file], mapast.FileMatter) {
+			scopes = append(scopes, b.open(pkg, File, file))
+		}
+	}
+	for _, file := range scopes {
+		b.toplevel(pkg, file)
+	}
+	for _, file := range scopes {
+		b.children(file, file.Key)
+	}
+	return info
+}
@@ -0,0 +1,105 @@
+package scope
+
+import (
+	"github.com/go-li/mapast"
+	"sort"
+)
+
+// Shadow is a local declaration hiding another declaration of the same
+// identifier made earlier in an enclosing function or block scope.
+type Shadow struct {
+	// Key and Shadowed are the keys of the declaring strings of the inner and
+	// of the outer declaration.
+	Key      uint64
+	Shadowed uint64
+}
+
+// redeclares reports whether the identifier string at the key is declared by
+// a short variable declaration taking the value of the identifier it shadows,
+// as in x := x or in switch x := x.(type), which are done on purpose.
+func redeclares(ast map[uint64][]byte, index map[uint64]uint64, key uint64) bool {
+	var assign, i, ok = element(ast, index, key)
+	if !ok {
+		return false
+	}
+	var short = mapast.Variant(ast[assign]) == mapast.AssignStmtColonEq
+	var values = mapast.ChildKeys(ast, assign)[lhs(ast, assign):]
+	if !short || i >= len(values) {
+		return false
+	}
+	var value = values[i]
+	if node := ast[value]; mapast.Is(node, mapast.Expression) && mapast.Count(node) == 1 &&
+		(mapast.Variant(node) == mapast.ExpressionIdentifier || mapast.Variant(node) == mapast.ExpressionType) {
+		value = mapast.O(value)
+	}
+	return mapast.Which(ast[value]) == nil && string(ast[value]) == string(ast[key])
+}
+
+// isshort reports whether the identifier string at the key is declared by
+// a short variable declaration.
+func isshort(ast map[uint64][]byte, index map[uint64]uint64, key uint64) bool {
+	var assign, _, ok = element(ast, index, key)
+	if !ok {
+		return false
+	}
+	var _, short = blanked[mapast.Variant(ast[assign])]
+	return short
+}
+
+// isvariable reports whether the identifier string at the key is declared as
+// a variable, by an assignment, a var declaration or as a parameter, rather
+// than as a type or a constant.
+func isvariable(ast map[uint64][]byte, index map[uint64]uint64, key uint64) bool {
+	var parent, ok = index[key]
+	if node := ast[parent]; ok && mapast.Is(node, mapast.Expression) && mapast.Variant(node) == mapast.ExpressionIdentifier {
+		parent, ok = index[parent]
+	}
+	switch {
+	case !ok:
+		return false
+
+	case mapast.Is(ast[parent], mapast.TypedIdent):
+		return true
+
+	case mapast.Is(ast[parent], mapast.AssignStmt):
+		var decl, ok = index[parent]
+		return !ok || !mapast.Is(ast[decl], mapast.VarDefStmt) || mapast.Variant(ast[decl]) != mapast.VarDefStmtConst
+	}
+	return false
+}
+
+// Shadows returns the short variable declarations of the tree at the root
+// shadowing a declaration of an enclosing function or block scope, such as
+// the err of x, err := f() hiding the err of the function, in the order of the
+// code. Only variables shadowing variables are reported. Like the shadow
+// analyzer of go vet does, the shadowing is reported
+// only if the shadowed declaration is used after it, so the inner declaration
+// may have been meant as an assignment. Shadowing the package level, imported
+// or predeclared identifiers is not reported, and neither are the
+// declarations such as x := x.
+func Shadows(ast map[uint64][]byte, root uint64) (shadows []Shadow) {
+	var info = Build(ast, root)
+	var index = mapast.BuildParentIndex(ast, root)
+	var _, offsets = mapast.CodeOffsets(ast, root, 0)
+	var last = make(map[uint64]int)
+	for use, def := range info.Uses {
+		if offsets[use] > last[def] {
+			last[def] = offsets[use]
+		}
+	}
+	for def, s := range info.Defs {
+		if s.Kind != Func && s.Kind != Block || s.Parent == nil || !isshort(ast, index, def) {
+			continue
+		}
+		var outer, key, ok = s.Parent.Lookup(string(ast[def]))
+		if !ok || outer.Kind != Func && outer.Kind != Block || offsets[key] > offsets[def] ||
+			last[key] <= offsets[def] || !isvariable(ast, index, key) || redeclares(ast, index, def) {
+			continue
+		}
+		shadows = append(shadows, Shadow{Key: def, Shadowed: key})
+	}
+	sort.Slice(shadows, func(i, j int) bool {
+		return offsets[shadows[i].Key] < offsets[shadows[j].Key]
+	})
+	return shadows
+}
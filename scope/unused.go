@@ -0,0 +1,163 @@
+package scope
+
+import (
+	"github.com/go-li/mapast"
+	"sort"
+)
+
+// blanked maps the short variable declaration variants to the assignments
+// replacing them once all their variables are blank.
+var blanked = map[byte]byte{
+	mapast.AssignStmtColonEq:          mapast.AssignStmtEqual,
+	mapast.AssignStmtMoreColonEq:      mapast.AssignStmtMoreEqual,
+	mapast.AssignStmtMoreColonEqRange: mapast.AssignStmtMoreEqualRange,
+}
+
+// element returns the key of the AssignStmt having the identifier string at
+// the key as a left hand side element, and the index of the element. It
+// returns false if the string is not such an element.
+func element(ast map[uint64][]byte, index map[uint64]uint64, key uint64) (uint64, int, bool) {
+	var parent, ok = index[key]
+	if !ok {
+		return 0, 0, false
+	}
+	if node := ast[parent]; mapast.Is(node, mapast.Expression) && mapast.Variant(node) == mapast.ExpressionIdentifier {
+		key = parent
+		if parent, ok = index[key]; !ok {
+			return 0, 0, false
+		}
+	}
+	if !mapast.Is(ast[parent], mapast.AssignStmt) {
+		return 0, 0, false
+	}
+	var i = int(key - mapast.O(parent))
+	return parent, i, i < lhs(ast, parent)
+}
+
+// iswrite reports whether the identifier string at the key is only written,
+// being assigned to by an assignment without operation or redeclared by
+// a short variable declaration. The operations such as += read the variable.
+func iswrite(ast map[uint64][]byte, index map[uint64]uint64, key uint64) bool {
+	var assign, _, ok = element(ast, index, key)
+	if !ok {
+		return false
+	}
+	switch mapast.Variant(ast[assign]) {
+	case mapast.AssignStmtEqual, mapast.AssignStmtMoreEqual, mapast.AssignStmtMoreEqualRange:
+		return true
+	}
+	var _, short = blanked[mapast.Variant(ast[assign])]
+	return short
+}
+
+// Unused returns a diagnostic for every local variable of the tree at the root
+// that is declared by a short variable declaration or a var declaration and
+// never read, which the compiler rejects as declared and not used. Assigning
+// to a variable without an operation does not read it. The diagnostics are at
+// the keys of the declaring strings, in the order of the code. Their fixes
+// rename the variables to the blank identifier, turning a short variable
+// declaration left without new variables into an assignment. The variables of
+// type switches have no fix.
+func Unused(ast map[uint64][]byte, root uint64) (diags []*mapast.Diagnostic) {
+	var info = Build(ast, root)
+	var index = mapast.BuildParentIndex(ast, root)
+	var read = make(map[uint64]bool)
+	for use, def := range info.Uses {
+		if !iswrite(ast, index, use) && use != def {
+			read[def] = true
+		}
+	}
+	// The keys of composite literals are not resolved, since they can be
+	// field names, so a variable named like one may be read as a map key.
+	var keys = make(map[string]bool)
+	mapast.Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		if mapast.Is(node, mapast.Expression) && mapast.Variant(node) == mapast.ExpressionKeyVal {
+			var k = mapast.O(key)
+			if mapast.Which(ast[k]) != nil && mapast.Variant(ast[k]) == mapast.ExpressionIdentifier {
+				k = mapast.O(k)
+			}
+			keys[string(ast[k])] = true
+		}
+		return true
+	})
+	var unused = make(map[uint64]bool)
+	var stmts = make(map[uint64]uint64)
+	for def, s := range info.Defs {
+		if s.Kind != Func && s.Kind != Block || read[def] || keys[string(ast[def])] {
+			continue
+		}
+		var stmt, _, ok = element(ast, index, def)
+		if !ok || !mapast.Is(ast[stmt], mapast.AssignStmt) {
+			continue
+		}
+		if p := ast[index[stmt]]; mapast.Is(p, mapast.VarDefStmt) && mapast.Variant(p) == mapast.VarDefStmtConst {
+			continue
+		}
+		unused[def] = true
+		stmts[def] = stmt
+	}
+	var _, offsets = mapast.CodeOffsets(ast, root, 0)
+	for def := range unused {
+		var d = &mapast.Diagnostic{Key: def, Message: "scope: " + string(ast[def]) + " declared and not used"}
+		d.Fix = blank(ast, index, info, stmts[def], def, unused)
+		diags = append(diags, d)
+	}
+	sort.Slice(diags, func(i, j int) bool {
+		return offsets[diags[i].Key] < offsets[diags[j].Key]
+	})
+	return diags
+}
+
+// blank returns the edits renaming the variable declared at the key by the
+// statement, and the assignments to it, to the blank identifier. It returns
+// nil if the variable cannot be renamed, being the variable of a type switch
+// or being redeclared by a short variable declaration.
+func blank(ast map[uint64][]byte, index map[uint64]uint64, info *Info, stmt uint64, def uint64,
+	unused map[uint64]bool) []mapast.Edit {
+	var node = ast[stmt]
+	var rename = map[uint64][]byte{0: []byte("_")}
+	var edits []mapast.Edit
+	for _, ref := range info.References(def) {
+		var assign, _, _ = element(ast, index, ref)
+		if _, short := blanked[mapast.Variant(ast[assign])]; short {
+			return nil
+		}
+		edits = append(edits, mapast.Edit{Op: mapast.EditReplace, Key: ref, Node: rename})
+	}
+	if s := info.Defs[def]; mapast.Is(ast[s.Key], mapast.BlocOfCode) &&
+		mapast.Variant(ast[s.Key]) == mapast.BlocOfCodeTypeSwitch && mapast.O(s.Key) == stmt {
+		return nil
+	}
+	var variant, short = blanked[mapast.Variant(node)]
+	if !short {
+		return append(edits, mapast.Edit{Op: mapast.EditReplace, Key: def, Node: rename})
+	}
+	// A short variable declaration needs a new variable that is not blank.
+	var elements = mapast.ChildKeys(ast, stmt)[:lhs(ast, stmt)]
+	for _, e := range elements {
+		var id = e
+		if mapast.Which(ast[id]) != nil {
+			id = mapast.O(id)
+		}
+		if _, ok := info.Defs[id]; ok && !unused[id] {
+			return append(edits, mapast.Edit{Op: mapast.EditReplace, Key: def, Node: rename})
+		}
+	}
+	var replaced = make(map[uint64][]byte)
+	mapast.CopySubtree(ast, stmt, replaced, 0)
+	replaced[0] = mapast.AssignStmtNode(variant, mapast.Count(node))
+	for i, e := range elements {
+		var id = e
+		if mapast.Which(ast[id]) != nil {
+			id = mapast.O(id)
+		}
+		if unused[id] {
+			var at = mapast.O(0) + uint64(i)
+			if id != e {
+				at = mapast.O(at)
+			}
+			replaced[at] = []byte("_")
+		}
+	}
+	return append(edits, mapast.Edit{Op: mapast.EditReplace, Key: stmt, Node: replaced})
+}
@@ -0,0 +1,199 @@
+package mapast
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// sexpname returns the name of the node in the s-expression, the Kind name
+// followed by a colon and the variant number for the kinds whose variant is a
+// number, such as FileMatter:1.
+func sexpname(node []byte) string {
+	var name = KindOf(node).String()
+	if _, ok := variantnames[kindnames[kindof(node)]]; !ok && Variant(node) != 0 {
+		name += ":" + strconv.Itoa(int(Variant(node)))
+	}
+	return name
+}
+
+func writesexp(bw *bufio.Writer, ast map[uint64][]byte, iterator uint64, depth int) {
+	var node = ast[iterator]
+	if node == nil {
+		bw.WriteString("nil")
+		return
+	}
+	if Which(node) == nil {
+		bw.WriteString(strconv.Quote(string(node)))
+		return
+	}
+	bw.WriteString("(" + sexpname(node))
+	if Count(node) != 0 {
+		bw.WriteString(" " + strconv.FormatUint(Count(node), 10))
+	}
+	var flat = true
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		if Which(ast[O(iterator)+i]) != nil {
+			flat = false
+		}
+	}
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		if flat {
+			bw.WriteString(" ")
+		} else {
+			bw.WriteString("\n" + strings.Repeat(" ", depth+1))
+		}
+		writesexp(bw, ast, O(iterator)+i, depth+1)
+	}
+	bw.WriteString(")")
+}
+
+// WriteSexp writes the subtree at the iterator position as a lisp style
+// s-expression. A node is a list starting with its Kind name, followed by its
+// element count unless it is zero, and by its children. Strings are quoted
+// like go strings and nil leaves are written as nil. Every node starts on its
+// own line, so the text diffs well.
+func WriteSexp(w io.Writer, ast map[uint64][]byte, iterator uint64) error {
+	if !Poke(ast, iterator) {
+		return errors.New("mapast: no node at the iterator position")
+	}
+	var bw = bufio.NewWriter(w)
+	writesexp(bw, ast, iterator, 0)
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// sexpreader splits an s-expression into tokens: parentheses, quoted strings
+// and atoms. Comments start with a semicolon and end with the line.
+type sexpreader struct {
+	text string
+}
+
+func (s *sexpreader) next() (string, error) {
+	for {
+		s.text = strings.TrimLeft(s.text, " \t\r\n")
+		if !strings.HasPrefix(s.text, ";") {
+			break
+		}
+		if end := strings.IndexByte(s.text, '\n'); end >= 0 {
+			s.text = s.text[end:]
+		} else {
+			s.text = ""
+		}
+	}
+	if s.text == "" {
+		return "", io.ErrUnexpectedEOF
+	}
+	var end = 1
+	switch s.text[0] {
+	case '(', ')':
+
+	case '"':
+		var quoted, err = strconv.QuotedPrefix(s.text)
+		if err != nil {
+			return "", errors.New("mapast: invalid s-expression string")
+		}
+		end = len(quoted)
+
+	default:
+		end = strings.IndexAny(s.text, " \t\r\n();\"")
+		if end < 0 {
+			end = len(s.text)
+		}
+	}
+	var token = s.text[:end]
+	s.text = s.text[end:]
+	return token, nil
+}
+
+// sexpnode returns the node of the name written by sexpname.
+func sexpnode(name string, count uint64) []byte {
+	var variant uint64
+	if colon := strings.IndexByte(name, ':'); colon >= 0 {
+		var n, err = strconv.ParseUint(name[colon+1:], 10, 8)
+		if err != nil {
+			return nil
+		}
+		name, variant = name[:colon], n
+	}
+	var kind, ok = ParseKind(name)
+	if !ok {
+		return nil
+	}
+	var node = kind.Node()
+	if node == nil {
+		return nil
+	}
+	return makenode(kindof(node), uint64(Variant(node))+variant, count)
+}
+
+func readsexp(s *sexpreader, token string, ast map[uint64][]byte, iterator uint64) error {
+	switch {
+	case token == "nil":
+		ast[iterator] = nil
+		return nil
+
+	case strings.HasPrefix(token, "\""):
+		var str, _ = strconv.Unquote(token)
+		if Which([]byte(str)) != nil {
+			return errors.New("mapast: s-expression string starts with NodeTag")
+		}
+		ast[iterator] = []byte(str)
+		return nil
+
+	case token != "(":
+		return errors.New("mapast: unexpected " + strconv.Quote(token) + " in s-expression")
+	}
+	var name, err = s.next()
+	if err != nil {
+		return err
+	}
+	token, err = s.next()
+	if err != nil {
+		return err
+	}
+	var count uint64
+	if n, err := strconv.ParseUint(token, 10, 64); err == nil {
+		count = n
+		if token, err = s.next(); err != nil {
+			return err
+		}
+	}
+	var node = sexpnode(name, count)
+	if node == nil {
+		return errors.New("mapast: invalid s-expression node " + strconv.Quote(name))
+	}
+	ast[iterator] = node
+	for i := uint64(0); token != ")"; i++ {
+		if err := readsexp(s, token, ast, O(iterator)+i); err != nil {
+			return err
+		}
+		if token, err = s.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSexp reads an s-expression written by WriteSexp and stores the subtree
+// into the ast at the iterator position.
+func ReadSexp(r io.Reader, ast map[uint64][]byte, iterator uint64) error {
+	var text, err = io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var s = sexpreader{text: string(text)}
+	token, err := s.next()
+	if err != nil {
+		return err
+	}
+	if err := readsexp(&s, token, ast, iterator); err != nil {
+		return err
+	}
+	if _, err := s.next(); err != io.ErrUnexpectedEOF {
+		return errors.New("mapast: trailing text after s-expression")
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package mapast
+
+// SimplifyRule selects the rewrites performed by Simplify. Rules can be
+// combined using the bitwise or operator.
+type SimplifyRule uint
+
+// SimplifyDoubleNot rewrites !!x and !(!x) to x.
+const SimplifyDoubleNot SimplifyRule = 1
+
+// SimplifyBrackets removes round brackets around identifiers, literals and
+// primary expressions such as calls, selectors or index expressions.
+const SimplifyBrackets SimplifyRule = 2
+
+// SimplifyBoolCompare rewrites comparisons with the true and false identifiers,
+// such as x == true to x, or x == false to !x.
+const SimplifyBoolCompare SimplifyRule = 4
+
+// SimplifyLenZero canonicalizes length tests to len(x) == 0 and len(x) != 0.
+// For example 0 == len(x) and len(x) < 1 both become len(x) == 0, while
+// len(x) > 0 and len(x) >= 1 both become len(x) != 0.
+const SimplifyLenZero SimplifyRule = 8
+
+// SimplifyAll enables all the rules.
+const SimplifyAll = SimplifyDoubleNot | SimplifyBrackets | SimplifyBoolCompare | SimplifyLenZero
+
+// isexpr reports whether the node is an Expression of the given variant and
+// element count.
+func isexpr(node []byte, op byte, l int) bool {
+	return Is(node, Expression) && Variant(node) == op && Count(node) == uint64(l)
+}
+
+// isname reports whether the node at the key is the given identifier, either
+// a plain string or a string wrapped by ExpressionIdentifier.
+func isname(ast map[uint64][]byte, key uint64, name string) bool {
+	if isexpr(ast[key], ExpressionIdentifier, 1) {
+		key = O(key)
+	}
+	return Which(ast[key]) == nil && string(ast[key]) == name
+}
+
+// isprimary reports whether the node at the key never needs to be enclosed in
+// round brackets, being an operand or a primary expression.
+func isprimary(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	if Which(node) == nil {
+		return true
+	}
+	if !Is(node, Expression) {
+		return false
+	}
+	switch Variant(node) {
+	case ExpressionIdentifier, ExpressionBrackets, ExpressionCall, ExpressionCallDotDotDot,
+		ExpressionIndex, ExpressionSlice, ExpressionType:
+		return true
+
+	case ExpressionDot:
+		return Count(node) == 2
+	}
+	return false
+}
+
+// printsstrings reports whether the node prints its string children, so a
+// string does not need to be wrapped by ExpressionIdentifier there.
+func printsstrings(node []byte) bool {
+	return Is(node, Expression) || Is(node, AssignStmt)
+}
+
+// islen reports whether the node at the key is a call of len with one argument.
+func islen(ast map[uint64][]byte, key uint64) bool {
+	return isexpr(ast[key], ExpressionCall, 2) && isname(ast, O(key), "len")
+}
+
+// replace stores the subtree at the key, wrapping strings if the parent does
+// not print them.
+func replace(ast map[uint64][]byte, key uint64, parent uint64, s subtree) {
+	if Which(s.node) == nil && !printsstrings(ast[parent]) {
+		s = subtree{node: ExpressionNode(ExpressionIdentifier, 1), children: []subtree{s}}
+	}
+	cut(ast, key)
+	paste(ast, key, s)
+}
+
+// negate returns the subtree enclosed by the not operator.
+func negate(ast map[uint64][]byte, key uint64) subtree {
+	var s = clone(ast, key)
+	if !isprimary(ast, key) && !isexpr(ast[key], ExpressionNot, 1) {
+		s = subtree{node: ExpressionNode(ExpressionBrackets, 1), children: []subtree{s}}
+	}
+	return subtree{node: ExpressionNode(ExpressionNot, 1), children: []subtree{s}}
+}
+
+// lenzero holds the canonical operator of a comparison of len(x) and a literal
+// given the operator and the literal, when len(x) is the left operand.
+var lenzero = map[byte]map[string]byte{
+	ExpressionEqual:    {"0": ExpressionEqual},
+	ExpressionNotEq:    {"0": ExpressionNotEq},
+	ExpressionLessThan: {"1": ExpressionEqual},
+	ExpressionLessEq:   {"0": ExpressionEqual},
+	ExpressionGrtThan:  {"0": ExpressionNotEq},
+	ExpressionGrtEq:    {"1": ExpressionNotEq},
+}
+
+// mirrored maps comparison operators to the operator used when the operands
+// are swapped.
+var mirrored = map[byte]byte{
+	ExpressionEqual:    ExpressionEqual,
+	ExpressionNotEq:    ExpressionNotEq,
+	ExpressionLessThan: ExpressionGrtThan,
+	ExpressionLessEq:   ExpressionGrtEq,
+	ExpressionGrtThan:  ExpressionLessThan,
+	ExpressionGrtEq:    ExpressionLessEq,
+}
+
+// simplifynode applies a single rewrite to the node. It reports whether the
+// node was changed.
+func simplifynode(ast map[uint64][]byte, key uint64, parent uint64, rules SimplifyRule) bool {
+	var node = ast[key]
+	if rules&SimplifyDoubleNot != 0 && isexpr(node, ExpressionNot, 1) &&
+		isexpr(ast[O(key)], ExpressionNot, 1) {
+		replace(ast, key, parent, clone(ast, O(O(key))))
+		return true
+	}
+	if rules&SimplifyDoubleNot != 0 && isexpr(node, ExpressionNot, 1) &&
+		isexpr(ast[O(key)], ExpressionBrackets, 1) && isexpr(ast[O(O(key))], ExpressionNot, 1) {
+		replace(ast, key, parent, clone(ast, O(O(O(key)))))
+		return true
+	}
+	if rules&SimplifyBrackets != 0 && isexpr(node, ExpressionBrackets, 1) && isprimary(ast, O(key)) {
+		replace(ast, key, parent, clone(ast, O(key)))
+		return true
+	}
+	var equal = isexpr(node, ExpressionEqual, 2)
+	var noteq = isexpr(node, ExpressionNotEq, 2)
+	if rules&SimplifyBoolCompare != 0 && (equal || noteq) {
+		for side := uint64(0); side < 2; side++ {
+			var other = O(key) + 1 - side
+			if isname(ast, O(key)+side, "true") {
+				if equal {
+					replace(ast, key, parent, clone(ast, other))
+				} else {
+					replace(ast, key, parent, negate(ast, other))
+				}
+				return true
+			}
+			if isname(ast, O(key)+side, "false") {
+				if noteq {
+					replace(ast, key, parent, clone(ast, other))
+				} else {
+					replace(ast, key, parent, negate(ast, other))
+				}
+				return true
+			}
+		}
+	}
+	if rules&SimplifyLenZero != 0 && isexpr(node, Variant(node), 2) {
+		var op = Variant(node)
+		var call, literal = O(key), O(key) + 1
+		if _, ok := mirrored[op]; ok && islen(ast, literal) && !islen(ast, call) {
+			op = mirrored[op]
+			call, literal = literal, call
+		}
+		if canonical, ok := lenzero[op][string(ast[literal])]; ok && islen(ast, call) &&
+			Which(ast[literal]) == nil {
+			if canonical == Variant(node) && call == O(key) && string(ast[literal]) == "0" {
+				return false
+			}
+			var s = clone(ast, call)
+			cut(ast, key)
+			paste(ast, key, subtree{node: ExpressionNode(canonical, 2),
+				children: []subtree{s, {node: []byte("0")}}})
+			return true
+		}
+	}
+	return false
+}
+
+// Simplify rewrites redundant expressions found under the iterator position
+// according to the rules. Parent is the key of the parent of the iterator
+// node. The children are simplified before their parents, and every node is
+// rewritten until no rule applies. Simplify returns the number of rewrites.
+func Simplify(ast map[uint64][]byte, iterator uint64, parent uint64, rules SimplifyRule) (n int) {
+	for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+		n += Simplify(ast, O(iterator)+i, iterator, rules)
+	}
+	for simplifynode(ast, iterator, parent, rules) {
+		n++
+		for i := uint64(0); Poke(ast, O(iterator)+i); i++ {
+			n += Simplify(ast, O(iterator)+i, iterator, rules)
+		}
+	}
+	return n
+}
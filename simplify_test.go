@@ -0,0 +1,84 @@
+package mapast_test
+
+import (
+	"flag"
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update makes the golden file tests write the golden files instead of
+// comparing them, once a change of the printer or of the simplifier was
+// checked.
+var update = flag.Bool("update", false, "write the golden files")
+
+// golden compares the code with the golden file of the name, or writes the
+// file if -update is set.
+func golden(t *testing.T, name string, code string) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(name, []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		t.Fatalf("no golden file %s, run with -update", name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != code {
+		t.Errorf("code differs from %s:\n%s\nwant:\n%s", name, code, want)
+	}
+}
+
+// sprint prints the tree at the key.
+func sprint(ast map[uint64][]byte, key uint64) string {
+	var out strings.Builder
+	mapast.Code(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, ast, key, 0)
+	return out.String()
+}
+
+// TestSimplify simplifies the go files of testdata/simplify with every rule
+// and compares the gofmt formatted result with the golden file of the same
+// name, such as lenzero.go.golden.
+func TestSimplify(t *testing.T) {
+	var names, err = filepath.Glob(filepath.Join("testdata", "simplify", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no files in testdata/simplify")
+	}
+	for _, name := range names {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := convert.Parse(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mapast.Simplify(ast, 0, 0, mapast.SimplifyAll)
+			var code = sprint(ast, 0)
+			formatted, err := format.Source([]byte(code))
+			if err != nil {
+				t.Fatalf("simplified code does not parse: %v\n%s", err, code)
+			}
+			golden(t, name+".golden", string(formatted))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package mapast
+
+import "strings"
+
+// sprint generates go source code from an abstract syntax tree into a string.
+func sprint(ast map[uint64][]byte, iterator uint64, parent uint64) string {
+	var out strings.Builder
+	Code(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, ast, iterator, parent)
+	return out.String()
+}
+
+// SprintCode generates go source code from the tree at the root into a string,
+// such as a whole file given the key of its FileMatter.
+func SprintCode(ast map[uint64][]byte, root uint64) string {
+	return sprint(ast, root, root)
+}
+
+// SprintNode prints just the declaration, statement or expression at the key
+// into a string, without the newlines that separate it from what follows in a
+// file or a block. An import is printed as a single import declaration, even
+// if it is grouped with others.
+func SprintNode(ast map[uint64][]byte, key uint64) string {
+	return strings.TrimRight(sprint(ast, key, key), "\n")
+}
@@ -0,0 +1,70 @@
+package mapast
+
+// TreeStats holds the statistics of a tree, see Stats.
+type TreeStats struct {
+	// Nodes is the number of nodes of the tree, the strings included, and
+	// Kinds is the number of nodes of every kind.
+	Nodes int
+	Kinds map[Kind]int
+	// Strings is the number of string leaves, and StringBytes their total
+	// length.
+	Strings     int
+	StringBytes int
+	// Depth is the number of nodes on the longest path from the root down to
+	// a leaf, like Depth returns, and FanOut the largest number of children
+	// of a node.
+	Depth  int
+	FanOut int
+	// Keys is the number of keys of the map, including the ones the tree at
+	// the root does not reach, such as other files or removed subtrees.
+	Keys int
+}
+
+// Occupancy returns the part of the keys of the map used by the tree, from
+// zero to one. A low occupancy means the map holds many nodes that are not in
+// the tree.
+func (s TreeStats) Occupancy() float64 {
+	if s.Keys == 0 {
+		return 0
+	}
+	return float64(s.Nodes) / float64(s.Keys)
+}
+
+// Stats returns the statistics of the tree at the root, which are useful to
+// tune the performance of the tools and to check that the conversion of a
+// large corpus looks sane. Like Depth, it uses an explicit stack, so it works
+// on trees of any depth.
+func Stats(ast map[uint64][]byte, root uint64) TreeStats {
+	var s = TreeStats{Kinds: make(map[Kind]int), Keys: len(ast)}
+	if !Poke(ast, root) {
+		return s
+	}
+	type frame struct {
+		key   uint64
+		depth int
+	}
+	var stack = []frame{{root, 1}}
+	for len(stack) > 0 {
+		var f = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		var node = ast[f.key]
+		s.Nodes++
+		s.Kinds[KindOf(node)]++
+		if Which(node) == nil {
+			s.Strings++
+			s.StringBytes += len(node)
+		}
+		if f.depth > s.Depth {
+			s.Depth = f.depth
+		}
+		var n = 0
+		for i := uint64(0); Poke(ast, O(f.key)+i); i++ {
+			stack = append(stack, frame{O(f.key) + i, f.depth + 1})
+			n++
+		}
+		if n > s.FanOut {
+			s.FanOut = n
+		}
+	}
+	return s
+}
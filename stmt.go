@@ -0,0 +1,81 @@
+package mapast
+
+import "errors"
+
+// bodystart returns the position of the first body statement of a BlocOfCode
+// node, which follows the header elements.
+func bodystart(ast map[uint64][]byte, block uint64) (uint64, error) {
+	var node = ast[block]
+	if !Is(node, BlocOfCode) {
+		return 0, errors.New("mapast: not a BlocOfCode node")
+	}
+	return Count(node), nil
+}
+
+// iselse reports whether the child at the position is a BlocOfCodeIfElse node,
+// which must stay followed by its else branch.
+func iselse(ast map[uint64][]byte, key uint64) bool {
+	var node = ast[key]
+	return Is(node, BlocOfCode) && Variant(node) == BlocOfCodeIfElse
+}
+
+// StmtCount returns the number of body statements of a BlocOfCode node, not
+// counting its header elements.
+func StmtCount(ast map[uint64][]byte, block uint64) uint64 {
+	var start, err = bodystart(ast, block)
+	if err != nil {
+		return 0
+	}
+	var n = childcount(ast, block)
+	if n < start {
+		return 0
+	}
+	return n - start
+}
+
+// InsertStmt inserts the statement into the body of a BlocOfCode node, so that
+// it becomes the body statement at the index. The header elements of the block
+// are not counted by the index. The statement is an ast having its node at key
+// zero. A statement cannot be inserted between an if else block and its else
+// branch.
+func InsertStmt(ast map[uint64][]byte, block uint64, index uint64, stmt map[uint64][]byte) error {
+	var start, err = bodystart(ast, block)
+	if err != nil {
+		return err
+	}
+	if index > StmtCount(ast, block) {
+		return errors.New("mapast: statement index out of range")
+	}
+	if index > 0 && iselse(ast, O(block)+start+index-1) {
+		return errors.New("mapast: cannot separate an if else block from its else branch")
+	}
+	InsertChild(ast, block, start+index, stmt)
+	return nil
+}
+
+// AppendStmt appends the statement to the body of a BlocOfCode node.
+func AppendStmt(ast map[uint64][]byte, block uint64, stmt map[uint64][]byte) error {
+	return InsertStmt(ast, block, StmtCount(ast, block), stmt)
+}
+
+// RemoveStmt removes the body statement at the index of a BlocOfCode node. The
+// header elements of the block are not counted by the index. Removing an if
+// else block removes its whole else chain too. The else branch alone cannot
+// be removed.
+func RemoveStmt(ast map[uint64][]byte, block uint64, index uint64) error {
+	var start, err = bodystart(ast, block)
+	if err != nil {
+		return err
+	}
+	if index >= StmtCount(ast, block) {
+		return errors.New("mapast: statement index out of range")
+	}
+	if index > 0 && iselse(ast, O(block)+start+index-1) {
+		return errors.New("mapast: cannot remove the else branch alone")
+	}
+	for iselse(ast, O(block)+start+index) {
+		RemoveChild(ast, block, start+index)
+	}
+	RemoveChild(ast, block, start+index)
+	return nil
+}
@@ -0,0 +1,223 @@
+package mapast
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// Store is a place holding the nodes of a tree by their keys. Overlay and
+// DiskStore are stores.
+type Store interface {
+	Get(key uint64) ([]byte, bool)
+	Set(key uint64, node []byte)
+	Delete(key uint64)
+}
+
+// storebatch is the number of bytes of records a DiskStore buffers before
+// writing them to the file.
+const storebatch = 1 << 20
+
+// DiskStore is a Store keeping the nodes in an append-only file, so trees
+// much larger than the memory, such as a whole GOPATH, can be analyzed. Only
+// the file offsets of the nodes are kept in memory. Writes are batched; Flush
+// writes the pending ones. The file is a sequence of records made of the
+// uvarint key, a uvarint being zero for a deleted key, one for a nil leaf or
+// the length of the node plus two, and the node.
+type DiskStore struct {
+	file    *os.File
+	size    int64
+	offsets map[uint64]int64
+	lengths map[uint64]int
+	pending map[uint64]storeentry
+	batch   []byte
+	err     error
+}
+
+// storeentry is a change of a DiskStore not written to the file yet.
+type storeentry struct {
+	node []byte
+	ok   bool
+}
+
+// OpenDiskStore opens the store kept in the file at the path, creating it if
+// needed.
+func OpenDiskStore(path string) (*DiskStore, error) {
+	var file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	var s = &DiskStore{file: file, offsets: make(map[uint64]int64), lengths: make(map[uint64]int),
+		pending: make(map[uint64]storeentry)}
+	var r = bufio.NewReader(file)
+	for {
+		var key, err = binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		}
+		length, err2 := binary.ReadUvarint(r)
+		if err != nil || err2 != nil {
+			file.Close()
+			return nil, errors.New("mapast: corrupt store file")
+		}
+		s.size += int64(uvarintlen(key) + uvarintlen(length))
+		if length == 0 {
+			delete(s.offsets, key)
+			delete(s.lengths, key)
+			continue
+		}
+		s.offsets[key], s.lengths[key] = s.size, int(length)-2
+		if length > 1 {
+			if _, err := r.Discard(int(length - 2)); err != nil {
+				file.Close()
+				return nil, errors.New("mapast: corrupt store file")
+			}
+			s.size += int64(length - 2)
+		}
+	}
+	return s, nil
+}
+
+func uvarintlen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// Get returns the node at the key, and whether the key is in the store. A
+// read error makes the key look missing and is reported by Err.
+func (s *DiskStore) Get(key uint64) ([]byte, bool) {
+	if e, ok := s.pending[key]; ok {
+		return e.node, e.ok
+	}
+	var offset, ok = s.offsets[key]
+	if !ok || s.lengths[key] < 0 {
+		return nil, ok
+	}
+	var node = make([]byte, s.lengths[key])
+	if _, err := s.file.ReadAt(node, offset); err != nil {
+		s.fail(err)
+		return nil, false
+	}
+	return node, true
+}
+
+// Poke reports whether the key is in the store.
+func (s *DiskStore) Poke(key uint64) bool {
+	if e, ok := s.pending[key]; ok {
+		return e.ok
+	}
+	var _, ok = s.offsets[key]
+	return ok
+}
+
+func (s *DiskStore) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// record appends the record of the key to the batch.
+func (s *DiskStore) record(key uint64, node []byte, deleted bool) {
+	var length = uint64(0)
+	switch {
+	case deleted:
+
+	case node == nil:
+		length = 1
+
+	default:
+		length = uint64(len(node)) + 2
+	}
+	s.batch = binary.AppendUvarint(s.batch, key)
+	s.batch = binary.AppendUvarint(s.batch, length)
+	s.batch = append(s.batch, node...)
+	if len(s.batch) >= storebatch {
+		s.Flush()
+	}
+}
+
+// Set stores the node at the key.
+func (s *DiskStore) Set(key uint64, node []byte) {
+	s.pending[key] = storeentry{node: node, ok: true}
+	s.record(key, node, false)
+}
+
+// Delete deletes the key from the store.
+func (s *DiskStore) Delete(key uint64) {
+	s.pending[key] = storeentry{}
+	s.record(key, nil, true)
+}
+
+// Flush writes the pending changes to the file.
+func (s *DiskStore) Flush() error {
+	if len(s.batch) == 0 || s.err != nil {
+		return s.err
+	}
+	if _, err := s.file.WriteAt(s.batch, s.size); err != nil {
+		s.fail(err)
+		return err
+	}
+	var offset = s.size
+	for len(s.batch) > 0 {
+		var key, n = binary.Uvarint(s.batch)
+		var length, m = binary.Uvarint(s.batch[n:])
+		offset += int64(n + m)
+		s.batch = s.batch[n+m:]
+		if length == 0 {
+			delete(s.offsets, key)
+			delete(s.lengths, key)
+			continue
+		}
+		s.offsets[key], s.lengths[key] = offset, int(length)-2
+		if length > 1 {
+			offset += int64(length - 2)
+			s.batch = s.batch[length-2:]
+		}
+	}
+	s.size = offset
+	s.pending = make(map[uint64]storeentry)
+	return nil
+}
+
+// Err returns the first error met while reading or writing the file.
+func (s *DiskStore) Err() error {
+	return s.err
+}
+
+// Close flushes the pending changes and closes the file.
+func (s *DiskStore) Close() error {
+	var err = s.Flush()
+	if err2 := s.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// SaveTree stores the subtree at the root of the ast into the store, at the
+// same keys.
+func SaveTree(store Store, ast map[uint64][]byte, root uint64) {
+	Walk(ast, root, func(key, parent uint64, node []byte) bool {
+		store.Set(key, node)
+		return true
+	})
+}
+
+// LoadTree returns a new tree holding the subtree at the root of the store,
+// at the same keys.
+func LoadTree(store Store, root uint64) map[uint64][]byte {
+	var ast = make(map[uint64][]byte)
+	var load func(key uint64) bool
+	load = func(key uint64) bool {
+		var node, ok = store.Get(key)
+		if ok {
+			ast[key] = node
+			for i := uint64(0); load(O(key) + i); i++ {
+			}
+		}
+		return ok
+	}
+	load(root)
+	return ast
+}
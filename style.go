@@ -0,0 +1,451 @@
+package mapast
+
+import "strings"
+
+// BlankLines tells CodeStyled what to do with the empty lines of the code.
+type BlankLines byte
+
+// BlankLinesKeep keeps the empty lines.
+const BlankLinesKeep BlankLines = 0
+
+// BlankLinesCollapse replaces each run of empty lines with a single one.
+const BlankLinesCollapse BlankLines = 1
+
+// BlankLinesRemove removes all the empty lines.
+const BlankLinesRemove BlankLines = 2
+
+// CodeStyle is the layout of the code generated by CodeStyled. The zero
+// CodeStyle generates the same code as Code.
+type CodeStyle struct {
+	// Indent is printed once for each level of nesting at the start of the
+	// lines, for example a tab or four spaces.
+	Indent string
+	// MaxWidth is the width above which the lines are broken after a comma
+	// between brackets, if they can be. Zero means no limit.
+	MaxWidth int
+	// TabWidth is the width of a tab when measuring the lines, eight if zero.
+	TabWidth int
+	// BlankLines is the policy for the empty lines.
+	BlankLines BlankLines
+	// ExpandTabs replaces the tabs outside of string literals with spaces,
+	// up to the next multiple of TabWidth.
+	ExpandTabs bool
+	// BraceSpacing puts a space after the opening brace and before the
+	// closing brace of the braces opened and closed on the same line, such as
+	// the ones of composite literals: T{ A: 1 }.
+	BraceSpacing bool
+	// Align aligns in columns the names, types and tags of struct fields and
+	// the names, types and values of var and const blocks, like gofmt does.
+	// The trees have no comments within such blocks, and lines having one
+	// are not aligned.
+	Align bool
+}
+
+// DefaultCodeStyle returns the style closest to the one of gofmt: lines are
+// indented by tabs, runs of empty lines are collapsed and blocks are aligned.
+func DefaultCodeStyle() CodeStyle {
+	return CodeStyle{Indent: "\t", TabWidth: 8, BlankLines: BlankLinesCollapse, Align: true}
+}
+
+// Byte classes of a line of code, as found by scanline.
+const (
+	styleCode    = 0
+	styleString  = 1
+	styleComment = 2
+)
+
+// scanline classifies the bytes of the line as code, string literals or
+// comments. Raw and block tell whether the line starts inside a raw string
+// literal or a block comment; the returned ones tell whether the next line
+// does.
+func scanline(line string, raw bool, block bool) (class []byte, rawafter bool, blockafter bool) {
+	class = make([]byte, len(line))
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch {
+		case raw:
+			class[i] = styleString
+			raw = line[i] != '`'
+
+		case block:
+			class[i] = styleComment
+			if line[i] == '*' && i+1 < len(line) && line[i+1] == '/' {
+				class[i+1] = styleComment
+				i++
+				block = false
+			}
+
+		case quote != 0:
+			class[i] = styleString
+			if line[i] == '\\' && i+1 < len(line) {
+				class[i+1] = styleString
+				i++
+			} else if line[i] == quote {
+				quote = 0
+			}
+
+		case line[i] == '`':
+			class[i] = styleString
+			raw = true
+
+		case line[i] == '"' || line[i] == '\'':
+			class[i] = styleString
+			quote = line[i]
+
+		case line[i] == '/' && i+1 < len(line) && line[i+1] == '/':
+			for ; i < len(line); i++ {
+				class[i] = styleComment
+			}
+
+		case line[i] == '/' && i+1 < len(line) && line[i+1] == '*':
+			class[i], class[i+1] = styleComment, styleComment
+			i++
+			block = true
+		}
+	}
+	return class, raw, block
+}
+
+// isopen and isclose report whether the byte is an opening or a closing
+// bracket.
+func isopen(b byte) bool {
+	return b == '(' || b == '[' || b == '{'
+}
+
+func isclose(b byte) bool {
+	return b == ')' || b == ']' || b == '}'
+}
+
+// commented reports whether the line of the classes has a comment.
+func commented(class []byte) bool {
+	for _, c := range class {
+		if c == styleComment {
+			return true
+		}
+	}
+	return false
+}
+
+// outdented reports whether the line is a case clause or a label, which are
+// indented one level less than the statements around them.
+func outdented(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if strings.HasPrefix(line, "case ") || strings.HasPrefix(line, "default:") {
+		return true
+	}
+	if !strings.HasSuffix(line, ":") || len(line) < 2 {
+		return false
+	}
+	for i := 0; i < len(line)-1; i++ {
+		if !identbyte(line[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// spacebraces puts the spaces of BraceSpacing into the line.
+func spacebraces(line string, class []byte) (string, []byte) {
+	var after = make([]bool, len(line))
+	var before = make([]bool, len(line))
+	var opened []int
+	for i := 0; i < len(line); i++ {
+		if class[i] != styleCode {
+			continue
+		}
+		switch {
+		case isopen(line[i]):
+			opened = append(opened, i)
+
+		case isclose(line[i]) && len(opened) > 0:
+			var o = opened[len(opened)-1]
+			opened = opened[:len(opened)-1]
+			if line[i] == '}' && line[o] == '{' && i > o+1 {
+				after[o] = line[o+1] != ' '
+				before[i] = line[i-1] != ' '
+			}
+		}
+	}
+	var b strings.Builder
+	var c []byte
+	for i := 0; i < len(line); i++ {
+		if before[i] {
+			b.WriteByte(' ')
+			c = append(c, styleCode)
+		}
+		b.WriteByte(line[i])
+		c = append(c, class[i])
+		if after[i] {
+			b.WriteByte(' ')
+			c = append(c, styleCode)
+		}
+	}
+	return b.String(), c
+}
+
+// expandtabs replaces the tabs of the line outside of string literals with
+// spaces. The line starts at the column.
+func expandtabs(line string, class []byte, column int, tabwidth int) string {
+	if strings.IndexByte(line, '\t') < 0 {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\t' && class[i] != styleString {
+			var n = tabwidth - column%tabwidth
+			b.WriteString(strings.Repeat(" ", n))
+			column += n
+			continue
+		}
+		b.WriteByte(line[i])
+		column++
+	}
+	return b.String()
+}
+
+// width returns the width of the text, counting the tabs up to the next
+// multiple of the tab width.
+func width(text string, tabwidth int) int {
+	var w = 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\t' {
+			w += tabwidth - w%tabwidth
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
+// breakpoint returns the position after the comma at which the line is best
+// broken to fit into the width, or -1 if it cannot be broken. Only the commas
+// between brackets opened on the line are considered.
+func breakpoint(line string, class []byte, indent int, maxwidth int, tabwidth int) int {
+	var depth = 0
+	var best = -1
+	for i := 0; i < len(line); i++ {
+		if class[i] != styleCode {
+			continue
+		}
+		switch {
+		case isopen(line[i]):
+			depth++
+
+		case isclose(line[i]):
+			depth--
+
+		case line[i] == ',' && depth > 0 && i+1 < len(line):
+			if best >= 0 && indent+width(line[:i+1], tabwidth) > maxwidth {
+				return best
+			}
+			best = i + 1
+		}
+	}
+	return best
+}
+
+// styler prints the lines of code in a CodeStyle.
+type styler struct {
+	style CodeStyle
+	print func(string)
+	depth int
+	// levels holds the number of brackets open at every indentation level.
+	levels []int
+	blank  int
+	lines  int
+	raw    bool
+	block  bool
+	last   bool
+	// aligns are the kinds of the blocks by the depth of their lines, and
+	// aligned are the lines of the section being aligned, at aligndepth.
+	aligns     map[int]byte
+	aligned    []alignline
+	aligndepth int
+}
+
+// brackets updates the open brackets by the code of the line. A line adds at
+// most one indentation level however many brackets it opens, and the level is
+// left once all of them are closed. Leading is set if the line starts by
+// closing brackets, so it is dedented by one level, and changed if the line
+// opens or closes brackets of other lines.
+func (s *styler) brackets(text string, class []byte) (leading bool, changed bool) {
+	var opened, touched int
+	var start = true
+	for i := 0; i < len(text); i++ {
+		if class[i] != styleCode {
+			start = false
+			continue
+		}
+		switch {
+		case isopen(text[i]):
+			opened++
+			start = false
+
+		case isclose(text[i]) && opened > 0:
+			opened--
+			start = false
+
+		case isclose(text[i]) && len(s.levels) > 0:
+			leading = leading || start
+			changed = true
+			touched++
+			if s.levels[len(s.levels)-1]--; s.levels[len(s.levels)-1] == 0 {
+				s.levels = s.levels[:len(s.levels)-1]
+				touched = 0
+			}
+
+		default:
+			start = false
+		}
+	}
+	switch {
+	case opened > 0 && touched > 0:
+		s.levels[len(s.levels)-1] += opened
+
+	case opened > 0:
+		s.levels = append(s.levels, opened)
+	}
+	s.depth = len(s.levels)
+	return leading, changed || opened > 0
+}
+
+// line prints the line of code.
+func (s *styler) line(text string) {
+	if s.raw || s.block {
+		var class, raw, block = scanline(text, s.raw, s.block)
+		s.flushsection()
+		s.flushblank()
+		s.emit(text)
+		s.brackets(text, class)
+		s.raw, s.block = raw, block
+		return
+	}
+	text = strings.TrimLeft(text, " \t")
+	if text == "" {
+		s.blank++
+		return
+	}
+	var class, raw, block = scanline(text, false, false)
+	var depth = s.depth
+	var kind = s.aligns[depth]
+	var leading, changed = s.brackets(text, class)
+	if leading {
+		depth--
+	}
+	if outdented(text) {
+		depth--
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	if s.style.BraceSpacing {
+		text, class = spacebraces(text, class)
+	}
+	if s.style.Align && kind != alignNone && !changed && s.blank == 0 && !raw && !block && !s.last &&
+		!outdented(text) && !commented(class) {
+		s.section(kind, depth, text, class)
+		return
+	}
+	s.flushsection()
+	s.flushblank()
+	s.wrap(text, class, depth)
+	if last := len(text) - 1; last >= 0 && class[last] == styleCode && isopen(text[last]) {
+		if s.aligns == nil {
+			s.aligns = make(map[int]byte)
+		}
+		s.aligns[s.depth] = alignkind(text)
+	}
+	s.raw, s.block = raw, block
+}
+
+// wrap prints the line indented to the depth, broken into several lines if it
+// is wider than the MaxWidth.
+func (s *styler) wrap(text string, class []byte, depth int) {
+	var tabwidth = s.style.TabWidth
+	if tabwidth <= 0 {
+		tabwidth = 8
+	}
+	var continuation = depth + 1
+	for {
+		var indent = strings.Repeat(s.style.Indent, depth)
+		var indentwidth = width(indent, tabwidth)
+		var at = -1
+		if s.style.MaxWidth > 0 && indentwidth+width(text, tabwidth) > s.style.MaxWidth {
+			at = breakpoint(text, class, indentwidth, s.style.MaxWidth, tabwidth)
+		}
+		var head, headclass = text, class
+		if at >= 0 {
+			head, headclass = text[:at], class[:at]
+		}
+		if s.style.ExpandTabs {
+			indent = strings.Repeat(" ", indentwidth)
+			head = expandtabs(head, headclass, indentwidth, tabwidth)
+		}
+		s.emit(indent + head)
+		if at < 0 {
+			return
+		}
+		for at < len(text) && text[at] == ' ' {
+			at++
+		}
+		text, class = text[at:], class[at:]
+		depth = continuation
+	}
+}
+
+// flushblank prints the empty lines preceding a line according to the policy.
+func (s *styler) flushblank() {
+	var n = s.blank
+	switch s.style.BlankLines {
+	case BlankLinesCollapse:
+		if n > 1 {
+			n = 1
+		}
+
+	case BlankLinesRemove:
+		n = 0
+	}
+	if s.lines == 0 && s.style.BlankLines != BlankLinesKeep {
+		n = 0
+	}
+	for ; n > 0; n-- {
+		s.print("")
+	}
+	s.blank = 0
+}
+
+// emit prints the line and, unless it is the last line of a code not ending
+// with a newline, a newline.
+func (s *styler) emit(text string) {
+	if text != "" {
+		s.print(text)
+	}
+	if !s.last {
+		s.print("")
+	}
+	s.lines++
+}
+
+// CodeStyled generates go source code from an abstract syntax tree like Code
+// does, laid out in the style: the lines are indented by their nesting, the
+// empty lines follow the blank line policy, and the lines too wide are broken
+// after commas.
+func CodeStyled(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64, style CodeStyle) {
+	var code = sprint(ast, iterator, parent)
+	var s = &styler{style: style, print: print}
+	var start = 0
+	for i := 0; i < len(code); i++ {
+		if code[i] == '\n' {
+			s.line(code[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(code) {
+		s.last = true
+		s.line(code[start:])
+	}
+	s.flushsection()
+	if style.BlankLines == BlankLinesKeep {
+		s.flushblank()
+	}
+}
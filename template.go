@@ -0,0 +1,151 @@
+package mapast
+
+import "errors"
+
+// TemplateVar returns the string leaf standing for the named variable of a
+// template, such as $recv. The leaf is not a valid identifier, so it cannot
+// be confused with the code around it.
+func TemplateVar(name string) []byte {
+	return []byte("$" + name)
+}
+
+// istemplatevar reports whether the node is a template variable leaf.
+func istemplatevar(node []byte) bool {
+	return Which(node) == nil && len(node) > 1 && node[0] == '$'
+}
+
+// TemplateVars returns the keys of the template variable leaves under the
+// root, by the names of the variables.
+func TemplateVars(template map[uint64][]byte, root uint64) map[string][]uint64 {
+	var vars = make(map[string][]uint64)
+	Walk(template, root, func(key, parent uint64, node []byte) bool {
+		if istemplatevar(node) {
+			vars[string(node[1:])] = append(vars[string(node[1:])], key)
+		}
+		return true
+	})
+	return vars
+}
+
+// wrapper reports whether the parent of a template variable only wraps it,
+// being an ExpressionIdentifier or the ExpressionBrackets of an expression
+// statement.
+func wrapper(ast map[uint64][]byte, parent uint64, grandparent uint64) bool {
+	var node = ast[parent]
+	if !Is(node, Expression) {
+		return false
+	}
+	switch Variant(node) {
+	case ExpressionIdentifier:
+		return true
+
+	case ExpressionBrackets:
+		return Is(ast[grandparent], BlocOfCode)
+	}
+	return false
+}
+
+// Instantiate copies the template, having its node at key zero, to the
+// destination key of the ast, and replaces its variables by their bindings.
+// Every variable is bound to a list of subtrees, each an ast having its node
+// at key zero. A single subtree replaces the variable, while any other number
+// of subtrees is spliced into the parent of the variable, such as the
+// statements of a $body. A variable wrapped by ExpressionIdentifier, or
+// standing alone as an expression statement, is replaced together with the
+// wrapper, unless the subtree is a string. Every
+// variable of the template must be bound.
+func Instantiate(template map[uint64][]byte, bindings map[string][]map[uint64][]byte, ast map[uint64][]byte, dstKey uint64) error {
+	for name := range TemplateVars(template, 0) {
+		if _, ok := bindings[name]; !ok {
+			return errors.New("mapast: template variable $" + name + " is not bound")
+		}
+	}
+	var root = template[0]
+	if Is(root, Expression) && Variant(root) == ExpressionIdentifier {
+		root = template[O(0)]
+	}
+	if istemplatevar(root) && len(bindings[string(root[1:])]) != 1 {
+		return errors.New("mapast: template root must be bound to a single subtree")
+	}
+	CopySubtree(template, 0, ast, dstKey)
+	var vars, parents []uint64
+	Walk(ast, dstKey, func(key, parent uint64, node []byte) bool {
+		if istemplatevar(node) {
+			vars, parents = append(vars, key), append(parents, parent)
+		}
+		return true
+	})
+	var index = BuildParentIndex(ast, dstKey)
+	for i := len(vars) - 1; i >= 0; i-- {
+		var subtrees = bindings[string(ast[vars[i]][1:])]
+		var key, parent = vars[i], parents[i]
+		var wrapped = key != dstKey && wrapper(ast, parent, index[parent])
+		if len(subtrees) == 1 && wrapped && Which(subtrees[0][0]) == nil {
+			CopySubtree(subtrees[0], 0, ast, key)
+			continue
+		}
+		if wrapped {
+			key = parent
+			parent = index[parent]
+		}
+		if len(subtrees) == 1 {
+			CopySubtree(subtrees[0], 0, ast, key)
+			continue
+		}
+		RemoveChild(ast, parent, key-O(parent))
+		InsertChild(ast, parent, key-O(parent), subtrees...)
+	}
+	return nil
+}
+
+// Match reports whether the subtree of the ast at the key matches the
+// template at the root, and returns the bindings of its variables, the keys
+// of the subtrees of the ast they stand for. A variable matches any single
+// subtree, but a variable used twice must match identical subtrees. The
+// variable $_ matches anything and is not bound. A variable wrapped by
+// ExpressionIdentifier, or standing alone as an expression statement, matches
+// any subtree in the place of the wrapper. The lists of statements spliced by
+// Instantiate are not matched.
+func Match(template map[uint64][]byte, root uint64, ast map[uint64][]byte, key uint64) (map[string]uint64, bool) {
+	var bindings = make(map[string]uint64)
+	if !match(template, root, root, ast, key, bindings) {
+		return nil, false
+	}
+	return bindings, true
+}
+
+// match matches the template node at the key t, whose parent is at the key
+// parent, to the ast node at the key, adding the bindings.
+func match(template map[uint64][]byte, t uint64, parent uint64, ast map[uint64][]byte, key uint64,
+	bindings map[string]uint64) bool {
+	var node = template[t]
+	if wrapper(template, t, parent) && Count(node) == 1 && istemplatevar(template[O(t)]) {
+		node = template[O(t)]
+	}
+	if istemplatevar(node) {
+		var name = string(node[1:])
+		if name == "_" {
+			return true
+		}
+		// An identifier is bound without its wrapper, so that it is the same
+		// wherever it occurs.
+		if isexpr(ast[key], ExpressionIdentifier, 1) && Which(ast[O(key)]) == nil {
+			key = O(key)
+		}
+		if bound, ok := bindings[name]; ok {
+			return Hash(ast, bound) == Hash(ast, key)
+		}
+		bindings[name] = key
+		return true
+	}
+	if string(node) != string(ast[key]) {
+		return false
+	}
+	var i uint64
+	for ; Poke(template, O(t)+i); i++ {
+		if !Poke(ast, O(key)+i) || !match(template, O(t)+i, t, ast, O(key)+i, bindings) {
+			return false
+		}
+	}
+	return !Poke(ast, O(key)+i)
+}
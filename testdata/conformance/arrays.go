@@ -0,0 +1,11 @@
+package p
+
+const n = 4
+
+var (
+	fixed    [4]byte
+	computed [n * 2][3]int
+	literal  = [...]string{"a", "b"}
+	indexed  = [...]int{5: 1, 9: 2}
+	slice    []int
+)
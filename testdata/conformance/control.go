@@ -0,0 +1,24 @@
+package p
+
+func control(s []int, m map[string]int) (n int) {
+outer:
+	for i := 0; i < len(s); i++ {
+		for k, v := range m {
+			if v == s[i] {
+				n += len(k)
+				continue outer
+			}
+		}
+		switch {
+		case s[i] < 0:
+			break outer
+		case s[i] == 0:
+			fallthrough
+		default:
+			n++
+		}
+	}
+	defer func() { n *= 2 }()
+	go func(x int) {}(n)
+	return
+}
@@ -0,0 +1,18 @@
+package p
+
+import (
+	"bytes"
+	"io"
+)
+
+type Embedded struct {
+	io.Reader
+	*bytes.Buffer
+	Plain
+	*Pointer `json:"pointer"`
+	name     string
+}
+
+type Plain struct{}
+
+type Pointer struct{}
@@ -0,0 +1,13 @@
+package p
+
+var (
+	empty    func()
+	handlers map[string]func(int) error
+	named    func(a, b int) (c int, err error)
+	variadic func(format string, args ...interface{})
+	literal  = func(x int) int { return x }
+)
+
+type Predicate func(...int) bool
+
+func apply(f func(int) int, x int) int { return f(x) }
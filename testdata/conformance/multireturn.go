@@ -0,0 +1,26 @@
+package p
+
+func multireturn(m map[int]int, k int, x interface{}, ch chan int) (int, error) {
+	if v, ok := m[k]; ok {
+		return v, nil
+	}
+	if v, ok := x.(int); ok {
+		return v, nil
+	}
+	var a, b int = pair()
+	switch v, err := pair(); v {
+	case a, b:
+		return v, nil
+	default:
+		_ = err
+	}
+	select {
+	case v, ok := <-ch:
+		if ok {
+			return v, nil
+		}
+	}
+	return 0, nil
+}
+
+func pair() (int, int) { return 1, 2 }
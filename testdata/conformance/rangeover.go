@@ -0,0 +1,20 @@
+package p
+
+func rangeover(seq func(func(int) bool), seq2 func(func(int, string) bool)) (n int) {
+	for i := range 10 {
+		n += i
+	}
+	for range 3 {
+		n++
+	}
+	for v := range seq {
+		n += v
+	}
+	for k, v := range seq2 {
+		n += k + len(v)
+	}
+	var i int
+	for i = range n {
+	}
+	return n + i
+}
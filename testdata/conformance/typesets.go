@@ -0,0 +1,17 @@
+package p
+
+import "fmt"
+
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+type Number interface {
+	Integer | ~float32 | ~float64
+	fmt.Stringer
+}
+
+type Exact interface {
+	int | string
+	comparable
+}
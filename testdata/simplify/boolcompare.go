@@ -0,0 +1,8 @@
+package p
+
+func f(a, b bool) bool {
+	if a == true || b != true {
+		return a == false
+	}
+	return true == b && false != a
+}
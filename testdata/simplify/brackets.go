@@ -0,0 +1,6 @@
+package p
+
+func f(a, b int, s []int, g func() int) int {
+	var c = (a) + (s[0]) + (g()) + (1)
+	return (a + b) * (c)
+}
@@ -0,0 +1,8 @@
+package p
+
+func f(a, b bool) bool {
+	if !!a {
+		return !(!b)
+	}
+	return !!!a
+}
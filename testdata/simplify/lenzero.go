@@ -0,0 +1,8 @@
+package p
+
+func f(s []int, m map[int]int) bool {
+	if 0 == len(s) || len(m) < 1 {
+		return len(s) > 0
+	}
+	return len(m) >= 1 || 0 != len(s) || len(s) == 0
+}
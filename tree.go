@@ -5,168 +5,287 @@ package mapast
 // nodes have.
 const MaxSubnodes = 1000000
 
-var storage = make([]byte, MaxSubnodes)
+// NodeTag is the first byte of every node. A node is a short header made of
+// NodeTag, the kind number, the variant byte and the element count encoded as
+// an unsigned varint. Anything else is a string. Strings must not start with
+// NodeTag, which never starts valid UTF-8 text. Nodes do not depend on the
+// memory they are stored in, so they survive copying and serialization.
+const NodeTag byte = 0xff
+
+// The kind numbers stored in the node headers.
+const (
+	kindRootMatter = iota
+	kindFileMatter
+	kindPackageDef
+	kindImportStmt
+	kindImportsDef
+	kindTypedIdent
+	kindRootOfType
+	kindTypDefStmt
+	kindStructType
+	kindBranchStmt
+	kindGoDferStmt
+	kindReturnStmt
+	kindIncDecStmt
+	kindVarDefStmt
+	kindLblGotoCnt
+	kindIfceTypExp
+	kindCommentRow
+	kindGenericExp
+	kindExpression
+	kindBlocOfCode
+	kindToplevFunc
+	kindAssignStmt
+	kindClosureExp
+	kindIfceMethod
+	kindPlaceholder
+	kindTotalCount
+)
+
+// header builds a node of the kind number with the variant and element count.
+func header(kind byte, variant byte, count uint64) []byte {
+	var node = []byte{NodeTag, kind, variant}
+	for count >= 0x80 {
+		node = append(node, byte(count)|0x80)
+		count >>= 7
+	}
+	return append(node, byte(count))
+}
 
 // RootMatter node is equivalent to a package block or an universe block
 // containing all Go source text contained within the map. There is only one
 // RootMatter located at key zero (0).
-var RootMatter = []byte("RootMatter")
+var RootMatter = header(kindRootMatter, 0, 0)
 
 // FileMatter contains the file block elements. It represents a single go file.
-// FileMatter node is a child of RootMatter node.
-var FileMatter = []byte("FileMatter")
+// FileMatter node is a child of RootMatter node. Its variant is
+// FileMatterUnknownEOF, since it does not know how the file ended; see
+// FileMatterNode.
+var FileMatter = header(kindFileMatter, FileMatterUnknownEOF, 0)
 
 // PackageDef is a child of FileMatter. Contains a string holding the file
 // package name, and an optional CommentRow containing the import comment.
-var PackageDef = []byte("PackageDef")
+var PackageDef = header(kindPackageDef, 0, 0)
 
 // ImportStmt holds a single import declaration. It is a child of ImportsDef or
 // of a FileMatter. If the parent is FileMatter, it is a standalone import
 // declaration. ImportStmt contains one or two strings.
-var ImportStmt = []byte("ImportStmt")
+var ImportStmt = header(kindImportStmt, 0, 0)
 
 // ImportsDef is a bracketed container for multiple ImportStmt nodes.
 // It is a child of FileMatter.
-var ImportsDef = []byte("ImportsDef")
+var ImportsDef = header(kindImportsDef, 0, 0)
 
 // TypedIdent field contains several string identifiers known as names, followed
 // by a RootOfType node representing the type shared by the named identifiers.
 // If contained within a StructType, it can be optionally tagged using the last
-// string child (the tag).
-var TypedIdent = []byte("TypedIdent")
+// string child (the tag). A TypedIdent of a StructType without names is an
+// embedded field.
+var TypedIdent = header(kindTypedIdent, 0, 0)
 
 // RootOfType marks the root of the type expression tree. It's only child is
 // usually an Expression.
-var RootOfType = []byte("RootOfType")
+var RootOfType = header(kindRootOfType, 0, 0)
 
 // TypDefStmt type declaration allows to declare an alias or a type. Bracketed
 // form is currently not available.
-var TypDefStmt = []byte("TypDefStmt")
+var TypDefStmt = header(kindTypDefStmt, 0, 0)
 
 // StructType is a sequence of named elements, called fields. Some fields can
-// share their type, using a TypedIdent node.
-var StructType = []byte("StructType")
+// share their type, using a TypedIdent node. Embedded fields are TypedIdent
+// nodes having no names.
+var StructType = header(kindStructType, 0, 0)
 
 // BranchStmt is a sole statement. One of semicolon, break, continue,
 // fallthrough, goto. It has no children.
-var BranchStmt = []byte("BranchStmt")
+var BranchStmt = header(kindBranchStmt, 0, 0)
 
 // GoDferStmt node is a statement node used to invoke a call Expression using
 // the go or defer keyword. It's only child is the Expression to be invoked.
-var GoDferStmt = []byte("GoDferStmt")
+var GoDferStmt = header(kindGoDferStmt, 0, 0)
 
 // ReturnStmt node is a return statement followed by it's children. Strings are
 // not allowed, a string child must be wrapped by ExpressionIdentifier.
-var ReturnStmt = []byte("ReturnStmt")
+var ReturnStmt = header(kindReturnStmt, 0, 0)
 
 // IncDecStmt is an increment++ or decrement-- statement. It's only child is
 // a string identifier or a more complex Expression.
-var IncDecStmt = []byte("IncDecStmt")
+var IncDecStmt = header(kindIncDecStmt, 0, 0)
 
 // VarDefStmt is a standalone or a multiple variable or constant declaration.
 // It's children are single or multiple AssignStmt nodes, one for each row.
-var VarDefStmt = []byte("VarDefStmt")
+var VarDefStmt = header(kindVarDefStmt, 0, 0)
 
 // LblGotoCnt is a labeled statement, or a statement that uses label: break,
 // continue or goto statement. It has one child, the string known as label name.
-var LblGotoCnt = []byte("LblGotoCnt")
+var LblGotoCnt = header(kindLblGotoCnt, 0, 0)
 
-// IfceTypExp node contains one or several IfceMethod or RootOfType nodes.
-var IfceTypExp = []byte("IfceTypExp")
+// IfceTypExp node contains one or several IfceMethod or RootOfType nodes. A
+// RootOfType is an embedded interface or a type set element, such as a union
+// ~int | ~string.
+var IfceTypExp = header(kindIfceTypExp, 0, 0)
 
 // CommentRow contains exactly one string, holding the comment verbatim, with
 // optional leading or trailing newlines.
-var CommentRow = []byte("CommentRow")
+var CommentRow = header(kindCommentRow, 0, 0)
 
 // GenericExp is a generic type node. This node is reserved for future use.
-var GenericExp = []byte("GenericExp")
+var GenericExp = header(kindGenericExp, 0, 0)
 
 // Expression node is one of the 38 differend kinds of Expression. It contains
 // strings, IfceTypExps, ClosureExps, StructTypes or more Expressions.
-var Expression = storage[0:]
+var Expression = header(kindExpression, 0, 0)
 
 // BlocOfCode node holds statements or other BlocOfCode nodes. Some BlocOfCode
 // kinds have a header followed by the opening brace. Other BlocOfCode kinds lack
 // braces altogether and use colon instead.
-var BlocOfCode = storage[16:]
+var BlocOfCode = header(kindBlocOfCode, 0, 0)
 
 // ToplevFunc is a child function of FileMatter. The first child is a string.
 // The rest of children can be TypedIdent nodes. The trailing child is
 // an optional BlocOfCode.
-var ToplevFunc = storage[32:]
+var ToplevFunc = header(kindToplevFunc, 0, 0)
 
 // AssignStmt contains left hand side entries followed by an optional RootOfType
 // and implicit equality kind operator, followed by a right hand side entries.
-var AssignStmt = storage[48:]
+var AssignStmt = header(kindAssignStmt, 0, 0)
 
-// ClosureExp is a function literal. The children are TypedIdent nodes.
-var ClosureExp = storage[64:]
+// ClosureExp is a function literal. The children are TypedIdent nodes, the
+// parameters followed by the results, and the BlocOfCode body. A ClosureExp
+// without the body is a function type, such as the type of var f func(int).
+var ClosureExp = header(kindClosureExp, 0, 0)
 
 // IfceMethod node is a child of IfceTypExp. It's children are TypedIdent nodes.
 // The name of interface method is stored in the first TypedIdent child, the one
 // that would otherwise work as a receiver field.
-var IfceMethod = storage[80:]
+var IfceMethod = header(kindIfceMethod, 0, 0)
+
+// Placeholder node stands for a region of source code that could not be
+// parsed, such as a broken statement or expression. It contains exactly one
+// string holding the region verbatim, which is printed back as it is, so that
+// partially broken files still round-trip.
+var Placeholder = header(kindPlaceholder, 0, 0)
+
+// Is reports whether the node is of the given kind, such as Expression or
+// BlocOfCode. It returns false for strings.
+func Is(node []byte, kind []byte) bool {
+	return len(node) > 2 && len(kind) > 2 && node[0] == NodeTag && node[1] == kind[1]
+}
+
+// Variant returns the variant byte of the node, such as ExpressionCall for a
+// call Expression. ToplevFunc nodes have variant 1 if they have a receiver.
+// FileMatter nodes have the number of trailing newlines as the variant. It
+// returns zero for strings.
+func Variant(node []byte) byte {
+	if Which(node) == nil {
+		return 0
+	}
+	return node[2]
+}
+
+// Count returns the element count of the node: the number of children of an
+// Expression or AssignStmt, the number of header elements of a BlocOfCode, the
+// number of arguments of a ToplevFunc, or the number of parameters of
+// a ClosureExp or IfceMethod. It returns zero for the other nodes and strings.
+func Count(node []byte) (count uint64) {
+	if Which(node) == nil {
+		return 0
+	}
+	for i, shift := 3, uint(0); i < len(node) && shift < 64; i, shift = i+1, shift+7 {
+		count |= uint64(node[i]&0x7f) << shift
+		if node[i] < 0x80 {
+			break
+		}
+	}
+	return count
+}
 
 // Constructor for ToplevFunc node. Argc is the count of proper arguments
 // excluding results and receiver.
 func ToplevFuncNode(receiver bool, argc uint64) []byte {
-	var recv = 0
+	var recv byte = 0
 	if receiver {
 		recv = 1
 	}
-	return ToplevFunc[:recv+1 : int(argc)+recv+1]
+	return header(kindToplevFunc, recv, argc)
 }
 
 // Constructor for BlocOfCode node. Headelemscount is the count of header
 // elements including semicolons.
 func BlocOfCodeNode(kind byte, headelemscount uint64) []byte {
-	return BlocOfCode[:int(kind)+1 : int(headelemscount)+int(BlocOfCodeTotalCount)]
+	return header(kindBlocOfCode, kind, headelemscount)
 }
 
 // Constructor for Expression node. Elemscount is the number of children elements.
 func ExpressionNode(kind byte, elemscount uint64) []byte {
-	return Expression[:int(kind)+1 : int(elemscount+uint64(ExpressionTotalCount))]
+	return header(kindExpression, kind, elemscount)
 }
 
 // Constructor for BranchStmt node.
 func BranchStmtNode(kind byte) []byte {
-	return BranchStmt[:int(kind)+1]
+	return header(kindBranchStmt, kind, 0)
 }
 
 // Constructor for IncDecStmt node.
 func IncDecStmtNode(kind byte) []byte {
-	return IncDecStmt[:int(kind)+1]
+	return header(kindIncDecStmt, kind, 0)
 }
 
 // Constructor for AssignStmt node. Elemscount is the number of elements
 // including both side elements and the central type node (if any).
 func AssignStmtNode(kind byte, elemscount uint64) []byte {
-	return AssignStmt[:int(kind)+1 : int(elemscount+uint64(AssignStmtTotalCount))]
+	return header(kindAssignStmt, kind, elemscount)
 }
 
 // Constructor for ClosureExp node. Paramscount is the number of parameters.
 func ClosureExpNode(paramscount uint64) []byte {
-	return ClosureExp[:int(paramscount)+1]
+	return header(kindClosureExp, 0, paramscount)
 }
 
 // Constructor for GoDferStmt node.
 func GoDferStmtNode(kind byte) []byte {
-	return GoDferStmt[:int(kind)+1]
+	return header(kindGoDferStmt, kind, 0)
 }
 
 // Constructor for LblGotoCnt node.
 func LblGotoCntNode(kind byte) []byte {
-	return LblGotoCnt[:int(kind)+1]
+	return header(kindLblGotoCnt, kind, 0)
 }
 
 // Constructor for VarDefStmt node.
 func VarDefStmtNode(kind byte) []byte {
-	return VarDefStmt[:int(kind)+1]
+	return header(kindVarDefStmt, kind, 0)
 }
 
 // Constructor for TypDefStmt node.
 func TypDefStmtNode(kind byte) []byte {
-	return TypDefStmt[:int(kind)+1]
+	return header(kindTypDefStmt, kind, 0)
+}
+
+// Constructor for FileMatter node. Newlines is the number of newlines that
+// ended the source file, or FileMatterUnknownEOF.
+func FileMatterNode(newlines byte) []byte {
+	return header(kindFileMatter, newlines, 0)
+}
+
+// Constructor for PackageDef node.
+func PackageDefNode(kind byte) []byte {
+	return header(kindPackageDef, kind, 0)
+}
+
+// Constructor for TypedIdent node.
+func TypedIdentNode(kind byte) []byte {
+	return header(kindTypedIdent, kind, 0)
+}
+
+// Constructor for CommentRow node.
+func CommentRowNode(kind byte) []byte {
+	return header(kindCommentRow, kind, 0)
+}
+
+// Constructor for IfceMethod node. Paramscount is the number of parameters.
+func IfceMethodNode(paramscount uint64) []byte {
+	return header(kindIfceMethod, 0, paramscount)
 }
 
 // BlocOfCodePlain is a plain code block. Child of BlocOfCode, ToplevFunc or
@@ -185,11 +304,12 @@ const BlocOfCodeIfElse byte = 2
 const BlocOfCodeSwitch byte = 3
 
 // BlocOfCodeFor is "for" or "for something range something" loop. The range
-// (if any) is part of AssignStmt child.
+// (if any) is part of AssignStmt child. The range expression can be of any
+// type go can range over, including integers and iterator functions.
 const BlocOfCodeFor byte = 4
 
 // BlocOfCodeForRange is a for range loop. This loop code begins with "for
-// range".
+// range". It has no iteration variables, as in for range 10.
 const BlocOfCodeForRange byte = 5
 
 // BlocOfCodeTypeSwitch switches using a .(type) header Expression.
@@ -326,7 +446,9 @@ const ExpressionCall byte = 24
 // ExpressionArrow is a send statement or an unary receive operation.
 const ExpressionArrow byte = 25
 
-// ExpressionArrayType is an array type.
+// ExpressionArrayType is an array type. The first child is the length, either
+// an expression or the string ... of a composite literal type whose length is
+// the number of its elements, and the second child is the element type.
 const ExpressionArrayType byte = 26
 
 // ExpressionSliceType is a slice type.
@@ -363,8 +485,13 @@ const ExpressionInChan byte = 36
 // ExpressionOutChan is a channel type with a receive direction arrow operator.
 const ExpressionOutChan byte = 37
 
+// ExpressionTilde is the ~ approximation element of an interface, such as
+// ~int, matching all types whose underlying type is the operand. Unions of
+// interface elements use ExpressionOr.
+const ExpressionTilde byte = 38
+
 // ExpressionTotalCount is a total count sentinel. Do not use.
-const ExpressionTotalCount byte = 38
+const ExpressionTotalCount byte = 39
 
 // IncDecStmtPlusPlus is an IncDec statement. It's the increment ++ statement.
 const IncDecStmtPlusPlus byte = 0
@@ -420,11 +547,16 @@ const AssignStmtIotaIsLast byte = 13
 const AssignStmtTypeIsLast byte = 14
 
 // AssignStmtMoreEqual is an assignment without an assignment operation. It has
-// one expression on the right hand side.
+// several left hand side entries and one expression on the right hand side,
+// such as a call returning several results, or the comma-ok forms of an index
+// ExpressionIndex, a type assertion ExpressionType or a receive
+// ExpressionArrow. In a variable declaration, a RootOfType may follow the left
+// hand side entries.
 const AssignStmtMoreEqual byte = 15
 
-// AssignStmtMoreColonEq is a short variable declaration with one expression on
-// the right hand side.
+// AssignStmtMoreColonEq is a short variable declaration with several left hand
+// side entries and one expression on the right hand side, like
+// AssignStmtMoreEqual.
 const AssignStmtMoreColonEq byte = 16
 
 // AssignStmtMoreEqualRange is an assignment without an assignment operation. It
@@ -492,10 +624,39 @@ const PackageDefNormal byte = 0
 // PackageDefSeparate is a package statement separated by an empty line(s).
 const PackageDefSeparate byte = 1
 
+// FileMatterUnknownEOF is the variant of a FileMatter node when the number of
+// newlines that ended the source file is unknown. Otherwise the variant of
+// a FileMatter node is the number of trailing newlines, up to 8.
+const FileMatterUnknownEOF byte = 9
+
 // O is an one way function. Given a node key it calculates the key of its first
 // child node. The other keys of child nodes follow by adding 1, 2, 3... to
-// the result.
+// the result. O uses the function set by SetKeyFunc, DefaultKeyFunc if none.
 func O(n uint64) uint64 {
+	return keyfunc(n)
+}
+
+// keyfunc is the function used by O.
+var keyfunc = DefaultKeyFunc
+
+// SetKeyFunc makes O use the function and returns the function used before.
+// A nil function restores DefaultKeyFunc. Users needing fewer collisions on
+// very large trees can supply a stronger mix, checking the trees with
+// CheckKeys. The function must be set before any tree is built or read, since
+// the keys of existing trees are no longer found once it changes, and it must
+// not be changed concurrently with the use of any tree.
+func SetKeyFunc(f func(uint64) uint64) func(uint64) uint64 {
+	var old = keyfunc
+	if f == nil {
+		f = DefaultKeyFunc
+	}
+	keyfunc = f
+	return old
+}
+
+// DefaultKeyFunc is the one way function used by O unless SetKeyFunc replaced
+// it.
+func DefaultKeyFunc(n uint64) uint64 {
 	var v = n*3935559000370003845 + 2691343689449507681
 	v ^= v >> 21
 	v ^= v << 37
@@ -513,62 +674,14 @@ func Poke(ast map[uint64][]byte, iterator uint64) bool {
 	return ok
 }
 
-// Which determines which node a given byte slice represents. It resets capacity
-// and the length to the maximum capacity and length. If node is a string, Which
+// Which determines which node a given byte slice represents. It returns the
+// kind variable, such as Expression, of the node. If node is a string, Which
 // returns nil.
 func Which(node []byte) []byte {
-	if node == nil {
+	if len(node) < 4 || node[0] != NodeTag || node[1] >= kindTotalCount {
 		return nil
-	} else if &node[0] == &RootMatter[0] {
-		return RootMatter
-	} else if &node[0] == &FileMatter[0] {
-		return FileMatter
-	} else if &node[0] == &PackageDef[0] {
-		return PackageDef
-	} else if &node[0] == &ImportStmt[0] {
-		return ImportStmt
-	} else if &node[0] == &ImportsDef[0] {
-		return ImportsDef
-	} else if &node[0] == &ToplevFunc[0] {
-		return ToplevFunc
-	} else if &node[0] == &TypedIdent[0] {
-		return TypedIdent
-	} else if &node[0] == &RootOfType[0] {
-		return RootOfType
-	} else if &node[0] == &BlocOfCode[0] {
-		return BlocOfCode
-	} else if &node[0] == &TypDefStmt[0] {
-		return TypDefStmt
-	} else if &node[0] == &StructType[0] {
-		return StructType
-	} else if &node[0] == &BranchStmt[0] {
-		return BranchStmt
-	} else if &node[0] == &GoDferStmt[0] {
-		return GoDferStmt
-	} else if &node[0] == &Expression[0] {
-		return Expression
-	} else if &node[0] == &ReturnStmt[0] {
-		return ReturnStmt
-	} else if &node[0] == &IncDecStmt[0] {
-		return IncDecStmt
-	} else if &node[0] == &AssignStmt[0] {
-		return AssignStmt
-	} else if &node[0] == &VarDefStmt[0] {
-		return VarDefStmt
-	} else if &node[0] == &LblGotoCnt[0] {
-		return LblGotoCnt
-	} else if &node[0] == &ClosureExp[0] {
-		return ClosureExp
-	} else if &node[0] == &IfceTypExp[0] {
-		return IfceTypExp
-	} else if &node[0] == &IfceMethod[0] {
-		return IfceMethod
-	} else if &node[0] == &CommentRow[0] {
-		return CommentRow
-	} else if &node[0] == &GenericExp[0] {
-		return GenericExp
 	}
-	return nil
+	return kinds[node[1]]
 }
 
 // Printer is used to print strings to standard error. Empty strings are printed
@@ -627,33 +740,11 @@ func Dump(print func(string), ast map[uint64][]byte, iterator uint64, pad int) b
 		print("")
 	} else if Which(ast[iterator]) != nil {
 		print(" [")
-		switch len(Which(ast[iterator])) {
-		case 10:
-			print(string(Which(ast[iterator])))
-
-		case MaxSubnodes:
-			print("Expression")
-
-		case MaxSubnodes - 16:
-			print("BlocOfCode")
-
-		case MaxSubnodes - 32:
-			print("ToplevFunc")
-
-		case MaxSubnodes - 48:
-			print("AssignStmt")
-
-		case MaxSubnodes - 64:
-			print("ClosureExp")
-
-		case MaxSubnodes - 80:
-			print("IfceMethod")
-
-		}
+		print(KindOf(ast[iterator]).String())
 		print(" ")
-		print(itoA(len(ast[iterator]) - 1))
+		print(itoA(int(Variant(ast[iterator]))))
 		print(" ")
-		print(itoA(cap(ast[iterator]) - 1))
+		print(itoA(int(Count(ast[iterator]))))
 		print("]")
 		print("")
 	} else {
@@ -674,33 +765,162 @@ func PrintCode(ast map[uint64][]byte, iterator uint64, parent uint64) {
 	Code(Printer, ast, iterator, parent)
 }
 
+// printlines prints the text, which can span several lines.
+func printlines(print func(string), text string) {
+	var start = 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] != '\n' {
+			continue
+		}
+		if i > start {
+			print(text[start:i])
+		}
+		if i < len(text) {
+			print("")
+		}
+		start = i + 1
+	}
+}
+
 // Code generates go source code from an abstract syntax tree.
 func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uint64) {
-	const uint64big = ^uint64(0) - 1
-	var ast_o_iterator = string(ast[O(iterator)])
-	if ast[iterator] != nil {
-		switch &(ast[iterator])[0] {
-		case &CommentRow[0]:
-			if len(ast[(iterator)])-1 == int(CommentRowSeparate) {
+	code(print, nil, ast, iterator, parent)
+}
+
+// uint64big is the child index code uses for the step after the last child.
+const uint64big = ^uint64(0) - 1
+
+// codeframe is a node being printed by code, with the index of the child being
+// printed. The node, the key of its first child and the child being printed
+// are kept, since the printer looks them up many times.
+type codeframe struct {
+	key            uint64
+	node           []byte
+	first          uint64
+	i              uint64
+	child          []byte
+	haschild       bool
+	entered        bool
+	ast_o_iterator []byte
+}
+
+// code generates go source code. Enter, if not nil, is called with the key of
+// every node before the node is printed. If enter returns true, it has printed
+// the node and its children itself, so code skips them. The tree is traversed
+// with an explicit stack, so deeply nested trees do not exhaust the goroutine
+// stack.
+func code(print func(string), enter func(uint64) bool, ast map[uint64][]byte, iterator uint64, parent uint64) {
+	codehooks(print, enter, nil, maptree(ast), iterator, parent)
+}
+
+// nodes is a tree the printer reads, such as a map wrapped by maptree or an
+// Overlay, so an overlay is printed without copying it into a map.
+type nodes interface {
+	Get(key uint64) ([]byte, bool)
+}
+
+// maptree is a map read by the printer.
+type maptree map[uint64][]byte
+
+// Get returns the node or the string at the key, and whether the key is in
+// the tree.
+func (t maptree) Get(key uint64) ([]byte, bool) {
+	var node, ok = t[key]
+	return node, ok
+}
+
+// nodeat returns the node or the string at the key of the tree, nil if there is
+// none.
+func nodeat(ast nodes, key uint64) []byte {
+	var node, _ = ast.Get(key)
+	return node
+}
+
+// codehooks generates go source code like code does. Leave, if not nil, is
+// called with the key of every node entered after the node is printed.
+func codehooks(print func(string), enter func(uint64) bool, leave func(uint64), ast nodes,
+	iterator uint64, parent uint64) {
+	var stack []codeframe
+	var open = func(iterator uint64, node []byte, parentnode []byte) {
+		if enter != nil && enter(iterator) {
+			return
+		}
+		// Strings have no children and are printed by their parents.
+		if node != nil && Which(node) == nil {
+			if leave != nil {
+				leave(iterator)
+			}
+			return
+		}
+		var first = O(iterator)
+		var ast_o_iterator, ok = ast.Get(first)
+		codeopen(print, ast, node, first, parentnode, ast_o_iterator)
+		stack = append(stack, codeframe{key: iterator, node: node, first: first, child: ast_o_iterator, haschild: ok,
+			ast_o_iterator: ast_o_iterator})
+	}
+	open(iterator, nodeat(ast, iterator), nodeat(ast, parent))
+	for len(stack) > 0 {
+		var f = &stack[len(stack)-1]
+		if !f.entered {
+			if f.haschild {
+				f.entered = true
+				open(f.first+f.i, f.child, f.node)
+				continue
+			}
+			// No node follows the last child, so the step after it has no
+			// child and no next node to look up.
+			f.i, f.child = uint64big, nil
+		}
+		var next []byte
+		var ok bool
+		if f.i != uint64big {
+			next, ok = ast.Get(f.first + f.i + 1)
+		}
+		codeclose(print, ast, f.node, f.first, f.i, f.child, next, f.ast_o_iterator)
+		f.entered = false
+		if f.i == uint64big {
+			var key = f.key
+			stack = stack[:len(stack)-1]
+			if leave != nil {
+				leave(key)
+			}
+		} else {
+			f.i, f.child, f.haschild = f.i+1, next, ok
+		}
+	}
+}
+
+// codeopen prints the part of the node preceding its first child.
+func codeopen(print func(string), ast nodes, node []byte, first uint64, parentnode []byte, ast_o_iterator []byte) {
+	if node != nil {
+		switch kindof(node) {
+		case kindCommentRow:
+			if Variant(node) == CommentRowSeparate {
 				print("")
 			}
-			print(ast_o_iterator)
+			printlines(print, string(ast_o_iterator))
 
-		case &PackageDef[0]:
-			if len(ast[(iterator)])-1 == int(PackageDefSeparate) {
+		case kindPlaceholder:
+			printlines(print, string(ast_o_iterator))
+			if Is(parentnode, FileMatter) {
+				print("")
+			}
+
+		case kindPackageDef:
+			if Variant(node) == PackageDefSeparate {
 				print("")
 			}
 			print("package ")
-			print(ast_o_iterator)
+			print(string(ast_o_iterator))
 
-		case &ImportStmt[0]:
-			var defparent = ast[(parent)] != nil && &ast[(parent)][0] == &ImportsDef[0]
+		case kindImportStmt:
+			var defparent = parentnode != nil && Is(parentnode, ImportsDef)
 			if defparent {
 			} else {
 				print("import ")
 			}
-			print(ast_o_iterator)
-			another := string(ast[O(iterator)+1])
+			print(string(ast_o_iterator))
+			another := string(nodeat(ast, first+1))
 			if len(another) > 0 {
 				print(" ")
 				print(another)
@@ -709,18 +929,18 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 				print("")
 			}
 
-		case &ImportsDef[0]:
+		case kindImportsDef:
 			print("import (")
 			print("")
 
-		case &ToplevFunc[0]:
+		case kindToplevFunc:
 			print("func ")
-			if len(ast[(iterator)]) == 2 {
+			if Variant(node) == 1 {
 				print("(")
 			}
 
-		case &BlocOfCode[0]:
-			switch byte(len(ast[(iterator)]) - 1) {
+		case kindBlocOfCode:
+			switch Variant(node) {
 			case BlocOfCodePlain:
 
 			case BlocOfCodeIf:
@@ -760,38 +980,38 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 			case BlocOfCodeNone:
 
 			}
-			if cap(ast[(iterator)]) == int(BlocOfCodeTotalCount) {
-				if len(ast[(iterator)])-1 < int(BlocOfCodeCase) {
+			if Count(node) == 0 {
+				if Variant(node) < BlocOfCodeCase {
 					print("{")
 					print("")
 				}
 			}
 
-		case &RootOfType[0]:
+		case kindRootOfType:
 
-		case &TypDefStmt[0]:
+		case kindTypDefStmt:
 			print("type ")
-			print(ast_o_iterator)
+			print(string(ast_o_iterator))
 			print(" ")
-			var op = byte(len(ast[(iterator)]) - 1)
+			var op = Variant(node)
 			if op == TypDefStmtAlias {
 				print("= ")
 			}
 
-		case &StructType[0]:
+		case kindStructType:
 			print("struct{")
-			if ast[O(iterator)] != nil {
+			if nodeat(ast, first) != nil {
 				print("")
 			}
 
-		case &IfceTypExp[0]:
+		case kindIfceTypExp:
 			print("interface{")
-			if ast[O(iterator)] != nil {
+			if nodeat(ast, first) != nil {
 				print("")
 			}
 
-		case &GoDferStmt[0]:
-			switch byte(len(ast[(iterator)]) - 1) {
+		case kindGoDferStmt:
+			switch Variant(node) {
 			case GoDferStmtGo:
 				print("go ")
 
@@ -800,8 +1020,8 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 
 			}
 
-		case &BranchStmt[0]:
-			switch byte(len(ast[(iterator)]) - 1) {
+		case kindBranchStmt:
+			switch Variant(node) {
 			case BranchStmtSemi:
 				print(";")
 
@@ -819,9 +1039,9 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 
 			}
 
-		case &Expression[0]:
-			var op = byte(len(ast[(iterator)]) - 1)
-			var l = byte(cap(ast[(iterator)]) - int(ExpressionTotalCount))
+		case kindExpression:
+			var op = Variant(node)
+			var l = Count(node)
 			if l == 1 {
 				switch op {
 				case ExpressionBrackets:
@@ -857,6 +1077,9 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 				case ExpressionOutChan:
 					print("<-chan ")
 
+				case ExpressionTilde:
+					print("~")
+
 				case ExpressionComposed:
 					print("{")
 
@@ -881,13 +1104,13 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 				}
 			}
 
-		case &ReturnStmt[0]:
+		case kindReturnStmt:
 			print("return ")
 
-		case &VarDefStmt[0]:
-			var op = byte(len(ast[(iterator)]) - 1)
-			var multi = len(ast[O(iterator)+1]) > 0
-			var none = len(ast[O(iterator)]) == 0
+		case kindVarDefStmt:
+			var op = Variant(node)
+			var multi = len(nodeat(ast, first+1)) > 0
+			var none = len(nodeat(ast, first)) == 0
 			switch op {
 			case VarDefStmtVar:
 				print("var ")
@@ -903,8 +1126,8 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 				print("()")
 			}
 
-		case &LblGotoCnt[0]:
-			var op = byte(len(ast[(iterator)]) - 1)
+		case kindLblGotoCnt:
+			var op = Variant(node)
 			switch op {
 			case LblGotoCntGoto:
 				print("goto ")
@@ -917,20 +1140,20 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 
 			}
 
-		case &ClosureExp[0]:
+		case kindClosureExp:
 			print("func(")
-			var end = ast[O(iterator)] == nil || &ast[O(iterator)][0] == &BlocOfCode[0]
-			var separ = uint64(len(ast[(iterator)]) - 1)
-			if separ == 0 {
+			var end = nodeat(ast, first) == nil || Is(nodeat(ast, first), BlocOfCode)
+			var separ = Count(node)
+			if separ == 0 && !end {
 				print(")(")
 			}
 			if end {
 				print(")")
 			}
 
-		case &TypedIdent[0]:
-			if ast[O(iterator)+1] == nil || &ast[O(iterator)+1][0] != &RootOfType[0] {
-				var op = byte(len(ast[(iterator)]) - 1)
+		case kindTypedIdent:
+			if nodeat(ast, first+1) == nil || !Is(nodeat(ast, first+1), RootOfType) {
+				var op = Variant(node)
 				switch op {
 				case TypedIdentEllipsis:
 					print("...")
@@ -940,543 +1163,604 @@ func Code(print func(string), ast map[uint64][]byte, iterator uint64, parent uin
 
 		}
 	}
-	for i := uint64(0); i < uint64big; i++ {
-		if Poke(ast, O(iterator)+i) {
-			Code(print, ast, O(iterator)+i, iterator)
-		} else {
-			i = uint64big
-		}
-		if ast[iterator] != nil {
-			switch &(ast[iterator])[0] {
-			case &ImportsDef[0]:
-				if i == uint64big {
-					print(")")
-				}
+}
 
-			case &ToplevFunc[0]:
-				var alpha = ast[O(iterator)+i] == nil || &ast[O(iterator)+i][0] == &BlocOfCode[0]
-				var beta = ast[O(iterator)+i+1] == nil || &ast[O(iterator)+i+1][0] == &BlocOfCode[0]
-				var gamma = cap(ast[(iterator)]) == len(ast[(iterator)])
-				var epsil = len(ast[(iterator)])-1 != 0
-				var omega = i != uint64big
-				var theta = i == 0
-				var phi = i == 1
-				var rho = i+1 == uint64(cap(ast[(iterator)]))
-				if alpha {
-				} else if beta {
-					if gamma && omega && epsil == phi && theta != phi {
-						if phi {
-							print(") ")
-						}
-						print(ast_o_iterator)
-						print("()")
-					} else {
-						print(")")
+// codeclose prints the part of the node following its child at the index i,
+// or following its last child if i is uint64big.
+func codeclose(print func(string), ast nodes, node []byte, first uint64, i uint64, child []byte,
+	next []byte, ast_o_iterator []byte) {
+	if node != nil {
+		switch kindof(node) {
+		case kindImportsDef:
+			if i == uint64big {
+				print(")")
+			}
+
+		case kindToplevFunc:
+			var alpha = child == nil || Is(child, BlocOfCode)
+			var beta = next == nil || Is(next, BlocOfCode)
+			var gamma = Count(node) == 0
+			var epsil = Variant(node) != 0
+			var omega = i != uint64big
+			var theta = i == 0
+			var phi = i == 1
+			var rho = i == Count(node)+uint64(Variant(node))
+			if alpha {
+			} else if beta {
+				if gamma && omega && epsil == phi && theta != phi {
+					if phi {
+						print(") ")
 					}
+					print(string(ast_o_iterator))
+					print("()")
 				} else {
-					if !theta {
-						if epsil && phi {
-							print(") ")
-							print(ast_o_iterator)
-							print("(")
-						} else if !rho {
-							print(", ")
-						}
-					} else if !epsil {
-						print(ast_o_iterator)
+					print(")")
+				}
+			} else {
+				if !theta {
+					if epsil && phi {
+						print(") ")
+						print(string(ast_o_iterator))
 						print("(")
+					} else if !rho {
+						print(", ")
 					}
-					if rho {
-						print(")(")
-					}
+				} else if !epsil {
+					print(string(ast_o_iterator))
+					print("(")
 				}
-				if i == uint64big {
-					print("")
+				if rho {
+					print(")(")
 				}
+			}
+			if i == uint64big {
+				print("")
+			}
 
-			case &TypedIdent[0]:
-				if ast[O(iterator)+i] != nil && &ast[O(iterator)+i][0] != &RootOfType[0] {
-					if ast[O(iterator)+i-1] != nil && &ast[O(iterator)+i-1][0] == &RootOfType[0] {
-						print(" ")
-					}
-					print(string(ast[O(iterator)+i]))
-					if ast[O(iterator)+i+1] != nil && &ast[O(iterator)+i+1][0] != &RootOfType[0] {
-						print(", ")
-					} else {
-						var op = byte(len(ast[(iterator)]) - 1)
-						switch op {
-						case TypedIdentTagged:
-							fallthrough
+		case kindTypedIdent:
+			if child != nil && !Is(child, RootOfType) {
+				if nodeat(ast, first+i-1) != nil && Is(nodeat(ast, first+i-1), RootOfType) {
+					print(" ")
+				}
+				print(string(child))
+				if next != nil && !Is(next, RootOfType) {
+					print(", ")
+				} else {
+					var op = Variant(node)
+					switch op {
+					case TypedIdentTagged:
+						fallthrough
 
-						case TypedIdentNormal:
-							print(" ")
+					case TypedIdentNormal:
+						print(" ")
 
-						case TypedIdentEquals:
-							print(" = ")
+					case TypedIdentEquals:
+						print(" = ")
 
-						case TypedIdentEllipsis:
-							print(" ...")
+					case TypedIdentEllipsis:
+						print(" ...")
 
-						}
 					}
 				}
+			}
 
-			case &BlocOfCode[0]:
-				if i != uint64big {
-					if i+uint64(BlocOfCodeTotalCount)+1 == uint64(cap(ast[(iterator)])) {
-						if len(ast[(iterator)])-1 == int(BlocOfCodeCase) {
-							print(":")
-							print("")
-						} else if len(ast[(iterator)])-1 == int(BlocOfCodeCommunicate) {
-							print(":")
-							print("")
-						} else if len(ast[(iterator)])-1 < int(BlocOfCodeCase) {
-							print("{")
-							print("")
-						}
-					} else if i+uint64(BlocOfCodeTotalCount)+1 > uint64(cap(ast[(iterator)])) {
+		case kindBlocOfCode:
+			if i != uint64big {
+				if i+1 == Count(node) {
+					if Variant(node) == BlocOfCodeCase {
+						print(":")
+						print("")
+					} else if Variant(node) == BlocOfCodeCommunicate {
+						print(":")
+						print("")
+					} else if Variant(node) < BlocOfCodeCase {
+						print("{")
 						print("")
-					} else if i+uint64(BlocOfCodeTotalCount)+1 < uint64(cap(ast[(iterator)])) {
-						if len(ast[(iterator)])-1 == int(BlocOfCodeCase) {
-							print(", ")
-						}
-					}
-				} else if i == uint64big {
-					if len(ast[(iterator)])-1 < int(BlocOfCodeCase) {
-						print("}")
 					}
-					if len(ast[(iterator)])-1 == int(BlocOfCodeIfElse) {
-						print(" else")
+				} else if i+1 > Count(node) {
+					print("")
+				} else if i+1 < Count(node) {
+					if Variant(node) == BlocOfCodeCase {
+						print(", ")
 					}
 				}
-
-			case &TypDefStmt[0]:
-				if i == uint64big {
-					print("")
+			} else if i == uint64big {
+				if Variant(node) < BlocOfCodeCase {
+					print("}")
+				}
+				if Variant(node) == BlocOfCodeIfElse {
+					print(" else")
 				}
+			}
 
-			case &IfceTypExp[0]:
-				fallthrough
+		case kindTypDefStmt:
+			if i == uint64big {
+				print("")
+			}
 
-			case &StructType[0]:
-				if i == uint64big {
-					print("}")
-				} else {
-					print("")
-				}
+		case kindIfceTypExp:
+			fallthrough
 
-			case &GoDferStmt[0]:
+		case kindStructType:
+			if i == uint64big {
+				print("}")
+			} else {
+				print("")
+			}
 
-			case &Expression[0]:
-				var caseheader = true
-				var blockheader = true
-				var op = byte(len(ast[(iterator)]) - 1)
-				var l = uint64(cap(ast[(iterator)]) - int(ExpressionTotalCount))
-				if Which(ast[O(iterator)+i]) == nil {
-					if len(ast[O(iterator)+i]) > 0 {
-						print(string(ast[O(iterator)+i]))
-					}
+		case kindGoDferStmt:
+
+		case kindExpression:
+			var caseheader = true
+			var blockheader = true
+			var op = Variant(node)
+			var l = Count(node)
+			if Which(child) == nil {
+				if len(child) > 0 {
+					print(string(child))
 				}
-				if i != uint64big {
-					i := i + 1
-					if ((i >= 1) == (l > 1)) && (i != l) {
-						if i == 0 {
-						} else {
-							switch op {
-							case ExpressionDot:
-								print(".")
+			}
+			if i != uint64big {
+				i := i + 1
+				if ((i >= 1) == (l > 1)) && (i != l) {
+					if i == 0 {
+					} else {
+						switch op {
+						case ExpressionDot:
+							print(".")
 
-							case ExpressionCallDotDotDot:
-								fallthrough
+						case ExpressionCallDotDotDot:
+							fallthrough
 
-							case ExpressionCall:
-								if i == 1 {
-									print("(")
-								} else {
-									print(",")
-								}
+						case ExpressionCall:
+							if i == 1 {
+								print("(")
+							} else {
+								print(",")
+							}
 
-							case ExpressionOrOr:
-								print(" || ")
+						case ExpressionOrOr:
+							print(" || ")
 
-							case ExpressionAndAnd:
-								print(" && ")
+						case ExpressionAndAnd:
+							print(" && ")
 
-							case ExpressionEqual:
-								print(" == ")
+						case ExpressionEqual:
+							print(" == ")
 
-							case ExpressionNotEq:
-								print(" != ")
+						case ExpressionNotEq:
+							print(" != ")
 
-							case ExpressionLessThan:
-								print(" < ")
+						case ExpressionLessThan:
+							print(" < ")
 
-							case ExpressionLessEq:
-								print(" <= ")
+						case ExpressionLessEq:
+							print(" <= ")
 
-							case ExpressionGrtEq:
-								print(" >= ")
+						case ExpressionGrtEq:
+							print(" >= ")
 
-							case ExpressionGrtThan:
-								print(" > ")
+						case ExpressionGrtThan:
+							print(" > ")
 
-							case ExpressionPlus:
-								print(" + ")
+						case ExpressionPlus:
+							print(" + ")
 
-							case ExpressionMinus:
-								print(" - ")
+						case ExpressionMinus:
+							print(" - ")
 
-							case ExpressionOr:
-								print(" | ")
+						case ExpressionOr:
+							print(" | ")
 
-							case ExpressionXor:
-								print(" ^ ")
+						case ExpressionXor:
+							print(" ^ ")
 
-							case ExpressionMul:
-								print(" * ")
+						case ExpressionMul:
+							print(" * ")
 
-							case ExpressionDiv:
-								print(" / ")
+						case ExpressionDiv:
+							print(" / ")
 
-							case ExpressionMod:
-								print(" % ")
+						case ExpressionMod:
+							print(" % ")
 
-							case ExpressionAnd:
-								print(" & ")
+						case ExpressionAnd:
+							print(" & ")
 
-							case ExpressionAndNot:
-								print(" &^ ")
+						case ExpressionAndNot:
+							print(" &^ ")
 
-							case ExpressionLSh:
-								print(" << ")
+						case ExpressionLSh:
+							print(" << ")
 
-							case ExpressionRSh:
-								print(" >> ")
+						case ExpressionRSh:
+							print(" >> ")
 
-							case ExpressionArrow:
-								print(" <- ")
+						case ExpressionArrow:
+							print(" <- ")
 
-							case ExpressionKeyVal:
-								print(":")
+						case ExpressionKeyVal:
+							print(":")
 
-							case ExpressionIndex:
+						case ExpressionIndex:
+							print("[")
+
+						case ExpressionSlice:
+							if i == 1 {
 								print("[")
+							} else {
+								print(":")
+							}
 
-							case ExpressionSlice:
-								if i == 1 {
-									print("[")
-								} else {
-									print(":")
-								}
-
-							case ExpressionComposite:
-								if i == 1 {
-									print("{")
-								} else {
-									print(", ")
-								}
-
-							case ExpressionComposed:
+						case ExpressionComposite:
+							if i == 1 {
+								print("{")
+							} else {
 								print(", ")
+							}
+
+						case ExpressionComposed:
+							print(", ")
 
-							case ExpressionMap:
-								fallthrough
+						case ExpressionMap:
+							fallthrough
 
-							case ExpressionArrayType:
-								print("]")
+						case ExpressionArrayType:
+							print("]")
 
-							case ExpressionType:
-								print(".(")
+						case ExpressionType:
+							print(".(")
 
-							}
 						}
-					} else if (i == 1) && (l == 1) {
-						switch op {
-						case ExpressionBrackets:
-							print(")")
+					}
+				} else if (i == 1) && (l == 1) {
+					switch op {
+					case ExpressionBrackets:
+						print(")")
 
-						case ExpressionCallDotDotDot:
-							fallthrough
+					case ExpressionCallDotDotDot:
+						fallthrough
 
-						case ExpressionCall:
-							print("()")
+					case ExpressionCall:
+						print("()")
 
-						case ExpressionComposed:
-							print("}")
+					case ExpressionComposed:
+						print("}")
 
-						case ExpressionComposite:
-							print("{}")
+					case ExpressionComposite:
+						print("{}")
 
-						case ExpressionType:
-							print(".(type)")
+					case ExpressionType:
+						print(".(type)")
 
-						}
-					} else if (i == l) && (i > 0) {
-						switch op {
-						case ExpressionIndex:
-							print("]")
+					}
+				} else if (i == l) && (i > 0) {
+					switch op {
+					case ExpressionIndex:
+						print("]")
 
-						case ExpressionSlice:
-							if l == 2 {
-								print(":]")
-							} else {
-								print("]")
-							}
+					case ExpressionSlice:
+						if l == 2 {
+							print(":]")
+						} else {
+							print("]")
+						}
 
-						case ExpressionComposed:
-							fallthrough
+					case ExpressionComposed:
+						fallthrough
 
-						case ExpressionComposite:
-							print("}")
+					case ExpressionComposite:
+						print("}")
 
-						case ExpressionCallDotDotDot:
-							print("...)")
+					case ExpressionCallDotDotDot:
+						print("...)")
 
-						case ExpressionCall:
-							print(")")
+					case ExpressionCall:
+						print(")")
 
-						case ExpressionType:
-							print(")")
+					case ExpressionType:
+						print(")")
 
-						}
 					}
-					if i == l {
-						if caseheader {
-						} else if blockheader {
-							print(" ")
-						}
+				}
+				if i == l {
+					if caseheader {
+					} else if blockheader {
+						print(" ")
 					}
 				}
+			}
 
-			case &ReturnStmt[0]:
-				if i != uint64big && ast[O(iterator)+i+1] != nil {
-					print(", ")
-				}
+		case kindReturnStmt:
+			if i != uint64big && next != nil {
+				print(", ")
+			}
 
-			case &IncDecStmt[0]:
-				if i == 0 {
-					if Which(ast[O(iterator)]) == nil {
-						if len(ast_o_iterator) > 0 {
-							print(ast_o_iterator)
-						}
+		case kindIncDecStmt:
+			if i == 0 {
+				if Which(nodeat(ast, first)) == nil {
+					if len(ast_o_iterator) > 0 {
+						print(string(ast_o_iterator))
 					}
 				}
-				if i == uint64big {
-					var op = byte(len(ast[(iterator)]) - 1)
-					switch op {
-					case IncDecStmtPlusPlus:
-						print("++")
+			}
+			if i == uint64big {
+				var op = Variant(node)
+				switch op {
+				case IncDecStmtPlusPlus:
+					print("++")
 
-					case IncDecStmtMinusMinus:
-						print("--")
+				case IncDecStmtMinusMinus:
+					print("--")
 
-					}
 				}
+			}
 
-			case &AssignStmt[0]:
-				var blockheader = true
-				var op = byte(len(ast[(iterator)]) - 1)
-				var l = uint64(cap(ast[(iterator)]) - int(AssignStmtTotalCount))
-				if Which(ast[O(iterator)+i]) == nil {
-					if len(string(ast[O(iterator)+i])) > 0 {
-						print(string(ast[O(iterator)+i]))
-					}
+		case kindAssignStmt:
+			var blockheader = true
+			var op = Variant(node)
+			var l = Count(node)
+			if Which(child) == nil {
+				if len(string(child)) > 0 {
+					print(string(child))
 				}
-				if i != uint64big {
-					i := i + 1
-					if i == l {
-						if blockheader {
-							print(" ")
-						} else {
-							print("")
-						}
-					} else if (i+1 == l) && (op > AssignStmtTypeIsLast) {
-						switch op {
-						case AssignStmtMoreEqual:
-							print(" = ")
+			}
+			if i != uint64big {
+				i := i + 1
+				if i == l {
+					if blockheader {
+						print(" ")
+					} else {
+						print("")
+					}
+				} else if Is(next, RootOfType) {
+					print(" ")
+				} else if (i+1 == l) && (op > AssignStmtTypeIsLast) {
+					switch op {
+					case AssignStmtMoreEqual:
+						print(" = ")
 
-						case AssignStmtMoreColonEq:
-							print(" := ")
+					case AssignStmtMoreColonEq:
+						print(" := ")
 
-						case AssignStmtMoreEqualRange:
-							print(" = range ")
+					case AssignStmtMoreEqualRange:
+						print(" = range ")
 
-						case AssignStmtMoreColonEqRange:
-							print(" := range ")
+					case AssignStmtMoreColonEqRange:
+						print(" := range ")
 
-						case AssignStmtIotaIsLast:
-							print(", ")
-
-						}
-					} else if (i == (l+1)>>1) && (op != AssignStmtTypeIsLast) {
-						switch op {
-						case AssignStmtEqual:
-							print(" = ")
+					case AssignStmtIotaIsLast:
+						print(", ")
 
-						case AssignStmtColonEq:
-							print(" := ")
+					}
+				} else if (i == (l+1)>>1) && (op != AssignStmtTypeIsLast) {
+					switch op {
+					case AssignStmtEqual:
+						print(" = ")
 
-						case AssignStmtAdd:
-							print(" += ")
+					case AssignStmtColonEq:
+						print(" := ")
 
-						case AssignStmtSub:
-							print(" -= ")
+					case AssignStmtAdd:
+						print(" += ")
 
-						case AssignStmtMul:
-							print(" *= ")
+					case AssignStmtSub:
+						print(" -= ")
 
-						case AssignStmtQuo:
-							print(" /= ")
+					case AssignStmtMul:
+						print(" *= ")
 
-						case AssignStmtRem:
-							print(" %= ")
+					case AssignStmtQuo:
+						print(" /= ")
 
-						case AssignStmtAnd:
-							print(" &= ")
+					case AssignStmtRem:
+						print(" %= ")
 
-						case AssignStmtOr:
-							print(" |= ")
+					case AssignStmtAnd:
+						print(" &= ")
 
-						case AssignStmtXor:
-							print(" ^= ")
+					case AssignStmtOr:
+						print(" |= ")
 
-						case AssignStmtShl:
-							print(" <<= ")
+					case AssignStmtXor:
+						print(" ^= ")
 
-						case AssignStmtShr:
-							print(" >>= ")
+					case AssignStmtShl:
+						print(" <<= ")
 
-						case AssignStmtAndNot:
-							print(" &^= ")
+					case AssignStmtShr:
+						print(" >>= ")
 
-						case AssignStmtMoreEqual:
-							fallthrough
+					case AssignStmtAndNot:
+						print(" &^= ")
 
-						case AssignStmtMoreColonEq:
-							fallthrough
+					case AssignStmtMoreEqual:
+						fallthrough
 
-						case AssignStmtMoreEqualRange:
-							fallthrough
+					case AssignStmtMoreColonEq:
+						fallthrough
 
-						case AssignStmtMoreColonEqRange:
-							print(", ")
+					case AssignStmtMoreEqualRange:
+						fallthrough
 
-						case AssignStmtIotaIsLast:
-							print(", ")
+					case AssignStmtMoreColonEqRange:
+						print(", ")
 
-						}
-					} else if (i == l>>1) && (op < AssignStmtTypeIsLast) {
-						print(" ")
-					} else if i > 0 && ((i+1 != l) || (op != AssignStmtTypeIsLast)) {
+					case AssignStmtIotaIsLast:
 						print(", ")
-					} else if i > 0 {
-						print(" ")
+
 					}
+				} else if (i == l>>1) && (op < AssignStmtTypeIsLast) {
+					print(" ")
+				} else if i > 0 && ((i+1 != l) || (op != AssignStmtTypeIsLast)) {
+					print(", ")
+				} else if i > 0 {
+					print(" ")
 				}
+			}
 
-			case &RootOfType[0]:
-				if i == uint64big && Which(ast[O(iterator)]) == nil {
-					if len(ast_o_iterator) > 0 {
-						print(ast_o_iterator)
-					}
+		case kindRootOfType:
+			if i == uint64big && Which(nodeat(ast, first)) == nil {
+				if len(ast_o_iterator) > 0 {
+					print(string(ast_o_iterator))
 				}
+			}
 
-			case &LblGotoCnt[0]:
-				if i == uint64big {
-					var op = byte(len(ast[(iterator)]) - 1)
-					switch op {
-					case LblGotoCntBreak:
-						fallthrough
+		case kindLblGotoCnt:
+			if i == uint64big {
+				var op = Variant(node)
+				switch op {
+				case LblGotoCntBreak:
+					fallthrough
 
-					case LblGotoCntContinue:
-						fallthrough
+				case LblGotoCntContinue:
+					fallthrough
 
-					case LblGotoCntGoto:
-						print(ast_o_iterator)
+				case LblGotoCntGoto:
+					print(string(ast_o_iterator))
 
-					case LblGotoCntLabel:
-						print(ast_o_iterator)
-						print(": ")
+				case LblGotoCntLabel:
+					print(string(ast_o_iterator))
+					print(": ")
 
-					}
 				}
+			}
 
-			case &VarDefStmt[0]:
-				if i != uint64big {
-					if len(ast[O(iterator)+i]) != 0 {
-						if len(ast[O(iterator)+i+1]) != 0 {
-							print("")
-						}
-					}
-					if len(ast[O(iterator)+i-1]) != 0 {
-						if len(ast[O(iterator)+i+1]) == 0 {
-							print("")
-							print(")")
-							print("")
-						}
+		case kindVarDefStmt:
+			if i != uint64big {
+				if len(child) != 0 {
+					if len(next) != 0 {
+						print("")
 					}
 				}
-
-			case &FileMatter[0]:
-				if i != uint64big {
-					var xyz = ast[O(iterator)+i+1] == nil || &ast[O(iterator)+i+1][0] == &CommentRow[0]
-					var abc = ast[O(iterator)+i+0] != nil && &ast[O(iterator)+i+0][0] == &CommentRow[0]
-					var def = ast[O(iterator)+i+1] != nil && &ast[O(iterator)+i+1][0] == &CommentRow[0]
-					var end = len(ast[O(iterator)+i+1])-1 == int(CommentRowEnder)
-					var ene = len(ast[O(iterator)+i+0])-1 == int(CommentRowEnder)
-					if !xyz || !end {
+				if len(nodeat(ast, first+i-1)) != 0 {
+					if len(next) == 0 {
 						print("")
-					}
-					if abc && def && end && ene {
+						print(")")
 						print("")
 					}
 				}
+			}
 
-			case &ClosureExp[0]:
-				if i != uint64big {
-					var end = ast[O(iterator)+i+1] == nil || &ast[O(iterator)+i+1][0] == &BlocOfCode[0]
-					var xyz = ast[O(iterator)+i+0] == nil || &ast[O(iterator)+i+0][0] == &BlocOfCode[0]
-					if end {
-						if !xyz {
-							print(")")
-						}
-					} else if !xyz {
-						var separ = uint64(len(ast[(iterator)]) - 1)
-						if i+1 == separ {
-							print(")(")
-						} else {
-							print(", ")
-						}
+		case kindFileMatter:
+			if i != uint64big {
+				var xyz = next == nil || Is(next, CommentRow)
+				var abc = child != nil && Is(child, CommentRow)
+				var def = next != nil && Is(next, CommentRow)
+				var end = isender(next)
+				var ene = isender(child)
+				if !xyz || !end {
+					print("")
+				}
+				if abc && def && end && ene {
+					print("")
+				}
+			}
+
+		case kindClosureExp:
+			if i != uint64big {
+				var end = next == nil || Is(next, BlocOfCode)
+				var xyz = child == nil || Is(child, BlocOfCode)
+				if end {
+					if !xyz {
+						print(")")
+					}
+				} else if !xyz {
+					var separ = Count(node)
+					if i+1 == separ {
+						print(")(")
+					} else {
+						print(", ")
 					}
 				}
+			}
 
-			case &IfceMethod[0]:
-				if i == 0 {
-					var end = ast[O(iterator)+i+1] == nil
-					if end {
+		case kindIfceMethod:
+			if i == 0 {
+				var end = next == nil
+				if end {
+					print("()")
+				} else {
+					var op = Count(node)
+					if 0 == op {
 						print("()")
-					} else {
-						var op = uint64(len(ast[(iterator)]) - 1)
-						if 0 == op {
-							print("()")
-						}
-						print("(")
 					}
-				} else if i != uint64big {
-					var end = ast[O(iterator)+i+1] == nil
-					if end {
-						print(")")
+					print("(")
+				}
+			} else if i != uint64big {
+				var end = next == nil
+				if end {
+					print(")")
+				} else {
+					var op = Count(node)
+					if i == op {
+						print(")(")
 					} else {
-						var op = uint64(len(ast[(iterator)]) - 1)
-						if i == op {
-							print(")(")
-						} else {
-							print(", ")
-						}
+						print(", ")
 					}
 				}
-
 			}
+
+		}
+	}
+}
+
+// CodeOffsets generates go source code from an abstract syntax tree like Code
+// does, and returns it together with the offsets in the code at which the
+// printing of every node started. Some strings are printed by their parent
+// node rather than on their own, so the offset of a string is the nearest
+// occurrence of the whole string in the code.
+func CodeOffsets(ast map[uint64][]byte, iterator uint64, parent uint64) (string, map[uint64]int) {
+	var out []byte
+	var offsets = make(map[uint64]int)
+	code(func(s string) {
+		if len(s) == 0 {
+			out = append(out, '\n')
+		} else {
+			out = append(out, s...)
+		}
+	}, func(key uint64) bool {
+		offsets[key] = len(out)
+		return false
+	}, ast, iterator, parent)
+	var src = string(out)
+	for key, offset := range offsets {
+		var node = ast[key]
+		if Which(node) == nil && len(node) > 0 {
+			offsets[key] = nearest(src, string(node), offset)
 		}
 	}
+	return src, offsets
+}
+
+// nearest returns the offset of the occurrence of the string in the code which
+// is nearest to the offset. Occurrences of identifiers must not be a part of a
+// longer identifier. It returns the offset itself if there is no occurrence.
+func nearest(code string, s string, offset int) int {
+	var whole = ValidIdent(s) == nil
+	var found = func(at int) bool {
+		if at < 0 || at+len(s) > len(code) || code[at:at+len(s)] != s {
+			return false
+		}
+		if !whole {
+			return true
+		}
+		return (at == 0 || !identbyte(code[at-1])) &&
+			(at+len(s) == len(code) || !identbyte(code[at+len(s)]))
+	}
+	for d := 0; d <= len(code); d++ {
+		if found(offset - d) {
+			return offset - d
+		}
+		if found(offset + d) {
+			return offset + d
+		}
+	}
+	return offset
+}
+
+// identbyte reports whether the byte can be a part of an identifier.
+func identbyte(b byte) bool {
+	return b == '_' || b >= 0x80 || (b >= '0' && b <= '9') ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
 }
@@ -0,0 +1,67 @@
+package mapast
+
+import "errors"
+
+// BuildArrayType builds an ExpressionArrayType of the length and the element
+// type, each of them an ast having its node at key zero. A nil length builds
+// the [...] array type of a composite literal.
+func BuildArrayType(length map[uint64][]byte, elem map[uint64][]byte) (map[uint64][]byte, error) {
+	var l = subtree{node: []byte("...")}
+	if length != nil {
+		if !Poke(length, 0) {
+			return nil, errors.New("mapast: array length without node at key zero")
+		}
+		l = clone(length, 0)
+	}
+	if !Poke(elem, 0) {
+		return nil, errors.New("mapast: array element type without node at key zero")
+	}
+	var ast = make(map[uint64][]byte)
+	paste(ast, 0, subtree{node: ExpressionNode(ExpressionArrayType, 2),
+		children: []subtree{l, clone(elem, 0)}})
+	return ast, nil
+}
+
+// ArrayLength returns the key of the length of the ExpressionArrayType at the
+// key. The second result is false if the node is not an array type or if its
+// length is given by the number of elements, as in [...]int{1, 2}.
+func ArrayLength(ast map[uint64][]byte, key uint64) (uint64, bool) {
+	var node = ast[key]
+	if !Is(node, Expression) || Variant(node) != ExpressionArrayType {
+		return 0, false
+	}
+	if string(ast[O(key)]) == "..." {
+		return 0, false
+	}
+	return O(key), Poke(ast, O(key))
+}
+
+// IsFuncType reports whether the node at the key is a function type: a
+// ClosureExp without a body.
+func IsFuncType(ast map[uint64][]byte, key uint64) bool {
+	if !Is(ast[key], ClosureExp) {
+		return false
+	}
+	for _, child := range ChildKeys(ast, key) {
+		if Is(ast[child], BlocOfCode) {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildFuncType builds a function type, a ClosureExp without a body, of the
+// parameters and the results. Each of them is an ast having a TypedIdent node
+// at key zero.
+func BuildFuncType(params []map[uint64][]byte, results []map[uint64][]byte) (map[uint64][]byte, error) {
+	var s = subtree{node: ClosureExpNode(uint64(len(params)))}
+	for _, elem := range append(append([]map[uint64][]byte{}, params...), results...) {
+		if !Is(elem[0], TypedIdent) {
+			return nil, errors.New("mapast: function type parameters and results must be TypedIdent nodes")
+		}
+		s.children = append(s.children, clone(elem, 0))
+	}
+	var ast = make(map[uint64][]byte)
+	paste(ast, 0, s)
+	return ast, nil
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"errors"
+	"go/types"
+)
+
+// typeof returns the type denoted by the type expression or declared by the
+// type name at the key. Unlike TypeOf, it keeps the aliases.
+func (info *Info) typeof(key uint64) types.Type {
+	if obj, ok := info.ObjectOf(key).(*types.TypeName); ok {
+		return obj.Type()
+	}
+	return info.TypeOf(key)
+}
+
+// Underlying returns the underlying type of the type expression or the type
+// name at the key, following aliases and named types. It returns nil if the
+// type is not known.
+func (info *Info) Underlying(key uint64) types.Type {
+	if t := info.typeof(key); t != nil {
+		return t.Underlying()
+	}
+	return nil
+}
+
+// AliasChain returns the aliases followed to resolve the type expression or
+// the type name at the key. For type A = B and type B = C, the chain of A is
+// A followed by B. The chain is empty if the type is not an alias.
+func (info *Info) AliasChain(key uint64) (chain []*types.TypeName) {
+	var t = info.typeof(key)
+	for {
+		var alias, ok = t.(*types.Alias)
+		if !ok {
+			return chain
+		}
+		chain = append(chain, alias.Obj())
+		t = alias.Rhs()
+	}
+}
+
+// same reports whether the types are the same, telling aliases apart.
+func same(x types.Type, y types.Type) bool {
+	var xa, xok = x.(*types.Alias)
+	var ya, yok = y.(*types.Alias)
+	if xok && yok {
+		return xa.Obj() == ya.Obj()
+	}
+	return types.Identical(x, y)
+}
+
+// IsAliasOf reports whether the type name or type expression at the alias key
+// is an alias, directly or through a chain of aliases, of the type at the
+// target key.
+func (info *Info) IsAliasOf(alias uint64, target uint64) bool {
+	var t = info.typeof(target)
+	if t == nil {
+		return false
+	}
+	for _, obj := range info.AliasChain(alias) {
+		if same(obj.Type().(*types.Alias).Rhs(), t) {
+			return true
+		}
+	}
+	return false
+}
+
+// check type-checks the package of the tree, which must have a RootMatter or
+// a FileMatter at key zero.
+func check(tree map[uint64][]byte) (*Info, error) {
+	var info, err = Check(tree, 0)
+	if info == nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Underlying type-checks the tree and returns the underlying type of the type
+// expression or the type name at the key. Type errors elsewhere in the tree do
+// not matter as long as the type is known.
+func Underlying(tree map[uint64][]byte, key uint64) (types.Type, error) {
+	var info, err = check(tree)
+	if err != nil {
+		return nil, err
+	}
+	if t := info.Underlying(key); t != nil {
+		return t, nil
+	}
+	return nil, errors.New("types: unknown type")
+}
+
+// IsAliasOf type-checks the tree and reports whether the type name or type
+// expression at the alias key is an alias of the type at the target key.
+func IsAliasOf(tree map[uint64][]byte, alias uint64, target uint64) (bool, error) {
+	var info, err = check(tree)
+	if err != nil {
+		return false, err
+	}
+	return info.IsAliasOf(alias, target), nil
+}
@@ -0,0 +1,219 @@
+// Package types type-checks mapast trees using go/types.
+//
+// The tree is printed to go source code, which is parsed and type-checked.
+// The results are keyed by the keys of the mapast nodes they belong to:
+// identifiers by the key of their string, and other expressions by the key of
+// the innermost node covering the same source code.
+package types
+
+import (
+	"errors"
+	"github.com/go-li/mapast"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// Info holds the results of type-checking a tree.
+type Info struct {
+	// Pkg is the type-checked package.
+	Pkg *types.Package
+	// Types maps the keys of expressions to their types and values.
+	Types map[uint64]types.TypeAndValue
+	// Defs maps the keys of identifiers to the objects they define.
+	Defs map[uint64]types.Object
+	// Uses maps the keys of identifiers to the objects they denote.
+	Uses map[uint64]types.Object
+}
+
+// ObjectOf returns the object defined or denoted by the identifier at the key,
+// or nil if there is none.
+func (info *Info) ObjectOf(key uint64) types.Object {
+	if obj, ok := info.Defs[key]; ok {
+		return obj
+	}
+	return info.Uses[key]
+}
+
+// TypeOf returns the type of the expression at the key, or nil if it is not
+// known.
+func (info *Info) TypeOf(key uint64) types.Type {
+	if tv, ok := info.Types[key]; ok {
+		return tv.Type
+	}
+	if obj := info.ObjectOf(key); obj != nil {
+		return obj.Type()
+	}
+	return nil
+}
+
+// span is the part of the code of a file covered by the strings of a node.
+type span struct {
+	key        uint64
+	start, end int
+	depth      int
+}
+
+// file is a printed file of the tree.
+type file struct {
+	ast     *ast.File
+	tfile   *token.File
+	strings map[int]uint64
+	spans   map[int][]span
+}
+
+// files returns the keys of the FileMatter nodes of the tree at the root.
+func files(tree map[uint64][]byte, root uint64) ([]uint64, error) {
+	if mapast.Is(tree[root], mapast.FileMatter) {
+		return []uint64{root}, nil
+	}
+	if !mapast.Is(tree[root], mapast.RootMatter) {
+		return nil, errors.New("types: root is neither a RootMatter nor a FileMatter")
+	}
+	var keys []uint64
+	for _, key := range mapast.ChildKeys(tree, root) {
+		if mapast.Is(tree[key], mapast.FileMatter) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// measure computes the spans of the node at the key and of its descendants,
+// from the offsets of their strings.
+func (f *file) measure(tree map[uint64][]byte, offsets map[uint64]int, key uint64, depth int) (start int, end int) {
+	start, end = -1, -1
+	if mapast.Which(tree[key]) == nil {
+		if len(tree[key]) == 0 {
+			return start, end
+		}
+		f.strings[offsets[key]] = key
+		return offsets[key], offsets[key] + len(tree[key])
+	}
+	for _, child := range mapast.ChildKeys(tree, key) {
+		s, e := f.measure(tree, offsets, child, depth+1)
+		if s < 0 {
+			continue
+		}
+		if start < 0 || s < start {
+			start = s
+		}
+		if e > end {
+			end = e
+		}
+	}
+	if start >= 0 {
+		f.spans[start] = append(f.spans[start], span{key: key, start: start, end: end, depth: depth})
+	}
+	return start, end
+}
+
+// ident returns the key of the string of the identifier.
+func (f *file) ident(id *ast.Ident) (uint64, bool) {
+	key, ok := f.strings[f.tfile.Offset(id.Pos())]
+	return key, ok
+}
+
+// expr returns the key of the node of the expression, which is the node with
+// the widest span starting with the expression and ending within it. The
+// innermost such node is preferred, so nodes wrapping the expression without
+// adding code to it are skipped.
+func (f *file) expr(x ast.Expr) (uint64, bool) {
+	if id, ok := x.(*ast.Ident); ok {
+		return f.ident(id)
+	}
+	var start, end = f.tfile.Offset(x.Pos()), f.tfile.Offset(x.End())
+	var best span
+	var found bool
+	for _, s := range f.spans[start] {
+		if s.end > end {
+			continue
+		}
+		if !found || s.end > best.end || (s.end == best.end && s.depth > best.depth) {
+			best, found = s, true
+		}
+	}
+	return best.key, found
+}
+
+// Check type-checks the package made of the files of the tree at the root. The
+// root is either a RootMatter, whose FileMatter children are the files of the
+// package, or a single FileMatter. Packages imported by the files are loaded
+// by the default importer. Check returns the first type error together with
+// the information collected despite it.
+func Check(tree map[uint64][]byte, root uint64) (*Info, error) {
+	var keys, err = files(tree, root)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("types: no files to check")
+	}
+	var fset = token.NewFileSet()
+	var printed = make([]*file, len(keys))
+	var asts = make([]*ast.File, len(keys))
+	for i, key := range keys {
+		var parent uint64
+		if key != root {
+			parent = root
+		}
+		src, offsets := mapast.CodeOffsets(tree, key, parent)
+		astfile, err := parser.ParseFile(fset, "", src, 0)
+		if err != nil {
+			return nil, err
+		}
+		var f = &file{ast: astfile, tfile: fset.File(astfile.Pos()),
+			strings: make(map[int]uint64), spans: make(map[int][]span)}
+		f.measure(tree, offsets, key, 0)
+		printed[i], asts[i] = f, astfile
+	}
+	var info = &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	var first error
+	var conf = types.Config{Importer: importer.Default(), Error: func(err error) {
+		if first == nil {
+			first = err
+		}
+	}}
+	pkg, _ := conf.Check(asts[0].Name.Name, fset, asts, info)
+	var result = &Info{Pkg: pkg,
+		Types: make(map[uint64]types.TypeAndValue),
+		Defs:  make(map[uint64]types.Object),
+		Uses:  make(map[uint64]types.Object),
+	}
+	var owner = func(pos token.Pos) *file {
+		var i = sort.Search(len(asts), func(i int) bool { return asts[i].End() >= pos })
+		if i == len(asts) {
+			return nil
+		}
+		return printed[i]
+	}
+	for x, tv := range info.Types {
+		if f := owner(x.Pos()); f != nil {
+			if key, ok := f.expr(x); ok {
+				result.Types[key] = tv
+			}
+		}
+	}
+	for id, obj := range info.Defs {
+		if f := owner(id.Pos()); f != nil {
+			if key, ok := f.ident(id); ok {
+				result.Defs[key] = obj
+			}
+		}
+	}
+	for id, obj := range info.Uses {
+		if f := owner(id.Pos()); f != nil {
+			if key, ok := f.ident(id); ok {
+				result.Uses[key] = obj
+			}
+		}
+	}
+	return result, first
+}
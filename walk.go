@@ -0,0 +1,35 @@
+package mapast
+
+// Walk traverses the tree under the root position in depth first order. Fn is
+// called for every node, including the root and the string leaves, with the
+// key of the node, the key of its parent and the node itself. The parent of
+// the root is reported as the root. If fn returns false, the children of the
+// node are skipped.
+func Walk(ast map[uint64][]byte, root uint64, fn func(key, parent uint64, node []byte) bool) {
+	WalkPrePost(ast, root, fn, nil)
+}
+
+// WalkPrePost traverses the tree like Walk does. Pre is called before the
+// children of a node are visited, and it can skip them by returning false.
+// Post is called after the children were visited, or skipped. Either function
+// can be nil.
+func WalkPrePost(ast map[uint64][]byte, root uint64, pre func(key, parent uint64, node []byte) bool,
+	post func(key, parent uint64, node []byte)) {
+	if Poke(ast, root) {
+		walk(ast, root, root, pre, post)
+	}
+}
+
+func walk(ast map[uint64][]byte, key uint64, parent uint64, pre func(key, parent uint64, node []byte) bool,
+	post func(key, parent uint64, node []byte)) {
+	var node = ast[key]
+	if pre == nil || pre(key, parent, node) {
+		var first = O(key)
+		for i := uint64(0); Poke(ast, first+i); i++ {
+			walk(ast, first+i, key, pre, post)
+		}
+	}
+	if post != nil {
+		post(key, parent, node)
+	}
+}
@@ -0,0 +1,76 @@
+// Package web is a small facade over mapast for web playgrounds. It uses
+// neither the file system nor the standard error, so it builds for js/wasm and
+// lets a browser format and inspect go code on the client side.
+package web
+
+import (
+	"github.com/go-li/mapast"
+	"github.com/go-li/mapast/convert"
+	"strings"
+)
+
+// ParseToJSON parses the go source code of a single file and returns its tree
+// encoded as JSON by mapast.MarshalJSON.
+func ParseToJSON(src string) (string, error) {
+	asttree, err := convert.Parse([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	data, err := mapast.MarshalJSON(asttree, 0)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// PrintFromJSON decodes a tree encoded as JSON by mapast.MarshalJSON and
+// returns the go source code it represents.
+func PrintFromJSON(json string) (string, error) {
+	var asttree = make(map[uint64][]byte)
+	if err := mapast.UnmarshalJSON(asttree, 0, []byte(json)); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	mapast.Code(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, asttree, 0, 0)
+	return out.String(), nil
+}
+
+// Format parses the go source code of a single file and returns it printed
+// by the tree printer, ending with a single newline.
+func Format(src string) (string, error) {
+	asttree, err := convert.Parse([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	mapast.CodeEOF(func(s string) {
+		if len(s) == 0 {
+			out.WriteByte('\n')
+		} else {
+			out.WriteString(s)
+		}
+	}, asttree, 0, 0, mapast.EOFSingleNewline)
+	return out.String(), nil
+}
+
+// DumpTree parses the go source code of a single file and returns its tree as
+// the indented JSON document of mapast.DumpJSON, which names the kinds and
+// the variants of the nodes and carries their keys, for a playground to show
+// the structure of the tree.
+func DumpTree(src string) (string, error) {
+	asttree, err := convert.Parse([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := mapast.DumpJSON(&out, asttree, 0); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}